@@ -3,6 +3,7 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
@@ -10,6 +11,7 @@ import (
 
 	"key-flow/internal/config"
 	db "key-flow/internal/db/migrations"
+	"key-flow/internal/election"
 	"key-flow/internal/i18n"
 	"key-flow/internal/keypool"
 	"key-flow/internal/models"
@@ -24,6 +26,10 @@ import (
 	"gorm.io/gorm"
 )
 
+// cacheFingerprintKey 记录存储中的缓存对应的数据库指纹，见 KeyProvider.CacheFingerprint。
+// 启动时指纹不一致说明集群停止期间数据库被修改或缓存字段发生了变化，需要重新加载
+const cacheFingerprintKey = "cluster:cache_fingerprint"
+
 // App holds all services and manages the application lifecycle.
 type App struct {
 	engine            *gin.Engine
@@ -35,7 +41,9 @@ type App struct {
 	cronChecker       *keypool.CronChecker
 	keyPoolProvider   *keypool.KeyProvider
 	proxyServer       *proxy.ProxyServer
+	elector           *election.Elector
 	storage           store.Store
+	snapshotter       *store.Snapshotter
 	db                *gorm.DB
 	httpServer        *http.Server
 }
@@ -52,7 +60,9 @@ type AppParams struct {
 	CronChecker       *keypool.CronChecker
 	KeyPoolProvider   *keypool.KeyProvider
 	ProxyServer       *proxy.ProxyServer
+	Elector           *election.Elector
 	Storage           store.Store
+	Snapshotter       *store.Snapshotter
 	DB                *gorm.DB
 }
 
@@ -68,7 +78,9 @@ func NewApp(params AppParams) *App {
 		cronChecker:       params.CronChecker,
 		keyPoolProvider:   params.KeyPoolProvider,
 		proxyServer:       params.ProxyServer,
+		elector:           params.Elector,
 		storage:           params.Storage,
+		snapshotter:       params.Snapshotter,
 		db:                params.DB,
 	}
 }
@@ -81,13 +93,17 @@ func (a *App) Start() error {
 	}
 	logrus.Info("i18n initialized successfully.")
 	
-	// Master 节点执行初始化
-	if a.configManager.IsMaster() {
-		logrus.Info("Starting as Master Node.")
+	// 仅 leader 节点运行的服务，随 leader 身份的变化启动和停止
+	a.elector.Register(a.requestLogService, a.logCleanupService, a.cronChecker)
 
-		if err := a.storage.Clear(); err != nil {
-			return fmt.Errorf("cache cleanup failed: %w", err)
-		}
+	// 启动时竞选成功的节点执行集群初始化，之后接管 leader 的节点只启动 leader 服务
+	// 固定为 master 的节点不能与另一个固定的 master 同时运行
+	isLeader, err := a.elector.Campaign()
+	if err != nil {
+		return fmt.Errorf("leader election failed: %w", err)
+	}
+	if isLeader {
+		logrus.Info("Starting as Master Node.")
 
 		// 数据库迁移
 		db.HandleLegacyIndexes(a.db)
@@ -107,28 +123,55 @@ func (a *App) Start() error {
 		}
 		logrus.Info("Database auto-migration completed.")
 
+		// 单节点内存存储优先从快照恢复。共享存储中的缓存在指纹与数据库一致时沿用，
+		// 滚动重启时不清空其他节点正在使用的缓存；不一致时清空缓存并从数据库重新加载
+		restored := a.snapshotter.Restore(a.keyPoolProvider.CacheFingerprint)
+		fingerprint, err := a.keyPoolProvider.CacheFingerprint()
+		if err != nil {
+			return err
+		}
+		upToDate := restored
+		if !upToDate {
+			if upToDate, err = a.cacheMatches(fingerprint); err != nil {
+				return err
+			}
+		}
+		if !upToDate {
+			if err := a.storage.Clear(); err != nil {
+				return fmt.Errorf("cache cleanup failed: %w", err)
+			}
+		}
+		// 清空或恢复缓存都会覆盖 leader 锁，重新写入
+		if err := a.elector.Reassert(); err != nil {
+			return err
+		}
+
 		// 初始化系统设置
 		if err := a.settingsManager.EnsureSettingsInitialized(a.configManager.GetAuthConfig()); err != nil {
 			return fmt.Errorf("failed to initialize system settings: %w", err)
 		}
 		logrus.Info("System settings initialized in DB.")
 
-		a.settingsManager.Initialize(a.storage, a.groupManager, a.configManager.IsMaster())
+		a.settingsManager.Initialize(a.storage, a.groupManager, a.elector.IsLeader)
 
-		// 从数据库加载密钥到 Redis
-		if err := a.keyPoolProvider.LoadKeysFromDB(); err != nil {
-			return fmt.Errorf("failed to load keys into key pool: %w", err)
+		// 从数据库加载密钥到 Redis，加载完成后记录加载时的指纹，中途失败时下次启动重新加载
+		if upToDate {
+			logrus.Info("Cache matches the database, skipping key loading.")
+		} else {
+			if err := a.keyPoolProvider.LoadKeysFromDB(); err != nil {
+				return fmt.Errorf("failed to load keys into key pool: %w", err)
+			}
+			logrus.Debug("API keys loaded into Redis cache by master.")
+		}
+		if err := a.storage.Set(cacheFingerprintKey, []byte(fingerprint), 0); err != nil {
+			return fmt.Errorf("failed to record cache fingerprint: %w", err)
 		}
-		logrus.Debug("API keys loaded into Redis cache by master.")
-
-		// 仅 Master 节点启动的服务
-		a.requestLogService.Start()
-		a.logCleanupService.Start()
-		a.cronChecker.Start()
 	} else {
 		logrus.Info("Starting as Slave Node.")
-		a.settingsManager.Initialize(a.storage, a.groupManager, a.configManager.IsMaster())
+		a.settingsManager.Initialize(a.storage, a.groupManager, a.elector.IsLeader)
 	}
+	a.elector.Start()
+	a.snapshotter.Start()
 
 	// 显示配置并启动所有后台服务
 	a.configManager.DisplayServerConfig()
@@ -180,24 +223,62 @@ func (a *App) Stop(ctx context.Context) {
 	}
 	logrus.Info("HTTP server has been shut down.")
 
-	// 使用原始的总超时 context 继续关闭其他后台服务
-	stoppableServices := []func(context.Context){
+	// 使用原始的总超时 context 继续关闭其他后台服务。
+	// 先停止 elector 及其 leader 服务（定时验证等仍会更新 key 状态），再落库写回队列
+	servicesStopped := stopServices(ctx,
+		a.elector.Stop,
 		a.groupManager.Stop,
 		a.settingsManager.Stop,
+	)
+	queueFlushed := stopServices(ctx, a.keyPoolProvider.Stop)
+
+	if servicesStopped && queueFlushed {
+		logrus.Info("All background services stopped.")
+		a.recordCacheFingerprint()
+	} else {
+		logrus.Warn("Shutdown timed out, some services may not have stopped gracefully.")
+	}
+
+	// 在其他服务停止、写回队列落库之后保存最终快照
+	a.snapshotter.Stop(ctx)
+
+	if a.storage != nil {
+		a.storage.Close()
 	}
 
-	if serverConfig.IsMaster {
-		stoppableServices = append(stoppableServices,
-			a.cronChecker.Stop,
-			a.logCleanupService.Stop,
-			a.requestLogService.Stop,
-		)
+	logrus.Info("Server exited gracefully")
+}
+
+// cacheMatches 检查存储中的缓存是否由指纹为 fingerprint 的数据库加载
+func (a *App) cacheMatches(fingerprint string) (bool, error) {
+	stored, err := a.storage.Get(cacheFingerprintKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
 	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read cache fingerprint: %w", err)
+	}
+	return string(stored) == fingerprint, nil
+}
 
+// recordCacheFingerprint 在写回队列落库后记录数据库的当前指纹，集群停止期间数据库未被修改时
+// 下次启动沿用缓存。其他节点之后的写入会改变数据库指纹，使下次启动重新加载
+func (a *App) recordCacheFingerprint() {
+	fingerprint, err := a.keyPoolProvider.CacheFingerprint()
+	if err == nil {
+		err = a.storage.Set(cacheFingerprintKey, []byte(fingerprint), 0)
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to record cache fingerprint")
+	}
+}
+
+// stopServices 并行停止服务，全部停止时返回 true，ctx 先结束时返回 false
+func stopServices(ctx context.Context, stopFuncs ...func(context.Context)) bool {
 	var wg sync.WaitGroup
-	wg.Add(len(stoppableServices))
+	wg.Add(len(stopFuncs))
 
-	for _, stopFunc := range stoppableServices {
+	for _, stopFunc := range stopFuncs {
 		go func(stop func(context.Context)) {
 			defer wg.Done()
 			stop(ctx)
@@ -212,14 +293,8 @@ func (a *App) Stop(ctx context.Context) {
 
 	select {
 	case <-done:
-		logrus.Info("All background services stopped.")
+		return true
 	case <-ctx.Done():
-		logrus.Warn("Shutdown timed out, some services may not have stopped gracefully.")
+		return false
 	}
-
-	if a.storage != nil {
-		a.storage.Close()
-	}
-
-	logrus.Info("Server exited gracefully")
 }
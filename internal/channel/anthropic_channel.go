@@ -5,10 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	app_errors "key-flow/internal/errors"
 	"key-flow/internal/models"
 	"key-flow/internal/utils"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -16,6 +14,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// anthropicMessagesPath is the Anthropic Messages API path, also accepted by the bedrock and vertex channels.
+const anthropicMessagesPath = "/v1/messages"
+
 func init() {
 	Register("anthropic", newAnthropicChannel)
 }
@@ -36,9 +37,19 @@ func newAnthropicChannel(f *Factory, group *models.Group) (ChannelProxy, error)
 }
 
 // ModifyRequest sets the required headers for the Anthropic API.
-func (ch *AnthropicChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) {
-	req.Header.Set("x-api-key", apiKey.KeyValue)
+func (ch *AnthropicChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) error {
+	setAnthropicAuthHeaders(req, apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
+	return nil
+}
+
+// setAnthropicAuthHeaders sends API keys as x-api-key and OAuth access tokens as a bearer token.
+func setAnthropicAuthHeaders(req *http.Request, apiKey *models.APIKey) {
+	if apiKey.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey.AccessToken)
+		return
+	}
+	req.Header.Set("x-api-key", apiKey.Secret())
 }
 
 // IsStreamRequest checks if the request is for a streaming response using the pre-read body.
@@ -75,9 +86,9 @@ func (ch *AnthropicChannel) ExtractModel(c *gin.Context, bodyBytes []byte) strin
 
 // ValidateKey checks if the given API key is valid by making a messages request.
 func (ch *AnthropicChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey, group *models.Group) (bool, error) {
-	upstreamURL := ch.getUpstreamURL()
+	upstreamURL := ch.getUpstreamURL(apiKey)
 	if upstreamURL == nil {
-		return false, fmt.Errorf("no upstream URL configured for channel %s", ch.Name)
+		return false, ch.noUpstreamError(apiKey)
 	}
 
 	// Parse validation endpoint to extract path and query parameters
@@ -100,22 +111,24 @@ func (ch *AnthropicChannel) ValidateKey(ctx context.Context, apiKey *models.APIK
 			{"role": "user", "content": "hi"},
 		},
 	}
-	body, err := json.Marshal(payload)
+	headerCtx := utils.NewHeaderVariableContext(group, apiKey)
+	body, err := ch.validation.payload(payload, ch.TestModel, headerCtx)
 	if err != nil {
-		return false, fmt.Errorf("failed to marshal validation payload: %w", err)
+		return false, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewBuffer(body))
 	if err != nil {
 		return false, fmt.Errorf("failed to create validation request: %w", err)
 	}
-	req.Header.Set("x-api-key", apiKey.KeyValue)
+	setAnthropicAuthHeaders(req, apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 	req.Header.Set("Content-Type", "application/json")
 
+	ch.validation.applyHeaders(req, headerCtx)
+
 	// Apply custom header rules if available
 	if len(group.HeaderRuleList) > 0 {
-		headerCtx := utils.NewHeaderVariableContext(group, apiKey)
 		utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
 	}
 
@@ -125,19 +138,5 @@ func (ch *AnthropicChannel) ValidateKey(ctx context.Context, apiKey *models.APIK
 	}
 	defer resp.Body.Close()
 
-	// Any 2xx status code indicates the key is valid.
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return true, nil
-	}
-
-	// For non-200 responses, parse the body to provide a more specific error reason.
-	errorBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return false, fmt.Errorf("key is invalid (status %d), but failed to read error body: %w", resp.StatusCode, err)
-	}
-
-	// Use the new parser to extract a clean error message.
-	parsedError := app_errors.ParseUpstreamError(errorBody)
-
-	return false, &app_errors.ValidationError{StatusCode: resp.StatusCode, Message: parsedError}
+	return ch.validation.evaluate(resp)
 }
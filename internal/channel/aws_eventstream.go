@@ -0,0 +1,203 @@
+package channel
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	eventStreamPreludeLen = 12
+	eventStreamMaxFrame   = 16 * 1024 * 1024
+)
+
+// eventStreamMessage is a single decoded frame of the AWS event-stream binary protocol.
+type eventStreamMessage struct {
+	Headers map[string]string
+	Payload []byte
+}
+
+// readEventStreamMessage reads and verifies one frame from the reader.
+// Only string-typed header values are kept, since those are the only ones Bedrock uses.
+func readEventStreamMessage(r io.Reader) (*eventStreamMessage, error) {
+	prelude := make([]byte, eventStreamPreludeLen)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated event stream prelude: %w", err)
+		}
+		return nil, err
+	}
+
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, fmt.Errorf("event stream prelude checksum mismatch")
+	}
+	if totalLen < eventStreamPreludeLen+4+headersLen || totalLen > eventStreamMaxFrame {
+		return nil, fmt.Errorf("invalid event stream frame length %d", totalLen)
+	}
+
+	frame := make([]byte, totalLen)
+	copy(frame, prelude)
+	if _, err := io.ReadFull(r, frame[eventStreamPreludeLen:]); err != nil {
+		return nil, fmt.Errorf("truncated event stream frame: %w", err)
+	}
+
+	messageCRC := binary.BigEndian.Uint32(frame[totalLen-4:])
+	if crc32.ChecksumIEEE(frame[:totalLen-4]) != messageCRC {
+		return nil, fmt.Errorf("event stream message checksum mismatch")
+	}
+
+	headers, err := decodeEventStreamHeaders(frame[eventStreamPreludeLen : eventStreamPreludeLen+headersLen])
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventStreamMessage{
+		Headers: headers,
+		Payload: frame[eventStreamPreludeLen+headersLen : totalLen-4],
+	}, nil
+}
+
+// decodeEventStreamHeaders parses the header block of an event-stream frame.
+func decodeEventStreamHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+1 {
+			return nil, fmt.Errorf("malformed event stream header")
+		}
+		name := string(data[1 : 1+nameLen])
+		valueType := data[1+nameLen]
+		data = data[2+nameLen:]
+
+		var valueLen int
+		switch valueType {
+		case 0, 1: // bool true / false
+			valueLen = 0
+		case 2: // byte
+			valueLen = 1
+		case 3: // short
+			valueLen = 2
+		case 4: // int
+			valueLen = 4
+		case 5, 8: // long, timestamp
+			valueLen = 8
+		case 9: // uuid
+			valueLen = 16
+		case 6, 7: // bytes, string
+			if len(data) < 2 {
+				return nil, fmt.Errorf("malformed event stream header value")
+			}
+			valueLen = int(binary.BigEndian.Uint16(data[0:2]))
+			data = data[2:]
+		default:
+			return nil, fmt.Errorf("unknown event stream header type %d", valueType)
+		}
+
+		if len(data) < valueLen {
+			return nil, fmt.Errorf("malformed event stream header value")
+		}
+		if valueType == 7 {
+			headers[name] = string(data[:valueLen])
+		}
+		data = data[valueLen:]
+	}
+	return headers, nil
+}
+
+// bedrockSSEReader converts a Bedrock InvokeModelWithResponseStream body into Anthropic SSE events.
+type bedrockSSEReader struct {
+	src io.ReadCloser
+	buf bytes.Buffer
+	err error
+}
+
+func newBedrockSSEReader(src io.ReadCloser) *bedrockSSEReader {
+	return &bedrockSSEReader{src: src}
+}
+
+// Read implements io.Reader, decoding one frame at a time so that each event can be flushed promptly.
+func (r *bedrockSSEReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		msg, err := readEventStreamMessage(r.src)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				r.err = io.EOF
+			} else {
+				r.err = err
+				writeAnthropicSSEError(&r.buf, "api_error", err.Error())
+			}
+			continue
+		}
+		r.writeEvent(msg)
+	}
+	return r.buf.Read(p)
+}
+
+// Close implements io.Closer.
+func (r *bedrockSSEReader) Close() error {
+	return r.src.Close()
+}
+
+// writeEvent renders a single event-stream message as an SSE event.
+func (r *bedrockSSEReader) writeEvent(msg *eventStreamMessage) {
+	switch msg.Headers[":message-type"] {
+	case "event":
+		if msg.Headers[":event-type"] != "chunk" {
+			return
+		}
+		var chunk struct {
+			Bytes string `json:"bytes"`
+		}
+		if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+			writeAnthropicSSEError(&r.buf, "api_error", "failed to parse bedrock chunk")
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(chunk.Bytes)
+		if err != nil {
+			writeAnthropicSSEError(&r.buf, "api_error", "failed to decode bedrock chunk")
+			return
+		}
+		var event struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil || event.Type == "" {
+			return
+		}
+		fmt.Fprintf(&r.buf, "event: %s\ndata: %s\n\n", event.Type, data)
+	case "exception", "error":
+		errorType := msg.Headers[":exception-type"]
+		if errorType == "" {
+			errorType = msg.Headers[":error-code"]
+		}
+		message := msg.Headers[":error-message"]
+		var payload struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(msg.Payload, &payload) == nil && payload.Message != "" {
+			message = payload.Message
+		}
+		writeAnthropicSSEError(&r.buf, errorType, message)
+	}
+}
+
+// writeAnthropicSSEError writes an Anthropic style error event.
+func writeAnthropicSSEError(w io.Writer, errorType, message string) {
+	data, _ := json.Marshal(map[string]any{
+		"type": "error",
+		"error": map[string]any{
+			"type":    errorType,
+			"message": message,
+		},
+	})
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+}
@@ -0,0 +1,180 @@
+package channel
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+)
+
+// encodeEventStreamFrame 按 AWS event-stream 格式编码一帧，header 值均为 string 类型
+func encodeEventStreamFrame(headers [][2]string, payload []byte) []byte {
+	var hb bytes.Buffer
+	for _, h := range headers {
+		hb.WriteByte(byte(len(h[0])))
+		hb.WriteString(h[0])
+		hb.WriteByte(7)
+		binary.Write(&hb, binary.BigEndian, uint16(len(h[1])))
+		hb.WriteString(h[1])
+	}
+
+	totalLen := eventStreamPreludeLen + hb.Len() + len(payload) + 4
+	frame := make([]byte, 0, totalLen)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(totalLen))
+	frame = binary.BigEndian.AppendUint32(frame, uint32(hb.Len()))
+	frame = binary.BigEndian.AppendUint32(frame, crc32.ChecksumIEEE(frame))
+	frame = append(frame, hb.Bytes()...)
+	frame = append(frame, payload...)
+	return binary.BigEndian.AppendUint32(frame, crc32.ChecksumIEEE(frame))
+}
+
+func bedrockChunkFrame(event string) []byte {
+	payload := `{"bytes":"` + base64.StdEncoding.EncodeToString([]byte(event)) + `"}`
+	return encodeEventStreamFrame([][2]string{
+		{":event-type", "chunk"},
+		{":content-type", "application/json"},
+		{":message-type", "event"},
+	}, []byte(payload))
+}
+
+func TestReadEventStreamMessageEmptyFrame(t *testing.T) {
+	// AWS event-stream 测试向量 empty_message：无 header、无 payload
+	frame, _ := hex.DecodeString("0000001000000000" + "05c248eb" + "7d98c8ff")
+	msg, err := readEventStreamMessage(bytes.NewReader(frame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Headers) != 0 || len(msg.Payload) != 0 {
+		t.Errorf("got headers %v, payload %q", msg.Headers, msg.Payload)
+	}
+}
+
+func TestReadEventStreamMessageHeaders(t *testing.T) {
+	// 非 string 类型的 header 会被跳过
+	var hb bytes.Buffer
+	hb.Write([]byte{4}) // name length
+	hb.WriteString("flag")
+	hb.WriteByte(0) // bool true
+	hb.Write([]byte{3})
+	hb.WriteString("num")
+	hb.WriteByte(4) // int32
+	hb.Write([]byte{0, 0, 0, 42})
+	hb.Write([]byte{3})
+	hb.WriteString("raw")
+	hb.WriteByte(6) // bytes
+	hb.Write([]byte{0, 2, 0xff, 0xfe})
+	hb.Write([]byte{3})
+	hb.WriteString("str")
+	hb.WriteByte(7)
+	hb.Write([]byte{0, 5})
+	hb.WriteString("value")
+
+	headers, err := decodeEventStreamHeaders(hb.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 1 || headers["str"] != "value" {
+		t.Errorf("headers = %v", headers)
+	}
+
+	for _, data := range [][]byte{
+		{3, 's', 't'},          // name 被截断
+		{1, 'a', 7, 0},         // 长度被截断
+		{1, 'a', 7, 0, 3, 'x'}, // 值被截断
+		{1, 'a', 10},           // 未知类型
+		{1, 'a', 4, 0, 0},      // 定长值被截断
+	} {
+		if _, err := decodeEventStreamHeaders(data); err == nil {
+			t.Errorf("decodeEventStreamHeaders(%v) succeeded, want error", data)
+		}
+	}
+}
+
+func TestReadEventStreamMessageSequence(t *testing.T) {
+	first := encodeEventStreamFrame([][2]string{{":message-type", "event"}}, []byte("one"))
+	second := encodeEventStreamFrame([][2]string{{":message-type", "event"}, {":event-type", "chunk"}}, []byte("two"))
+	r := bytes.NewReader(append(first, second...))
+
+	for _, want := range []string{"one", "two"} {
+		msg, err := readEventStreamMessage(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Payload) != want || msg.Headers[":message-type"] != "event" {
+			t.Errorf("got headers %v, payload %q, want payload %q", msg.Headers, msg.Payload, want)
+		}
+	}
+	if _, err := readEventStreamMessage(r); !errors.Is(err, io.EOF) {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+}
+
+func TestReadEventStreamMessageErrors(t *testing.T) {
+	valid := encodeEventStreamFrame([][2]string{{":message-type", "event"}}, []byte("payload"))
+	corrupt := func(i int) []byte {
+		frame := bytes.Clone(valid)
+		frame[i] ^= 0xff
+		return frame
+	}
+
+	tests := []struct {
+		name  string
+		frame []byte
+		want  string
+	}{
+		{"truncated prelude", valid[:6], "truncated event stream prelude"},
+		{"prelude checksum", corrupt(9), "prelude checksum mismatch"},
+		{"truncated frame", valid[:len(valid)-2], "truncated event stream frame"},
+		{"message checksum", corrupt(len(valid) - 6), "message checksum mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readEventStreamMessage(bytes.NewReader(tt.frame))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBedrockSSEReader(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(bedrockChunkFrame(`{"type":"message_start","message":{"id":"msg_1"}}`))
+	stream.Write(encodeEventStreamFrame([][2]string{{":message-type", "event"}, {":event-type", "metadata"}}, []byte("{}")))
+	stream.Write(bedrockChunkFrame(`{"type":"message_stop"}`))
+	stream.Write(encodeEventStreamFrame([][2]string{
+		{":message-type", "exception"},
+		{":exception-type", "throttlingException"},
+	}, []byte(`{"message":"Too many requests"}`)))
+
+	got, err := io.ReadAll(newBedrockSSEReader(io.NopCloser(&stream)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n" +
+		"event: error\ndata: {\"error\":{\"message\":\"Too many requests\",\"type\":\"throttlingException\"},\"type\":\"error\"}\n\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestBedrockSSEReaderCorruptStream(t *testing.T) {
+	frame := bedrockChunkFrame(`{"type":"message_stop"}`)
+	frame[len(frame)-1] ^= 0xff
+
+	r := newBedrockSSEReader(io.NopCloser(bytes.NewReader(frame)))
+	got, err := io.ReadAll(r)
+	if err == nil || !strings.Contains(err.Error(), "message checksum mismatch") {
+		t.Fatalf("err = %v, want checksum mismatch", err)
+	}
+	if !strings.HasPrefix(string(got), "event: error\n") || !strings.Contains(string(got), `"type":"api_error"`) {
+		t.Errorf("got %q, want an api_error event", got)
+	}
+}
@@ -0,0 +1,296 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"key-flow/internal/models"
+	"key-flow/internal/utils"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+)
+
+const defaultAzureAPIVersion = "2024-10-21"
+
+func init() {
+	Register("azure", newAzureChannel)
+	RegisterConfigValidator("azure", validateAzureConfig)
+}
+
+// azureConfig holds the Azure OpenAI specific settings stored in the group's channel config.
+type azureConfig struct {
+	APIVersion           string            `json:"api_version"`
+	Deployments          map[string]string `json:"deployments"`
+	ValidationDeployment string            `json:"validation_deployment"`
+}
+
+type AzureChannel struct {
+	*BaseChannel
+	apiVersion           string
+	deployments          map[string]string
+	validationDeployment string
+}
+
+func parseAzureConfig(cfg datatypes.JSONMap) (*azureConfig, error) {
+	var ac azureConfig
+	if err := decodeChannelConfig(cfg, &ac); err != nil {
+		return nil, err
+	}
+	ac.APIVersion = strings.TrimSpace(ac.APIVersion)
+	if ac.APIVersion == "" {
+		ac.APIVersion = defaultAzureAPIVersion
+	}
+	for model, deployment := range ac.Deployments {
+		if strings.TrimSpace(model) == "" || strings.TrimSpace(deployment) == "" {
+			return nil, fmt.Errorf("deployment mapping cannot contain empty model or deployment name")
+		}
+		if strings.Contains(deployment, "/") {
+			return nil, fmt.Errorf("invalid deployment name '%s'", deployment)
+		}
+	}
+	if strings.Contains(ac.ValidationDeployment, "/") {
+		return nil, fmt.Errorf("invalid validation deployment '%s'", ac.ValidationDeployment)
+	}
+	return &ac, nil
+}
+
+func validateAzureConfig(cfg datatypes.JSONMap) error {
+	_, err := parseAzureConfig(cfg)
+	return err
+}
+
+func newAzureChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
+	base, err := f.newBaseChannel("azure", group)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := parseAzureConfig(group.ChannelConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel config for azure channel: %w", err)
+	}
+
+	return &AzureChannel{
+		BaseChannel:          base,
+		apiVersion:           ac.APIVersion,
+		deployments:          ac.Deployments,
+		validationDeployment: ac.ValidationDeployment,
+	}, nil
+}
+
+// resolveDeployment maps a model name to its deployment. Unmapped models are used as the deployment name.
+func (ch *AzureChannel) resolveDeployment(model string) string {
+	if deployment, ok := ch.deployments[model]; ok {
+		return deployment
+	}
+	return model
+}
+
+// BuildUpstreamURL rewrites OpenAI style paths to the Azure /openai prefix and injects api-version.
+// The deployment segment is added in ApplyModelRedirect once the final model is known.
+func (ch *AzureChannel) BuildUpstreamURL(originalURL *url.URL, groupName string, apiKey *models.APIKey) (string, error) {
+	base := ch.getUpstreamURL(apiKey)
+	if base == nil {
+		return "", ch.noUpstreamError(apiKey)
+	}
+
+	requestPath := strings.TrimPrefix(originalURL.Path, "/proxy/"+groupName)
+	if !strings.HasPrefix(requestPath, "/openai/") {
+		requestPath = "/openai" + strings.TrimPrefix(requestPath, "/v1")
+	}
+
+	finalURL := *base
+	finalURL.Path = strings.TrimRight(finalURL.Path, "/") + requestPath
+
+	query := originalURL.Query()
+	if query.Get("api-version") == "" {
+		query.Set("api-version", ch.apiVersion)
+	}
+	finalURL.RawQuery = query.Encode()
+
+	return finalURL.String(), nil
+}
+
+// ModifyRequest sets the api-key header for the Azure OpenAI service.
+func (ch *AzureChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) error {
+	req.Header.Set("api-key", apiKey.Secret())
+	return nil
+}
+
+// ApplyModelRedirect applies the group's redirect rules and then routes the request to the
+// deployment mapped from the resulting model.
+func (ch *AzureChannel) ApplyModelRedirect(req *http.Request, bodyBytes []byte, group *models.Group) ([]byte, error) {
+	finalBody, err := ch.BaseChannel.ApplyModelRedirect(req, bodyBytes, group)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := strings.Index(req.URL.Path, "/openai/")
+	if idx < 0 {
+		return finalBody, nil
+	}
+	operation := req.URL.Path[idx+len("/openai"):]
+	if strings.HasPrefix(operation, "/deployments/") || operation == "/deployments" || operation == "/models" {
+		return finalBody, nil
+	}
+
+	var p struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(finalBody, &p); err != nil || p.Model == "" {
+		return finalBody, nil
+	}
+
+	// Path holds the unescaped form; url.URL escapes the deployment name when the request is sent
+	deployment := ch.resolveDeployment(p.Model)
+	req.URL.Path = req.URL.Path[:idx] + "/openai/deployments/" + deployment + operation
+	req.URL.RawPath = ""
+
+	logrus.WithFields(logrus.Fields{
+		"group":      group.Name,
+		"model":      p.Model,
+		"deployment": deployment,
+	}).Debug("Model mapped to Azure deployment")
+
+	return finalBody, nil
+}
+
+// IsStreamRequest checks if the request is for a streaming response using the pre-read body.
+func (ch *AzureChannel) IsStreamRequest(c *gin.Context, bodyBytes []byte) bool {
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		return true
+	}
+
+	if c.Query("stream") == "true" {
+		return true
+	}
+
+	type streamPayload struct {
+		Stream bool `json:"stream"`
+	}
+	var p streamPayload
+	if err := json.Unmarshal(bodyBytes, &p); err == nil {
+		return p.Stream
+	}
+
+	return false
+}
+
+func (ch *AzureChannel) ExtractModel(c *gin.Context, bodyBytes []byte) string {
+	type modelPayload struct {
+		Model string `json:"model"`
+	}
+	var p modelPayload
+	if err := json.Unmarshal(bodyBytes, &p); err == nil {
+		return p.Model
+	}
+	return ""
+}
+
+// ValidateKey checks if the given API key is valid by calling the configured validation deployment.
+func (ch *AzureChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey, group *models.Group) (bool, error) {
+	upstreamURL := ch.getUpstreamURL(apiKey)
+	if upstreamURL == nil {
+		return false, ch.noUpstreamError(apiKey)
+	}
+
+	// The validation endpoint is relative to the deployment, e.g. /chat/completions
+	endpointURL, err := url.Parse(ch.ValidationEndpoint)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse validation endpoint: %w", err)
+	}
+
+	deployment := ch.validationDeployment
+	if deployment == "" {
+		deployment = ch.resolveDeployment(ch.TestModel)
+	}
+
+	finalURL := *upstreamURL
+	finalURL.Path = strings.TrimRight(finalURL.Path, "/") + "/openai/deployments/" + url.PathEscape(deployment) + endpointURL.Path
+	query := endpointURL.Query()
+	if query.Get("api-version") == "" {
+		query.Set("api-version", ch.apiVersion)
+	}
+	finalURL.RawQuery = query.Encode()
+	reqURL := finalURL.String()
+
+	// Use a minimal, low-cost payload for validation
+	payload := gin.H{
+		"messages": []gin.H{
+			{"role": "user", "content": "hi"},
+		},
+	}
+	headerCtx := utils.NewHeaderVariableContext(group, apiKey)
+	body, err := ch.validation.payload(payload, ch.TestModel, headerCtx)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewBuffer(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create validation request: %w", err)
+	}
+	req.Header.Set("api-key", apiKey.Secret())
+	req.Header.Set("Content-Type", "application/json")
+
+	ch.validation.applyHeaders(req, headerCtx)
+
+	// Apply custom header rules if available
+	if len(group.HeaderRuleList) > 0 {
+		utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
+	}
+
+	resp, err := ch.HTTPClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to send validation request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return ch.validation.evaluate(resp)
+}
+
+// TransformModelList replaces the upstream model list with the configured deployments,
+// since only deployed models can actually be called through this group.
+func (ch *AzureChannel) TransformModelList(req *http.Request, bodyBytes []byte, group *models.Group) (map[string]any, error) {
+	modelIDs := make([]string, 0, len(ch.deployments))
+	for model := range ch.deployments {
+		modelIDs = append(modelIDs, model)
+	}
+	sort.Strings(modelIDs)
+
+	deploymentModels := make([]any, 0, len(modelIDs))
+	for _, model := range modelIDs {
+		deploymentModels = append(deploymentModels, map[string]any{
+			"id":         model,
+			"object":     "model",
+			"created":    0,
+			"owned_by":   "azure",
+			"deployment": ch.deployments[model],
+		})
+	}
+
+	configuredModels := buildConfiguredModels(group.ModelRedirectMap)
+	response := map[string]any{"object": "list"}
+
+	if group.ModelRedirectStrict {
+		response["data"] = configuredModels
+	} else {
+		response["data"] = mergeModelLists(deploymentModels, configuredModels)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"group":            group.Name,
+		"deployment_count": len(deploymentModels),
+		"configured_count": len(configuredModels),
+		"strict_mode":      group.ModelRedirectStrict,
+	}).Debug("Model list built from Azure deployments")
+
+	return response, nil
+}
@@ -0,0 +1,310 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"key-flow/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/datatypes"
+)
+
+// newTestAzureChannel 按渠道配置构造指向 upstream 的 Azure 渠道
+func newTestAzureChannel(t *testing.T, upstream string, cfg datatypes.JSONMap) *AzureChannel {
+	t.Helper()
+	u, err := url.Parse(upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ac, err := parseAzureConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AzureChannel{
+		BaseChannel: &BaseChannel{
+			Name:               "azure",
+			Upstreams:          []UpstreamInfo{{URL: u, Weight: 1}},
+			HTTPClient:         http.DefaultClient,
+			TestModel:          "gpt-4o-mini",
+			ValidationEndpoint: "/chat/completions",
+		},
+		apiVersion:           ac.APIVersion,
+		deployments:          ac.Deployments,
+		validationDeployment: ac.ValidationDeployment,
+	}
+}
+
+func TestAzureBuildUpstreamURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream string
+		request  string
+		want     string
+	}{
+		{
+			name:     "v1 prefix rewritten to openai",
+			upstream: "https://res.openai.azure.com",
+			request:  "/proxy/azure/v1/chat/completions",
+			want:     "https://res.openai.azure.com/openai/chat/completions?api-version=2024-06-01",
+		},
+		{
+			name:     "path without v1",
+			upstream: "https://res.openai.azure.com/",
+			request:  "/proxy/azure/embeddings",
+			want:     "https://res.openai.azure.com/openai/embeddings?api-version=2024-06-01",
+		},
+		{
+			name:     "openai path kept",
+			upstream: "https://res.openai.azure.com",
+			request:  "/proxy/azure/openai/deployments/gpt4/chat/completions",
+			want:     "https://res.openai.azure.com/openai/deployments/gpt4/chat/completions?api-version=2024-06-01",
+		},
+		{
+			name:     "caller api-version kept",
+			upstream: "https://res.openai.azure.com",
+			request:  "/proxy/azure/v1/chat/completions?api-version=2025-01-01-preview&foo=bar",
+			want:     "https://res.openai.azure.com/openai/chat/completions?api-version=2025-01-01-preview&foo=bar",
+		},
+		{
+			name:     "upstream base path",
+			upstream: "https://gateway.example.com/azure",
+			request:  "/proxy/azure/v1/models",
+			want:     "https://gateway.example.com/azure/openai/models?api-version=2024-06-01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := newTestAzureChannel(t, tt.upstream, datatypes.JSONMap{"api_version": "2024-06-01"})
+			original, err := url.Parse(tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ch.BuildUpstreamURL(original, "azure", &models.APIKey{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("BuildUpstreamURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAzureDefaultAPIVersion(t *testing.T) {
+	ch := newTestAzureChannel(t, "https://res.openai.azure.com", nil)
+	original, _ := url.Parse("/proxy/azure/v1/chat/completions")
+	got, err := ch.BuildUpstreamURL(original, "azure", &models.APIKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "api-version=" + defaultAzureAPIVersion; !strings.HasSuffix(got, want) {
+		t.Fatalf("BuildUpstreamURL() = %s, want suffix %s", got, want)
+	}
+}
+
+func TestAzureApplyModelRedirect(t *testing.T) {
+	deployments := datatypes.JSONMap{"deployments": map[string]any{"gpt-4o": "prod-gpt4o", "gpt-4o-mini": "mini deployment"}}
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		redirect map[string]string
+		wantPath string
+		wantBody string // 为空时期望 body 不变
+	}{
+		{
+			name:     "mapped model",
+			path:     "/openai/chat/completions",
+			body:     `{"model":"gpt-4o"}`,
+			wantPath: "/openai/deployments/prod-gpt4o/chat/completions",
+		},
+		{
+			name:     "deployment name escaped",
+			path:     "/openai/chat/completions",
+			body:     `{"model":"gpt-4o-mini"}`,
+			wantPath: "/openai/deployments/mini%20deployment/chat/completions",
+		},
+		{
+			name:     "unmapped model used as deployment",
+			path:     "/openai/embeddings",
+			body:     `{"model":"text-embedding-3-small"}`,
+			wantPath: "/openai/deployments/text-embedding-3-small/embeddings",
+		},
+		{
+			name:     "redirect applied before mapping",
+			path:     "/openai/chat/completions",
+			body:     `{"model":"gpt4"}`,
+			redirect: map[string]string{"gpt4": "gpt-4o"},
+			wantPath: "/openai/deployments/prod-gpt4o/chat/completions",
+			wantBody: `{"model":"gpt-4o"}`,
+		},
+		{
+			name:     "upstream base path kept",
+			path:     "/azure/openai/chat/completions",
+			body:     `{"model":"gpt-4o"}`,
+			wantPath: "/azure/openai/deployments/prod-gpt4o/chat/completions",
+		},
+		{
+			name:     "deployment path passed through",
+			path:     "/openai/deployments/other/chat/completions",
+			body:     `{"model":"gpt-4o"}`,
+			wantPath: "/openai/deployments/other/chat/completions",
+		},
+		{
+			name:     "deployments list passed through",
+			path:     "/openai/deployments",
+			wantPath: "/openai/deployments",
+		},
+		{
+			name:     "models list passed through",
+			path:     "/openai/models",
+			body:     `{"model":"gpt-4o"}`,
+			wantPath: "/openai/models",
+		},
+		{
+			name:     "non JSON body",
+			path:     "/openai/audio/transcriptions",
+			body:     "--boundary\r\nContent-Disposition: form-data; name=\"model\"\r\n\r\ngpt-4o\r\n",
+			wantPath: "/openai/audio/transcriptions",
+		},
+		{
+			name:     "body without model",
+			path:     "/openai/chat/completions",
+			body:     `{"messages":[]}`,
+			wantPath: "/openai/chat/completions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := newTestAzureChannel(t, "https://res.openai.azure.com", deployments)
+			req := httptest.NewRequest(http.MethodPost, "https://res.openai.azure.com"+tt.path, nil)
+			group := &models.Group{Name: "azure", ModelRedirectMap: tt.redirect}
+
+			body, err := ch.ApplyModelRedirect(req, []byte(tt.body), group)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := req.URL.EscapedPath(); got != tt.wantPath {
+				t.Errorf("path = %s, want %s", got, tt.wantPath)
+			}
+			wantBody := tt.wantBody
+			if wantBody == "" {
+				wantBody = tt.body
+			}
+			if string(body) != wantBody {
+				t.Errorf("body = %s, want %s", body, wantBody)
+			}
+		})
+	}
+}
+
+func TestAzureValidateKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      datatypes.JSONMap
+		wantPath string
+	}{
+		{
+			name:     "validation deployment",
+			cfg:      datatypes.JSONMap{"validation_deployment": "health-check", "deployments": map[string]any{"gpt-4o-mini": "prod-mini"}},
+			wantPath: "/openai/deployments/health-check/chat/completions",
+		},
+		{
+			name:     "test model mapped to deployment",
+			cfg:      datatypes.JSONMap{"deployments": map[string]any{"gpt-4o-mini": "prod-mini"}},
+			wantPath: "/openai/deployments/prod-mini/chat/completions",
+		},
+		{
+			name:     "unmapped test model",
+			wantPath: "/openai/deployments/gpt-4o-mini/chat/completions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath, gotVersion, gotKey string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath, gotVersion, gotKey = r.URL.Path, r.URL.Query().Get("api-version"), r.Header.Get("api-key")
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			ch := newTestAzureChannel(t, srv.URL, tt.cfg)
+			ok, err := ch.ValidateKey(context.Background(), &models.APIKey{KeyValue: "azure-key"}, &models.Group{Name: "azure"})
+			if err != nil || !ok {
+				t.Fatalf("ValidateKey() = %v, %v", ok, err)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("path = %s, want %s", gotPath, tt.wantPath)
+			}
+			if gotVersion != defaultAzureAPIVersion {
+				t.Errorf("api-version = %s, want %s", gotVersion, defaultAzureAPIVersion)
+			}
+			if gotKey != "azure-key" {
+				t.Errorf("api-key = %s, want azure-key", gotKey)
+			}
+		})
+	}
+}
+
+func TestAzureTransformModelList(t *testing.T) {
+	deployments := datatypes.JSONMap{"deployments": map[string]any{"gpt-4o": "prod-gpt4o", "gpt-4o-mini": "prod-mini"}}
+	tests := []struct {
+		name     string
+		strict   bool
+		redirect map[string]string
+		wantIDs  []string
+	}{
+		{
+			name:    "deployments only",
+			wantIDs: []string{"gpt-4o", "gpt-4o-mini"},
+		},
+		{
+			name:     "merge adds redirect sources",
+			redirect: map[string]string{"gpt4": "gpt-4o", "gpt-4o": "gpt-4o"},
+			wantIDs:  []string{"gpt-4o", "gpt-4o-mini", "gpt4"},
+		},
+		{
+			name:     "strict returns only redirect sources",
+			strict:   true,
+			redirect: map[string]string{"gpt4": "gpt-4o"},
+			wantIDs:  []string{"gpt4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := newTestAzureChannel(t, "https://res.openai.azure.com", deployments)
+			group := &models.Group{Name: "azure", ModelRedirectMap: tt.redirect, ModelRedirectStrict: tt.strict}
+
+			// 上游返回的模型列表被忽略，只有已部署的模型可以调用
+			upstream := []byte(`{"object":"list","data":[{"id":"dall-e-3"}]}`)
+			response, err := ch.TransformModelList(nil, upstream, group)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, _ := response["data"].([]any)
+			ids := make([]string, 0, len(data))
+			for _, item := range data {
+				ids = append(ids, item.(map[string]any)["id"].(string))
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				raw, _ := json.Marshal(response)
+				t.Fatalf("model ids = %v, want %v (%s)", ids, tt.wantIDs, raw)
+			}
+			if !tt.strict {
+				if deployment := data[0].(map[string]any)["deployment"]; deployment != "prod-gpt4o" {
+					t.Errorf("gpt-4o deployment = %v, want prod-gpt4o", deployment)
+				}
+			}
+		})
+	}
+}
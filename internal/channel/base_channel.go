@@ -3,6 +3,7 @@ package channel
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"key-flow/internal/models"
 	"key-flow/internal/types"
@@ -17,6 +18,10 @@ import (
 	"gorm.io/datatypes"
 )
 
+// ErrBoundUpstreamMissing is returned when a key is bound to an upstream that the group no longer configures.
+// The key cannot be used until it is bound again, so callers treat it as a key failure.
+var ErrBoundUpstreamMissing = errors.New("key is bound to an upstream that is not configured in the group")
+
 // UpstreamInfo holds the information for a single upstream server, including its weight.
 type UpstreamInfo struct {
 	URL           *url.URL
@@ -34,16 +39,34 @@ type BaseChannel struct {
 	ValidationEndpoint string
 	upstreamLock       sync.Mutex
 
+	// boundUpstreams holds every configured upstream by URL, including those with weight 0,
+	// for keys pinned to a specific upstream
+	boundUpstreams map[string]*url.URL
+
+	// validation holds the group's validation request overrides and success condition
+	validation validationSpec
+
 	// Cached fields from the group for stale check
 	channelType         string
 	groupUpstreams      datatypes.JSON
 	effectiveConfig     *types.SystemSettings
 	modelRedirectRules  datatypes.JSONMap
 	modelRedirectStrict bool
+	channelConfig       datatypes.JSONMap
 }
 
-// getUpstreamURL selects an upstream URL using a smooth weighted round-robin algorithm.
-func (b *BaseChannel) getUpstreamURL() *url.URL {
+// getUpstreamURL returns the upstream bound to apiKey, or selects one using a smooth weighted
+// round-robin algorithm for unbound keys. It returns nil when the bound upstream is no longer configured.
+func (b *BaseChannel) getUpstreamURL(apiKey *models.APIKey) *url.URL {
+	if apiKey != nil && apiKey.Upstream != "" {
+		if u, ok := b.boundUpstreams[apiKey.Upstream]; ok {
+			return u
+		}
+		// 不能回退到其他上游，key 只在绑定的上游有效
+		logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "upstream": apiKey.Upstream}).Warn("Key is bound to an upstream that is not configured in the group")
+		return nil
+	}
+
 	b.upstreamLock.Lock()
 	defer b.upstreamLock.Unlock()
 
@@ -75,11 +98,19 @@ func (b *BaseChannel) getUpstreamURL() *url.URL {
 	return best.URL
 }
 
+// noUpstreamError describes why getUpstreamURL returned nil for apiKey.
+func (b *BaseChannel) noUpstreamError(apiKey *models.APIKey) error {
+	if apiKey != nil && apiKey.Upstream != "" {
+		return fmt.Errorf("%w: %s", ErrBoundUpstreamMissing, apiKey.Upstream)
+	}
+	return fmt.Errorf("no upstream URL configured for channel %s", b.Name)
+}
+
 // BuildUpstreamURL constructs the target URL for the upstream service.
-func (b *BaseChannel) BuildUpstreamURL(originalURL *url.URL, groupName string) (string, error) {
-	base := b.getUpstreamURL()
+func (b *BaseChannel) BuildUpstreamURL(originalURL *url.URL, groupName string, apiKey *models.APIKey) (string, error) {
+	base := b.getUpstreamURL(apiKey)
 	if base == nil {
-		return "", fmt.Errorf("no upstream URL configured for channel %s", b.Name)
+		return "", b.noUpstreamError(apiKey)
 	}
 
 	finalURL := *base
@@ -118,6 +149,9 @@ func (b *BaseChannel) IsConfigStale(group *models.Group) bool {
 	if b.modelRedirectStrict != group.ModelRedirectStrict {
 		return true
 	}
+	if !reflect.DeepEqual(b.channelConfig, group.ChannelConfig) {
+		return true
+	}
 	return false
 }
 
@@ -0,0 +1,309 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"key-flow/internal/models"
+	"key-flow/internal/utils"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+)
+
+const (
+	bedrockService                 = "bedrock"
+	defaultBedrockAnthropicVersion = "bedrock-2023-05-31"
+)
+
+func init() {
+	Register("bedrock", newBedrockChannel)
+	RegisterConfigValidator("bedrock", validateBedrockConfig)
+}
+
+// bedrockConfig holds the AWS Bedrock specific settings stored in the group's channel config.
+type bedrockConfig struct {
+	Region           string `json:"region"`
+	AnthropicVersion string `json:"anthropic_version"`
+}
+
+type BedrockChannel struct {
+	*BaseChannel
+	region           string
+	anthropicVersion string
+}
+
+func parseBedrockConfig(cfg datatypes.JSONMap) (*bedrockConfig, error) {
+	var bc bedrockConfig
+	if err := decodeChannelConfig(cfg, &bc); err != nil {
+		return nil, err
+	}
+	bc.Region = strings.TrimSpace(bc.Region)
+	bc.AnthropicVersion = strings.TrimSpace(bc.AnthropicVersion)
+	if bc.AnthropicVersion == "" {
+		bc.AnthropicVersion = defaultBedrockAnthropicVersion
+	}
+	return &bc, nil
+}
+
+func validateBedrockConfig(cfg datatypes.JSONMap) error {
+	_, err := parseBedrockConfig(cfg)
+	return err
+}
+
+// regionFromBedrockHost extracts the region from hosts like bedrock-runtime.us-east-1.amazonaws.com.
+func regionFromBedrockHost(host string) string {
+	parts := strings.Split(host, ".")
+	if len(parts) >= 4 && strings.HasPrefix(parts[0], "bedrock-runtime") {
+		return parts[1]
+	}
+	return ""
+}
+
+func newBedrockChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
+	base, err := f.newBaseChannel("bedrock", group)
+	if err != nil {
+		return nil, err
+	}
+
+	bc, err := parseBedrockConfig(group.ChannelConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel config for bedrock channel: %w", err)
+	}
+
+	region := bc.Region
+	if region == "" {
+		for _, up := range base.Upstreams {
+			if region = regionFromBedrockHost(up.URL.Hostname()); region != "" {
+				break
+			}
+		}
+	}
+	if region == "" {
+		return nil, fmt.Errorf("bedrock channel requires a region in channel config or a regional bedrock-runtime upstream")
+	}
+
+	return &BedrockChannel{
+		BaseChannel:      base,
+		region:           region,
+		anthropicVersion: bc.AnthropicVersion,
+	}, nil
+}
+
+// BuildUpstreamURL supports the Anthropic Messages endpoint and native Bedrock /model/ paths.
+// Messages requests are routed to the model specific invoke path in ApplyModelRedirect.
+func (ch *BedrockChannel) BuildUpstreamURL(originalURL *url.URL, groupName string, apiKey *models.APIKey) (string, error) {
+	requestPath := strings.TrimPrefix(originalURL.Path, "/proxy/"+groupName)
+	if requestPath != anthropicMessagesPath && !strings.HasPrefix(requestPath, "/model/") {
+		return "", fmt.Errorf("path '%s' is not supported by the bedrock channel", requestPath)
+	}
+	return ch.BaseChannel.BuildUpstreamURL(originalURL, groupName, apiKey)
+}
+
+// ApplyModelRedirect applies the group's redirect rules and converts an Anthropic Messages
+// request into an InvokeModel or InvokeModelWithResponseStream call.
+func (ch *BedrockChannel) ApplyModelRedirect(req *http.Request, bodyBytes []byte, group *models.Group) ([]byte, error) {
+	finalBody, err := ch.BaseChannel.ApplyModelRedirect(req, bodyBytes, group)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(req.URL.Path, anthropicMessagesPath) {
+		return finalBody, nil
+	}
+
+	var requestData map[string]any
+	if err := json.Unmarshal(finalBody, &requestData); err != nil {
+		return nil, fmt.Errorf("invalid request body for bedrock channel: %w", err)
+	}
+
+	model, _ := requestData["model"].(string)
+	if model == "" {
+		return nil, fmt.Errorf("model is required for bedrock channel")
+	}
+	stream, _ := requestData["stream"].(bool)
+
+	delete(requestData, "model")
+	delete(requestData, "stream")
+	if _, ok := requestData["anthropic_version"]; !ok {
+		requestData["anthropic_version"] = ch.anthropicVersion
+	}
+	// Bedrock takes beta flags in the body instead of the anthropic-beta header.
+	if beta := req.Header.Get("anthropic-beta"); beta != "" {
+		if _, ok := requestData["anthropic_beta"]; !ok {
+			var flags []string
+			for _, flag := range strings.Split(beta, ",") {
+				if flag = strings.TrimSpace(flag); flag != "" {
+					flags = append(flags, flag)
+				}
+			}
+			requestData["anthropic_beta"] = flags
+		}
+	}
+	req.Header.Del("anthropic-beta")
+	req.Header.Del("anthropic-version")
+
+	ch.setInvokePath(req.URL, model, stream)
+
+	logrus.WithFields(logrus.Fields{
+		"group":  group.Name,
+		"model":  model,
+		"stream": stream,
+	}).Debug("Anthropic request converted to Bedrock invoke")
+
+	return json.Marshal(requestData)
+}
+
+// setInvokePath replaces the messages path with /model/{modelId}/invoke[-with-response-stream].
+func (ch *BedrockChannel) setInvokePath(u *url.URL, model string, stream bool) {
+	action := "invoke"
+	if stream {
+		action = "invoke-with-response-stream"
+	}
+	prefix := strings.TrimSuffix(u.Path, anthropicMessagesPath)
+	u.Path = prefix + "/model/" + model + "/" + action
+	// Model IDs may contain ':' which Bedrock expects to be percent-encoded.
+	u.RawPath = awsURIEncode(prefix, false) + "/model/" + awsURIEncode(model, true) + "/" + action
+}
+
+// ModifyRequest signs the request with the key's AWS credentials.
+func (ch *BedrockChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) error {
+	creds, err := parseAWSCredentials(apiKey)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(req.URL.Path, "/invoke-with-response-stream") {
+		req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := signAWSRequestV4(req, creds, ch.region, bedrockService, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign bedrock request: %w", err)
+	}
+	return nil
+}
+
+// TransformResponse converts the event-stream body of streaming invocations into Anthropic SSE.
+func (ch *BedrockChannel) TransformResponse(resp *http.Response, isStream bool) {
+	if !strings.Contains(resp.Header.Get("Content-Type"), "application/vnd.amazon.eventstream") {
+		return
+	}
+	resp.Body = newBedrockSSEReader(resp.Body)
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	resp.Header.Set("Content-Type", "text/event-stream")
+	resp.Header.Set("Cache-Control", "no-cache")
+}
+
+// IsStreamRequest checks if the request is for a streaming response using the pre-read body.
+func (ch *BedrockChannel) IsStreamRequest(c *gin.Context, bodyBytes []byte) bool {
+	if strings.HasSuffix(c.Request.URL.Path, "/invoke-with-response-stream") {
+		return true
+	}
+
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		return true
+	}
+
+	type streamPayload struct {
+		Stream bool `json:"stream"`
+	}
+	var p streamPayload
+	if err := json.Unmarshal(bodyBytes, &p); err == nil {
+		return p.Stream
+	}
+
+	return false
+}
+
+func (ch *BedrockChannel) ExtractModel(c *gin.Context, bodyBytes []byte) string {
+	// Native Bedrock paths carry the model ID in the path: /model/{modelId}/invoke
+	path := c.Request.URL.Path
+	if idx := strings.Index(path, "/model/"); idx >= 0 {
+		rest := path[idx+len("/model/"):]
+		if end := strings.Index(rest, "/"); end > 0 {
+			return rest[:end]
+		}
+	}
+
+	type modelPayload struct {
+		Model string `json:"model"`
+	}
+	var p modelPayload
+	if err := json.Unmarshal(bodyBytes, &p); err == nil {
+		return p.Model
+	}
+	return ""
+}
+
+// ValidateKey checks if the given credentials are valid by invoking the test model with a one-token request.
+func (ch *BedrockChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey, group *models.Group) (bool, error) {
+	upstreamURL := ch.getUpstreamURL(apiKey)
+	if upstreamURL == nil {
+		return false, ch.noUpstreamError(apiKey)
+	}
+
+	creds, err := parseAWSCredentials(apiKey)
+	if err != nil {
+		return false, err
+	}
+
+	model := ch.TestModel
+	if target, ok := group.ModelRedirectMap[model]; ok {
+		model = target
+	}
+
+	finalURL := *upstreamURL
+	finalURL.RawQuery = ""
+	finalURL.Path = strings.TrimRight(finalURL.Path, "/") + anthropicMessagesPath
+	ch.setInvokePath(&finalURL, model, false)
+
+	// Use a minimal, low-cost payload for validation
+	payload := gin.H{
+		"anthropic_version": ch.anthropicVersion,
+		"max_tokens":        1,
+		"messages": []gin.H{
+			{"role": "user", "content": "hi"},
+		},
+	}
+	headerCtx := utils.NewHeaderVariableContext(group, apiKey)
+	body, err := ch.validation.payload(payload, model, headerCtx)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", finalURL.String(), bytes.NewBuffer(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create validation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	ch.validation.applyHeaders(req, headerCtx)
+
+	// Apply custom header rules before signing so that signed headers are final
+	if len(group.HeaderRuleList) > 0 {
+		utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
+	}
+
+	if err := signAWSRequestV4(req, creds, ch.region, bedrockService, time.Now()); err != nil {
+		return false, err
+	}
+
+	resp, err := ch.HTTPClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to send validation request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return ch.validation.evaluate(resp)
+}
@@ -0,0 +1,58 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"key-flow/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestBedrockValidationUsesRedirectedModel(t *testing.T) {
+	const (
+		testModel = "claude-sonnet"
+		modelID   = "anthropic.claude-sonnet-4-v1:0"
+	)
+
+	var (
+		gotPath  string
+		received map[string]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("validation body is not valid JSON: %v: %s", err, body)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	upstream, _ := url.Parse(srv.URL)
+	ch := &BedrockChannel{
+		BaseChannel: &BaseChannel{
+			Name:       "bedrock",
+			Upstreams:  []UpstreamInfo{{URL: upstream, Weight: 1}},
+			HTTPClient: srv.Client(),
+			TestModel:  testModel,
+			validation: validationSpec{Body: `{"model":"${TEST_MODEL}"}`},
+		},
+		region: "us-east-1",
+	}
+
+	// 请求地址和自定义请求体使用同一个重定向后的模型
+	group := &models.Group{Name: "bedrock", ModelRedirectMap: map[string]string{testModel: modelID}}
+	ok, err := ch.ValidateKey(context.Background(), &models.APIKey{KeyValue: "AKIDEXAMPLE:secret"}, group)
+	if err != nil || !ok {
+		t.Fatalf("ValidateKey() = %v, %v", ok, err)
+	}
+	if want := "/model/" + modelID + "/invoke"; gotPath != want {
+		t.Errorf("path = %s, want %s", gotPath, want)
+	}
+	if received["model"] != modelID {
+		t.Errorf("body model = %q, want %q", received["model"], modelID)
+	}
+}
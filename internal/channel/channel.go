@@ -11,8 +11,8 @@ import (
 
 // ChannelProxy defines the interface for different API channel proxies.
 type ChannelProxy interface {
-	// BuildUpstreamURL constructs the target URL for the upstream service.
-	BuildUpstreamURL(originalURL *url.URL, groupName string) (string, error)
+	// BuildUpstreamURL constructs the target URL for the upstream service, using the upstream bound to apiKey if any.
+	BuildUpstreamURL(originalURL *url.URL, groupName string, apiKey *models.APIKey) (string, error)
 
 	// IsConfigStale checks if the channel's configuration is stale compared to the provided group.
 	IsConfigStale(group *models.Group) bool
@@ -23,8 +23,9 @@ type ChannelProxy interface {
 	// GetStreamClient returns the client for streaming requests.
 	GetStreamClient() *http.Client
 
-	// ModifyRequest allows the channel to add specific headers or modify the request
-	ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group)
+	// ModifyRequest allows the channel to add specific headers or modify the request.
+	// An error means the key's credentials could not be applied, and the request must not be sent.
+	ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) error
 
 	// IsStreamRequest checks if the request is for a streaming response,
 	IsStreamRequest(c *gin.Context, bodyBytes []byte) bool
@@ -41,3 +42,10 @@ type ChannelProxy interface {
 	// TransformModelList transforms the model list response based on redirect rules.
 	TransformModelList(req *http.Request, bodyBytes []byte, group *models.Group) (map[string]any, error)
 }
+
+// ResponseTransformer is an optional interface for channels whose upstream response format
+// differs from the API exposed to clients.
+type ResponseTransformer interface {
+	// TransformResponse rewrites a successful upstream response in place before it is relayed.
+	TransformResponse(resp *http.Response, isStream bool)
+}
@@ -0,0 +1,53 @@
+package channel
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/datatypes"
+)
+
+// channelConfigValidator validates the channel-specific configuration of a group.
+type channelConfigValidator func(cfg datatypes.JSONMap) error
+
+var (
+	// channelConfigValidators holds optional validators keyed by channel type.
+	channelConfigValidators = make(map[string]channelConfigValidator)
+)
+
+// RegisterConfigValidator adds a channel config validator for the given channel type.
+func RegisterConfigValidator(channelType string, validator channelConfigValidator) {
+	if _, exists := channelConfigValidators[channelType]; exists {
+		panic(fmt.Sprintf("config validator for channel type '%s' is already registered", channelType))
+	}
+	channelConfigValidators[channelType] = validator
+}
+
+// ValidateChannelConfig validates the channel config against the rules of the channel type.
+// The common validation section is checked for every type, channel types without a
+// registered validator accept any other config.
+func ValidateChannelConfig(channelType string, cfg datatypes.JSONMap) error {
+	if _, err := parseValidationSpec(cfg); err != nil {
+		return err
+	}
+	validator, ok := channelConfigValidators[channelType]
+	if !ok {
+		return nil
+	}
+	return validator(cfg)
+}
+
+// decodeChannelConfig decodes the group's channel config into a typed struct.
+func decodeChannelConfig(cfg datatypes.JSONMap, out any) error {
+	if len(cfg) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal channel config: %w", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse channel config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package channel
+
+import (
+	"fmt"
+	"key-flow/internal/models"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// credentialSchema lists the fields of a structured credential accepted by a channel type.
+type credentialSchema struct {
+	Required []string
+	Optional []string
+}
+
+// credentialSchemas defines the structured credentials of each channel. Channels not listed here
+// only accept plain keys or, for vertex, service account files.
+var credentialSchemas = map[string]credentialSchema{
+	"openai":          {Required: []string{models.CredentialSecretField}, Optional: []string{"org_id", "project_id"}},
+	"openai-response": {Required: []string{models.CredentialSecretField}, Optional: []string{"org_id", "project_id"}},
+	"anthropic":       {Required: []string{models.CredentialSecretField}},
+	"gemini":          {Required: []string{models.CredentialSecretField}},
+	"azure":           {Required: []string{models.CredentialSecretField}},
+	"bedrock":         {Required: []string{"access_key_id", "secret_access_key"}, Optional: []string{"session_token"}},
+	// 自定义渠道的其他字段通过 ${KEY_FIELD:name} 在请求头规则中使用
+	"custom": {Required: []string{models.CredentialSecretField}},
+}
+
+// oauthCredentialSchema defines OAuth credentials, whose access token is refreshed by the key pool
+// and sent in place of the api_key field.
+var oauthCredentialSchema = credentialSchema{
+	Required: []string{models.OAuthRefreshTokenField, models.OAuthTokenURLField},
+	Optional: []string{models.OAuthClientIDField, models.OAuthClientSecretField, models.OAuthScopeField},
+}
+
+// oauthChannels are the channels that send the key as a bearer token and so accept OAuth credentials.
+var oauthChannels = map[string]bool{
+	"openai":          true,
+	"openai-response": true,
+	"anthropic":       true,
+	"custom":          true,
+}
+
+// ValidateCredential checks a structured credential against the channel's schema.
+// Plain keys are always accepted.
+func ValidateCredential(channelType, keyValue string) error {
+	fields := models.ParseCredentialFields(keyValue)
+	if fields == nil {
+		return nil
+	}
+
+	if channelType == "vertex" {
+		_, err := parseGoogleServiceAccount(keyValue)
+		return err
+	}
+
+	schema, ok := credentialSchemas[channelType]
+	if fields[models.OAuthRefreshTokenField] != "" {
+		if !oauthChannels[channelType] {
+			return fmt.Errorf("channel type %s does not support OAuth credentials", channelType)
+		}
+		if tokenURL := fields[models.OAuthTokenURLField]; tokenURL != "" {
+			if u, err := url.Parse(tokenURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid OAuth token_url: %s", tokenURL)
+			}
+		}
+		schema, ok = oauthCredentialSchema, true
+	}
+	if !ok {
+		return fmt.Errorf("channel type %s does not support structured credentials", channelType)
+	}
+
+	var missing []string
+	for _, name := range schema.Required {
+		if fields[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("credential is missing required fields: %s", strings.Join(missing, ", "))
+	}
+
+	// 自定义渠道允许任意附加字段
+	if channelType == "custom" {
+		return nil
+	}
+	for name := range fields {
+		if !slices.Contains(schema.Required, name) && !slices.Contains(schema.Optional, name) {
+			return fmt.Errorf("unknown credential field %q for %s channel", name, channelType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"key-flow/internal/models"
+	"key-flow/internal/utils"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+)
+
+const defaultCustomValidationBody = `{"model":"${TEST_MODEL}","messages":[{"role":"user","content":"hi"}]}`
+
+func init() {
+	Register("custom", newCustomChannel)
+	RegisterConfigValidator("custom", validateCustomConfig)
+}
+
+// customAuthConfig describes how the key is injected into upstream requests.
+type customAuthConfig struct {
+	// In is either "header" (default) or "query".
+	In string `json:"in"`
+	// Name is the header or query parameter name. Defaults to Authorization for headers.
+	Name string `json:"name"`
+	// Prefix is prepended to the key, e.g. "Bearer ".
+	Prefix string `json:"prefix"`
+}
+
+// customStreamConfig describes how streaming requests are detected.
+type customStreamConfig struct {
+	// BodyPath is the JSON path of a boolean stream flag in the request body. Defaults to "stream".
+	BodyPath string `json:"body_path"`
+	// QueryParam is a query parameter that marks a streaming request when set to "true".
+	QueryParam string `json:"query_param"`
+	// PathSuffix marks requests whose path ends with it as streaming, e.g. ":streamGenerateContent".
+	PathSuffix string `json:"path_suffix"`
+}
+
+// customConfig holds the settings of a custom channel stored in the group's channel config.
+type customConfig struct {
+	Auth      customAuthConfig   `json:"auth"`
+	ModelPath string             `json:"model_path"`
+	Stream    customStreamConfig `json:"stream"`
+}
+
+// CustomChannel is a channel whose behaviour is entirely defined by the group's channel config.
+type CustomChannel struct {
+	*BaseChannel
+	config customConfig
+}
+
+func parseCustomConfig(cfg datatypes.JSONMap) (*customConfig, error) {
+	var cc customConfig
+	if err := decodeChannelConfig(cfg, &cc); err != nil {
+		return nil, err
+	}
+
+	switch cc.Auth.In {
+	case "", "header":
+		cc.Auth.In = "header"
+		if cc.Auth.Name == "" {
+			cc.Auth.Name = "Authorization"
+			if cc.Auth.Prefix == "" {
+				cc.Auth.Prefix = "Bearer "
+			}
+		}
+	case "query":
+		if cc.Auth.Name == "" {
+			return nil, fmt.Errorf("auth.name is required when auth.in is query")
+		}
+	default:
+		return nil, fmt.Errorf("auth.in must be 'header' or 'query'")
+	}
+
+	if cc.ModelPath == "" {
+		cc.ModelPath = "model"
+	}
+	if cc.Stream.BodyPath == "" {
+		cc.Stream.BodyPath = "stream"
+	}
+	return &cc, nil
+}
+
+func validateCustomConfig(cfg datatypes.JSONMap) error {
+	_, err := parseCustomConfig(cfg)
+	return err
+}
+
+func newCustomChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
+	base, err := f.newBaseChannel("custom", group)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := parseCustomConfig(group.ChannelConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel config for custom channel: %w", err)
+	}
+
+	return &CustomChannel{
+		BaseChannel: base,
+		config:      *cc,
+	}, nil
+}
+
+// injectKey applies the configured key injection to the request.
+func (ch *CustomChannel) injectKey(req *http.Request, keyValue string) {
+	value := ch.config.Auth.Prefix + keyValue
+	if ch.config.Auth.In == "query" {
+		q := req.URL.Query()
+		q.Set(ch.config.Auth.Name, value)
+		req.URL.RawQuery = q.Encode()
+		return
+	}
+	req.Header.Set(ch.config.Auth.Name, value)
+}
+
+// ModifyRequest injects the key as configured for the group.
+func (ch *CustomChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) error {
+	ch.injectKey(req, apiKey.Secret())
+	return nil
+}
+
+// IsStreamRequest checks if the request is for a streaming response using the configured indicators.
+func (ch *CustomChannel) IsStreamRequest(c *gin.Context, bodyBytes []byte) bool {
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		return true
+	}
+
+	if ch.config.Stream.PathSuffix != "" && strings.HasSuffix(c.Request.URL.Path, ch.config.Stream.PathSuffix) {
+		return true
+	}
+
+	if ch.config.Stream.QueryParam != "" && c.Query(ch.config.Stream.QueryParam) == "true" {
+		return true
+	}
+
+	var data any
+	if err := json.Unmarshal(bodyBytes, &data); err == nil {
+		if value, ok := utils.GetJSONPathValue(data, ch.config.Stream.BodyPath); ok {
+			stream, _ := value.(bool)
+			return stream
+		}
+	}
+
+	return false
+}
+
+// ExtractModel reads the model from the configured JSON path.
+func (ch *CustomChannel) ExtractModel(c *gin.Context, bodyBytes []byte) string {
+	var data any
+	if err := json.Unmarshal(bodyBytes, &data); err != nil {
+		return ""
+	}
+	if value, ok := utils.GetJSONPathValue(data, ch.config.ModelPath); ok {
+		model, _ := value.(string)
+		return model
+	}
+	return ""
+}
+
+// ApplyModelRedirect applies model redirection to the model at the configured JSON path.
+func (ch *CustomChannel) ApplyModelRedirect(req *http.Request, bodyBytes []byte, group *models.Group) ([]byte, error) {
+	if len(group.ModelRedirectMap) == 0 || len(bodyBytes) == 0 {
+		return bodyBytes, nil
+	}
+
+	var requestData map[string]any
+	if err := json.Unmarshal(bodyBytes, &requestData); err != nil {
+		return bodyBytes, nil
+	}
+
+	value, ok := utils.GetJSONPathValue(requestData, ch.config.ModelPath)
+	if !ok {
+		return bodyBytes, nil
+	}
+	model, ok := value.(string)
+	if !ok {
+		return bodyBytes, nil
+	}
+
+	if targetModel, found := group.ModelRedirectMap[model]; found {
+		if !utils.SetJSONPathValue(requestData, ch.config.ModelPath, targetModel) {
+			return bodyBytes, nil
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"group":          group.Name,
+			"original_model": model,
+			"target_model":   targetModel,
+			"channel":        "custom",
+		}).Debug("Model redirected")
+
+		return json.Marshal(requestData)
+	}
+
+	if group.ModelRedirectStrict {
+		return nil, fmt.Errorf("model '%s' is not configured in redirect rules", model)
+	}
+
+	return bodyBytes, nil
+}
+
+// ValidateKey checks if the given API key is valid using the configured validation request.
+func (ch *CustomChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey, group *models.Group) (bool, error) {
+	upstreamURL := ch.getUpstreamURL(apiKey)
+	if upstreamURL == nil {
+		return false, ch.noUpstreamError(apiKey)
+	}
+
+	path := ch.validation.Path
+	if path == "" {
+		path = ch.ValidationEndpoint
+	}
+	if path == "" {
+		path = "/v1/chat/completions"
+	}
+	method := strings.ToUpper(ch.validation.Method)
+	if method == "" {
+		method = "POST"
+	}
+
+	endpointURL, err := url.Parse(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse validation endpoint: %w", err)
+	}
+
+	finalURL := *upstreamURL
+	finalURL.Path = strings.TrimRight(finalURL.Path, "/") + endpointURL.Path
+	finalURL.RawQuery = endpointURL.RawQuery
+
+	headerCtx := utils.NewHeaderVariableContext(group, apiKey)
+
+	var body io.Reader
+	if method != "GET" && method != "HEAD" {
+		spec := ch.validation
+		if spec.Body == "" {
+			spec.Body = defaultCustomValidationBody
+		}
+		body = bytes.NewBufferString(spec.renderBody(ch.TestModel, headerCtx))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, finalURL.String(), body)
+	if err != nil {
+		return false, fmt.Errorf("failed to create validation request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	ch.injectKey(req, apiKey.Secret())
+
+	ch.validation.applyHeaders(req, headerCtx)
+
+	// Apply custom header rules if available
+	if len(group.HeaderRuleList) > 0 {
+		utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
+	}
+
+	resp, err := ch.HTTPClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to send validation request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return ch.validation.evaluate(resp)
+}
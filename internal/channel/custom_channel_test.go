@@ -0,0 +1,58 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"key-flow/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCustomChannelValidationBodyEscapesVariables(t *testing.T) {
+	const (
+		keyValue  = "sk-\"quoted\"\\key\t"
+		testModel = `model "x"`
+	)
+
+	var received map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("validation body is not valid JSON: %v: %s", err, body)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	upstream, _ := url.Parse(srv.URL)
+	cc, err := parseCustomConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := &CustomChannel{
+		BaseChannel: &BaseChannel{
+			Name:       "custom",
+			Upstreams:  []UpstreamInfo{{URL: upstream, Weight: 1}},
+			HTTPClient: srv.Client(),
+			TestModel:  testModel,
+			validation: validationSpec{Body: `{"model":"${TEST_MODEL}","key":"${API_KEY}","group":"${GROUP_NAME}"}`},
+		},
+		config: *cc,
+	}
+
+	group := &models.Group{Name: `group\1`}
+	ok, err := ch.ValidateKey(context.Background(), &models.APIKey{KeyValue: keyValue}, group)
+	if err != nil || !ok {
+		t.Fatalf("ValidateKey() = %v, %v", ok, err)
+	}
+
+	want := map[string]string{"model": testModel, "key": keyValue, "group": group.Name}
+	for field, value := range want {
+		if received[field] != value {
+			t.Errorf("%s = %q, want %q", field, received[field], value)
+		}
+	}
+}
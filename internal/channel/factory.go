@@ -98,11 +98,14 @@ func (f *Factory) newBaseChannel(name string, group *models.Group) (*BaseChannel
 	}
 
 	var upstreamInfos []UpstreamInfo
+	boundUpstreams := make(map[string]*url.URL, len(defs))
 	for _, def := range defs {
 		u, err := url.Parse(def.URL)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse upstream url '%s' for %s channel: %w", def.URL, name, err)
 		}
+		// 权重为 0 的上游不参与加权选择，但仍可被 key 绑定
+		boundUpstreams[def.URL] = u
 		if def.Weight <= 0 {
 			continue
 		}
@@ -136,6 +139,11 @@ func (f *Factory) newBaseChannel(name string, group *models.Group) (*BaseChannel
 	streamConfig.MaxIdleConns = max(group.EffectiveConfig.MaxIdleConns*2, 50)
 	streamConfig.MaxIdleConnsPerHost = max(group.EffectiveConfig.MaxIdleConnsPerHost*2, 20)
 
+	validation, err := parseValidationSpec(group.ChannelConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validation config for group %s: %w", group.Name, err)
+	}
+
 	// Get both clients from the manager using their respective configurations.
 	httpClient := f.clientManager.GetClient(clientConfig)
 	streamClient := f.clientManager.GetClient(&streamConfig)
@@ -143,6 +151,7 @@ func (f *Factory) newBaseChannel(name string, group *models.Group) (*BaseChannel
 	return &BaseChannel{
 		Name:                name,
 		Upstreams:           upstreamInfos,
+		boundUpstreams:      boundUpstreams,
 		HTTPClient:          httpClient,
 		StreamClient:        streamClient,
 		TestModel:           group.TestModel,
@@ -152,5 +161,7 @@ func (f *Factory) newBaseChannel(name string, group *models.Group) (*BaseChannel
 		effectiveConfig:     &group.EffectiveConfig,
 		modelRedirectRules:  group.ModelRedirectRules,
 		modelRedirectStrict: group.ModelRedirectStrict,
+		channelConfig:       group.ChannelConfig,
+		validation:          validation,
 	}, nil
 }
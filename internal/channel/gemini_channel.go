@@ -5,10 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	app_errors "key-flow/internal/errors"
 	"key-flow/internal/models"
 	"key-flow/internal/utils"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -37,14 +35,15 @@ func newGeminiChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
 }
 
 // ModifyRequest adds the API key as a query parameter for Gemini requests.
-func (ch *GeminiChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) {
+func (ch *GeminiChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) error {
 	if strings.Contains(req.URL.Path, "v1beta/openai") {
-		req.Header.Set("Authorization", "Bearer "+apiKey.KeyValue)
+		req.Header.Set("Authorization", "Bearer "+apiKey.Secret())
 	} else {
 		q := req.URL.Query()
-		q.Set("key", apiKey.KeyValue)
+		q.Set("key", apiKey.Secret())
 		req.URL.RawQuery = q.Encode()
 	}
+	return nil
 }
 
 // IsStreamRequest checks if the request is for a streaming response.
@@ -98,9 +97,9 @@ func (ch *GeminiChannel) ExtractModel(c *gin.Context, bodyBytes []byte) string {
 
 // ValidateKey checks if the given API key is valid by making a generateContent request.
 func (ch *GeminiChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey, group *models.Group) (bool, error) {
-	upstreamURL := ch.getUpstreamURL()
+	upstreamURL := ch.getUpstreamURL(apiKey)
 	if upstreamURL == nil {
-		return false, fmt.Errorf("no upstream URL configured for channel %s", ch.Name)
+		return false, ch.noUpstreamError(apiKey)
 	}
 
 	// Safely join the path segments
@@ -108,7 +107,7 @@ func (ch *GeminiChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey,
 	if err != nil {
 		return false, fmt.Errorf("failed to create gemini validation path: %w", err)
 	}
-	reqURL += "?key=" + apiKey.KeyValue
+	reqURL += "?key=" + apiKey.Secret()
 
 	payload := gin.H{
 		"contents": []gin.H{
@@ -120,9 +119,10 @@ func (ch *GeminiChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey,
 			},
 		},
 	}
-	body, err := json.Marshal(payload)
+	headerCtx := utils.NewHeaderVariableContext(group, apiKey)
+	body, err := ch.validation.payload(payload, ch.TestModel, headerCtx)
 	if err != nil {
-		return false, fmt.Errorf("failed to marshal validation payload: %w", err)
+		return false, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewBuffer(body))
@@ -131,9 +131,10 @@ func (ch *GeminiChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey,
 	}
 	req.Header.Set("Content-Type", "application/json")
 
+	ch.validation.applyHeaders(req, headerCtx)
+
 	// Apply custom header rules if available
 	if len(group.HeaderRuleList) > 0 {
-		headerCtx := utils.NewHeaderVariableContext(group, apiKey)
 		utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
 	}
 
@@ -143,21 +144,7 @@ func (ch *GeminiChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey,
 	}
 	defer resp.Body.Close()
 
-	// Any 2xx status code indicates the key is valid.
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return true, nil
-	}
-
-	// For non-200 responses, parse the body to provide a more specific error reason.
-	errorBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return false, fmt.Errorf("key is invalid (status %d), but failed to read error body: %w", resp.StatusCode, err)
-	}
-
-	// Use the new parser to extract a clean error message.
-	parsedError := app_errors.ParseUpstreamError(errorBody)
-
-	return false, &app_errors.ValidationError{StatusCode: resp.StatusCode, Message: parsedError}
+	return ch.validation.evaluate(resp)
 }
 
 // ApplyModelRedirect overrides the default implementation for Gemini channel.
@@ -0,0 +1,311 @@
+package channel
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	app_errors "key-flow/internal/errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGoogleTokenURL = "https://oauth2.googleapis.com/token"
+	defaultGoogleScope    = "https://www.googleapis.com/auth/cloud-platform"
+	googleJWTBearerGrant  = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	googleTokenLifetime   = time.Hour
+	// googleTokenRefreshSkew refreshes tokens a little before they actually expire.
+	googleTokenRefreshSkew = 5 * time.Minute
+	// googleTokenSweepInterval is how often idle entries are evicted, so keys that were deleted or rotated
+	// do not keep their tokens in memory.
+	googleTokenSweepInterval = 10 * time.Minute
+)
+
+// googleServiceAccount holds the fields of a service-account JSON key that are needed for token exchange.
+type googleServiceAccount struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// parseGoogleServiceAccount parses a service-account key. The key may be raw JSON or base64 encoded JSON,
+// the latter being convenient for text imports that split on whitespace.
+func parseGoogleServiceAccount(keyValue string) (*googleServiceAccount, error) {
+	data := []byte(strings.TrimSpace(keyValue))
+	if len(data) > 0 && data[0] != '{' {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("service account key is neither JSON nor base64 encoded JSON")
+		}
+		data = decoded
+	}
+
+	var sa googleServiceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("failed to parse service account JSON: %w", err)
+	}
+	if sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, fmt.Errorf("service account JSON must contain client_email and private_key")
+	}
+	return &sa, nil
+}
+
+// parseRSAPrivateKey parses a PEM encoded PKCS#8 or PKCS#1 RSA private key.
+func parseRSAPrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM private key")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is not an RSA key")
+		}
+		return rsaKey, nil
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	return key, nil
+}
+
+// buildGoogleJWTAssertion creates the RS256 signed assertion for the JWT-bearer grant.
+func buildGoogleJWTAssertion(sa *googleServiceAccount, tokenURL, scope string, now time.Time) (string, error) {
+	privateKey, err := parseRSAPrivateKey(sa.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	header := map[string]any{"alg": "RS256", "typ": "JWT"}
+	if sa.PrivateKeyID != "" {
+		header["kid"] = sa.PrivateKeyID
+	}
+	claims := map[string]any{
+		"iss":   sa.ClientEmail,
+		"scope": scope,
+		"aud":   tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(googleTokenLifetime).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT assertion: %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// googleAccount is a service account parsed from a key value, cached by googleAccountCache.
+type googleAccount struct {
+	*googleServiceAccount
+	keyID       uint
+	source      string    // key value the account was parsed from
+	fingerprint string    // hash of the key value, part of the token cache key
+	lastUsed    time.Time // guarded by googleAccountCache.mu
+}
+
+// googleAccountCache caches parsed service accounts by key ID, so a key is parsed and hashed once
+// rather than on every request. An entry is replaced when the key value changes.
+type googleAccountCache struct {
+	mu        sync.Mutex
+	entries   map[uint]*googleAccount
+	lastSweep time.Time
+}
+
+var googleAccounts = &googleAccountCache{entries: make(map[uint]*googleAccount)}
+
+// get returns the parsed service account of the key, parsing keyValue when it is not cached or
+// differs from the value the cached account was parsed from.
+func (c *googleAccountCache) get(keyID uint, keyValue string) (*googleAccount, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= googleTokenSweepInterval {
+		for id, a := range c.entries {
+			if now.Sub(a.lastUsed) >= googleTokenSweepInterval {
+				delete(c.entries, id)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if a, ok := c.entries[keyID]; ok && a.source == keyValue {
+		a.lastUsed = now
+		return a, nil
+	}
+
+	sa, err := parseGoogleServiceAccount(keyValue)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(keyValue))
+	a := &googleAccount{
+		googleServiceAccount: sa,
+		keyID:                keyID,
+		source:               keyValue,
+		fingerprint:          hex.EncodeToString(sum[:]),
+		lastUsed:             now,
+	}
+	c.entries[keyID] = a
+	return a, nil
+}
+
+// googleTokenEntry caches the access token of one service account.
+type googleTokenEntry struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	lastUsed  time.Time // guarded by googleTokenCache.mu
+}
+
+// googleTokenCache caches access tokens keyed by the key ID, the fingerprint of the service account,
+// the token URL and the scope.
+type googleTokenCache struct {
+	mu        sync.Mutex
+	entries   map[string]*googleTokenEntry
+	lastSweep time.Time
+}
+
+var googleTokens = &googleTokenCache{entries: make(map[string]*googleTokenEntry)}
+
+func (c *googleTokenCache) entry(cacheKey string) *googleTokenEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= googleTokenSweepInterval {
+		c.sweepLocked(now)
+		c.lastSweep = now
+	}
+
+	e, ok := c.entries[cacheKey]
+	if !ok {
+		e = &googleTokenEntry{}
+		c.entries[cacheKey] = e
+	}
+	e.lastUsed = now
+	return e
+}
+
+// sweepLocked evicts entries whose token has expired and that have not been used for a sweep interval.
+// Entries held by an in-flight exchange are skipped. c.mu must be held.
+func (c *googleTokenCache) sweepLocked(now time.Time) {
+	for cacheKey, e := range c.entries {
+		if now.Sub(e.lastUsed) < googleTokenSweepInterval || !e.mu.TryLock() {
+			continue
+		}
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, cacheKey)
+		}
+		e.mu.Unlock()
+	}
+}
+
+// GetToken returns a cached access token, exchanging a new JWT assertion when it is missing or about to expire.
+// Concurrent callers for the same service account wait for a single exchange.
+func (c *googleTokenCache) GetToken(ctx context.Context, client *http.Client, account *googleAccount, tokenURL, scope string) (string, error) {
+	e := c.entry(fmt.Sprintf("%d:%s\x00%s\x00%s", account.keyID, account.fingerprint, tokenURL, scope))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.token != "" && time.Until(e.expiresAt) > googleTokenRefreshSkew {
+		return e.token, nil
+	}
+
+	token, expiresIn, err := exchangeGoogleJWT(ctx, client, account.googleServiceAccount, tokenURL, scope)
+	if err != nil {
+		return "", err
+	}
+
+	e.token = token
+	e.expiresAt = time.Now().Add(expiresIn)
+	return token, nil
+}
+
+// exchangeGoogleJWT performs the JWT-bearer token exchange against the token URL.
+func exchangeGoogleJWT(ctx context.Context, client *http.Client, sa *googleServiceAccount, tokenURL, scope string) (string, time.Duration, error) {
+	assertion, err := buildGoogleJWTAssertion(sa, tokenURL, scope, time.Now())
+	if err != nil {
+		return "", 0, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", googleJWTBearerGrant)
+	form.Set("assertion", assertion)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to send token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var oauthErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		message := app_errors.ParseUpstreamError(body)
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			message = strings.TrimSpace(oauthErr.Error + ": " + oauthErr.ErrorDescription)
+		}
+		return "", 0, &app_errors.ValidationError{StatusCode: resp.StatusCode, Message: "token exchange failed: " + message}
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", 0, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response does not contain an access_token")
+	}
+
+	expiresIn := time.Duration(tokenResp.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = googleTokenLifetime
+	}
+	return tokenResp.AccessToken, expiresIn, nil
+}
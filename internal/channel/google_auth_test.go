@@ -0,0 +1,310 @@
+package channel
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testGoogleTokenServer 是本地的 token 端点，校验 JWT assertion 并签发递增编号的 access token
+type testGoogleTokenServer struct {
+	*httptest.Server
+	exchanges atomic.Int32
+	expiresIn atomic.Int64
+	claims    atomic.Value // 最近一次 assertion 的 claims
+}
+
+func newTestGoogleTokenServer(t *testing.T, publicKey *rsa.PublicKey) *testGoogleTokenServer {
+	t.Helper()
+	s := &testGoogleTokenServer{}
+	s.expiresIn.Store(3600)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != googleJWTBearerGrant {
+			http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		}
+		claims, err := verifyTestJWT(r.PostForm.Get("assertion"), publicKey)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"invalid_grant","error_description":%q}`, err.Error()), http.StatusBadRequest)
+			return
+		}
+		s.claims.Store(claims)
+		n := s.exchanges.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":%d,"token_type":"Bearer"}`, n, s.expiresIn.Load())
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// verifyTestJWT 校验 RS256 签名并返回 claims
+func verifyTestJWT(assertion string, publicKey *rsa.PublicKey) (map[string]any, error) {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("assertion has %d parts", len(parts))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("bad signature: %w", err)
+	}
+
+	var header map[string]any
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	if err := json.Unmarshal(headerJSON, &header); err != nil || header["alg"] != "RS256" {
+		return nil, fmt.Errorf("bad header %s", headerJSON)
+	}
+	var claims map[string]any
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// newTestServiceAccount 生成新的 RSA 密钥并返回解析后的 service account，每次调用得到不同的缓存条目
+func newTestServiceAccount(t *testing.T) (*googleAccount, *rsa.PublicKey) {
+	t.Helper()
+	privateKey := newTestServiceAccountKey(t)
+	account, err := newTestGoogleAccountCache().get(1, testServiceAccountJSON(t, privateKey, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account, &privateKey.PublicKey
+}
+
+func newTestServiceAccountKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey
+}
+
+func testServiceAccountJSON(t *testing.T, privateKey *rsa.PrivateKey, tokenURI string) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sa, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "sa-project",
+		"private_key_id": "kid-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "proxy@sa-project.iam.gserviceaccount.com",
+		"token_uri":      tokenURI,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(sa)
+}
+
+func newTestGoogleAccountCache() *googleAccountCache {
+	return &googleAccountCache{entries: make(map[uint]*googleAccount), lastSweep: time.Now()}
+}
+
+func newTestGoogleTokenCache() *googleTokenCache {
+	return &googleTokenCache{entries: make(map[string]*googleTokenEntry), lastSweep: time.Now()}
+}
+
+func TestGoogleTokenExchange(t *testing.T) {
+	sa, publicKey := newTestServiceAccount(t)
+	srv := newTestGoogleTokenServer(t, publicKey)
+	cache := newTestGoogleTokenCache()
+
+	token, err := cache.GetToken(context.Background(), srv.Client(), sa, srv.URL, defaultGoogleScope)
+	if err != nil || token != "token-1" {
+		t.Fatalf("GetToken() = %q, %v, want token-1", token, err)
+	}
+
+	claims := srv.claims.Load().(map[string]any)
+	want := map[string]any{
+		"iss":   "proxy@sa-project.iam.gserviceaccount.com",
+		"scope": defaultGoogleScope,
+		"aud":   srv.URL,
+	}
+	for name, value := range want {
+		if claims[name] != value {
+			t.Errorf("claim %s = %v, want %v", name, claims[name], value)
+		}
+	}
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+	if time.Duration(exp-iat)*time.Second != googleTokenLifetime {
+		t.Errorf("assertion lifetime = %v, want %v", time.Duration(exp-iat)*time.Second, googleTokenLifetime)
+	}
+}
+
+func TestGoogleAccountCache(t *testing.T) {
+	privateKey := newTestServiceAccountKey(t)
+	sa := testServiceAccountJSON(t, privateKey, "")
+	rotated := testServiceAccountJSON(t, newTestServiceAccountKey(t), "")
+	cache := newTestGoogleAccountCache()
+
+	first, err := cache.get(1, sa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cache.get(1, sa); again != first {
+		t.Fatal("get() parsed the same key value again")
+	}
+	// 其他 key 使用同一个 service account 时指纹相同，但按 key ID 分别缓存
+	other, _ := cache.get(2, sa)
+	if other == first || other.fingerprint != first.fingerprint {
+		t.Fatalf("get() for another key = %+v, want a separate entry with the same fingerprint", other)
+	}
+	// key 值变化后重新解析，指纹随之变化，不会复用旧凭证的 token
+	changed, err := cache.get(1, rotated)
+	if err != nil || changed == first || changed.fingerprint == first.fingerprint {
+		t.Fatalf("get() after rotation = %+v, %v, want a new account with a new fingerprint", changed, err)
+	}
+
+	if _, err := cache.get(3, "not a service account"); err == nil {
+		t.Fatal("get() accepted an invalid key value")
+	}
+	if _, ok := cache.entries[3]; ok {
+		t.Fatal("invalid key value was cached")
+	}
+
+	cache.entries[1].lastUsed = time.Now().Add(-2 * googleTokenSweepInterval)
+	cache.lastSweep = time.Now().Add(-googleTokenSweepInterval)
+	cache.get(2, sa)
+	if _, ok := cache.entries[1]; ok {
+		t.Fatal("idle account was not evicted")
+	}
+}
+
+func TestGoogleTokenCaching(t *testing.T) {
+	tests := []struct {
+		name          string
+		expiresIn     int64
+		wantExchanges int32
+		wantSecond    string
+	}{
+		{name: "cached until near expiry", expiresIn: 3600, wantExchanges: 1, wantSecond: "token-1"},
+		{name: "refreshed inside the skew", expiresIn: int64((googleTokenRefreshSkew - time.Minute) / time.Second), wantExchanges: 2, wantSecond: "token-2"},
+		{name: "missing expires_in uses the default lifetime", expiresIn: 0, wantExchanges: 1, wantSecond: "token-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sa, publicKey := newTestServiceAccount(t)
+			srv := newTestGoogleTokenServer(t, publicKey)
+			srv.expiresIn.Store(tt.expiresIn)
+			cache := newTestGoogleTokenCache()
+
+			if _, err := cache.GetToken(context.Background(), srv.Client(), sa, srv.URL, defaultGoogleScope); err != nil {
+				t.Fatal(err)
+			}
+			token, err := cache.GetToken(context.Background(), srv.Client(), sa, srv.URL, defaultGoogleScope)
+			if err != nil || token != tt.wantSecond {
+				t.Fatalf("GetToken() = %q, %v, want %q", token, err, tt.wantSecond)
+			}
+			if n := srv.exchanges.Load(); n != tt.wantExchanges {
+				t.Fatalf("exchanges = %d, want %d", n, tt.wantExchanges)
+			}
+		})
+	}
+}
+
+func TestGoogleTokenConcurrentCallersShareExchange(t *testing.T) {
+	sa, publicKey := newTestServiceAccount(t)
+	srv := newTestGoogleTokenServer(t, publicKey)
+	cache := newTestGoogleTokenCache()
+
+	done := make(chan string, 8)
+	for range cap(done) {
+		go func() {
+			token, _ := cache.GetToken(context.Background(), srv.Client(), sa, srv.URL, defaultGoogleScope)
+			done <- token
+		}()
+	}
+	for range cap(done) {
+		if token := <-done; token != "token-1" {
+			t.Fatalf("GetToken() = %q, want token-1", token)
+		}
+	}
+	if n := srv.exchanges.Load(); n != 1 {
+		t.Fatalf("exchanges = %d, want 1", n)
+	}
+}
+
+func TestGoogleTokenExchangeFailure(t *testing.T) {
+	sa, _ := newTestServiceAccount(t)
+	// 端点使用另一个公钥，签名校验失败
+	_, otherKey := newTestServiceAccount(t)
+	srv := newTestGoogleTokenServer(t, otherKey)
+	cache := newTestGoogleTokenCache()
+
+	_, err := cache.GetToken(context.Background(), srv.Client(), sa, srv.URL, defaultGoogleScope)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("GetToken() err = %v, want invalid_grant", err)
+	}
+}
+
+func TestGoogleTokenCacheEviction(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		lastUsed  time.Time
+		expiresAt time.Time
+		wantKept  bool
+	}{
+		{name: "idle and expired", lastUsed: now.Add(-2 * googleTokenSweepInterval), expiresAt: now.Add(-time.Minute)},
+		{name: "idle but token still valid", lastUsed: now.Add(-2 * googleTokenSweepInterval), expiresAt: now.Add(time.Hour), wantKept: true},
+		{name: "expired but recently used", lastUsed: now.Add(-time.Minute), expiresAt: now.Add(-time.Minute), wantKept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newTestGoogleTokenCache()
+			cache.entries["old"] = &googleTokenEntry{token: "old-token", expiresAt: tt.expiresAt, lastUsed: tt.lastUsed}
+
+			// 未到清理间隔时不清理
+			cache.entry("other")
+			if _, ok := cache.entries["old"]; !ok {
+				t.Fatal("entry evicted before the sweep interval elapsed")
+			}
+
+			cache.lastSweep = now.Add(-googleTokenSweepInterval)
+			cache.entry("other")
+			if _, kept := cache.entries["old"]; kept != tt.wantKept {
+				t.Fatalf("entry kept = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestGoogleTokenEvictionSkipsInFlightExchange(t *testing.T) {
+	cache := newTestGoogleTokenCache()
+	busy := &googleTokenEntry{lastUsed: time.Now().Add(-2 * googleTokenSweepInterval)}
+	cache.entries["busy"] = busy
+
+	busy.mu.Lock()
+	cache.lastSweep = time.Now().Add(-googleTokenSweepInterval)
+	cache.entry("other")
+	busy.mu.Unlock()
+
+	if _, ok := cache.entries["busy"]; !ok {
+		t.Fatal("entry held by an in-flight exchange was evicted")
+	}
+}
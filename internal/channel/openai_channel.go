@@ -5,10 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	app_errors "key-flow/internal/errors"
 	"key-flow/internal/models"
 	"key-flow/internal/utils"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -36,8 +34,21 @@ func newOpenAIChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
 }
 
 // ModifyRequest sets the Authorization header for the OpenAI service.
-func (ch *OpenAIChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) {
-	req.Header.Set("Authorization", "Bearer "+apiKey.KeyValue)
+func (ch *OpenAIChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) error {
+	setOpenAIAuthHeaders(req, apiKey)
+	return nil
+}
+
+// setOpenAIAuthHeaders sets the bearer token and, for structured credentials,
+// the organization and project headers.
+func setOpenAIAuthHeaders(req *http.Request, apiKey *models.APIKey) {
+	req.Header.Set("Authorization", "Bearer "+apiKey.Secret())
+	if orgID := apiKey.CredentialField("org_id"); orgID != "" {
+		req.Header.Set("OpenAI-Organization", orgID)
+	}
+	if projectID := apiKey.CredentialField("project_id"); projectID != "" {
+		req.Header.Set("OpenAI-Project", projectID)
+	}
 }
 
 // IsStreamRequest checks if the request is for a streaming response using the pre-read body.
@@ -74,9 +85,9 @@ func (ch *OpenAIChannel) ExtractModel(c *gin.Context, bodyBytes []byte) string {
 
 // ValidateKey checks if the given API key is valid by making a chat completion request.
 func (ch *OpenAIChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey, group *models.Group) (bool, error) {
-	upstreamURL := ch.getUpstreamURL()
+	upstreamURL := ch.getUpstreamURL(apiKey)
 	if upstreamURL == nil {
-		return false, fmt.Errorf("no upstream URL configured for channel %s", ch.Name)
+		return false, ch.noUpstreamError(apiKey)
 	}
 
 	// Parse validation endpoint to extract path and query parameters
@@ -98,21 +109,23 @@ func (ch *OpenAIChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey,
 			{"role": "user", "content": "hi"},
 		},
 	}
-	body, err := json.Marshal(payload)
+	headerCtx := utils.NewHeaderVariableContext(group, apiKey)
+	body, err := ch.validation.payload(payload, ch.TestModel, headerCtx)
 	if err != nil {
-		return false, fmt.Errorf("failed to marshal validation payload: %w", err)
+		return false, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewBuffer(body))
 	if err != nil {
 		return false, fmt.Errorf("failed to create validation request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+apiKey.KeyValue)
+	setOpenAIAuthHeaders(req, apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
+	ch.validation.applyHeaders(req, headerCtx)
+
 	// Apply custom header rules if available
 	if len(group.HeaderRuleList) > 0 {
-		headerCtx := utils.NewHeaderVariableContext(group, apiKey)
 		utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
 	}
 
@@ -122,19 +135,5 @@ func (ch *OpenAIChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey,
 	}
 	defer resp.Body.Close()
 
-	// Any 2xx status code indicates the key is valid.
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return true, nil
-	}
-
-	// For non-200 responses, parse the body to provide a more specific error reason.
-	errorBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return false, fmt.Errorf("key is invalid (status %d), but failed to read error body: %w", resp.StatusCode, err)
-	}
-
-	// Use the new parser to extract a clean error message.
-	parsedError := app_errors.ParseUpstreamError(errorBody)
-
-	return false, &app_errors.ValidationError{StatusCode: resp.StatusCode, Message: parsedError}
+	return ch.validation.evaluate(resp)
 }
@@ -5,10 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	app_errors "key-flow/internal/errors"
 	"key-flow/internal/models"
 	"key-flow/internal/utils"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -35,8 +33,9 @@ func newOpenAIResponseChannel(f *Factory, group *models.Group) (ChannelProxy, er
 	}, nil
 }
 
-func (ch *OpenAIResponseChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) {
-	req.Header.Set("Authorization", "Bearer "+apiKey.KeyValue)
+func (ch *OpenAIResponseChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) error {
+	setOpenAIAuthHeaders(req, apiKey)
+	return nil
 }
 
 func (ch *OpenAIResponseChannel) IsStreamRequest(c *gin.Context, bodyBytes []byte) bool {
@@ -71,9 +70,9 @@ func (ch *OpenAIResponseChannel) ExtractModel(c *gin.Context, bodyBytes []byte)
 }
 
 func (ch *OpenAIResponseChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey, group *models.Group) (bool, error) {
-	upstreamURL := ch.getUpstreamURL()
+	upstreamURL := ch.getUpstreamURL(apiKey)
 	if upstreamURL == nil {
-		return false, fmt.Errorf("no upstream URL configured for channel %s", ch.Name)
+		return false, ch.noUpstreamError(apiKey)
 	}
 
 	endpointURL, err := url.Parse(ch.ValidationEndpoint)
@@ -90,20 +89,22 @@ func (ch *OpenAIResponseChannel) ValidateKey(ctx context.Context, apiKey *models
 		"model": ch.TestModel,
 		"input": "hi",
 	}
-	body, err := json.Marshal(payload)
+	headerCtx := utils.NewHeaderVariableContext(group, apiKey)
+	body, err := ch.validation.payload(payload, ch.TestModel, headerCtx)
 	if err != nil {
-		return false, fmt.Errorf("failed to marshal validation payload: %w", err)
+		return false, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewBuffer(body))
 	if err != nil {
 		return false, fmt.Errorf("failed to create validation request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+apiKey.KeyValue)
+	setOpenAIAuthHeaders(req, apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
+	ch.validation.applyHeaders(req, headerCtx)
+
 	if len(group.HeaderRuleList) > 0 {
-		headerCtx := utils.NewHeaderVariableContext(group, apiKey)
 		utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
 	}
 
@@ -113,16 +114,5 @@ func (ch *OpenAIResponseChannel) ValidateKey(ctx context.Context, apiKey *models
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return true, nil
-	}
-
-	errorBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return false, fmt.Errorf("key is invalid (status %d), but failed to read error body: %w", resp.StatusCode, err)
-	}
-
-	parsedError := app_errors.ParseUpstreamError(errorBody)
-
-	return false, &app_errors.ValidationError{StatusCode: resp.StatusCode, Message: parsedError}
+	return ch.validation.evaluate(resp)
 }
@@ -0,0 +1,179 @@
+package channel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	app_errors "key-flow/internal/errors"
+	"key-flow/internal/utils"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gorm.io/datatypes"
+)
+
+// validationSpec describes a configurable key validation request. It is read from the
+// "validation" section of the group's channel config and honoured by every channel.
+type validationSpec struct {
+	// Method and Path are only used by channels without a fixed validation request, such as custom.
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Body is a template that replaces the channel's default validation payload.
+	Body string `json:"body"`
+	// Headers are added to the validation request, header variables are supported.
+	Headers map[string]string `json:"headers"`
+	Success validationSuccess `json:"success"`
+}
+
+// validationSuccess describes when a validation response counts as a valid key.
+type validationSuccess struct {
+	// Status lists the accepted status codes. Any 2xx is accepted when empty.
+	Status []int `json:"status"`
+	// JSONPath optionally points to a value in the response body that must exist.
+	JSONPath string `json:"json_path"`
+	// Equals optionally requires the value at JSONPath to equal this value.
+	Equals any `json:"equals"`
+}
+
+// parseValidationSpec reads and checks the optional validation section of the channel config.
+func parseValidationSpec(cfg datatypes.JSONMap) (validationSpec, error) {
+	var wrapper struct {
+		Validation validationSpec `json:"validation"`
+	}
+	if err := decodeChannelConfig(cfg, &wrapper); err != nil {
+		return validationSpec{}, err
+	}
+	spec := wrapper.Validation
+	if err := spec.validate(); err != nil {
+		return validationSpec{}, err
+	}
+	return spec, nil
+}
+
+// validate checks that the spec is well formed.
+func (s *validationSpec) validate() error {
+	if s.Method != "" {
+		switch strings.ToUpper(s.Method) {
+		case "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD":
+		default:
+			return fmt.Errorf("unsupported validation method '%s'", s.Method)
+		}
+	}
+	if s.Path != "" && (!strings.HasPrefix(s.Path, "/") || strings.Contains(s.Path, "://")) {
+		return fmt.Errorf("validation path must start with / and must not be a full URL")
+	}
+	for name := range s.Headers {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("validation header name cannot be empty")
+		}
+	}
+	for _, code := range s.Success.Status {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid expected status code %d", code)
+		}
+	}
+	if s.Success.Equals != nil && s.Success.JSONPath == "" {
+		return fmt.Errorf("success.equals requires success.json_path")
+	}
+	return nil
+}
+
+// renderBody resolves the body template variables. ${TEST_MODEL} is replaced by the group's
+// test model, the header variables such as ${API_KEY} are supported as well. The body is a JSON
+// template, so every substituted value is JSON-escaped.
+func (s *validationSpec) renderBody(testModel string, ctx *utils.HeaderVariableContext) string {
+	body := strings.ReplaceAll(s.Body, "${TEST_MODEL}", utils.EscapeJSONString(testModel))
+	return utils.ResolveJSONVariables(body, ctx)
+}
+
+// payload returns the rendered body template, or the marshaled default payload when no template is set.
+func (s *validationSpec) payload(defaultPayload any, testModel string, ctx *utils.HeaderVariableContext) ([]byte, error) {
+	if s.Body != "" {
+		return []byte(s.renderBody(testModel, ctx)), nil
+	}
+	body, err := json.Marshal(defaultPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal validation payload: %w", err)
+	}
+	return body, nil
+}
+
+// applyHeaders sets the configured validation headers on the request.
+func (s *validationSpec) applyHeaders(req *http.Request, ctx *utils.HeaderVariableContext) {
+	for name, value := range s.Headers {
+		req.Header.Set(name, utils.ResolveHeaderVariables(value, ctx))
+	}
+}
+
+// evaluate reads the validation response and checks it against the success condition.
+// Failures are reported as ValidationError, naming the assertion that failed when one is configured.
+func (s *validationSpec) evaluate(resp *http.Response) (bool, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read validation response (status %d): %w", resp.StatusCode, err)
+	}
+
+	if !s.Success.statusAccepted(resp.StatusCode) {
+		ve := &app_errors.ValidationError{StatusCode: resp.StatusCode, Message: app_errors.ParseUpstreamError(body)}
+		if len(s.Success.Status) > 0 {
+			ve.Assertion = fmt.Sprintf("expected status %v, got %d", s.Success.Status, resp.StatusCode)
+		}
+		return false, ve
+	}
+
+	if failure := s.Success.checkBody(body); failure != "" {
+		return false, &app_errors.ValidationError{
+			StatusCode: resp.StatusCode,
+			Message:    app_errors.ParseUpstreamError(body),
+			Assertion:  failure,
+		}
+	}
+
+	return true, nil
+}
+
+// statusAccepted reports whether the status code satisfies the success condition.
+func (s *validationSuccess) statusAccepted(statusCode int) bool {
+	if len(s.Status) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	return slices.Contains(s.Status, statusCode)
+}
+
+// checkBody evaluates the JSON path assertion against the response body.
+// It returns a description of the failed assertion, or an empty string on success.
+func (s *validationSuccess) checkBody(body []byte) string {
+	if s.JSONPath == "" {
+		return ""
+	}
+
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return fmt.Sprintf("response body is not valid JSON, cannot evaluate json_path '%s'", s.JSONPath)
+	}
+
+	value, ok := utils.GetJSONPathValue(data, s.JSONPath)
+	if !ok || value == nil {
+		return fmt.Sprintf("json_path '%s' not found in response", s.JSONPath)
+	}
+
+	if s.Equals != nil && !reflect.DeepEqual(normalizeJSONValue(value), normalizeJSONValue(s.Equals)) {
+		return fmt.Sprintf("json_path '%s' is %v, expected %v", s.JSONPath, value, s.Equals)
+	}
+	return ""
+}
+
+// normalizeJSONValue round-trips a value through JSON so that numbers and nested types compare equal.
+func normalizeJSONValue(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
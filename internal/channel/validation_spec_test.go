@@ -0,0 +1,185 @@
+package channel
+
+import (
+	"errors"
+	"io"
+	app_errors "key-flow/internal/errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gorm.io/datatypes"
+)
+
+func TestValidationSpecEvaluate(t *testing.T) {
+	tests := []struct {
+		name      string
+		success   validationSuccess
+		status    int
+		body      string
+		wantOK    bool
+		assertion string // 为空时表示不应有断言失败描述
+		message   string
+	}{
+		{
+			name:   "default accepts 2xx",
+			status: http.StatusNoContent,
+			wantOK: true,
+		},
+		{
+			name:    "default rejects non-2xx",
+			status:  http.StatusUnauthorized,
+			body:    `{"error":{"message":"invalid api key"}}`,
+			message: "invalid api key",
+		},
+		{
+			name:    "status list accepts listed code",
+			success: validationSuccess{Status: []int{200, 400}},
+			status:  http.StatusBadRequest,
+			wantOK:  true,
+		},
+		{
+			name:      "status list rejects unlisted 2xx",
+			success:   validationSuccess{Status: []int{200}},
+			status:    http.StatusCreated,
+			assertion: "expected status [200], got 201",
+		},
+		{
+			name:    "json path exists",
+			success: validationSuccess{JSONPath: "data[0].id"},
+			status:  http.StatusOK,
+			body:    `{"data":[{"id":"gpt-4o"}]}`,
+			wantOK:  true,
+		},
+		{
+			name:      "json path missing",
+			success:   validationSuccess{JSONPath: "data.1.id"},
+			status:    http.StatusOK,
+			body:      `{"data":[{"id":"gpt-4o"}]}`,
+			assertion: "json_path 'data.1.id' not found in response",
+		},
+		{
+			name:      "json path null counts as missing",
+			success:   validationSuccess{JSONPath: "result"},
+			status:    http.StatusOK,
+			body:      `{"result":null}`,
+			assertion: "json_path 'result' not found in response",
+		},
+		{
+			name:      "json path on non JSON body",
+			success:   validationSuccess{JSONPath: "ok"},
+			status:    http.StatusOK,
+			body:      "ok",
+			assertion: "response body is not valid JSON, cannot evaluate json_path 'ok'",
+		},
+		{
+			name:    "equals string",
+			success: validationSuccess{JSONPath: "status", Equals: "active"},
+			status:  http.StatusOK,
+			body:    `{"status":"active"}`,
+			wantOK:  true,
+		},
+		{
+			name:    "equals compares numbers across types",
+			success: validationSuccess{JSONPath: "balance.total", Equals: 10},
+			status:  http.StatusOK,
+			body:    `{"balance":{"total":10.0}}`,
+			wantOK:  true,
+		},
+		{
+			name:    "equals nested object",
+			success: validationSuccess{JSONPath: "limits", Equals: map[string]any{"rpm": 60, "tags": []any{"a"}}},
+			status:  http.StatusOK,
+			body:    `{"limits":{"tags":["a"],"rpm":60}}`,
+			wantOK:  true,
+		},
+		{
+			name:      "equals mismatch",
+			success:   validationSuccess{JSONPath: "valid", Equals: true},
+			status:    http.StatusOK,
+			body:      `{"valid":false,"error":{"message":"key disabled"}}`,
+			assertion: "json_path 'valid' is false, expected true",
+			message:   "key disabled",
+		},
+		{
+			name:      "status checked before body",
+			success:   validationSuccess{Status: []int{200}, JSONPath: "valid", Equals: true},
+			status:    http.StatusForbidden,
+			body:      `{"valid":true}`,
+			assertion: "expected status [200], got 403",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := validationSpec{Success: tt.success}
+			resp := &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(tt.body))}
+
+			ok, err := spec.evaluate(resp)
+			if ok != tt.wantOK {
+				t.Fatalf("evaluate() ok = %v, err = %v, want ok %v", ok, err, tt.wantOK)
+			}
+			if tt.wantOK {
+				if err != nil {
+					t.Fatalf("evaluate() err = %v, want nil", err)
+				}
+				return
+			}
+
+			var ve *app_errors.ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("evaluate() err = %v, want *ValidationError", err)
+			}
+			if ve.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", ve.StatusCode, tt.status)
+			}
+			if ve.Assertion != tt.assertion {
+				t.Errorf("Assertion = %q, want %q", ve.Assertion, tt.assertion)
+			}
+			if tt.message != "" && ve.Message != tt.message {
+				t.Errorf("Message = %q, want %q", ve.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestParseValidationSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    map[string]any
+		wantErr string
+	}{
+		{name: "empty", spec: nil},
+		{name: "full", spec: map[string]any{
+			"method":  "post",
+			"path":    "/v1/models",
+			"headers": map[string]any{"X-Test": "${GROUP_NAME}"},
+			"success": map[string]any{"status": []any{200}, "json_path": "data", "equals": 1},
+		}},
+		{name: "bad method", spec: map[string]any{"method": "TRACE"}, wantErr: "unsupported validation method"},
+		{name: "relative path", spec: map[string]any{"path": "v1/models"}, wantErr: "must start with /"},
+		{name: "full url", spec: map[string]any{"path": "/https://example.com"}, wantErr: "must not be a full URL"},
+		{name: "blank header", spec: map[string]any{"headers": map[string]any{" ": "x"}}, wantErr: "header name cannot be empty"},
+		{name: "bad status", spec: map[string]any{"success": map[string]any{"status": []any{99}}}, wantErr: "invalid expected status code 99"},
+		{name: "equals without path", spec: map[string]any{"success": map[string]any{"equals": true}}, wantErr: "success.equals requires success.json_path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := datatypes.JSONMap{}
+			if tt.spec != nil {
+				cfg["validation"] = tt.spec
+			}
+			_, err := parseValidationSpec(cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("parseValidationSpec() err = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("parseValidationSpec() err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
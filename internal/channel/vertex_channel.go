@@ -0,0 +1,356 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	app_errors "key-flow/internal/errors"
+	"key-flow/internal/models"
+	"key-flow/internal/utils"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+)
+
+const (
+	defaultVertexLocation         = "us-central1"
+	defaultVertexPublisher        = "google"
+	defaultVertexAPIVersion       = "v1"
+	defaultVertexAnthropicVersion = "vertex-2023-10-16"
+	// vertexProjectPlaceholder marks the project segment to be filled from the key's project_id.
+	vertexProjectPlaceholder = "_"
+)
+
+func init() {
+	Register("vertex", newVertexChannel)
+	RegisterConfigValidator("vertex", validateVertexConfig)
+}
+
+// vertexConfig holds the Vertex AI specific settings stored in the group's channel config.
+type vertexConfig struct {
+	Project          string `json:"project"`
+	Location         string `json:"location"`
+	Publisher        string `json:"publisher"`
+	APIVersion       string `json:"api_version"`
+	TokenURL         string `json:"token_url"`
+	Scope            string `json:"scope"`
+	AnthropicVersion string `json:"anthropic_version"`
+}
+
+// VertexChannel proxies Gemini (and Anthropic publisher) requests to Vertex AI using service-account keys.
+type VertexChannel struct {
+	*GeminiChannel
+	config vertexConfig
+}
+
+func parseVertexConfig(cfg datatypes.JSONMap) (*vertexConfig, error) {
+	var vc vertexConfig
+	if err := decodeChannelConfig(cfg, &vc); err != nil {
+		return nil, err
+	}
+	if vc.Location == "" {
+		vc.Location = defaultVertexLocation
+	}
+	if vc.Publisher == "" {
+		vc.Publisher = defaultVertexPublisher
+	}
+	if vc.APIVersion == "" {
+		vc.APIVersion = defaultVertexAPIVersion
+	}
+	if vc.Scope == "" {
+		vc.Scope = defaultGoogleScope
+	}
+	if vc.AnthropicVersion == "" {
+		vc.AnthropicVersion = defaultVertexAnthropicVersion
+	}
+	for name, value := range map[string]string{"project": vc.Project, "location": vc.Location, "publisher": vc.Publisher, "api_version": vc.APIVersion} {
+		if strings.ContainsAny(value, "/?#") {
+			return nil, fmt.Errorf("invalid %s '%s'", name, value)
+		}
+	}
+	if vc.TokenURL != "" {
+		u, err := url.Parse(vc.TokenURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid token_url '%s'", vc.TokenURL)
+		}
+	}
+	return &vc, nil
+}
+
+func validateVertexConfig(cfg datatypes.JSONMap) error {
+	_, err := parseVertexConfig(cfg)
+	return err
+}
+
+func newVertexChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
+	base, err := f.newBaseChannel("vertex", group)
+	if err != nil {
+		return nil, err
+	}
+
+	vc, err := parseVertexConfig(group.ChannelConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel config for vertex channel: %w", err)
+	}
+
+	return &VertexChannel{
+		GeminiChannel: &GeminiChannel{BaseChannel: base},
+		config:        *vc,
+	}, nil
+}
+
+// modelPath returns the publisher model resource path for the given project.
+func (ch *VertexChannel) modelPath(project, modelAndAction string) string {
+	return fmt.Sprintf("/%s/projects/%s/locations/%s/publishers/%s/models/%s",
+		ch.config.APIVersion, project, ch.config.Location, ch.config.Publisher, modelAndAction)
+}
+
+func (ch *VertexChannel) configuredProject() string {
+	if ch.config.Project != "" {
+		return ch.config.Project
+	}
+	return vertexProjectPlaceholder
+}
+
+// BuildUpstreamURL maps Gemini style paths such as /v1beta/models/{m}:generateContent to
+// Vertex publisher model paths. Native /v1/projects/... paths are passed through.
+func (ch *VertexChannel) BuildUpstreamURL(originalURL *url.URL, groupName string, apiKey *models.APIKey) (string, error) {
+	base := ch.getUpstreamURL(apiKey)
+	if base == nil {
+		return "", ch.noUpstreamError(apiKey)
+	}
+
+	requestPath := strings.TrimPrefix(originalURL.Path, "/proxy/"+groupName)
+	parts := strings.Split(strings.Trim(requestPath, "/"), "/")
+
+	var targetPath string
+	switch {
+	case len(parts) > 1 && parts[1] == "projects":
+		targetPath = requestPath
+	case requestPath == anthropicMessagesPath:
+		// Anthropic Messages requests are routed to the model in ApplyModelRedirect.
+		targetPath = requestPath
+	case len(parts) > 0 && parts[len(parts)-1] == "models":
+		targetPath = fmt.Sprintf("/v1beta1/publishers/%s/models", ch.config.Publisher)
+	default:
+		for i, part := range parts {
+			if part == "models" && i+1 < len(parts) {
+				targetPath = ch.modelPath(ch.configuredProject(), strings.Join(parts[i+1:], "/"))
+				break
+			}
+		}
+		if targetPath == "" {
+			return "", fmt.Errorf("path '%s' is not supported by the vertex channel", requestPath)
+		}
+	}
+
+	finalURL := *base
+	finalURL.Path = strings.TrimRight(finalURL.Path, "/") + targetPath
+
+	query := originalURL.Query()
+	query.Del("key")
+	finalURL.RawQuery = query.Encode()
+
+	return finalURL.String(), nil
+}
+
+// ModifyRequest exchanges the service-account key for an access token and fills in the project.
+func (ch *VertexChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) error {
+	account, err := googleAccounts.get(apiKey.ID, apiKey.Secret())
+	if err != nil {
+		return fmt.Errorf("failed to obtain vertex access token: %w", err)
+	}
+
+	if ch.config.Project == "" && account.ProjectID != "" {
+		placeholder := "/projects/" + vertexProjectPlaceholder + "/"
+		if strings.Contains(req.URL.Path, placeholder) {
+			req.URL.Path = strings.Replace(req.URL.Path, placeholder, "/projects/"+account.ProjectID+"/", 1)
+			req.URL.RawPath = ""
+		}
+	}
+
+	token, err := ch.accessToken(req.Context(), account)
+	if err != nil {
+		return fmt.Errorf("failed to obtain vertex access token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// accessToken returns a cached access token for the service account.
+func (ch *VertexChannel) accessToken(ctx context.Context, account *googleAccount) (string, error) {
+	tokenURL := ch.config.TokenURL
+	if tokenURL == "" {
+		tokenURL = account.TokenURI
+	}
+	if tokenURL == "" {
+		tokenURL = defaultGoogleTokenURL
+	}
+	return googleTokens.GetToken(ctx, ch.HTTPClient, account, tokenURL, ch.config.Scope)
+}
+
+// ApplyModelRedirect converts Anthropic Messages requests to rawPredict calls and otherwise
+// falls back to the Gemini redirect handling.
+func (ch *VertexChannel) ApplyModelRedirect(req *http.Request, bodyBytes []byte, group *models.Group) ([]byte, error) {
+	if !strings.HasSuffix(req.URL.Path, anthropicMessagesPath) {
+		return ch.GeminiChannel.ApplyModelRedirect(req, bodyBytes, group)
+	}
+
+	finalBody, err := ch.BaseChannel.ApplyModelRedirect(req, bodyBytes, group)
+	if err != nil {
+		return nil, err
+	}
+
+	var requestData map[string]any
+	if err := json.Unmarshal(finalBody, &requestData); err != nil {
+		return nil, fmt.Errorf("invalid request body for vertex channel: %w", err)
+	}
+	model, _ := requestData["model"].(string)
+	if model == "" {
+		return nil, fmt.Errorf("model is required for vertex channel")
+	}
+	stream, _ := requestData["stream"].(bool)
+
+	delete(requestData, "model")
+	if _, ok := requestData["anthropic_version"]; !ok {
+		requestData["anthropic_version"] = ch.config.AnthropicVersion
+	}
+	req.Header.Del("anthropic-version")
+
+	action := ":rawPredict"
+	if stream {
+		action = ":streamRawPredict"
+	}
+	prefix := strings.TrimSuffix(req.URL.Path, anthropicMessagesPath)
+	req.URL.Path = prefix + ch.modelPath(ch.configuredProject(), model+action)
+	req.URL.RawPath = ""
+
+	return json.Marshal(requestData)
+}
+
+// TransformModelList converts the Vertex publisher model list into the Gemini native format.
+func (ch *VertexChannel) TransformModelList(req *http.Request, bodyBytes []byte, group *models.Group) (map[string]any, error) {
+	var response map[string]any
+	if err := json.Unmarshal(bodyBytes, &response); err != nil {
+		logrus.WithError(err).Debug("Failed to parse model list response, returning empty")
+		return nil, err
+	}
+
+	if publisherModels, ok := response["publisherModels"].([]any); ok {
+		converted := make([]any, 0, len(publisherModels))
+		for _, item := range publisherModels {
+			modelObj, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			name, _ := modelObj["name"].(string)
+			if idx := strings.LastIndex(name, "/models/"); idx >= 0 {
+				name = name[idx+1:]
+			}
+			converted = append(converted, map[string]any{
+				"name":                       name,
+				"displayName":                strings.TrimPrefix(name, "models/"),
+				"supportedGenerationMethods": []string{"generateContent"},
+			})
+		}
+		delete(response, "publisherModels")
+		response["models"] = converted
+	}
+
+	if modelsInterface, hasModels := response["models"]; hasModels {
+		return ch.transformGeminiNativeFormat(req, response, modelsInterface, group), nil
+	}
+
+	return response, nil
+}
+
+// ValidateKey checks if the service-account key is valid by minting a token and calling the test model.
+func (ch *VertexChannel) ValidateKey(ctx context.Context, apiKey *models.APIKey, group *models.Group) (bool, error) {
+	upstreamURL := ch.getUpstreamURL(apiKey)
+	if upstreamURL == nil {
+		return false, ch.noUpstreamError(apiKey)
+	}
+
+	account, err := googleAccounts.get(apiKey.ID, apiKey.Secret())
+	if err != nil {
+		return false, err
+	}
+	project := ch.config.Project
+	if project == "" {
+		project = account.ProjectID
+	}
+	if project == "" {
+		return false, fmt.Errorf("no project configured and service account has no project_id")
+	}
+
+	token, err := ch.accessToken(ctx, account)
+	if err != nil {
+		var ve *app_errors.ValidationError
+		if errors.As(err, &ve) {
+			return false, ve
+		}
+		return false, fmt.Errorf("failed to obtain access token: %w", err)
+	}
+
+	var payload gin.H
+	action := ":generateContent"
+	if ch.config.Publisher == "anthropic" {
+		action = ":rawPredict"
+		payload = gin.H{
+			"anthropic_version": ch.config.AnthropicVersion,
+			"max_tokens":        1,
+			"messages": []gin.H{
+				{"role": "user", "content": "hi"},
+			},
+		}
+	} else {
+		payload = gin.H{
+			"contents": []gin.H{
+				{
+					"role": "user",
+					"parts": []gin.H{
+						{"text": "hi"},
+					},
+				},
+			},
+		}
+	}
+
+	finalURL := *upstreamURL
+	finalURL.Path = strings.TrimRight(finalURL.Path, "/") + ch.modelPath(project, ch.TestModel+action)
+	finalURL.RawQuery = ""
+
+	headerCtx := utils.NewHeaderVariableContext(group, apiKey)
+	body, err := ch.validation.payload(payload, ch.TestModel, headerCtx)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", finalURL.String(), bytes.NewBuffer(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create validation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	ch.validation.applyHeaders(req, headerCtx)
+
+	// Apply custom header rules if available
+	if len(group.HeaderRuleList) > 0 {
+		utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
+	}
+
+	resp, err := ch.HTTPClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to send validation request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return ch.validation.evaluate(resp)
+}
@@ -0,0 +1,157 @@
+package channel
+
+import (
+	"key-flow/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gorm.io/datatypes"
+)
+
+// newTestVertexChannel 按渠道配置构造指向 upstream 的 Vertex 渠道
+func newTestVertexChannel(t *testing.T, upstream string, cfg datatypes.JSONMap) *VertexChannel {
+	t.Helper()
+	u, err := url.Parse(upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vc, err := parseVertexConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &VertexChannel{
+		GeminiChannel: &GeminiChannel{BaseChannel: &BaseChannel{
+			Name:       "vertex",
+			Upstreams:  []UpstreamInfo{{URL: u, Weight: 1}},
+			HTTPClient: http.DefaultClient,
+		}},
+		config: *vc,
+	}
+}
+
+func TestVertexBuildUpstreamURL(t *testing.T) {
+	const upstream = "https://us-central1-aiplatform.googleapis.com"
+	tests := []struct {
+		name    string
+		cfg     datatypes.JSONMap
+		request string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "gemini path uses project placeholder",
+			request: "/proxy/vertex/v1beta/models/gemini-2.0-flash:generateContent?key=abc",
+			want:    upstream + "/v1/projects/_/locations/us-central1/publishers/google/models/gemini-2.0-flash:generateContent",
+		},
+		{
+			name:    "stream query kept",
+			request: "/proxy/vertex/v1beta/models/gemini-2.0-flash:streamGenerateContent?alt=sse&key=abc",
+			want:    upstream + "/v1/projects/_/locations/us-central1/publishers/google/models/gemini-2.0-flash:streamGenerateContent?alt=sse",
+		},
+		{
+			name:    "configured project",
+			cfg:     datatypes.JSONMap{"project": "my-project"},
+			request: "/proxy/vertex/v1beta/models/gemini-2.0-flash:countTokens",
+			want:    upstream + "/v1/projects/my-project/locations/us-central1/publishers/google/models/gemini-2.0-flash:countTokens",
+		},
+		{
+			name:    "custom location publisher and version",
+			cfg:     datatypes.JSONMap{"project": "p", "location": "europe-west4", "publisher": "anthropic", "api_version": "v1beta1"},
+			request: "/proxy/vertex/v1/models/claude-sonnet-4:rawPredict",
+			want:    upstream + "/v1beta1/projects/p/locations/europe-west4/publishers/anthropic/models/claude-sonnet-4:rawPredict",
+		},
+		{
+			name:    "native project path passed through",
+			cfg:     datatypes.JSONMap{"project": "configured"},
+			request: "/proxy/vertex/v1/projects/other/locations/asia-east1/publishers/google/models/gemini-pro:generateContent",
+			want:    upstream + "/v1/projects/other/locations/asia-east1/publishers/google/models/gemini-pro:generateContent",
+		},
+		{
+			name:    "model list",
+			request: "/proxy/vertex/v1beta/models?key=abc",
+			want:    upstream + "/v1beta1/publishers/google/models",
+		},
+		{
+			name:    "unsupported path",
+			request: "/proxy/vertex/v1beta/files",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := newTestVertexChannel(t, upstream, tt.cfg)
+			original, err := url.Parse(tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ch.BuildUpstreamURL(original, "vertex", &models.APIKey{})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("BuildUpstreamURL() = %s, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("BuildUpstreamURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVertexModifyRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      datatypes.JSONMap
+		path     string
+		wantPath string
+	}{
+		{
+			name:     "placeholder filled from service account",
+			path:     "/v1/projects/_/locations/us-central1/publishers/google/models/gemini-pro:generateContent",
+			wantPath: "/v1/projects/sa-project/locations/us-central1/publishers/google/models/gemini-pro:generateContent",
+		},
+		{
+			name:     "configured project skips substitution",
+			cfg:      datatypes.JSONMap{"project": "cfg-project"},
+			path:     "/v1/projects/_/locations/us-central1/publishers/google/models/gemini-pro:generateContent",
+			wantPath: "/v1/projects/_/locations/us-central1/publishers/google/models/gemini-pro:generateContent",
+		},
+		{
+			name:     "explicit project left alone",
+			path:     "/v1/projects/other/locations/us-central1/publishers/google/models/gemini-pro:generateContent",
+			wantPath: "/v1/projects/other/locations/us-central1/publishers/google/models/gemini-pro:generateContent",
+		},
+		{
+			name:     "model list has no project",
+			path:     "/v1beta1/publishers/google/models",
+			wantPath: "/v1beta1/publishers/google/models",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// service account 的 token_uri 指向本地端点，不配置 token_url
+			privateKey := newTestServiceAccountKey(t)
+			srv := newTestGoogleTokenServer(t, &privateKey.PublicKey)
+			sa := testServiceAccountJSON(t, privateKey, srv.URL)
+
+			ch := newTestVertexChannel(t, "https://us-central1-aiplatform.googleapis.com", tt.cfg)
+			req := httptest.NewRequest(http.MethodPost, "https://us-central1-aiplatform.googleapis.com"+tt.path, nil)
+			if err := ch.ModifyRequest(req, &models.APIKey{KeyValue: sa}, &models.Group{Name: "vertex"}); err != nil {
+				t.Fatal(err)
+			}
+			if req.URL.Path != tt.wantPath {
+				t.Errorf("path = %s, want %s", req.URL.Path, tt.wantPath)
+			}
+			if got := req.Header.Get("Authorization"); got != "Bearer token-1" {
+				t.Errorf("Authorization = %q, want Bearer token-1", got)
+			}
+		})
+	}
+}
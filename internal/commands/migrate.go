@@ -285,7 +285,7 @@ func (cmd *MigrateKeysCommand) detectIfAlreadyEncrypted() error {
 
 	for _, key := range sampleKeys {
 		// For unencrypted data: key_hash should match SHA256(key_value)
-		expectedHash := noopService.Hash(key.KeyValue)
+		expectedHash := noopService.Hash(models.CanonicalKeyValue(key.KeyValue))
 		if expectedHash == key.KeyHash {
 			hashConsistentCount++
 		}
@@ -313,7 +313,7 @@ func (cmd *MigrateKeysCommand) detectIfAlreadyEncrypted() error {
 				decrypted, err := targetService.Decrypt(key.KeyValue)
 				if err == nil {
 					// Verify hash matches
-					expectedHash := targetService.Hash(decrypted)
+					expectedHash := targetService.Hash(models.CanonicalKeyValue(decrypted))
 					if expectedHash == key.KeyHash {
 						canDecryptCount++
 					}
@@ -537,7 +537,7 @@ func (cmd *MigrateKeysCommand) processBatchToTempTable(keys []models.APIKey, old
 		}
 
 		// 3. Generate new hash using new service
-		newHash := newService.Hash(decrypted)
+		newHash := newService.Hash(models.CanonicalKeyValue(decrypted))
 
 		tempRecords = append(tempRecords, TempMigration{
 			ID:          key.ID,
@@ -642,7 +642,8 @@ func (cmd *MigrateKeysCommand) switchColumns() error {
 				UPDATE api_keys a
 				INNER JOIN temp_migration t ON a.id = t.id
 				SET a.key_value = t.key_value_new,
-				    a.key_hash = t.key_hash_new
+				    a.key_hash = t.key_hash_new,
+				    a.updated_at = CURRENT_TIMESTAMP
 			`
 
 		case "postgres":
@@ -650,7 +651,8 @@ func (cmd *MigrateKeysCommand) switchColumns() error {
 			updateSQL = `
 				UPDATE api_keys
 				SET key_value = t.key_value_new,
-				    key_hash = t.key_hash_new
+				    key_hash = t.key_hash_new,
+				    updated_at = CURRENT_TIMESTAMP
 				FROM temp_migration t
 				WHERE api_keys.id = t.id
 			`
@@ -660,7 +662,8 @@ func (cmd *MigrateKeysCommand) switchColumns() error {
 			updateSQL = `
 				UPDATE api_keys
 				SET key_value = (SELECT key_value_new FROM temp_migration WHERE temp_migration.id = api_keys.id),
-				    key_hash = (SELECT key_hash_new FROM temp_migration WHERE temp_migration.id = api_keys.id)
+				    key_hash = (SELECT key_hash_new FROM temp_migration WHERE temp_migration.id = api_keys.id),
+				    updated_at = CURRENT_TIMESTAMP
 				WHERE EXISTS (SELECT 1 FROM temp_migration WHERE temp_migration.id = api_keys.id)
 			`
 
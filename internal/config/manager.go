@@ -49,6 +49,8 @@ type Config struct {
 	Log           types.LogConfig
 	Database      types.DatabaseConfig
 	RedisDSN      string
+	StoreType     string
+	Snapshot      types.SnapshotConfig
 	EncryptionKey string
 }
 
@@ -69,9 +71,15 @@ func (m *Manager) ReloadConfig() error {
 		logrus.Info("Info: Create .env file to support environment variable configuration")
 	}
 
+	// 选举需通过 LEADER_ELECTION=true 显式开启，未开启时与此前一样由 IS_SLAVE 决定主从（默认主节点）。
+	// 开启后未设置 IS_SLAVE 的节点参与选举，设置后固定为主节点（false）或从节点（true）
+	isSlave := os.Getenv("IS_SLAVE")
+
 	config := &Config{
 		Server: types.ServerConfig{
-			IsMaster:                !utils.ParseBoolean(os.Getenv("IS_SLAVE"), false),
+			IsMaster:                !utils.ParseBoolean(isSlave, false),
+			LeaderElection:          utils.ParseBoolean(os.Getenv("LEADER_ELECTION"), false),
+			RolePinned:              strings.TrimSpace(isSlave) != "",
 			Port:                    utils.ParseInteger(os.Getenv("PORT"), 3001),
 			Host:                    utils.GetEnvOrDefault("HOST", "0.0.0.0"),
 			ReadTimeout:             utils.ParseInteger(os.Getenv("SERVER_READ_TIMEOUT"), 60),
@@ -101,7 +109,12 @@ func (m *Manager) ReloadConfig() error {
 		Database: types.DatabaseConfig{
 			DSN: utils.GetEnvOrDefault("DATABASE_DSN", "./data/key-flow.db"),
 		},
-		RedisDSN:      os.Getenv("REDIS_DSN"),
+		RedisDSN:  os.Getenv("REDIS_DSN"),
+		StoreType: strings.ToLower(strings.TrimSpace(os.Getenv("STORE_TYPE"))),
+		Snapshot: types.SnapshotConfig{
+			Interval: utils.ParseInteger(os.Getenv("MEMORY_SNAPSHOT_INTERVAL"), 0),
+			Path:     utils.GetEnvOrDefault("MEMORY_SNAPSHOT_PATH", "./data/memory-store.snapshot"),
+		},
 		EncryptionKey: os.Getenv("ENCRYPTION_KEY"),
 	}
 	m.config = config
@@ -144,6 +157,16 @@ func (m *Manager) GetRedisDSN() string {
 	return m.config.RedisDSN
 }
 
+// GetStoreType returns the configured store backend. Empty means Redis when REDIS_DSN is set, otherwise memory.
+func (m *Manager) GetStoreType() string {
+	return m.config.StoreType
+}
+
+// GetSnapshotConfig returns the memory store snapshot configuration.
+func (m *Manager) GetSnapshotConfig() types.SnapshotConfig {
+	return m.config.Snapshot
+}
+
 // GetDatabaseConfig returns the database configuration.
 func (m *Manager) GetDatabaseConfig() types.DatabaseConfig {
 	return m.config.Database
@@ -185,6 +208,16 @@ func (m *Manager) Validate() error {
 		m.config.Server.GracefulShutdownTimeout = 10
 	}
 
+	switch m.config.StoreType {
+	case "", "memory", "database":
+	case "redis":
+		if m.config.RedisDSN == "" {
+			validationErrors = append(validationErrors, "STORE_TYPE is redis but REDIS_DSN is not set")
+		}
+	default:
+		validationErrors = append(validationErrors, fmt.Sprintf("unsupported STORE_TYPE %q, expected redis, database or memory", m.config.StoreType))
+	}
+
 	if m.config.CORS.Enabled {
 		if len(m.config.CORS.AllowedOrigins) == 0 {
 			validationErrors = append(validationErrors, "CORS is enabled but ALLOWED_ORIGINS is not set. UI will not work from a browser.")
@@ -218,6 +251,18 @@ func (m *Manager) DisplayServerConfig() {
 	logrus.Info("======= Server Configuration =======")
 	logrus.Info("  --- Server ---")
 	logrus.Infof("    Listen Address: %s:%d", serverConfig.Host, serverConfig.Port)
+	switch {
+	case !serverConfig.LeaderElection && serverConfig.IsMaster:
+		logrus.Info("    Node Role: master")
+	case !serverConfig.LeaderElection:
+		logrus.Info("    Node Role: slave")
+	case !serverConfig.RolePinned:
+		logrus.Info("    Node Role: elected (leader election enabled)")
+	case serverConfig.IsMaster:
+		logrus.Info("    Node Role: master (pinned by IS_SLAVE=false, leader election enabled)")
+	default:
+		logrus.Info("    Node Role: slave (pinned by IS_SLAVE=true, leader election enabled)")
+	}
 	logrus.Infof("    Graceful Shutdown Timeout: %d seconds", serverConfig.GracefulShutdownTimeout)
 	logrus.Infof("    Read Timeout: %d seconds", serverConfig.ReadTimeout)
 	logrus.Infof("    Write Timeout: %d seconds", serverConfig.WriteTimeout)
@@ -258,6 +303,12 @@ func (m *Manager) DisplayServerConfig() {
 	} else {
 		logrus.Info("    Redis: not configured")
 	}
+	if storeType := m.GetStoreType(); storeType != "" {
+		logrus.Infof("    Store: %s", storeType)
+	}
+	if snapshot := m.GetSnapshotConfig(); snapshot.Interval > 0 {
+		logrus.Infof("    Memory Snapshot: every %d seconds to %s", snapshot.Interval, snapshot.Path)
+	}
 	logrus.Info("====================================")
 	logrus.Info("")
 }
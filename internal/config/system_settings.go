@@ -12,8 +12,10 @@ import (
 	"key-flow/internal/utils"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/datatypes"
@@ -37,7 +39,8 @@ type groupManager interface {
 }
 
 // Initialize initializes the SystemSettingsManager with database and store dependencies.
-func (sm *SystemSettingsManager) Initialize(store store.Store, gm groupManager, isMaster bool) error {
+// isLeader reports whether this node currently holds leadership and should invalidate the group cache.
+func (sm *SystemSettingsManager) Initialize(store store.Store, gm groupManager, isLeader func() bool) error {
 	settingsLoader := func() (types.SystemSettings, error) {
 		var dbSettings []models.SystemSetting
 		if err := db.DB.Find(&dbSettings).Error; err != nil {
@@ -81,7 +84,7 @@ func (sm *SystemSettingsManager) Initialize(store store.Store, gm groupManager,
 	}
 
 	afterLoader := func(newData types.SystemSettings) {
-		if !isMaster {
+		if !isLeader() {
 			return
 		}
 		gm.Invalidate()
@@ -306,6 +309,9 @@ func (sm *SystemSettingsManager) ValidateSettings(settingsMap map[string]any) er
 						return fmt.Errorf("value for %s is required", key)
 					}
 				}
+				if err := validateStringFormat(key, trimmedRule, strVal); err != nil {
+					return err
+				}
 			}
 		default:
 			return fmt.Errorf("unsupported type for setting key validation: %s", key)
@@ -315,6 +321,31 @@ func (sm *SystemSettingsManager) ValidateSettings(settingsMap map[string]any) er
 	return nil
 }
 
+// validateStringFormat checks the format rules of string settings: clock (HH:MM), timezone (IANA name)
+// and oneof (space separated allowed values).
+func validateStringFormat(key, rule, value string) error {
+	if value == "" {
+		return nil
+	}
+	if allowed, ok := strings.CutPrefix(rule, "oneof="); ok {
+		if !slices.Contains(strings.Fields(allowed), value) {
+			return fmt.Errorf("invalid value for %s: must be one of %s", key, strings.Join(strings.Fields(allowed), ", "))
+		}
+		return nil
+	}
+	switch rule {
+	case "clock":
+		if _, err := time.Parse("15:04", value); err != nil {
+			return fmt.Errorf("invalid value for %s: expected a time of day in HH:MM format", key)
+		}
+	case "timezone":
+		if _, err := time.LoadLocation(value); err != nil {
+			return fmt.Errorf("invalid value for %s: unknown time zone %q", key, value)
+		}
+	}
+	return nil
+}
+
 // ValidateGroupConfigOverrides validates a map of group-level configuration overrides.
 func (sm *SystemSettingsManager) ValidateGroupConfigOverrides(configMap map[string]any) error {
 	tempSettings := types.SystemSettings{}
@@ -376,6 +407,9 @@ func (sm *SystemSettingsManager) ValidateGroupConfigOverrides(configMap map[stri
 						return fmt.Errorf("value for %s is required", key)
 					}
 				}
+				if err := validateStringFormat(key, trimmedRule, strVal); err != nil {
+					return err
+				}
 			}
 		case reflect.Bool:
 			_, ok := value.(bool)
@@ -6,6 +6,7 @@ import (
 	"key-flow/internal/channel"
 	"key-flow/internal/config"
 	"key-flow/internal/db"
+	"key-flow/internal/election"
 	"key-flow/internal/encryption"
 	"key-flow/internal/handler"
 	"key-flow/internal/httpclient"
@@ -41,6 +42,12 @@ func BuildContainer() (*dig.Container, error) {
 	if err := container.Provide(store.NewStore); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(election.NewElector); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(store.NewSnapshotter); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(httpclient.NewHTTPClientManager); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,502 @@
+// Package election elects one node of a cluster as leader through a leased lock in the shared store.
+package election
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"key-flow/internal/store"
+	"key-flow/internal/types"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	leaderLockKey  = "leader:lock"
+	leaderEpochKey = "leader:epoch"
+
+	// leaseTTL 是锁在 store 中的有效期，leader 宕机后最多经过该时间由其他节点接管
+	leaseTTL = 15 * time.Second
+	// renewInterval 是 leader 续约的间隔
+	renewInterval = leaseTTL / 3
+	// leaseSafetyMargin 使本地认为的租约比 store 中的锁提前到期，
+	// 续约失败的旧 leader 会在新 leader 拿到锁之前停止 leader 服务
+	leaseSafetyMargin = 2 * time.Second
+	// checkInterval 是检查租约到期和尝试竞选的间隔
+	checkInterval = time.Second
+	// serviceStopTimeout 是失去 leader 身份时停止 leader 服务的超时时间
+	serviceStopTimeout = 10 * time.Second
+)
+
+// Mode 表示节点参与 leader 选举的方式
+type Mode string
+
+const (
+	// ModeElection 节点参与选举，持有锁时为 leader
+	ModeElection Mode = "election"
+	// ModeMaster 节点固定为 leader：未开启选举时的主节点，或开启选举时设置了 IS_SLAVE=false 的节点
+	ModeMaster Mode = "master"
+	// ModeSlave 节点从不成为 leader（IS_SLAVE=true）
+	ModeSlave Mode = "slave"
+)
+
+// ErrMasterConflict is returned when another node pinned as master holds the leader lock.
+var ErrMasterConflict = errors.New("another node pinned as master holds the leader lock")
+
+// LeaderService 是只在 leader 节点运行的后台服务，失去和重新获得 leader 身份时会被多次停止和启动。
+// 启动时传入本次 leader 任期的 Fence，服务在每次只能由 leader 执行的写入前检查它。
+type LeaderService interface {
+	Start(fence Fence)
+	Stop(ctx context.Context)
+}
+
+// Fence 是 leader 服务启动时获得的 fencing token。新的 leader 拿到锁后旧任期的 Fence 立即失效，
+// 续约失败但尚未停止服务的旧 leader 不会再写入
+type Fence struct {
+	elector *Elector
+	token   int64
+}
+
+// Token returns the fencing token of the leader term the services were started in.
+func (f Fence) Token() int64 {
+	return f.token
+}
+
+// Valid reports whether this node still holds the leader lock with the fence's token.
+func (f Fence) Valid() bool {
+	return f.elector != nil && f.elector.verify(f.token)
+}
+
+// Lease 是写入锁中的持有者信息
+type Lease struct {
+	NodeID     string    `json:"node_id"`
+	Token      int64     `json:"token"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	// Pinned 表示持有者由 IS_SLAVE=false 固定为 master，选举出的 leader 不会覆盖它
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// Status 是节点的 leader 选举状态
+type Status struct {
+	NodeID   string `json:"node_id"`
+	Mode     Mode   `json:"mode"`
+	IsLeader bool   `json:"is_leader"`
+	// Token 是本节点获得 leader 身份时分配的 fencing token，每次选出新 leader 时递增
+	Token  int64  `json:"token,omitempty"`
+	Leader *Lease `json:"leader,omitempty"`
+}
+
+// leaseTiming 是租约的时长设置，测试中使用更短的时长
+type leaseTiming struct {
+	ttl           time.Duration
+	renewInterval time.Duration
+	safetyMargin  time.Duration
+}
+
+var defaultLeaseTiming = leaseTiming{ttl: leaseTTL, renewInterval: renewInterval, safetyMargin: leaseSafetyMargin}
+
+// Elector 在 store 中竞争 leader 锁，并随 leader 身份的变化启动和停止 leader 服务
+type Elector struct {
+	store    store.Store
+	mode     Mode
+	election bool // 是否开启了选举，未开启时角色固定，不读写 leader 锁
+	nodeID   string
+	timing   leaseTiming
+	services []LeaderService
+
+	mu         sync.Mutex
+	lease      []byte // 本节点持有的锁内容，未持有时为 nil
+	token      int64
+	validUntil time.Time
+	lastRenew  time.Time
+	running    bool // leader 服务是否已启动
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewElector creates an Elector. Leader election is opt-in through LEADER_ELECTION; without it the node
+// keeps the fixed role from IS_SLAVE (master by default) and never touches the leader lock.
+// With it, unset IS_SLAVE joins the election, false pins the node as leader and true keeps it a follower.
+func NewElector(configManager types.ConfigManager, store store.Store) *Elector {
+	serverConfig := configManager.GetEffectiveServerConfig()
+	mode := ModeSlave
+	if serverConfig.IsMaster {
+		mode = ModeMaster
+	}
+	if serverConfig.LeaderElection && !serverConfig.RolePinned {
+		mode = ModeElection
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "node"
+	}
+
+	return &Elector{
+		store:    store,
+		mode:     mode,
+		election: serverConfig.LeaderElection,
+		nodeID:   fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8]),
+		timing:   defaultLeaseTiming,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Register adds services that run only while this node is the leader. It must be called before Start.
+func (e *Elector) Register(services ...LeaderService) {
+	e.services = append(e.services, services...)
+}
+
+// NodeID returns the identifier this node uses in the leader lock.
+func (e *Elector) NodeID() string {
+	return e.nodeID
+}
+
+// IsLeader reports whether this node is the leader. In election mode it is true only
+// while the lease is locally valid, so a node that cannot renew steps down on its own.
+func (e *Elector) IsLeader() bool {
+	switch e.mode {
+	case ModeMaster:
+		return true
+	case ModeSlave:
+		return false
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.holdsLease(time.Now())
+}
+
+// holdsLease 调用方需持有 mu
+func (e *Elector) holdsLease(now time.Time) bool {
+	return e.lease != nil && now.Before(e.validUntil)
+}
+
+// verify 检查本节点是否仍以 token 持有 leader 锁。除本地租约外还读取 store 中的锁，
+// 被新 leader 或固定的 master 接管后立即返回 false；store 暂时不可用时按本地租约判断
+func (e *Elector) verify(token int64) bool {
+	if !e.election {
+		return e.mode == ModeMaster
+	}
+
+	e.mu.Lock()
+	lease := e.lease
+	held := lease != nil && e.token == token && e.holdsLease(time.Now())
+	e.mu.Unlock()
+	if !held {
+		return false
+	}
+
+	value, err := e.store.Get(leaderLockKey)
+	if err != nil {
+		return !errors.Is(err, store.ErrNotFound)
+	}
+	return bytes.Equal(value, lease)
+}
+
+// Campaign makes a single attempt to acquire the leader lock and reports whether this node
+// is the leader afterwards. It is used at startup to decide which node bootstraps the cluster.
+// A node pinned as master takes the lock over from an elected leader and returns
+// ErrMasterConflict when another pinned master holds it.
+func (e *Elector) Campaign() (bool, error) {
+	if e.mode == ModeSlave {
+		return false, nil
+	}
+	if !e.election {
+		return true, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.acquire(); err != nil {
+		if errors.Is(err, ErrMasterConflict) {
+			return false, err
+		}
+		logrus.WithError(err).Warn("Leader election: failed to acquire leader lock")
+	}
+	if e.mode == ModeMaster {
+		return true, nil
+	}
+	return e.holdsLease(time.Now()), nil
+}
+
+// Reassert writes back the lease this node holds, e.g. after the store has been cleared during bootstrap.
+func (e *Elector) Reassert() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.lease == nil {
+		return nil
+	}
+	if err := e.store.Set(leaderLockKey, e.lease, e.timing.ttl); err != nil {
+		return fmt.Errorf("failed to reassert leader lock: %w", err)
+	}
+	// 恢复 epoch，保证之后选出的 leader 的 token 仍然递增
+	if _, err := e.store.HIncrBy(leaderEpochKey, "epoch", e.token); err != nil {
+		return fmt.Errorf("failed to restore leader epoch: %w", err)
+	}
+	e.lastRenew = time.Now()
+	e.validUntil = e.lastRenew.Add(e.timing.ttl - e.timing.safetyMargin)
+	return nil
+}
+
+// acquire 尝试获取锁，已持有时直接返回。固定的 master 优先于选举出的 leader，
+// 直接覆盖锁，原 leader 续约失败后停止 leader 服务。调用方需持有 mu
+func (e *Elector) acquire() error {
+	if e.lease != nil {
+		return nil
+	}
+
+	current, err := e.currentLease()
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		if e.mode != ModeMaster {
+			return nil
+		}
+		if current.Pinned && current.NodeID != e.nodeID {
+			return fmt.Errorf("%w: %s", ErrMasterConflict, current.NodeID)
+		}
+	}
+
+	// 先分配 token 再抢占锁，竞争失败的节点只会在 epoch 中留下空号，token 仍然单调递增
+	token, err := e.store.HIncrBy(leaderEpochKey, "epoch", 1)
+	if err != nil {
+		return fmt.Errorf("failed to allocate fencing token: %w", err)
+	}
+	now := time.Now()
+	lease, err := json.Marshal(Lease{NodeID: e.nodeID, Token: token, AcquiredAt: now, Pinned: e.mode == ModeMaster})
+	if err != nil {
+		return fmt.Errorf("failed to marshal leader lease: %w", err)
+	}
+	if e.mode == ModeMaster {
+		if err := e.store.Set(leaderLockKey, lease, e.timing.ttl); err != nil {
+			return err
+		}
+		if current != nil {
+			logrus.WithField("previous_leader", current.NodeID).Warn("Leader election: node is pinned as master, took over the leader lock")
+		}
+	} else {
+		ok, err := e.store.SetNX(leaderLockKey, lease, e.timing.ttl)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	e.lease = lease
+	e.token = token
+	e.lastRenew = now
+	e.validUntil = now.Add(e.timing.ttl - e.timing.safetyMargin)
+	logrus.WithFields(logrus.Fields{"node_id": e.nodeID, "token": token}).Info("Leader election: acquired leader lock")
+	return nil
+}
+
+// currentLease 读取 store 中锁的持有者，锁不存在时返回 nil。调用方需持有 mu
+func (e *Elector) currentLease() (*Lease, error) {
+	value, err := e.store.Get(leaderLockKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lease Lease
+	if err := json.Unmarshal(value, &lease); err != nil {
+		// 无法解析的锁视为由其他节点持有
+		return &Lease{}, nil
+	}
+	return &lease, nil
+}
+
+// renew 续约已持有的锁，锁已被其他节点持有时放弃。调用方需持有 mu
+func (e *Elector) renew(now time.Time) {
+	ok, err := e.store.RenewLease(leaderLockKey, e.lease, e.timing.ttl)
+	if err != nil {
+		// store 暂时不可用时保留租约，直到本地到期
+		logrus.WithError(err).Warn("Leader election: failed to renew leader lock")
+		return
+	}
+	if !ok {
+		logrus.WithField("token", e.token).Warn("Leader election: leader lock was lost")
+		e.lease = nil
+		return
+	}
+	e.lastRenew = now
+	e.validUntil = now.Add(e.timing.ttl - e.timing.safetyMargin)
+}
+
+// checkLock 确认 store 中的锁仍由本节点持有。固定的 master 可能随时接管锁，
+// 不等到下次续约就停止 leader 服务。调用方需持有 mu
+func (e *Elector) checkLock() {
+	value, err := e.store.Get(leaderLockKey)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return
+	}
+	if err == nil && bytes.Equal(value, e.lease) {
+		return
+	}
+	logrus.WithField("token", e.token).Warn("Leader election: leader lock was taken over")
+	e.lease = nil
+}
+
+// Start starts the leader services if this node is the leader and keeps campaigning
+// or renewing the lease in the background.
+func (e *Elector) Start() {
+	if e.mode == ModeSlave {
+		logrus.Info("Leader election: node is pinned as slave, leader services will not run here.")
+		return
+	}
+	if !e.election {
+		// 未开启选举时主节点固定运行 leader 服务，不使用 leader 锁
+		e.mu.Lock()
+		e.running = true
+		e.mu.Unlock()
+		fence := Fence{elector: e}
+		for _, s := range e.services {
+			s.Start(fence)
+		}
+		return
+	}
+
+	e.reconcile()
+	e.wg.Add(1)
+	go e.run()
+}
+
+func (e *Elector) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.reconcile()
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// reconcile 续约或竞选，并使 leader 服务的运行状态与 leader 身份一致
+func (e *Elector) reconcile() {
+	e.mu.Lock()
+	now := time.Now()
+	switch {
+	case e.lease == nil:
+		if err := e.acquire(); errors.Is(err, ErrMasterConflict) {
+			logrus.WithError(err).Error("Leader election: more than one node is pinned as master")
+		} else if err != nil {
+			logrus.WithError(err).Warn("Leader election: failed to acquire leader lock")
+		}
+	case now.Sub(e.lastRenew) >= e.timing.renewInterval:
+		e.renew(now)
+	default:
+		e.checkLock()
+	}
+	if e.lease != nil && !e.holdsLease(now) {
+		logrus.WithField("token", e.token).Warn("Leader election: lease expired before it could be renewed")
+		e.lease = nil
+	}
+
+	leading := e.mode == ModeMaster || e.holdsLease(now)
+	changed := leading != e.running
+	e.running = leading
+	token := e.token
+	e.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if leading {
+		logrus.WithFields(logrus.Fields{"node_id": e.nodeID, "token": token}).Info("Leader election: became leader, starting leader services")
+		fence := Fence{elector: e, token: token}
+		for _, s := range e.services {
+			s.Start(fence)
+		}
+		return
+	}
+
+	logrus.WithField("node_id", e.nodeID).Warn("Leader election: lost leadership, stopping leader services")
+	ctx, cancel := context.WithTimeout(context.Background(), serviceStopTimeout)
+	defer cancel()
+	e.stopServices(ctx)
+}
+
+// stopServices 并发停止所有 leader 服务
+func (e *Elector) stopServices(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, s := range e.services {
+		wg.Add(1)
+		go func(s LeaderService) {
+			defer wg.Done()
+			s.Stop(ctx)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// Stop stops campaigning, stops the leader services if they are running and releases the lock
+// so that another node can take over without waiting for the lease to expire.
+func (e *Elector) Stop(ctx context.Context) {
+	if e.mode == ModeSlave {
+		return
+	}
+
+	close(e.stopCh)
+	e.wg.Wait()
+
+	e.mu.Lock()
+	running := e.running
+	e.running = false
+	e.mu.Unlock()
+
+	if running {
+		e.stopServices(ctx)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lease == nil {
+		return
+	}
+	if _, err := e.store.ReleaseLease(leaderLockKey, e.lease); err != nil {
+		logrus.WithError(err).Warn("Leader election: failed to release leader lock")
+	} else {
+		logrus.Info("Leader election: released leader lock.")
+	}
+	e.lease = nil
+}
+
+// Status returns the election state of this node and the current holder of the leader lock.
+func (e *Elector) Status() Status {
+	status := Status{
+		NodeID:   e.nodeID,
+		Mode:     e.mode,
+		IsLeader: e.IsLeader(),
+	}
+
+	e.mu.Lock()
+	if e.holdsLease(time.Now()) {
+		status.Token = e.token
+	}
+	e.mu.Unlock()
+
+	if value, err := e.store.Get(leaderLockKey); err == nil {
+		var lease Lease
+		if json.Unmarshal(value, &lease) == nil {
+			status.Leader = &lease
+		}
+	}
+	return status
+}
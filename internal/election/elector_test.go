@@ -0,0 +1,263 @@
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"key-flow/internal/store"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTiming 缩短租约，使到期和续约在测试中可以快速发生
+var testTiming = leaseTiming{
+	ttl:           600 * time.Millisecond,
+	renewInterval: 200 * time.Millisecond,
+	safetyMargin:  100 * time.Millisecond,
+}
+
+func newTestElector(s store.Store, nodeID string, mode Mode) *Elector {
+	return &Elector{
+		store:    s,
+		mode:     mode,
+		election: true,
+		nodeID:   nodeID,
+		timing:   testTiming,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// recordingService 记录 leader 服务的启停和每次启动时获得的 Fence
+type recordingService struct {
+	mu      sync.Mutex
+	running bool
+	fences  []Fence
+}
+
+func (r *recordingService) Start(fence Fence) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = true
+	r.fences = append(r.fences, fence)
+}
+
+func (r *recordingService) Stop(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = false
+}
+
+func (r *recordingService) state() (bool, Fence) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.fences) == 0 {
+		return r.running, Fence{}
+	}
+	return r.running, r.fences[len(r.fences)-1]
+}
+
+func readLease(t *testing.T, s store.Store) *Lease {
+	t.Helper()
+	value, err := s.Get(leaderLockKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lease Lease
+	if err := json.Unmarshal(value, &lease); err != nil {
+		t.Fatal(err)
+	}
+	return &lease
+}
+
+func campaign(t *testing.T, e *Elector) bool {
+	t.Helper()
+	ok, err := e.Campaign()
+	if err != nil {
+		t.Fatalf("%s: Campaign() err = %v", e.nodeID, err)
+	}
+	return ok
+}
+
+func TestElectorFirstAcquire(t *testing.T) {
+	s := store.NewMemoryStore()
+	a := newTestElector(s, "a", ModeElection)
+	b := newTestElector(s, "b", ModeElection)
+
+	if !campaign(t, a) {
+		t.Fatal("first node should acquire the free lock")
+	}
+	if campaign(t, b) {
+		t.Fatal("second node should not acquire a held lock")
+	}
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("IsLeader: a = %v, b = %v", a.IsLeader(), b.IsLeader())
+	}
+
+	lease := readLease(t, s)
+	if lease == nil || lease.NodeID != "a" || lease.Token != 1 || lease.Pinned {
+		t.Fatalf("lock = %+v, want node a with token 1", lease)
+	}
+	// 已持有时再次竞选不会重新分配 token
+	if !campaign(t, a) || readLease(t, s).Token != 1 {
+		t.Fatal("campaigning again should keep the held lease")
+	}
+}
+
+func TestElectorRenew(t *testing.T) {
+	s := store.NewMemoryStore()
+	a := newTestElector(s, "a", ModeElection)
+	b := newTestElector(s, "b", ModeElection)
+
+	if !campaign(t, a) {
+		t.Fatal("a should acquire the lock")
+	}
+	time.Sleep(testTiming.renewInterval + 50*time.Millisecond)
+	a.reconcile()
+
+	// 续约后超过最初的 TTL，锁仍由 a 持有
+	time.Sleep(testTiming.ttl - testTiming.renewInterval)
+	if !a.IsLeader() {
+		t.Fatal("a should still be leader after renewing")
+	}
+	if lease := readLease(t, s); lease == nil || lease.NodeID != "a" {
+		t.Fatalf("lock = %+v, want node a", lease)
+	}
+	if campaign(t, b) {
+		t.Fatal("b should not acquire a renewed lock")
+	}
+}
+
+func TestElectorTakeoverAfterExpiry(t *testing.T) {
+	s := store.NewMemoryStore()
+	a := newTestElector(s, "a", ModeElection)
+	b := newTestElector(s, "b", ModeElection)
+	svc := &recordingService{}
+	a.Register(svc)
+
+	a.reconcile()
+	running, oldFence := svc.state()
+	if !running || !oldFence.Valid() {
+		t.Fatal("a should run the leader services with a valid fence")
+	}
+
+	// a 停止续约，锁在 store 中到期
+	time.Sleep(testTiming.ttl + 50*time.Millisecond)
+	if a.IsLeader() {
+		t.Fatal("a should step down once its local lease expires")
+	}
+	if oldFence.Valid() {
+		t.Fatal("fence of an expired lease should be invalid")
+	}
+	if !campaign(t, b) {
+		t.Fatal("b should take over the expired lock")
+	}
+	if lease := readLease(t, s); lease == nil || lease.NodeID != "b" || lease.Token != 2 {
+		t.Fatalf("lock = %+v, want node b with token 2", lease)
+	}
+
+	a.reconcile()
+	if running, _ := svc.state(); running {
+		t.Fatal("a should stop its leader services after losing the lock")
+	}
+	if lease := readLease(t, s); lease == nil || lease.NodeID != "b" {
+		t.Fatalf("a must not overwrite b's lock, got %+v", lease)
+	}
+
+	// b 释放锁后 a 重新当选，获得新的 token，旧任期的 Fence 保持无效
+	b.Stop(context.Background())
+	a.reconcile()
+	running, newFence := svc.state()
+	if !running || newFence.Token() != 3 || !newFence.Valid() {
+		t.Fatalf("a should lead again with token 3, got running = %v token = %d", running, newFence.Token())
+	}
+	if oldFence.Valid() {
+		t.Fatal("fence of a previous term should stay invalid")
+	}
+}
+
+func TestElectorRenewFailsWhenLockHeldByOther(t *testing.T) {
+	s := store.NewMemoryStore()
+	a := newTestElector(s, "a", ModeElection)
+	svc := &recordingService{}
+	a.Register(svc)
+
+	a.reconcile()
+	other, _ := json.Marshal(Lease{NodeID: "b", Token: 9})
+	if err := s.Set(leaderLockKey, other, testTiming.ttl); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(testTiming.renewInterval + 50*time.Millisecond)
+	a.reconcile()
+	if a.IsLeader() {
+		t.Fatal("a should not be leader after failing to renew")
+	}
+	if running, fence := svc.state(); running || fence.Valid() {
+		t.Fatal("a should stop its leader services and invalidate its fence")
+	}
+	if lease := readLease(t, s); lease == nil || lease.NodeID != "b" {
+		t.Fatalf("renew must not overwrite the other node's lock, got %+v", lease)
+	}
+}
+
+func TestElectorPinnedMaster(t *testing.T) {
+	s := store.NewMemoryStore()
+	a := newTestElector(s, "a", ModeElection)
+	svc := &recordingService{}
+	a.Register(svc)
+	a.reconcile()
+	_, electedFence := svc.state()
+
+	// 固定的 master 接管选举出的 leader 的锁
+	m := newTestElector(s, "m", ModeMaster)
+	if !campaign(t, m) {
+		t.Fatal("pinned master should be leader")
+	}
+	if lease := readLease(t, s); lease == nil || lease.NodeID != "m" || !lease.Pinned || lease.Token != 2 {
+		t.Fatalf("lock = %+v, want pinned node m with token 2", lease)
+	}
+	if electedFence.Valid() {
+		t.Fatal("elected leader's fence should be invalid once the master takes over")
+	}
+	a.reconcile()
+	if running, _ := svc.state(); running || a.IsLeader() {
+		t.Fatal("elected leader should stop its services once the master takes over")
+	}
+
+	// 第二个固定的 master 拒绝启动
+	m2 := newTestElector(s, "m2", ModeMaster)
+	ok, err := m2.Campaign()
+	if ok || !errors.Is(err, ErrMasterConflict) {
+		t.Fatalf("second master Campaign() = %v, %v, want ErrMasterConflict", ok, err)
+	}
+	if lease := readLease(t, s); lease == nil || lease.NodeID != "m" {
+		t.Fatalf("second master must not take the lock, got %+v", lease)
+	}
+}
+
+func TestElectorWithoutElection(t *testing.T) {
+	s := store.NewMemoryStore()
+	master := newTestElector(s, "m", ModeMaster)
+	master.election = false
+	slave := newTestElector(s, "s", ModeSlave)
+	slave.election = false
+	svc := &recordingService{}
+	master.Register(svc)
+
+	if !campaign(t, master) || campaign(t, slave) {
+		t.Fatal("fixed roles should decide leadership without election")
+	}
+	master.Start()
+	defer master.Stop(context.Background())
+
+	if running, fence := svc.state(); !running || !fence.Valid() {
+		t.Fatal("fixed master should run the leader services with a valid fence")
+	}
+	if lease := readLease(t, s); lease != nil {
+		t.Fatalf("no lock should be written without election, got %+v", lease)
+	}
+}
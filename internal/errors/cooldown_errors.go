@@ -0,0 +1,50 @@
+package errors
+
+import (
+	"net/http"
+	"strings"
+)
+
+// cooldownSubstrings contains substrings of transient rate limit and quota errors
+// that should put a key into cooldown instead of counting as a failure.
+var cooldownSubstrings = []string{
+	"rate limit",
+	"rate_limit",
+	"too many requests",
+	"resource has been exhausted",
+	"resource_exhausted",
+	"overloaded",
+}
+
+// permanentQuotaSubstrings marks quota errors that do not recover by waiting, such as an empty balance.
+var permanentQuotaSubstrings = []string{
+	"insufficient_quota",
+	"billing",
+}
+
+// IsCooldownError checks if the upstream error is a transient rate limit or quota error.
+func IsCooldownError(statusCode int, errorMsg string) bool {
+	errorLower := strings.ToLower(errorMsg)
+
+	for _, pattern := range permanentQuotaSubstrings {
+		if strings.Contains(errorLower, pattern) {
+			return false
+		}
+	}
+
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	if errorLower == "" {
+		return false
+	}
+
+	for _, pattern := range cooldownSubstrings {
+		if strings.Contains(errorLower, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
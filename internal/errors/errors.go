@@ -38,6 +38,7 @@ var (
 	ErrNoActiveKeys       = &APIError{HTTPStatus: http.StatusServiceUnavailable, Code: "NO_ACTIVE_KEYS", Message: "No active API keys available for this group"}
 	ErrMaxRetriesExceeded = &APIError{HTTPStatus: http.StatusBadGateway, Code: "MAX_RETRIES_EXCEEDED", Message: "Request failed after maximum retries"}
 	ErrNoKeysAvailable    = &APIError{HTTPStatus: http.StatusServiceUnavailable, Code: "NO_KEYS_AVAILABLE", Message: "No API keys available to process the request"}
+	ErrKeysRateLimited    = &APIError{HTTPStatus: http.StatusTooManyRequests, Code: "KEYS_RATE_LIMITED", Message: "All API keys of this group are at their rate limit"}
 )
 
 // NewAPIError creates a new APIError with a custom message.
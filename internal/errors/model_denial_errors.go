@@ -0,0 +1,36 @@
+package errors
+
+import (
+	"net/http"
+	"strings"
+)
+
+// modelDeniedSubstrings contains substrings of upstream errors saying the requested model
+// is not available to the key, as opposed to the key itself being invalid.
+var modelDeniedSubstrings = []string{
+	"model_not_found",
+	"does not exist or you do not have access",
+	"do not have access to the model",
+	"does not have access to model",
+	"not have access to this model",
+	"is not available for your",
+	"model is not supported",
+	"unsupported model",
+}
+
+// IsModelDenied checks if the upstream error means the requested model is unavailable to this key.
+func IsModelDenied(statusCode int, errorMsg string) bool {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound:
+	default:
+		return false
+	}
+
+	errorLower := strings.ToLower(errorMsg)
+	for _, pattern := range modelDeniedSubstrings {
+		if strings.Contains(errorLower, pattern) {
+			return true
+		}
+	}
+	return false
+}
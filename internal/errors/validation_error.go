@@ -7,9 +7,17 @@ import "fmt"
 type ValidationError struct {
 	StatusCode int
 	Message    string
+	// Assertion describes the configured validation assertion that failed, if any.
+	Assertion string
 }
 
 // Error implements the error interface.
 func (e *ValidationError) Error() string {
+	if e.Assertion != "" {
+		if e.Message != "" {
+			return fmt.Sprintf("[status %d] assertion failed: %s (%s)", e.StatusCode, e.Assertion, e.Message)
+		}
+		return fmt.Sprintf("[status %d] assertion failed: %s", e.StatusCode, e.Assertion)
+	}
 	return fmt.Sprintf("[status %d] %s", e.StatusCode, e.Message)
 }
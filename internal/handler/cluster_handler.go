@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"key-flow/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLeaderStatus returns the leader election state of the node serving the request
+// and the node currently holding the leader lock.
+func (s *Server) GetLeaderStatus(c *gin.Context) {
+	response.Success(c, s.Elector.Status())
+}
@@ -91,6 +91,9 @@ func (s *Server) Stats(c *gin.Context) {
 	// 获取安全警告信息
 	securityWarnings := s.getSecurityWarnings(c)
 
+	// key 状态写回队列，最近一次写入失败时标记为负面
+	queueStats := s.KeyService.KeyProvider.KeyStateQueueStats()
+
 	stats := models.DashboardStatsResponse{
 		KeyCount: models.StatCard{
 			Value:       float64(activeKeys),
@@ -108,6 +111,12 @@ func (s *Server) Stats(c *gin.Context) {
 			Trend:         errorRateTrend,
 			TrendIsGrowth: errorRateTrendIsGrowth,
 		},
+		KeyStateQueue: models.StatCard{
+			Value:         float64(queueStats.Depth),
+			SubValue:      int64(queueStats.LastFlushCount),
+			SubValueTip:   i18n.Message(c, "dashboard.key_state_last_flush"),
+			TrendIsGrowth: queueStats.LastFlushError == "",
+		},
 		SecurityWarnings: securityWarnings,
 	}
 
@@ -440,7 +449,7 @@ func (s *Server) checkEncryptionMismatch(c *gin.Context) (bool, string, string,
 	unencryptedHashMatchCount := 0
 	for _, key := range sampleKeys {
 		// For unencrypted data: key_hash should match SHA256(key_value)
-		expectedHash := noopService.Hash(key.KeyValue)
+		expectedHash := noopService.Hash(models.CanonicalKeyValue(key.KeyValue))
 		if expectedHash == key.KeyHash {
 			unencryptedHashMatchCount++
 		}
@@ -458,7 +467,7 @@ func (s *Server) checkEncryptionMismatch(c *gin.Context) (bool, string, string,
 				decrypted, err := currentService.Decrypt(key.KeyValue)
 				if err == nil {
 					// Successfully decrypted, check if hash matches
-					expectedHash := currentService.Hash(decrypted)
+					expectedHash := currentService.Hash(models.CanonicalKeyValue(decrypted))
 					if expectedHash == key.KeyHash {
 						currentKeyHashMatchCount++
 					}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"key-flow/internal/config"
+	"key-flow/internal/election"
 	"key-flow/internal/encryption"
 	"key-flow/internal/i18n"
 	"key-flow/internal/services"
@@ -34,6 +35,7 @@ type Server struct {
 	RequestLogService          *services.RequestLogService
 	CommonHandler              *CommonHandler
 	EncryptionSvc              encryption.Service
+	Elector                    *election.Elector
 }
 
 // NewServerParams defines the dependencies for the NewServer constructor.
@@ -54,6 +56,7 @@ type NewServerParams struct {
 	RequestLogService          *services.RequestLogService
 	CommonHandler              *CommonHandler
 	EncryptionSvc              encryption.Service
+	Elector                    *election.Elector
 }
 
 // NewServer creates a new handler instance with dependencies injected by dig.
@@ -74,6 +77,7 @@ func NewServer(params NewServerParams) *Server {
 		RequestLogService:          params.RequestLogService,
 		CommonHandler:              params.CommonHandler,
 		EncryptionSvc:              params.EncryptionSvc,
+		Elector:                    params.Elector,
 	}
 }
 
@@ -129,5 +133,7 @@ func (s *Server) Health(c *gin.Context) {
 		"status":    "healthy",
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 		"uptime":    uptime,
+		// 健康检查无需认证，只返回本节点是否为 leader，节点信息见 GET /cluster/leader
+		"is_leader": s.Elector.IsLeader(),
 	})
 }
@@ -3,8 +3,10 @@ package handler
 import (
 	"fmt"
 	app_errors "key-flow/internal/errors"
+	"key-flow/internal/keypool"
 	"key-flow/internal/models"
 	"key-flow/internal/response"
+	"key-flow/internal/services"
 	"io"
 	"log"
 	"path/filepath"
@@ -47,6 +49,53 @@ func validateKeysText(c *gin.Context, keysText string) bool {
 	return true
 }
 
+// isKeyStatus reports whether status is a known key status.
+func isKeyStatus(status string) bool {
+	switch status {
+	case models.KeyStatusActive, models.KeyStatusInvalid, models.KeyStatusCooldown, models.KeyStatusArchived,
+		models.KeyStatusScheduled, models.KeyStatusExpired, models.KeyStatusQuotaExhausted:
+		return true
+	}
+	return false
+}
+
+// parseKeySchedule parses optional RFC 3339 activates_at/expires_at values and validates the window.
+// Returns false if validation fails (error is already sent to client)
+func parseKeySchedule(c *gin.Context, activatesAt, expiresAt string) (services.KeySchedule, bool) {
+	var schedule services.KeySchedule
+	for _, field := range []struct {
+		value  string
+		target **time.Time
+	}{
+		{activatesAt, &schedule.ActivatesAt},
+		{expiresAt, &schedule.ExpiresAt},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(field.value))
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("invalid time '%s', expected RFC 3339", field.value)))
+			return schedule, false
+		}
+		*field.target = &t
+	}
+	if !validateKeySchedule(c, schedule) {
+		return schedule, false
+	}
+	return schedule, true
+}
+
+// validateKeySchedule checks that the key validity window is well formed.
+// Returns false if validation fails (error is already sent to client)
+func validateKeySchedule(c *gin.Context, schedule services.KeySchedule) bool {
+	if err := keypool.ValidateSchedule(schedule.ActivatesAt, schedule.ExpiresAt); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		return false
+	}
+	return true
+}
+
 // findGroupByID is a helper function to find a group by its ID.
 func (s *Server) findGroupByID(c *gin.Context, groupID uint) (*models.Group, bool) {
 	var group models.Group
@@ -67,6 +116,12 @@ type KeyTextRequest struct {
 	KeysText string `json:"keys_text" binding:"required"`
 }
 
+// AddKeysRequest defines the payload for adding keys, with an optional validity window, tags and upstream binding.
+type AddKeysRequest struct {
+	KeyTextRequest
+	services.KeyImportOptions
+}
+
 // GroupIDRequest defines a generic payload for operations requiring only a group ID.
 type GroupIDRequest struct {
 	GroupID uint `json:"group_id" binding:"required"`
@@ -80,7 +135,7 @@ type ValidateGroupKeysRequest struct {
 
 // AddMultipleKeys handles creating new keys from a text block within a specific group.
 func (s *Server) AddMultipleKeys(c *gin.Context) {
-	var req KeyTextRequest
+	var req AddKeysRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
 		return
@@ -94,7 +149,16 @@ func (s *Server) AddMultipleKeys(c *gin.Context) {
 		return
 	}
 
-	result, err := s.KeyService.AddMultipleKeys(req.GroupID, req.KeysText)
+	if !validateKeySchedule(c, req.KeySchedule) {
+		return
+	}
+
+	req.Upstream = strings.TrimSpace(req.Upstream)
+	if !s.validateKeyUpstream(c, req.GroupID, req.Upstream) {
+		return
+	}
+
+	result, err := s.KeyService.AddMultipleKeys(req.GroupID, req.KeysText, req.KeyImportOptions)
 	if err != nil {
 		if strings.Contains(err.Error(), "batch size exceeds the limit") {
 			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
@@ -113,6 +177,7 @@ func (s *Server) AddMultipleKeys(c *gin.Context) {
 func (s *Server) AddMultipleKeysAsync(c *gin.Context) {
 	var groupID uint
 	var keysText string
+	var options services.KeyImportOptions
 
 	// Check content type to determine if it's a file upload or JSON request
 	contentType := c.ContentType()
@@ -141,7 +206,7 @@ func (s *Server) AddMultipleKeysAsync(c *gin.Context) {
 
 		// Validate file extension
 		ext := strings.ToLower(filepath.Ext(file.Filename))
-		if ext != ".txt" {
+		if ext != ".txt" && ext != ".csv" {
 			response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.only_txt_supported")
 			return
 		}
@@ -161,15 +226,27 @@ func (s *Server) AddMultipleKeysAsync(c *gin.Context) {
 			return
 		}
 		keysText = string(buf)
+
+		var ok bool
+		if options.KeySchedule, ok = parseKeySchedule(c, c.PostForm("activates_at"), c.PostForm("expires_at")); !ok {
+			return
+		}
+		options.Tags = models.ParseKeyTags(c.PostForm("tags"))
+		options.Upstream = strings.TrimSpace(c.PostForm("upstream"))
 	} else {
 		// Handle JSON request (original behavior)
-		var req KeyTextRequest
+		var req AddKeysRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
 			return
 		}
 		groupID = req.GroupID
 		keysText = req.KeysText
+		options = req.KeyImportOptions
+		options.Upstream = strings.TrimSpace(options.Upstream)
+		if !validateKeySchedule(c, options.KeySchedule) {
+			return
+		}
 	}
 
 	group, ok := s.findGroupByID(c, groupID)
@@ -181,7 +258,11 @@ func (s *Server) AddMultipleKeysAsync(c *gin.Context) {
 		return
 	}
 
-	taskStatus, err := s.KeyImportService.StartImportTask(group, keysText)
+	if !s.validateKeyUpstream(c, groupID, options.Upstream) {
+		return
+	}
+
+	taskStatus, err := s.KeyImportService.StartImportTask(group, keysText, options)
 	if err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrTaskInProgress, err.Error()))
 		return
@@ -202,15 +283,28 @@ func (s *Server) ListKeysInGroup(c *gin.Context) {
 	}
 
 	statusFilter := c.Query("status")
-	if statusFilter != "" && statusFilter != models.KeyStatusActive && statusFilter != models.KeyStatusInvalid {
+	if statusFilter != "" && !isKeyStatus(statusFilter) {
 		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_status_filter")
 		return
 	}
 
+	// 过滤在指定时间之前过期的 key
+	var expiresBefore *time.Time
+	if v := c.Query("expires_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("invalid expires_before '%s', expected RFC 3339", v)))
+			return
+		}
+		expiresBefore = &t
+	}
+
+	tag := strings.TrimSpace(c.Query("tag"))
+
 	searchKeyword := c.Query("key_value")
 	searchHash := ""
 	if searchKeyword != "" {
-		searchHash = s.EncryptionSvc.Hash(searchKeyword)
+		searchHash = s.EncryptionSvc.Hash(models.CanonicalKeyValue(searchKeyword))
 	}
 
 	// 排序参数
@@ -220,7 +314,7 @@ func (s *Server) ListKeysInGroup(c *gin.Context) {
 		sortOrder = "desc"
 	}
 
-	query := s.KeyService.ListKeysInGroupQuery(groupID, statusFilter, searchHash, sortBy, sortOrder)
+	query := s.KeyService.ListKeysInGroupQuery(groupID, statusFilter, searchHash, expiresBefore, tag, sortBy, sortOrder)
 
 	var keys []models.APIKey
 	paginatedResult, err := response.Paginate(c, query, &keys)
@@ -388,7 +482,7 @@ func (s *Server) ValidateGroupKeys(c *gin.Context) {
 	}
 
 	// Validate status if provided
-	if req.Status != "" && req.Status != models.KeyStatusActive && req.Status != models.KeyStatusInvalid {
+	if req.Status != "" && req.Status != models.KeyStatusActive && req.Status != models.KeyStatusInvalid && req.Status != models.KeyStatusArchived {
 		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_status_value")
 		return
 	}
@@ -488,23 +582,35 @@ func (s *Server) ExportKeys(c *gin.Context) {
 		statusFilter = "all"
 	}
 
-	switch statusFilter {
-	case "all", models.KeyStatusActive, models.KeyStatusInvalid:
-	default:
+	if statusFilter != "all" && !isKeyStatus(statusFilter) {
 		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_status_filter")
 		return
 	}
 
+	format := c.Query("format")
+	if format == "" {
+		format = "txt"
+	}
+	if format != "txt" && format != "csv" {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("invalid export format '%s'", format)))
+		return
+	}
+
 	group, ok := s.findGroupByID(c, groupID)
 	if !ok {
 		return
 	}
 
-	filename := fmt.Sprintf("keys-%s-%s.txt", group.Name, statusFilter)
+	filename := fmt.Sprintf("keys-%s-%s.%s", group.Name, statusFilter, format)
 	c.Header("Content-Disposition", "attachment; filename="+filename)
-	c.Header("Content-Type", "text/plain; charset=utf-8")
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+	}
 
-	if err := s.KeyService.StreamKeysToWriter(groupID, statusFilter, c.Writer); err != nil {
+	tag := strings.TrimSpace(c.Query("tag"))
+	if err := s.KeyService.StreamKeysToWriter(groupID, statusFilter, tag, format, c.Writer); err != nil {
 		log.Printf("Failed to stream keys: %v", err)
 	}
 }
@@ -585,6 +691,123 @@ func (s *Server) UpdateKeyWeight(c *gin.Context) {
 	response.Success(c, nil)
 }
 
+// UpdateKeyRateLimitsRequest defines the payload for updating a key's rate limits.
+type UpdateKeyRateLimitsRequest struct {
+	RPMLimit       int `json:"rpm_limit" binding:"min=0"`
+	TPMLimit       int `json:"tpm_limit" binding:"min=0"`
+	MaxConcurrency int `json:"max_concurrency" binding:"min=0"`
+}
+
+// UpdateKeyRateLimits handles updating the RPM/TPM and concurrency limits of a specific API key.
+func (s *Server) UpdateKeyRateLimits(c *gin.Context) {
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || keyID <= 0 {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "invalid key ID format"))
+		return
+	}
+
+	var req UpdateKeyRateLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "rate limits must be non-negative integers"))
+		return
+	}
+
+	if err := s.KeyService.UpdateKeyRateLimits(uint(keyID), req.RPMLimit, req.TPMLimit, req.MaxConcurrency); err != nil {
+		logrus.WithError(err).WithField("keyID", keyID).Error("Failed to update key rate limits")
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrDatabase, err.Error()))
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// UpdateKeyTagsRequest defines the payload for replacing a key's tags.
+type UpdateKeyTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// UpdateKeyTags handles replacing the tags of a specific API key.
+func (s *Server) UpdateKeyTags(c *gin.Context) {
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || keyID <= 0 {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "invalid key ID format"))
+		return
+	}
+
+	var req UpdateKeyTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	// 标签以 ",a,b," 形式存储，不能包含分隔符
+	for _, tag := range req.Tags {
+		if strings.ContainsAny(tag, ",;|") {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("invalid tag '%s': tags must not contain ',', ';' or '|'", tag)))
+			return
+		}
+	}
+	if len(models.NormalizeKeyTags(req.Tags).String()) > 500 {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "tags are too long"))
+		return
+	}
+
+	if err := s.KeyService.UpdateKeyTags(uint(keyID), req.Tags); err != nil {
+		logrus.WithError(err).WithField("keyID", keyID).Error("Failed to update key tags")
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrDatabase, err.Error()))
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// validateKeyUpstream checks that the upstream a key is bound to belongs to the group.
+// It writes the error response and returns false if the upstream is invalid.
+func (s *Server) validateKeyUpstream(c *gin.Context, groupID uint, upstream string) bool {
+	if err := s.KeyService.ValidateKeyUpstream(groupID, upstream); err != nil {
+		s.handleGroupError(c, err)
+		return false
+	}
+	return true
+}
+
+// UpdateKeyUpstreamRequest defines the payload for binding a key to an upstream.
+type UpdateKeyUpstreamRequest struct {
+	Upstream string `json:"upstream"`
+}
+
+// UpdateKeyUpstream handles binding a specific API key to one of its group's upstreams.
+// An empty upstream removes the binding.
+func (s *Server) UpdateKeyUpstream(c *gin.Context) {
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || keyID <= 0 {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "invalid key ID format"))
+		return
+	}
+
+	var req UpdateKeyUpstreamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	if err := s.KeyService.UpdateKeyUpstream(uint(keyID), strings.TrimSpace(req.Upstream)); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.Error(c, app_errors.ErrResourceNotFound)
+			return
+		}
+		if _, ok := err.(*services.I18nError); ok {
+			s.handleGroupError(c, err)
+			return
+		}
+		logrus.WithError(err).WithField("keyID", keyID).Error("Failed to update key upstream")
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrDatabase, err.Error()))
+		return
+	}
+
+	response.Success(c, nil)
+}
+
 // UpdateKeysWeightRequest defines the payload for batch updating key weights.
 type UpdateKeysWeightRequest struct {
 	GroupID  uint   `json:"group_id" binding:"required"`
@@ -57,6 +57,8 @@ var MessagesEnUS = map[string]string{
 	"validation.test_model_empty":        "Test model cannot be empty or contain only spaces",
 	"validation.invalid_status_value":    "Invalid status value",
 	"validation.invalid_upstreams":       "Invalid upstreams configuration: {{.error}}",
+	"validation.key_upstream_not_in_group": "Upstream {{.upstream}} is not configured in this group",
+	"validation.upstream_has_bound_keys": "Upstream {{.upstream}} cannot be removed while {{.count}} keys are bound to it",
 	"validation.group_id_required":       "group_id query parameter is required",
 	"validation.invalid_group_id_format": "Invalid group_id format",
 	"validation.keys_text_empty":         "Keys text cannot be empty",
@@ -72,6 +74,7 @@ var MessagesEnUS = map[string]string{
 	"validation.sub_group_referenced_cannot_modify": "This group is referenced by {{.count}} aggregate group(s) as a sub-group. Cannot modify channel type or validation endpoint. Please remove this group from related aggregate groups before making changes",
 	"validation.standard_group_requires_upstreams_testmodel": "Converting to standard group requires providing upstreams and test model",
 	"validation.aggregate_no_model_redirect": "Aggregate groups do not support model redirect rules",
+	"validation.invalid_channel_config": "Invalid channel config: {{.error}}",
 
 	// Task related
 	"task.validation_started": "Key validation task started",
@@ -82,6 +85,7 @@ var MessagesEnUS = map[string]string{
 
 	// Dashboard related
 	"dashboard.invalid_keys":                                     "Invalid Keys",
+	"dashboard.key_state_last_flush":                             "Keys persisted in the last flush",
 	"dashboard.success_requests":                                 "Success",
 	"dashboard.failed_requests":                                  "Failed",
 	"dashboard.auth_key_missing":                                 "AUTH_KEY is not set, system cannot function properly",
@@ -177,6 +181,38 @@ var MessagesEnUS = map[string]string{
 	"config.enable_instant_disable_desc":        "Immediately disable a key when a matching error code or keyword is detected, without waiting for the blacklist threshold.",
 	"config.instant_disable_rules":              "Instant Disable Rules",
 	"config.instant_disable_rules_desc":         "One rule per line. status:401 matches HTTP status codes, keyword:invalid_api_key matches error message keywords. Lines starting with # are comments.",
+	"config.key_rpm_limit":                      "Key RPM Limit",
+	"config.key_rpm_limit_desc":                 "Default maximum requests per minute for each key. Keys at their limit are skipped during selection. 0 means unlimited, a per-key limit takes precedence.",
+	"config.key_tpm_limit":                      "Key TPM Limit",
+	"config.key_tpm_limit_desc":                 "Default maximum tokens per minute for each key, counted from upstream usage. 0 means unlimited, a per-key limit takes precedence.",
+	"config.key_max_concurrency":                "Key Max Concurrency",
+	"config.key_max_concurrency_desc":           "Default maximum number of concurrent requests per key, including open streams. Saturated keys are skipped during selection. 0 means unlimited, a per-key limit takes precedence.",
+	"config.key_cooldown_seconds":               "Key Cooldown Duration",
+	"config.key_cooldown_seconds_desc":          "Seconds a key is paused after a rate limit or transient quota error when the upstream sends no Retry-After. The key is restored automatically afterwards. 0 disables cooldown and counts such errors as failures.",
+	"config.key_cooldown_max_seconds":           "Max Key Cooldown Duration",
+	"config.key_cooldown_max_seconds_desc":      "Upper bound in seconds for exponentially growing cooldowns. 0 means no upper bound.",
+	"config.key_cooldown_exponential":           "Exponential Cooldown",
+	"config.key_cooldown_exponential_desc":      "Double the cooldown duration each time a key is cooled down again before a successful request.",
+	"config.key_validation_backoff_max_minutes":      "Max Validation Backoff (minutes)",
+	"config.key_validation_backoff_max_minutes_desc": "Upper bound for the revalidation interval of invalid keys. Each consecutive failed validation doubles the interval, starting from the key validation interval. 0 disables backoff.",
+	"config.key_archive_after_days":                  "Auto-Archive After (days)",
+	"config.key_archive_after_days_desc":             "Archive invalid keys that have kept failing validation for this many days. Archived keys are no longer revalidated automatically. 0 disables archiving.",
+	"config.active_key_sample_percent":               "Active Key Sample Percentage",
+	"config.active_key_sample_percent_desc":          "Percentage of active keys to validate each key validation interval, so dead keys in low-traffic groups are found before user requests fail. 0 disables sampling.",
+	"config.active_key_sample_skip_minutes":          "Sampling Skip Window (minutes)",
+	"config.active_key_sample_skip_minutes_desc":     "Active keys with a successful proxied request within this many minutes are not sampled.",
+	"config.quota_exhausted_rules":                   "Quota Exhausted Rules",
+	"config.quota_exhausted_rules_desc":              "Errors that mean the key has used up its daily quota, in the same format as instant disable rules (status:429, keyword:resource has been exhausted). Matching keys leave rotation until the next quota reset. Empty disables this.",
+	"config.quota_reset_time":                        "Quota Reset Time",
+	"config.quota_reset_time_desc":                   "Time of day (HH:MM) at which exhausted quotas reset.",
+	"config.quota_reset_timezone":                    "Quota Reset Time Zone",
+	"config.quota_reset_timezone_desc":               "IANA time zone of the quota reset time, e.g. America/Los_Angeles or UTC.",
+	"config.key_tag_rules":                           "Key Tag Rules",
+	"config.key_tag_rules_desc":                      "Restrict key selection by model or proxy key, one rule per line: model:<name>=<tags> or proxy_key:<key>=<tags>. Only keys carrying all listed tags are used. Clients can also send the X-Key-Tags header.",
+	"config.model_denial_ttl_minutes":                "Model Denial Duration (minutes)",
+	"config.model_denial_ttl_minutes_desc":           "When the upstream reports that a key cannot access the requested model, that key is skipped for the model for this many minutes without counting as a failure. 0 treats such errors as normal failures.",
+	"config.key_selection_strategy":                  "Key Selection Strategy",
+	"config.key_selection_strategy_desc":             "How a key is chosen for each request. round_robin: rotate through keys (weighted random when cache hit enhancement is on); least_in_flight: the key serving the fewest requests relative to its weight; least_recently_used: the key idle the longest; power_of_two: the less busy of two weighted random keys. In-flight counts are shared across nodes through the store.",
 
 	// Category labels
 	"config.category.basic":   "Basic",
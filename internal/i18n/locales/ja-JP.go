@@ -57,6 +57,8 @@ var MessagesJaJP = map[string]string{
 	"validation.test_model_empty":        "テストモデルは空またはスペースのみにできません",
 	"validation.invalid_status_value":    "無効なステータス値",
 	"validation.invalid_upstreams":       "無効なupstreams設定: {{.error}}",
+	"validation.key_upstream_not_in_group": "アップストリーム {{.upstream}} はこのグループに設定されていません",
+	"validation.upstream_has_bound_keys": "{{.count}} 個のキーがバインドされているため、アップストリーム {{.upstream}} を削除できません",
 	"validation.group_id_required":       "group_idクエリパラメータが必要です",
 	"validation.invalid_group_id_format": "無効なgroup_id形式",
 	"validation.keys_text_empty":         "キーテキストは空にできません",
@@ -72,6 +74,7 @@ var MessagesJaJP = map[string]string{
 	"validation.sub_group_referenced_cannot_modify": "このグループは {{.count}} 個の集約グループでサブグループとして参照されています。チャンネルタイプまたは検証エンドポイントは変更できません。変更前に関連する集約グループからこのグループを削除してください",
 	"validation.standard_group_requires_upstreams_testmodel": "標準グループへの変換にはアップストリームサーバーとテストモデルの提供が必要です",
 	"validation.aggregate_no_model_redirect": "集約グループはモデルリダイレクトルールをサポートしていません",
+	"validation.invalid_channel_config": "チャンネル設定が無効です: {{.error}}",
 
 	// Task related
 	"task.validation_started": "キー検証タスクが開始されました",
@@ -82,6 +85,7 @@ var MessagesJaJP = map[string]string{
 
 	// Dashboard related
 	"dashboard.invalid_keys":                                     "無効なキー",
+	"dashboard.key_state_last_flush":                             "直近の書き込みで保存したキー数",
 	"dashboard.success_requests":                                 "成功",
 	"dashboard.failed_requests":                                  "失敗",
 	"dashboard.auth_key_missing":                                 "AUTH_KEYが設定されていません。システムが正常に動作しません",
@@ -177,6 +181,38 @@ var MessagesJaJP = map[string]string{
 	"config.enable_instant_disable_desc":        "一致するエラーコードまたはキーワードが検出された場合、ブラックリスト閾値を待たずにキーを即座に無効化します。",
 	"config.instant_disable_rules":              "即時無効化ルール",
 	"config.instant_disable_rules_desc":         "1行に1ルール。status:401 はHTTPステータスコードに一致、keyword:invalid_api_key はエラーメッセージのキーワードに一致。# で始まる行はコメントです。",
+	"config.key_rpm_limit":                      "キー RPM 制限",
+	"config.key_rpm_limit_desc":                 "各キーのデフォルトの1分あたり最大リクエスト数。上限に達したキーは選択時にスキップされます。0 は無制限で、キー個別の制限が優先されます。",
+	"config.key_tpm_limit":                      "キー TPM 制限",
+	"config.key_tpm_limit_desc":                 "各キーのデフォルトの1分あたり最大トークン数。上流の使用量から集計されます。0 は無制限で、キー個別の制限が優先されます。",
+	"config.key_max_concurrency":                "キー最大同時実行数",
+	"config.key_max_concurrency_desc":           "各キーのデフォルトの最大同時リクエスト数（進行中のストリームを含む）。上限に達したキーは選択時にスキップされます。0 は無制限で、キー個別の制限が優先されます。",
+	"config.key_cooldown_seconds":               "キーのクールダウン時間",
+	"config.key_cooldown_seconds_desc":          "レート制限または一時的なクォータエラーの際、上流が Retry-After を返さない場合にキーを一時停止する秒数。経過後は自動的に復帰します。0 でクールダウンを無効にし、これらのエラーを失敗として数えます。",
+	"config.key_cooldown_max_seconds":           "最大クールダウン時間",
+	"config.key_cooldown_max_seconds_desc":      "指数的に増加するクールダウン時間の上限（秒）。0 は上限なし。",
+	"config.key_cooldown_exponential":           "指数クールダウン",
+	"config.key_cooldown_exponential_desc":      "成功したリクエストの前に再びクールダウンに入るたびに、クールダウン時間を2倍にします。",
+	"config.key_validation_backoff_max_minutes":      "最大検証バックオフ（分）",
+	"config.key_validation_backoff_max_minutes_desc": "無効なキーの再検証間隔の上限。検証に連続で失敗するたびに、キー検証間隔から開始して間隔が2倍になります。0の場合はバックオフしません。",
+	"config.key_archive_after_days":                  "自動アーカイブまでの日数",
+	"config.key_archive_after_days_desc":             "この日数の間検証に失敗し続けた無効なキーをアーカイブします。アーカイブされたキーは自動検証されなくなります。0の場合はアーカイブしません。",
+	"config.active_key_sample_percent":               "アクティブキーのサンプリング率",
+	"config.active_key_sample_percent_desc":          "キー検証間隔ごとに検証するアクティブキーの割合。低トラフィックのグループで無効になったキーを、ユーザーのリクエストが失敗する前に検出します。0の場合はサンプリングしません。",
+	"config.active_key_sample_skip_minutes":          "サンプリング除外期間（分）",
+	"config.active_key_sample_skip_minutes_desc":     "この時間内にプロキシリクエストが成功したアクティブキーはサンプリングされません。",
+	"config.quota_exhausted_rules":                   "クォータ枯渇ルール",
+	"config.quota_exhausted_rules_desc":              "キーの1日のクォータが尽きたことを示すエラー。即時無効化ルールと同じ形式です（status:429、keyword:resource has been exhausted）。一致したキーは次のクォータリセットまでローテーションから外れます。空の場合は無効です。",
+	"config.quota_reset_time":                        "クォータリセット時刻",
+	"config.quota_reset_time_desc":                   "クォータがリセットされる時刻（HH:MM）。",
+	"config.quota_reset_timezone":                    "クォータリセットのタイムゾーン",
+	"config.quota_reset_timezone_desc":               "クォータリセット時刻の IANA タイムゾーン。例：America/Los_Angeles、UTC。",
+	"config.key_tag_rules":                           "キータグルール",
+	"config.key_tag_rules_desc":                      "モデルまたはプロキシキーごとに使用するキーを制限します。1 行 1 ルール：model:<モデル>=<タグ> または proxy_key:<キー>=<タグ>。すべてのタグを持つキーのみ使用されます。クライアントは X-Key-Tags ヘッダーでも指定できます。",
+	"config.model_denial_ttl_minutes":                "モデル拒否期間（分）",
+	"config.model_denial_ttl_minutes_desc":           "上流がキーに要求モデルへのアクセス権がないと返した場合、この期間そのモデルではキーを選択せず、失敗回数にも数えません。0 の場合は通常の失敗として扱います。",
+	"config.key_selection_strategy":                  "キー選択戦略",
+	"config.key_selection_strategy_desc":             "各リクエストでキーを選ぶ方法。round_robin：ローテーション（キャッシュヒット強化が有効な場合は重み付きランダム）、least_in_flight：重みに対して処理中のリクエストが最も少ないキー、least_recently_used：最も長く使われていないキー、power_of_two：重み付きランダムに選んだ 2 つのキーのうち空いている方。処理中のリクエスト数はストアを通じて全ノードで共有されます。",
 
 	// Category labels
 	"config.category.basic":   "基本設定",
@@ -57,6 +57,8 @@ var MessagesZhCN = map[string]string{
 	"validation.test_model_empty":        "测试模型不能为空或只有空格",
 	"validation.invalid_status_value":    "无效的状态值",
 	"validation.invalid_upstreams":       "upstreams配置错误: {{.error}}",
+	"validation.key_upstream_not_in_group": "上游 {{.upstream}} 未在该分组中配置",
+	"validation.upstream_has_bound_keys": "上游 {{.upstream}} 仍绑定了 {{.count}} 个密钥，无法移除",
 	"validation.group_id_required":       "需要提供group_id参数",
 	"validation.invalid_group_id_format": "无效的group_id格式",
 	"validation.keys_text_empty":         "密钥文本不能为空",
@@ -72,6 +74,7 @@ var MessagesZhCN = map[string]string{
 	"validation.sub_group_referenced_cannot_modify": "该分组正被 {{.count}} 个聚合分组引用为子分组，无法修改渠道类型或验证端点。请先从相关聚合分组中移除此分组后再进行修改",
 	"validation.standard_group_requires_upstreams_testmodel": "转换为标准分组需要提供上游服务器和测试模型",
 	"validation.aggregate_no_model_redirect": "聚合分组不支持配置模型重定向规则",
+	"validation.invalid_channel_config": "渠道配置无效: {{.error}}",
 
 	// Task related
 	"task.validation_started": "密钥验证任务已开始",
@@ -82,6 +85,7 @@ var MessagesZhCN = map[string]string{
 
 	// Dashboard related
 	"dashboard.invalid_keys":                                     "无效密钥数量",
+	"dashboard.key_state_last_flush":                             "最近一次写入的密钥数",
 	"dashboard.success_requests":                                 "成功请求",
 	"dashboard.failed_requests":                                  "失败请求",
 	"dashboard.auth_key_missing":                                 "AUTH_KEY未设置，系统无法正常工作",
@@ -177,6 +181,38 @@ var MessagesZhCN = map[string]string{
 	"config.enable_instant_disable_desc":        "检测到匹配的错误码或关键字时，立即禁用对应的 Key，无需等待达到黑名单阈值。",
 	"config.instant_disable_rules":              "立即禁用规则",
 	"config.instant_disable_rules_desc":         "每行一条规则。status:401 表示匹配 HTTP 状态码，keyword:invalid_api_key 表示匹配错误消息关键字。以 # 开头的行为注释。",
+	"config.key_rpm_limit":                      "密钥 RPM 限制",
+	"config.key_rpm_limit_desc":                 "每个密钥默认的每分钟最大请求数，达到限制的密钥在选择时会被跳过。0 表示不限制，密钥单独设置的限制优先。",
+	"config.key_tpm_limit":                      "密钥 TPM 限制",
+	"config.key_tpm_limit_desc":                 "每个密钥默认的每分钟最大 Token 数，根据上游返回的用量统计。0 表示不限制，密钥单独设置的限制优先。",
+	"config.key_max_concurrency":                "密钥最大并发数",
+	"config.key_max_concurrency_desc":           "每个密钥默认的最大并发请求数，包括进行中的流式响应。并发已满的密钥在选择时会被跳过。0 表示不限制，密钥单独设置的限制优先。",
+	"config.key_cooldown_seconds":               "密钥冷却时长",
+	"config.key_cooldown_seconds_desc":          "密钥遇到限流或临时配额错误且上游未返回 Retry-After 时暂停使用的秒数，到期后自动恢复。0 表示禁用冷却，此类错误按失败计数。",
+	"config.key_cooldown_max_seconds":           "密钥最大冷却时长",
+	"config.key_cooldown_max_seconds_desc":      "指数增长冷却时长的上限（秒）。0 表示不限制。",
+	"config.key_cooldown_exponential":           "指数冷却",
+	"config.key_cooldown_exponential_desc":      "密钥在成功请求之前再次进入冷却时，冷却时长翻倍。",
+	"config.key_validation_backoff_max_minutes":      "最大验证退避时间（分钟）",
+	"config.key_validation_backoff_max_minutes_desc": "失效密钥重新验证间隔的上限。每次连续验证失败间隔翻倍，初始值为密钥验证间隔。为0时不退避。",
+	"config.key_archive_after_days":                  "自动归档天数",
+	"config.key_archive_after_days_desc":             "失效密钥连续验证失败达到该天数后归档，归档的密钥不再自动验证。为0时不归档。",
+	"config.active_key_sample_percent":               "活跃密钥抽样比例",
+	"config.active_key_sample_percent_desc":          "每个密钥验证间隔抽样验证的活跃密钥百分比，用于在用户请求失败前发现低流量分组中失效的密钥。为0时不抽样。",
+	"config.active_key_sample_skip_minutes":          "抽样跳过窗口（分钟）",
+	"config.active_key_sample_skip_minutes_desc":     "在该时间内有成功代理请求的活跃密钥不参与抽样。",
+	"config.quota_exhausted_rules":                   "配额耗尽规则",
+	"config.quota_exhausted_rules_desc":              "表示密钥每日配额已用尽的错误，格式与立即禁用规则相同（status:429、keyword:resource has been exhausted）。命中的密钥在下次配额重置前不参与轮询。为空时不启用。",
+	"config.quota_reset_time":                        "配额重置时间",
+	"config.quota_reset_time_desc":                   "配额每天重置的时间（HH:MM）。",
+	"config.quota_reset_timezone":                    "配额重置时区",
+	"config.quota_reset_timezone_desc":               "配额重置时间所在的 IANA 时区，例如 America/Los_Angeles 或 UTC。",
+	"config.key_tag_rules":                           "密钥标签规则",
+	"config.key_tag_rules_desc":                      "按模型或代理密钥限制可选的密钥，每行一条：model:<模型>=<标签> 或 proxy_key:<密钥>=<标签>。只会使用包含全部标签的密钥。客户端也可以通过 X-Key-Tags 请求头指定标签。",
+	"config.model_denial_ttl_minutes":                "模型拒绝时长（分钟）",
+	"config.model_denial_ttl_minutes_desc":           "上游返回密钥无权访问所请求的模型时，在该时长内不再为此模型选择该密钥，且不计入失败次数。0 表示按普通失败处理。",
+	"config.key_selection_strategy":                  "密钥选择策略",
+	"config.key_selection_strategy_desc":             "每个请求选择密钥的方式。round_robin：轮询（开启缓存命中增强时为加权随机）；least_in_flight：按权重计算当前处理请求最少的密钥；least_recently_used：最久未使用的密钥；power_of_two：随机选取两个密钥中较空闲的一个。进行中的请求数通过存储在各节点间共享。",
 
 	// Category labels
 	"config.category.basic":   "基础参数",
@@ -0,0 +1,88 @@
+package keypool
+
+import (
+	"fmt"
+	"key-flow/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// concurrencyLeaseTTL 是并发槽位的租约时长，节点崩溃后未释放的槽位在到期后自动回收
+	concurrencyLeaseTTL = time.Minute
+	// concurrencyLeaseRenewInterval 是请求进行中续约槽位的间隔，长时间的流式响应不会丢失槽位
+	concurrencyLeaseRenewInterval = concurrencyLeaseTTL / 3
+	// concurrencyRetryAfter 是所有 key 并发已满时建议客户端等待的时间
+	concurrencyRetryAfter = time.Second
+)
+
+func concurrencyKey(keyID uint) string {
+	return fmt.Sprintf("concurrency:key:%d", keyID)
+}
+
+// acquireConcurrencySlot 在 store 中原子地检查并占用 key 的一个并发槽位，返回本次占用的 token。
+// token 在每次占用时随机生成，槽位租约过期后，原持有者的续约和释放不会影响其他请求。
+// 未配置并发限制或 store 出错时放行并返回空 token，避免 store 故障导致所有请求被拒绝。
+func (p *KeyProvider) acquireConcurrencySlot(keyID uint, limit int) (string, bool) {
+	if limit <= 0 {
+		return "", true
+	}
+	token := uuid.NewString()
+	ok, err := p.store.SemaphoreAcquire(concurrencyKey(keyID), token, int64(limit), concurrencyLeaseTTL)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to acquire key concurrency slot")
+		return "", true
+	}
+	if !ok {
+		return "", false
+	}
+	return token, true
+}
+
+// releaseConcurrencySlot 释放 key 占用的并发槽位，槽位已过期时不做处理
+func (p *KeyProvider) releaseConcurrencySlot(keyID uint, token string) {
+	if token == "" {
+		return
+	}
+	if err := p.store.SemaphoreRelease(concurrencyKey(keyID), token); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to release key concurrency slot")
+	}
+}
+
+// holdConcurrencySlot 在请求进行中定期续约 key 占用的槽位，返回的函数停止续约并释放槽位
+func (p *KeyProvider) holdConcurrencySlot(apiKey *models.APIKey) func() {
+	keyID, token := apiKey.ID, apiKey.ConcurrencyToken
+	if token == "" {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(concurrencyLeaseRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ok, err := p.store.SemaphoreRenew(concurrencyKey(keyID), token, concurrencyLeaseTTL)
+				if err != nil {
+					logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to renew key concurrency slot")
+					continue
+				}
+				if !ok {
+					// 租约已过期，槽位可能已被其他请求占用，不再续约，避免超出并发限制
+					logrus.WithFields(logrus.Fields{"keyID": keyID}).Warn("Key concurrency slot lease lost")
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		p.releaseConcurrencySlot(keyID, token)
+	}
+}
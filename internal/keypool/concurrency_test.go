@@ -0,0 +1,63 @@
+package keypool
+
+import (
+	"key-flow/internal/models"
+	"key-flow/internal/types"
+	"testing"
+)
+
+func TestSelectKeyConcurrency(t *testing.T) {
+	runSelectKeyTests(t, []selectKeyTest{
+		{
+			name: "concurrency slots full",
+			keys: []models.APIKey{{MaxConcurrency: 1}, {}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				if token, ok := p.acquireConcurrencySlot(1, 1); !ok || token == "" {
+					t.Fatal("failed to take the only slot of key 1")
+				}
+			},
+			wantKey: 2,
+			check: func(t *testing.T, p *KeyProvider, apiKey *models.APIKey) {
+				if apiKey.ConcurrencyToken != "" {
+					t.Error("key without concurrency limit should not hold a slot")
+				}
+			},
+		},
+		{
+			name:    "concurrency slot held until the request ends",
+			keys:    []models.APIKey{{MaxConcurrency: 1}},
+			wantKey: 1,
+			check: func(t *testing.T, p *KeyProvider, apiKey *models.APIKey) {
+				if apiKey.ConcurrencyToken == "" {
+					t.Fatal("selected key should hold a concurrency slot")
+				}
+				if _, err := p.SelectKey(testGroupID, SelectConstraints{}); err == nil {
+					t.Fatal("second SelectKey() should fail while the only slot is held")
+				}
+				release := p.TrackKeyRequest(testGroup(types.SystemSettings{}), apiKey)
+				release()
+				if second, err := p.SelectKey(testGroupID, SelectConstraints{}); err != nil || second.ID != 1 {
+					t.Fatalf("SelectKey() after release = %v, %v, want key 1", second, err)
+				}
+			},
+		},
+		{
+			name: "group default concurrency",
+			keys: []models.APIKey{{}, {MaxConcurrency: 2}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				p.acquireConcurrencySlot(1, 1)
+				p.acquireConcurrencySlot(2, 1)
+			},
+			constraints: SelectConstraints{Limits: RateLimits{Concurrency: 1}},
+			wantKey:     2,
+		},
+		{
+			name: "all concurrency slots full",
+			keys: []models.APIKey{{MaxConcurrency: 1}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				p.acquireConcurrencySlot(1, 1)
+			},
+			wantStatus: map[uint]string{1: models.KeyStatusActive},
+		},
+	})
+}
@@ -0,0 +1,136 @@
+package keypool
+
+import (
+	"context"
+	"fmt"
+	"key-flow/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// cooldownSweepInterval 是扫描已到期冷却 key 的间隔，用于兜底恢复其他节点或重启前设置的冷却
+const cooldownSweepInterval = 15 * time.Second
+
+// cooldownDuration 计算本次冷却时长。上游返回 Retry-After 时优先使用，否则使用分组默认值，
+// 开启指数冷却时每次连续冷却时长翻倍，并受最大冷却时长限制。
+func cooldownDuration(group *models.Group, retryAfter time.Duration, cooldownCount int) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	cfg := group.EffectiveConfig
+	duration := time.Duration(cfg.KeyCooldownSeconds) * time.Second
+	maxDuration := time.Duration(cfg.KeyCooldownMaxSeconds) * time.Second
+
+	if cfg.KeyCooldownExponential {
+		for i := 1; i < cooldownCount; i++ {
+			duration *= 2
+			if maxDuration > 0 && duration >= maxDuration {
+				break
+			}
+		}
+	}
+
+	if maxDuration > 0 && duration > maxDuration {
+		duration = maxDuration
+	}
+	return duration
+}
+
+// CooldownKey 异步地将 key 移出活跃列表一段时间，到期后自动恢复，不需要重新验证。
+// retryAfter 为上游返回的 Retry-After，0 表示未提供。
+func (p *KeyProvider) CooldownKey(apiKey *models.APIKey, group *models.Group, retryAfter time.Duration) {
+	go func() {
+		if err := p.cooldownKey(apiKey, group, retryAfter); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to put key into cooldown")
+		}
+	}()
+}
+
+func (p *KeyProvider) cooldownKey(apiKey *models.APIKey, group *models.Group, retryAfter time.Duration) error {
+	keyDetails, err := p.store.HGetAll(fmt.Sprintf("key:%d", apiKey.ID))
+	if err != nil {
+		return fmt.Errorf("failed to get key details from store: %w", err)
+	}
+
+	cooldownCount, _ := strconv.Atoi(keyDetails["cooldown_count"])
+	cooldownCount++
+	duration := cooldownDuration(group, retryAfter, cooldownCount)
+	cooldownUntil := time.Now().Add(duration)
+
+	// 只有仍为 active 的 key 进入冷却，已失效或已在冷却中的 key 不重复处理。数据库由写回队列异步写入
+	t := p.keyMove(apiKey.ID, group.ID, models.KeyStatusCooldown, models.KeyStatusActive)
+	t.Set = map[string]any{
+		"cooldown_until": cooldownUntil.Unix(),
+		"cooldown_count": cooldownCount,
+	}
+	result, err := p.applyKeyTransition(t)
+	if err != nil || !result.Changed {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"keyID":    apiKey.ID,
+		"groupID":  group.ID,
+		"duration": duration,
+		"count":    cooldownCount,
+	}).Info("Key is cooling down after rate limit")
+
+	// 本节点到期后立即恢复，其他情况由定期扫描兜底
+	time.AfterFunc(duration, func() {
+		if err := p.restoreCooledDownKey(apiKey.ID, group.ID); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to restore key from cooldown")
+		}
+	})
+	return nil
+}
+
+// restoreCooledDownKey 按 store 中的状态将冷却到期的 key 恢复到活跃列表，数据库由写回队列异步写入。
+// 冷却次数保留到下一次成功请求，用于指数冷却。
+func (p *KeyProvider) restoreCooledDownKey(keyID, groupID uint) error {
+	t := p.keyMove(keyID, groupID, models.KeyStatusActive, models.KeyStatusCooldown)
+	t.DueField = "cooldown_until"
+	t.DueAt = time.Now().Unix()
+	t.Unset = []string{"cooldown_until"}
+
+	result, err := p.applyKeyTransition(t)
+	if err == nil && result.Changed {
+		logrus.WithField("keyID", keyID).Debug("Key cooldown expired, restored to active pool")
+	}
+	return err
+}
+
+// restoreExpiredCooldowns 恢复所有冷却已到期的 key。数据库只用于查找候选 key，
+// 是否恢复由 store 中的状态决定，尚未写回数据库的冷却由设置冷却的节点按时恢复
+func (p *KeyProvider) restoreExpiredCooldowns() {
+	var keys []models.APIKey
+	if err := p.db.Model(&models.APIKey{}).Select("id", "group_id").
+		Where("status = ? AND (cooldown_until IS NULL OR cooldown_until <= ?)", models.KeyStatusCooldown, time.Now()).
+		Find(&keys).Error; err != nil {
+		logrus.WithError(err).Error("Failed to query expired key cooldowns")
+		return
+	}
+
+	for _, key := range keys {
+		if err := p.restoreCooledDownKey(key.ID, key.GroupID); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to restore key from cooldown")
+		}
+	}
+}
+
+// startCooldownSweep 定期恢复冷却到期的 key
+func (p *KeyProvider) startCooldownSweep(ctx context.Context) {
+	ticker := time.NewTicker(cooldownSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.restoreExpiredCooldowns()
+		}
+	}
+}
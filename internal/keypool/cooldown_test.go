@@ -0,0 +1,95 @@
+package keypool
+
+import (
+	"key-flow/internal/models"
+	"key-flow/internal/types"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCooldownKey(t *testing.T) {
+	exponential := types.SystemSettings{KeyCooldownSeconds: 60, KeyCooldownMaxSeconds: 300, KeyCooldownExponential: true}
+	tests := []struct {
+		name         string
+		key          models.APIKey
+		config       types.SystemSettings
+		retryAfter   time.Duration
+		wantDuration time.Duration // 0 表示 key 不进入冷却
+		wantCount    int
+	}{
+		{name: "first cooldown", config: exponential, wantDuration: time.Minute, wantCount: 1},
+		{name: "second cooldown doubles", key: models.APIKey{CooldownCount: 1}, config: exponential, wantDuration: 2 * time.Minute, wantCount: 2},
+		{name: "third cooldown doubles again", key: models.APIKey{CooldownCount: 2}, config: exponential, wantDuration: 4 * time.Minute, wantCount: 3},
+		{name: "capped at max", key: models.APIKey{CooldownCount: 5}, config: exponential, wantDuration: 5 * time.Minute, wantCount: 6},
+		{
+			name:         "fixed duration without exponential",
+			key:          models.APIKey{CooldownCount: 3},
+			config:       types.SystemSettings{KeyCooldownSeconds: 60, KeyCooldownMaxSeconds: 300},
+			wantDuration: time.Minute,
+			wantCount:    4,
+		},
+		{name: "retry after wins", key: models.APIKey{CooldownCount: 3}, config: exponential, retryAfter: 90 * time.Second, wantDuration: 90 * time.Second, wantCount: 4},
+		{name: "invalid key is not cooled down", key: models.APIKey{Status: models.KeyStatusInvalid}, config: exponential},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			addTestKeys(t, p, tt.key, models.APIKey{})
+			group := testGroup(tt.config)
+
+			start := time.Now()
+			if err := p.cooldownKey(&models.APIKey{ID: 1, GroupID: testGroupID}, group, tt.retryAfter); err != nil {
+				t.Fatal(err)
+			}
+			details := keyDetails(t, p, 1)
+
+			if tt.wantDuration == 0 {
+				if details["status"] != tt.key.Status || details["cooldown_until"] != "" {
+					t.Fatalf("status = %q, cooldown_until = %q, want unchanged", details["status"], details["cooldown_until"])
+				}
+				return
+			}
+			if details["status"] != models.KeyStatusCooldown || details["cooldown_count"] != strconv.Itoa(tt.wantCount) {
+				t.Fatalf("status = %q, cooldown_count = %q, want cooldown, %d", details["status"], details["cooldown_count"], tt.wantCount)
+			}
+			until, _ := strconv.ParseInt(details["cooldown_until"], 10, 64)
+			if want := start.Add(tt.wantDuration).Unix(); until < want-1 || until > want+1 {
+				t.Fatalf("cooldown_until = %d, want %d", until, want)
+			}
+
+			// 冷却中的 key 不参与选择
+			for _, strategy := range selectStrategies {
+				apiKey, err := p.SelectKey(testGroupID, SelectConstraints{Strategy: strategy})
+				if err != nil || apiKey.ID != 2 {
+					t.Fatalf("SelectKey(%q) during cooldown = %v, %v, want key 2", strategy, apiKey, err)
+				}
+			}
+
+			// 未到期时不恢复，到期后恢复为 active 并保留冷却次数用于下一次指数冷却
+			if err := p.restoreCooledDownKey(1, testGroupID); err != nil {
+				t.Fatal(err)
+			}
+			if status := keyDetails(t, p, 1)["status"]; status != models.KeyStatusCooldown {
+				t.Fatalf("status before cooldown expiry = %q, want cooldown", status)
+			}
+			must(t, p.store.HSet("key:1", map[string]any{"cooldown_until": time.Now().Add(-time.Second).Unix()}))
+			if err := p.restoreCooledDownKey(1, testGroupID); err != nil {
+				t.Fatal(err)
+			}
+			details = keyDetails(t, p, 1)
+			if details["status"] != models.KeyStatusActive || details["cooldown_count"] != strconv.Itoa(tt.wantCount) {
+				t.Fatalf("after expiry status = %q, cooldown_count = %q, want active, %d", details["status"], details["cooldown_count"], tt.wantCount)
+			}
+
+			// 成功请求清零冷却次数
+			if err := p.handleSuccess(1, testGroupID); err != nil {
+				t.Fatal(err)
+			}
+			if count := keyDetails(t, p, 1)["cooldown_count"]; count != "0" {
+				t.Fatalf("cooldown_count after success = %q, want 0", count)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package keypool
 import (
 	"context"
 	"key-flow/internal/config"
+	"key-flow/internal/election"
 	"key-flow/internal/encryption"
 	"key-flow/internal/models"
 	"sync"
@@ -13,12 +14,14 @@ import (
 	"gorm.io/gorm"
 )
 
-// NewCronChecker is responsible for periodically validating invalid keys.
+// NewCronChecker is responsible for periodically validating invalid keys and sampling active ones.
 type CronChecker struct {
 	DB              *gorm.DB
 	SettingsManager *config.SystemSettingsManager
 	Validator       *KeyValidator
+	KeyProvider     *KeyProvider
 	EncryptionSvc   encryption.Service
+	fence           election.Fence
 	stopChan        chan struct{}
 	wg              sync.WaitGroup
 }
@@ -28,20 +31,26 @@ func NewCronChecker(
 	db *gorm.DB,
 	settingsManager *config.SystemSettingsManager,
 	validator *KeyValidator,
+	keyProvider *KeyProvider,
 	encryptionSvc encryption.Service,
 ) *CronChecker {
 	return &CronChecker{
 		DB:              db,
 		SettingsManager: settingsManager,
 		Validator:       validator,
+		KeyProvider:     keyProvider,
 		EncryptionSvc:   encryptionSvc,
 		stopChan:        make(chan struct{}),
 	}
 }
 
-// Start begins the cron job execution.
-func (s *CronChecker) Start() {
+// Start begins the cron job execution. It can be called again after Stop,
+// once the previous run has exited.
+func (s *CronChecker) Start(fence election.Fence) {
 	logrus.Debug("Starting CronChecker...")
+	s.wg.Wait()
+	s.fence = fence
+	s.stopChan = make(chan struct{})
 	s.wg.Add(1)
 	go s.runLoop()
 }
@@ -103,8 +112,21 @@ func (s *CronChecker) runLoop() {
 	}
 }
 
+// stillLeader 在写入前检查本节点是否仍是启动 CronChecker 时的 leader，锁已被新 leader 接管时跳过写入
+func (s *CronChecker) stillLeader() bool {
+	if s.fence.Valid() {
+		return true
+	}
+	logrus.WithField("token", s.fence.Token()).Warn("CronChecker: Leadership lost, skipping writes")
+	return false
+}
+
 // submitValidationJobs finds groups whose keys need validation and validates them concurrently.
 func (s *CronChecker) submitValidationJobs() {
+	if !s.stillLeader() {
+		return
+	}
+
 	var groups []models.Group
 	if err := s.DB.Where("group_type != ? OR group_type IS NULL", "aggregate").Find(&groups).Error; err != nil {
 		logrus.Errorf("CronChecker: Failed to get groups: %v", err)
@@ -113,6 +135,8 @@ func (s *CronChecker) submitValidationJobs() {
 
 	validationStartTime := time.Now()
 	var wg sync.WaitGroup
+	var sampleMu sync.Mutex
+	var sampleTotal healthSampleResult
 
 	for i := range groups {
 		group := &groups[i]
@@ -124,20 +148,42 @@ func (s *CronChecker) submitValidationJobs() {
 			g := group
 			go func() {
 				defer wg.Done()
+				if !s.stillLeader() {
+					return
+				}
 				s.validateGroupKeys(g)
+
+				sampled := s.sampleActiveKeys(g, validationStartTime)
+				sampleMu.Lock()
+				sampleTotal.eligible += sampled.eligible
+				sampleTotal.sampled += sampled.sampled
+				sampleTotal.failed += sampled.failed
+				sampleMu.Unlock()
 			}()
 		}
 	}
 
 	wg.Wait()
+
+	if sampleTotal.sampled > 0 {
+		logrus.Infof(
+			"CronChecker: Active key health sampling run finished. Eligible: %d, sampled: %d, failed: %d. Duration: %s.",
+			sampleTotal.eligible,
+			sampleTotal.sampled,
+			sampleTotal.failed,
+			time.Since(validationStartTime).String(),
+		)
+	}
 }
 
 // validateGroupKeys validates all invalid keys for a single group concurrently.
 func (s *CronChecker) validateGroupKeys(group *models.Group) {
 	groupProcessStart := time.Now()
 
+	// 只验证已到下次验证时间的 key，失败次数越多的 key 验证间隔越长
 	var invalidKeys []models.APIKey
-	err := s.DB.Where("group_id = ? AND status = ?", group.ID, models.KeyStatusInvalid).Find(&invalidKeys).Error
+	err := s.DB.Where("group_id = ? AND status = ? AND (next_validation_at IS NULL OR next_validation_at <= ?)", group.ID, models.KeyStatusInvalid, groupProcessStart).
+		Find(&invalidKeys).Error
 	if err != nil {
 		logrus.Errorf("CronChecker: Failed to get invalid keys for group %s: %v", group.Name, err)
 		return
@@ -147,11 +193,11 @@ func (s *CronChecker) validateGroupKeys(group *models.Group) {
 		if err := s.DB.Model(group).Update("last_validated_at", time.Now()).Error; err != nil {
 			logrus.Errorf("CronChecker: Failed to update last_validated_at for group %s: %v", group.Name, err)
 		}
-		logrus.Infof("CronChecker: Group '%s' has no invalid keys to check.", group.Name)
+		logrus.Infof("CronChecker: Group '%s' has no invalid keys due for check.", group.Name)
 		return
 	}
 
-	var becameValidCount int32
+	var becameValidCount, archivedCount int32
 	var keyWg sync.WaitGroup
 	jobs := make(chan *models.APIKey, len(invalidKeys))
 
@@ -166,6 +212,10 @@ func (s *CronChecker) validateGroupKeys(group *models.Group) {
 					if !ok {
 						return
 					}
+					// 验证结果会写入 key 状态，失去 leader 身份后不再处理剩余的 key
+					if !s.stillLeader() {
+						continue
+					}
 
 					// Decrypt the key before validation
 					decryptedKey, err := s.EncryptionSvc.Decrypt(key.KeyValue)
@@ -181,6 +231,8 @@ func (s *CronChecker) validateGroupKeys(group *models.Group) {
 					isValid, _, _ := s.Validator.ValidateSingleKey(&keyForValidation, group, false) // 定时验证，不强制禁用
 					if isValid {
 						atomic.AddInt32(&becameValidCount, 1)
+					} else if s.recordValidationFailure(key, group) {
+						atomic.AddInt32(&archivedCount, 1)
 					}
 				case <-s.stopChan:
 					return
@@ -201,16 +253,81 @@ DistributeLoop:
 
 	keyWg.Wait()
 
+	if !s.stillLeader() {
+		return
+	}
 	if err := s.DB.Model(group).Update("last_validated_at", time.Now()).Error; err != nil {
 		logrus.Errorf("CronChecker: Failed to update last_validated_at for group %s: %v", group.Name, err)
 	}
 
 	duration := time.Since(groupProcessStart)
 	logrus.Infof(
-		"CronChecker: Group '%s' validation finished. Total checked: %d, became valid: %d. Duration: %s.",
+		"CronChecker: Group '%s' validation finished. Total checked: %d, became valid: %d, archived: %d. Duration: %s.",
 		group.Name,
 		len(invalidKeys),
 		becameValidCount,
+		archivedCount,
 		duration.String(),
 	)
 }
+
+// validationBackoff 返回连续验证失败 failures 次后到下次验证的间隔。
+// 从分组的验证间隔开始每次翻倍，不超过 KeyValidationBackoffMaxMinutes，为0时不退避。
+func validationBackoff(group *models.Group, failures int) time.Duration {
+	interval := time.Duration(group.EffectiveConfig.KeyValidationIntervalMinutes) * time.Minute
+	maxInterval := time.Duration(group.EffectiveConfig.KeyValidationBackoffMaxMinutes) * time.Minute
+	if maxInterval <= 0 || maxInterval <= interval {
+		return interval
+	}
+
+	for i := 1; i < failures && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	return min(interval, maxInterval)
+}
+
+// recordValidationFailure 记录一次定时验证失败并安排下次验证时间。
+// 连续失败超过 KeyArchiveAfterDays 天的 key 会被归档，返回 true。
+func (s *CronChecker) recordValidationFailure(key *models.APIKey, group *models.Group) bool {
+	now := time.Now()
+	failures := key.ValidationFailures + 1
+	failingSince := now
+	if key.ValidationFailingSince != nil {
+		failingSince = *key.ValidationFailingSince
+	}
+
+	updates := map[string]any{
+		"validation_failures":      failures,
+		"validation_failing_since": failingSince,
+		"next_validation_at":       now.Add(validationBackoff(group, failures)),
+	}
+
+	archiveAfter := time.Duration(group.EffectiveConfig.KeyArchiveAfterDays) * 24 * time.Hour
+	archive := archiveAfter > 0 && now.Sub(failingSince) >= archiveAfter
+	if archive {
+		updates["status"] = models.KeyStatusArchived
+		updates["next_validation_at"] = nil
+	}
+
+	// 仅在 key 仍为失效状态时更新，避免覆盖并发恢复的结果
+	result := s.DB.Model(&models.APIKey{}).
+		Where("id = ? AND status = ?", key.ID, models.KeyStatusInvalid).
+		Updates(updates)
+	if result.Error != nil {
+		logrus.WithError(result.Error).WithField("key_id", key.ID).Error("CronChecker: Failed to record validation failure")
+		return false
+	}
+	if result.RowsAffected == 0 || !archive {
+		return false
+	}
+
+	if _, err := s.KeyProvider.applyKeyTransition(s.KeyProvider.keyMove(key.ID, key.GroupID, models.KeyStatusArchived, models.KeyStatusInvalid)); err != nil {
+		logrus.WithError(err).WithField("key_id", key.ID).Error("CronChecker: Failed to update archived key in store")
+	}
+	logrus.WithFields(logrus.Fields{
+		"key_id":        key.ID,
+		"group":         group.Name,
+		"failing_since": failingSince,
+	}).Warn("CronChecker: Key archived after failing validation for too long")
+	return true
+}
@@ -0,0 +1,170 @@
+package keypool
+
+import (
+	"context"
+	"key-flow/internal/election"
+	"key-flow/internal/models"
+	"key-flow/internal/store"
+	"key-flow/internal/types"
+	"testing"
+	"time"
+)
+
+// testConfigManager 只提供 elector 需要的服务器配置和快照配置
+type testConfigManager struct {
+	types.ConfigManager
+	server   types.ServerConfig
+	snapshot types.SnapshotConfig
+}
+
+func (m testConfigManager) GetEffectiveServerConfig() types.ServerConfig {
+	return m.server
+}
+
+func (m testConfigManager) GetSnapshotConfig() types.SnapshotConfig {
+	return m.snapshot
+}
+
+// fenceRecorder 记录 elector 启动 leader 服务时传入的 Fence
+type fenceRecorder struct {
+	fence election.Fence
+}
+
+func (r *fenceRecorder) Start(fence election.Fence) { r.fence = fence }
+func (r *fenceRecorder) Stop(ctx context.Context)   {}
+
+func TestCronCheckerStillLeader(t *testing.T) {
+	s := store.NewMemoryStore()
+	leader := election.NewElector(testConfigManager{server: types.ServerConfig{IsMaster: true, LeaderElection: true}}, s)
+	recorder := &fenceRecorder{}
+	leader.Register(recorder)
+	if ok, err := leader.Campaign(); !ok || err != nil {
+		t.Fatalf("Campaign() = %v, %v", ok, err)
+	}
+	leader.Start()
+	defer leader.Stop(context.Background())
+
+	checker := &CronChecker{}
+	checker.fence = recorder.fence
+	if !checker.stillLeader() {
+		t.Fatal("stillLeader() should be true while the fence is current")
+	}
+
+	// 固定的 master 接管锁后，旧任期的 Fence 失效
+	master := election.NewElector(testConfigManager{server: types.ServerConfig{IsMaster: true, LeaderElection: true, RolePinned: true}}, s)
+	if ok, err := master.Campaign(); !ok || err != nil {
+		t.Fatalf("master Campaign() = %v, %v", ok, err)
+	}
+	if checker.stillLeader() {
+		t.Fatal("stillLeader() should be false once another node holds the leader lock")
+	}
+}
+
+func TestValidationBackoff(t *testing.T) {
+	tests := []struct {
+		name        string
+		interval    int
+		maxMinutes  int
+		failures    int
+		wantBackoff time.Duration
+	}{
+		{name: "first failure uses interval", interval: 10, maxMinutes: 120, failures: 1, wantBackoff: 10 * time.Minute},
+		{name: "doubles per failure", interval: 10, maxMinutes: 120, failures: 3, wantBackoff: 40 * time.Minute},
+		{name: "capped at max", interval: 10, maxMinutes: 120, failures: 10, wantBackoff: 120 * time.Minute},
+		{name: "zero max disables backoff", interval: 10, failures: 10, wantBackoff: 10 * time.Minute},
+		{name: "max below interval", interval: 60, maxMinutes: 30, failures: 5, wantBackoff: 60 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := testGroup(types.SystemSettings{KeyValidationIntervalMinutes: tt.interval, KeyValidationBackoffMaxMinutes: tt.maxMinutes})
+			if got := validationBackoff(group, tt.failures); got != tt.wantBackoff {
+				t.Fatalf("validationBackoff(%d) = %s, want %s", tt.failures, got, tt.wantBackoff)
+			}
+		})
+	}
+}
+
+func TestRecordValidationFailure(t *testing.T) {
+	longAgo := time.Now().Add(-10 * 24 * time.Hour)
+	config := types.SystemSettings{KeyValidationIntervalMinutes: 10, KeyValidationBackoffMaxMinutes: 120}
+	tests := []struct {
+		name         string
+		key          models.APIKey
+		archiveDays  int
+		wantArchived bool
+		wantFailures int
+		wantNext     time.Duration // 距离现在的下次验证间隔，归档时为 0
+	}{
+		{
+			name:         "first failure",
+			key:          models.APIKey{Status: models.KeyStatusInvalid},
+			wantFailures: 1,
+			wantNext:     10 * time.Minute,
+		},
+		{
+			name:         "repeated failure backs off",
+			key:          models.APIKey{Status: models.KeyStatusInvalid, ValidationFailures: 2, ValidationFailingSince: &longAgo},
+			archiveDays:  30,
+			wantFailures: 3,
+			wantNext:     40 * time.Minute,
+		},
+		{
+			name:         "failing longer than archive window",
+			key:          models.APIKey{Status: models.KeyStatusInvalid, ValidationFailures: 5, ValidationFailingSince: &longAgo},
+			archiveDays:  7,
+			wantArchived: true,
+			wantFailures: 6,
+		},
+		{
+			name:         "key restored concurrently is left alone",
+			key:          models.APIKey{Status: models.KeyStatusActive, ValidationFailures: 2},
+			archiveDays:  7,
+			wantFailures: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			addTestKeys(t, p, tt.key)
+			checker := &CronChecker{DB: p.db, KeyProvider: p}
+			cfg := config
+			cfg.KeyArchiveAfterDays = tt.archiveDays
+
+			key := tt.key
+			key.ID, key.GroupID = 1, testGroupID
+			start := time.Now()
+			if archived := checker.recordValidationFailure(&key, testGroup(cfg)); archived != tt.wantArchived {
+				t.Fatalf("recordValidationFailure() = %v, want %v", archived, tt.wantArchived)
+			}
+
+			var stored models.APIKey
+			if err := p.db.First(&stored, 1).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.ValidationFailures != tt.wantFailures {
+				t.Errorf("validation_failures = %d, want %d", stored.ValidationFailures, tt.wantFailures)
+			}
+			switch {
+			case tt.wantNext > 0:
+				if stored.NextValidationAt == nil || stored.NextValidationAt.Sub(start.Add(tt.wantNext)).Abs() > time.Second {
+					t.Errorf("next_validation_at = %v, want %v", stored.NextValidationAt, start.Add(tt.wantNext))
+				}
+			case stored.NextValidationAt != nil:
+				t.Errorf("next_validation_at = %v, want nil", stored.NextValidationAt)
+			}
+
+			wantStatus := tt.key.Status
+			if tt.wantArchived {
+				wantStatus = models.KeyStatusArchived
+			}
+			if stored.Status != wantStatus {
+				t.Errorf("DB status = %q, want %q", stored.Status, wantStatus)
+			}
+			if status := keyDetails(t, p, 1)["status"]; status != wantStatus {
+				t.Errorf("store status = %q, want %q", status, wantStatus)
+			}
+		})
+	}
+}
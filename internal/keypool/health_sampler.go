@@ -0,0 +1,115 @@
+package keypool
+
+import (
+	"key-flow/internal/models"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// healthSampleResult 汇总一次抽样检查的结果
+type healthSampleResult struct {
+	eligible int
+	sampled  int
+	failed   int32
+}
+
+// sampleActiveKeys 按比例抽样检查分组内的活跃 key，最近有成功代理请求的 key 不参与抽样。
+// 验证失败的 key 会经过 ValidateSingleKey 走正常的 UpdateStatus 失败路径。
+func (s *CronChecker) sampleActiveKeys(group *models.Group, startTime time.Time) healthSampleResult {
+	var result healthSampleResult
+
+	percent := group.EffectiveConfig.ActiveKeySamplePercent
+	if percent <= 0 {
+		return result
+	}
+
+	query := s.DB.Where("group_id = ? AND status = ?", group.ID, models.KeyStatusActive)
+	if skipMinutes := group.EffectiveConfig.ActiveKeySampleSkipMinutes; skipMinutes > 0 {
+		// last_used_at 只在请求成功时更新
+		cutoff := startTime.Add(-time.Duration(skipMinutes) * time.Minute)
+		query = query.Where("last_used_at IS NULL OR last_used_at < ?", cutoff)
+	}
+
+	var candidates []models.APIKey
+	if err := query.Find(&candidates).Error; err != nil {
+		logrus.Errorf("CronChecker: Failed to get active keys to sample for group %s: %v", group.Name, err)
+		return result
+	}
+
+	result.eligible = len(candidates)
+	if result.eligible == 0 {
+		return result
+	}
+
+	sampleSize := int(math.Ceil(float64(len(candidates)) * float64(min(percent, 100)) / 100))
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	samples := candidates[:sampleSize]
+
+	var keyWg sync.WaitGroup
+	jobs := make(chan *models.APIKey, len(samples))
+
+	for range group.EffectiveConfig.KeyValidationConcurrency {
+		keyWg.Add(1)
+		go func() {
+			defer keyWg.Done()
+			for {
+				select {
+				case key, ok := <-jobs:
+					if !ok {
+						return
+					}
+
+					decryptedKey, err := s.EncryptionSvc.Decrypt(key.KeyValue)
+					if err != nil {
+						logrus.WithError(err).WithField("key_id", key.ID).Error("CronChecker: Failed to decrypt key for health sampling, skipping")
+						continue
+					}
+
+					keyForValidation := *key
+					keyForValidation.KeyValue = decryptedKey
+
+					isValid, _, err := s.Validator.ValidateSingleKey(&keyForValidation, group, false)
+					if !isValid {
+						atomic.AddInt32(&result.failed, 1)
+						logrus.WithFields(logrus.Fields{
+							"key_id": key.ID,
+							"group":  group.Name,
+							"error":  err,
+						}).Debug("CronChecker: Sampled active key failed validation")
+					}
+				case <-s.stopChan:
+					return
+				}
+			}
+		}()
+	}
+
+DistributeLoop:
+	for i := range samples {
+		select {
+		case jobs <- &samples[i]:
+			result.sampled++
+		case <-s.stopChan:
+			break DistributeLoop
+		}
+	}
+	close(jobs)
+
+	keyWg.Wait()
+
+	logrus.Infof(
+		"CronChecker: Group '%s' health sampling finished. Eligible: %d, sampled: %d, failed: %d.",
+		group.Name,
+		result.eligible,
+		result.sampled,
+		result.failed,
+	)
+	return result
+}
@@ -0,0 +1,211 @@
+package keypool
+
+import (
+	"encoding/json"
+	"key-flow/internal/channel"
+	"key-flow/internal/config"
+	"key-flow/internal/httpclient"
+	"key-flow/internal/models"
+	"key-flow/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sampleUpstream 是 custom 渠道的上游，记录被验证的 key，对 failing 中的 key 返回 401
+type sampleUpstream struct {
+	mu      sync.Mutex
+	checked []string
+	failing map[string]bool
+}
+
+func (u *sampleUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	u.mu.Lock()
+	u.checked = append(u.checked, key)
+	u.mu.Unlock()
+	if u.failing[key] {
+		http.Error(w, `{"error":{"message":"invalid api key"}}`, http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (u *sampleUpstream) checkedKeys() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	keys := slices.Clone(u.checked)
+	slices.Sort(keys)
+	return keys
+}
+
+// newSampleChecker 构造使用真实 KeyValidator 的 CronChecker，验证请求发往 upstream
+func newSampleChecker(t *testing.T, p *KeyProvider, upstream http.Handler, cfg types.SystemSettings) (*CronChecker, *models.Group) {
+	t.Helper()
+	srv := httptest.NewServer(upstream)
+	t.Cleanup(srv.Close)
+
+	upstreams, err := json.Marshal([]map[string]any{{"url": srv.URL, "weight": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AppUrl = "http://localhost"
+	cfg.KeyValidationConcurrency = 2
+	cfg.KeyValidationTimeoutSeconds = 5
+	group := testGroup(cfg)
+	group.ChannelType = "custom"
+	group.Upstreams = upstreams
+	group.TestModel = "test-model"
+
+	validator := &KeyValidator{
+		DB:              p.db,
+		channelFactory:  channel.NewFactory(config.NewSystemSettingsManager(), httpclient.NewHTTPClientManager()),
+		keypoolProvider: p,
+		encryptionSvc:   p.encryptionSvc,
+	}
+	checker := &CronChecker{
+		DB:            p.db,
+		Validator:     validator,
+		KeyProvider:   p,
+		EncryptionSvc: p.encryptionSvc,
+		stopChan:      make(chan struct{}),
+	}
+	return checker, group
+}
+
+func TestSampleActiveKeys(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Minute)
+	stale := now.Add(-time.Hour)
+	fourActive := []models.APIKey{{}, {}, {}, {}}
+
+	tests := []struct {
+		name        string
+		keys        []models.APIKey
+		percent     int
+		skipMinutes int
+		failing     []string
+		want        healthSampleResult
+		wantChecked []string // 为空时只检查数量
+		wantStatus  map[uint]string
+	}{
+		{
+			name:    "sampling disabled",
+			keys:    fourActive,
+			percent: 0,
+			want:    healthSampleResult{},
+		},
+		{
+			name:    "half of the active keys",
+			keys:    fourActive,
+			percent: 50,
+			want:    healthSampleResult{eligible: 4, sampled: 2},
+		},
+		{
+			name:    "sample size rounds up",
+			keys:    fourActive,
+			percent: 30,
+			want:    healthSampleResult{eligible: 4, sampled: 2},
+		},
+		{
+			name:        "all active keys",
+			keys:        []models.APIKey{{}, {Status: models.KeyStatusInvalid}, {}},
+			percent:     100,
+			want:        healthSampleResult{eligible: 2, sampled: 2},
+			wantChecked: []string{"sk-test-1", "sk-test-3"},
+		},
+		{
+			name: "recently succeeded keys skipped",
+			keys: []models.APIKey{
+				{LastUsedAt: &recent},
+				{LastUsedAt: &stale},
+				{},
+				{LastUsedAt: &recent},
+			},
+			percent:     100,
+			skipMinutes: 30,
+			want:        healthSampleResult{eligible: 2, sampled: 2},
+			wantChecked: []string{"sk-test-2", "sk-test-3"},
+		},
+		{
+			name:        "skip disabled samples recently used keys",
+			keys:        []models.APIKey{{LastUsedAt: &recent}, {LastUsedAt: &recent}},
+			percent:     100,
+			want:        healthSampleResult{eligible: 2, sampled: 2},
+			wantChecked: []string{"sk-test-1", "sk-test-2"},
+		},
+		{
+			name:        "no eligible keys",
+			keys:        []models.APIKey{{LastUsedAt: &recent}, {Status: models.KeyStatusInvalid}},
+			percent:     100,
+			skipMinutes: 30,
+			want:        healthSampleResult{},
+		},
+		{
+			name:        "failures go through UpdateStatus",
+			keys:        []models.APIKey{{}, {}, {}},
+			percent:     100,
+			failing:     []string{"sk-test-2"},
+			want:        healthSampleResult{eligible: 3, sampled: 3, failed: 1},
+			wantChecked: []string{"sk-test-1", "sk-test-2", "sk-test-3"},
+			wantStatus:  map[uint]string{1: models.KeyStatusActive, 2: models.KeyStatusInvalid, 3: models.KeyStatusActive},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			addTestKeys(t, p, slices.Clone(tt.keys)...)
+			upstream := &sampleUpstream{failing: make(map[string]bool)}
+			for _, key := range tt.failing {
+				upstream.failing[key] = true
+			}
+			checker, group := newSampleChecker(t, p, upstream, types.SystemSettings{
+				ActiveKeySamplePercent:     tt.percent,
+				ActiveKeySampleSkipMinutes: tt.skipMinutes,
+				BlacklistThreshold:         1,
+			})
+
+			got := checker.sampleActiveKeys(group, now)
+			if got != tt.want {
+				t.Fatalf("sampleActiveKeys() = %+v, want %+v", got, tt.want)
+			}
+
+			checked := upstream.checkedKeys()
+			if len(checked) != tt.want.sampled {
+				t.Errorf("upstream checked %d keys, want %d", len(checked), tt.want.sampled)
+			}
+			if tt.wantChecked != nil && !slices.Equal(checked, tt.wantChecked) {
+				t.Errorf("checked keys = %v, want %v", checked, tt.wantChecked)
+			}
+
+			for keyID, want := range tt.wantStatus {
+				details := waitForKeyDetails(t, p, keyID, func(d map[string]string) bool { return d["status"] == want })
+				if details["status"] != want {
+					t.Errorf("key %d status = %q, want %q", keyID, details["status"], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSampleActiveKeysStopsOnShutdown(t *testing.T) {
+	p := newTestProvider(t)
+	addTestKeys(t, p, models.APIKey{}, models.APIKey{})
+	upstream := &sampleUpstream{failing: make(map[string]bool)}
+	checker, group := newSampleChecker(t, p, upstream, types.SystemSettings{ActiveKeySamplePercent: 100})
+
+	// 停止后不再分发新的 key，已分发的 key 可能仍被验证
+	close(checker.stopChan)
+	got := checker.sampleActiveKeys(group, time.Now())
+	if got.eligible != 2 || got.failed != 0 {
+		t.Fatalf("sampleActiveKeys() = %+v, want eligible 2 and no failures", got)
+	}
+	if checked := len(upstream.checkedKeys()); checked > got.sampled {
+		t.Fatalf("upstream checked %d keys, more than the %d sampled", checked, got.sampled)
+	}
+}
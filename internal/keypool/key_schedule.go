@@ -0,0 +1,98 @@
+package keypool
+
+import (
+	"context"
+	"fmt"
+	"key-flow/internal/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// keyScheduleSweepInterval 是检查 key 生效/过期时间的间隔
+const keyScheduleSweepInterval = 30 * time.Second
+
+// ScheduledStatus 返回有效期窗口之外的 key 应处于的状态：未到生效时间为 scheduled，
+// 已过期为 expired，在窗口内返回空字符串。
+func ScheduledStatus(activatesAt, expiresAt *time.Time, now time.Time) string {
+	if expiresAt != nil && !expiresAt.After(now) {
+		return models.KeyStatusExpired
+	}
+	if activatesAt != nil && activatesAt.After(now) {
+		return models.KeyStatusScheduled
+	}
+	return ""
+}
+
+// ValidateSchedule 检查生效时间早于过期时间
+func ValidateSchedule(activatesAt, expiresAt *time.Time) error {
+	if activatesAt != nil && expiresAt != nil && !expiresAt.After(*activatesAt) {
+		return fmt.Errorf("expires_at must be later than activates_at")
+	}
+	return nil
+}
+
+// applyKeySchedules 将过期的 key 移出活跃列表，并激活到达生效时间的 key。
+// 生效和过期时间只保存在数据库中，用于查找候选 key；状态在 store 中原子地变更，由写回队列写入数据库
+func (p *KeyProvider) applyKeySchedules() {
+	now := time.Now()
+
+	var expiredKeys []models.APIKey
+	if err := p.db.Model(&models.APIKey{}).Select("id", "group_id").
+		Where("expires_at IS NOT NULL AND expires_at <= ? AND status <> ?", now, models.KeyStatusExpired).
+		Find(&expiredKeys).Error; err != nil {
+		logrus.WithError(err).Error("Failed to query expired keys")
+	}
+	expired := 0
+	for _, key := range expiredKeys {
+		result, err := p.applyKeyTransition(p.keyMove(key.ID, key.GroupID, models.KeyStatusExpired))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to expire key")
+			continue
+		}
+		if result.Changed {
+			expired++
+		}
+	}
+
+	var dueKeys []models.APIKey
+	if err := p.db.Model(&models.APIKey{}).Select("id", "group_id").
+		Where("status = ? AND (activates_at IS NULL OR activates_at <= ?) AND (expires_at IS NULL OR expires_at > ?)",
+			models.KeyStatusScheduled, now, now).
+		Find(&dueKeys).Error; err != nil {
+		logrus.WithError(err).Error("Failed to query scheduled keys")
+	}
+	activated := 0
+	for _, key := range dueKeys {
+		result, err := p.applyKeyTransition(p.keyMove(key.ID, key.GroupID, models.KeyStatusActive, models.KeyStatusScheduled))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to activate scheduled key")
+			continue
+		}
+		if result.Changed {
+			activated++
+		}
+	}
+
+	if expired > 0 || activated > 0 {
+		logrus.WithFields(logrus.Fields{
+			"expired":   expired,
+			"activated": activated,
+		}).Info("Applied key schedules")
+	}
+}
+
+// startKeyScheduleSweep 定期应用 key 的生效和过期时间
+func (p *KeyProvider) startKeyScheduleSweep(ctx context.Context) {
+	ticker := time.NewTicker(keyScheduleSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.applyKeySchedules()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package keypool
+
+import (
+	"errors"
+	app_errors "key-flow/internal/errors"
+	"key-flow/internal/models"
+	"testing"
+	"time"
+)
+
+func TestApplyKeySchedules(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name         string
+		key          models.APIKey
+		wantStatus   string
+		wantSelected bool
+	}{
+		{
+			name:         "active key inside window",
+			key:          models.APIKey{ActivatesAt: &past, ExpiresAt: &future},
+			wantStatus:   models.KeyStatusActive,
+			wantSelected: true,
+		},
+		{
+			name:       "active key past expiry",
+			key:        models.APIKey{ExpiresAt: &past},
+			wantStatus: models.KeyStatusExpired,
+		},
+		{
+			name:         "scheduled key due",
+			key:          models.APIKey{Status: models.KeyStatusScheduled, ActivatesAt: &past, ExpiresAt: &future},
+			wantStatus:   models.KeyStatusActive,
+			wantSelected: true,
+		},
+		{
+			name:       "scheduled key not yet due",
+			key:        models.APIKey{Status: models.KeyStatusScheduled, ActivatesAt: &future},
+			wantStatus: models.KeyStatusScheduled,
+		},
+		{
+			name:       "scheduled key expired before activation",
+			key:        models.APIKey{Status: models.KeyStatusScheduled, ActivatesAt: &past, ExpiresAt: &past},
+			wantStatus: models.KeyStatusExpired,
+		},
+		{
+			name:       "invalid key past expiry",
+			key:        models.APIKey{Status: models.KeyStatusInvalid, ExpiresAt: &past},
+			wantStatus: models.KeyStatusExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			addTestKeys(t, p, tt.key)
+
+			p.applyKeySchedules()
+			if status := keyDetails(t, p, 1)["status"]; status != tt.wantStatus {
+				t.Fatalf("status = %q, want %q", status, tt.wantStatus)
+			}
+
+			for _, strategy := range selectStrategies {
+				apiKey, err := p.SelectKey(testGroupID, SelectConstraints{Strategy: strategy})
+				if tt.wantSelected {
+					if err != nil || apiKey.ID != 1 {
+						t.Fatalf("SelectKey(%q) = %v, %v, want key 1", strategy, apiKey, err)
+					}
+				} else if !errors.Is(err, app_errors.ErrNoActiveKeys) {
+					t.Fatalf("SelectKey(%q) err = %v, want ErrNoActiveKeys", strategy, err)
+				}
+			}
+		})
+	}
+}
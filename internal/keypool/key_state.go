@@ -0,0 +1,291 @@
+package keypool
+
+import (
+	"context"
+	"fmt"
+	"key-flow/internal/models"
+	"key-flow/internal/store"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// key 的失败次数、冷却次数和状态变更先写入 store，再由写回队列批量异步写入数据库。
+// store 是实时状态的唯一来源，数据库最终一致。
+const (
+	// pendingKeyStatesSet 记录 store 中状态已变更、尚未写入数据库的 key ID，所有节点共享，同一 key 只保留一条
+	pendingKeyStatesSet = "pending_key_states"
+	// keyStateFlushInterval 是 key 状态批量写入数据库的间隔
+	keyStateFlushInterval = time.Second
+	// keyStateFlushBatchSize 是每个事务写入的 key 数量，本节点入队达到该数量时立即写入
+	keyStateFlushBatchSize = 500
+)
+
+// KeyStateQueueStats 是 key 状态写回队列的运行指标
+type KeyStateQueueStats struct {
+	Depth          int64     `json:"depth"`
+	LastFlushAt    time.Time `json:"last_flush_at"`
+	LastFlushCount int       `json:"last_flush_count"`
+	LastFlushError string    `json:"last_flush_error,omitempty"`
+}
+
+// keyStateQueue 保存本节点写回队列的触发信号和指标
+type keyStateQueue struct {
+	signal  chan struct{}
+	done    chan struct{}
+	pending atomic.Int64 // 本节点上次写入后入队的数量
+
+	mu    sync.Mutex
+	stats KeyStateQueueStats
+}
+
+func newKeyStateQueue() *keyStateQueue {
+	return &keyStateQueue{
+		signal: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+var (
+	// successRestoredStatuses 是请求成功时恢复为 active 的 key 状态。只有失效的 key 由成功请求恢复，
+	// 冷却、配额耗尽、归档、未生效和已过期的 key 由各自的流程恢复，进行中的请求成功不能让它们提前回到轮询
+	successRestoredStatuses = []string{models.KeyStatusInvalid}
+	// failureSkippedStatuses 是请求失败时不计数的 key 状态
+	failureSkippedStatuses = []string{
+		models.KeyStatusInvalid, models.KeyStatusArchived, models.KeyStatusScheduled, models.KeyStatusExpired,
+	}
+)
+
+// keyTransition 构造 key 的 store 状态变更，失败阈值和移动的目标状态由调用方设置
+func (p *KeyProvider) keyTransition(keyID, groupID uint, kind store.KeyTransitionKind) store.KeyTransition {
+	t := store.KeyTransition{
+		HashKey:      fmt.Sprintf("key:%d", keyID),
+		ListKey:      fmt.Sprintf("group:%d:active_keys", groupID),
+		IndexKey:     weightIndexKey(groupID),
+		PendingKey:   pendingKeyStatesSet,
+		StatsKey:     lastUsedKey(groupID),
+		KeyID:        strconv.FormatUint(uint64(keyID), 10),
+		Kind:         kind,
+		ActiveStatus: models.KeyStatusActive,
+	}
+	switch kind {
+	case store.KeyTransitionSuccess:
+		t.RestoreStatuses = successRestoredStatuses
+	case store.KeyTransitionFailure:
+		t.SkipStatuses = failureSkippedStatuses
+		t.DisabledStatus = models.KeyStatusInvalid
+	}
+	return t
+}
+
+// keyMove 构造将 key 从 from 中的状态移动到 to 的状态变更，from 为空时允许任意状态
+func (p *KeyProvider) keyMove(keyID, groupID uint, to string, from ...string) store.KeyTransition {
+	t := p.keyTransition(keyID, groupID, store.KeyTransitionMove)
+	t.ToStatus = to
+	t.FromStatuses = from
+	return t
+}
+
+// applyKeyTransition 在 store 中原子地应用状态变更，变更后的 key 由写回队列写入数据库
+func (p *KeyProvider) applyKeyTransition(t store.KeyTransition) (store.KeyTransitionResult, error) {
+	result, err := p.store.ApplyKeyTransition(t)
+	if err != nil {
+		return result, fmt.Errorf("failed to apply key %s transition in store: %w", t.Kind, err)
+	}
+	if result.Changed {
+		p.keyStateQueued()
+	}
+	return result, nil
+}
+
+// keyStateQueued 记录一次入队，达到批量大小时提前触发写入
+func (p *KeyProvider) keyStateQueued() {
+	if p.keyStates.pending.Add(1) < keyStateFlushBatchSize {
+		return
+	}
+	select {
+	case p.keyStates.signal <- struct{}{}:
+	default:
+	}
+}
+
+// KeyStateQueueStats 返回 key 状态写回队列的当前深度和最近一次写入的结果
+func (p *KeyProvider) KeyStateQueueStats() KeyStateQueueStats {
+	p.keyStates.mu.Lock()
+	stats := p.keyStates.stats
+	p.keyStates.mu.Unlock()
+
+	depth, err := p.store.SCard(pendingKeyStatesSet)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to get key state queue depth")
+	}
+	stats.Depth = depth
+	return stats
+}
+
+// startKeyStateFlush 定期或入队达到批量大小时将 store 中变更的 key 状态写入数据库，退出前再写入一次
+func (p *KeyProvider) startKeyStateFlush(ctx context.Context) {
+	defer close(p.keyStates.done)
+
+	ticker := time.NewTicker(keyStateFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.flushKeyStates()
+			return
+		case <-ticker.C:
+			p.flushKeyStates()
+		case <-p.keyStates.signal:
+			p.flushKeyStates()
+		}
+	}
+}
+
+// flushKeyStates 取出待持久化的 key，按 store 中的当前状态写入数据库
+func (p *KeyProvider) flushKeyStates() {
+	p.keyStates.pending.Store(0)
+
+	flushed := 0
+	var flushErr error
+	for {
+		members, err := p.store.SPopN(pendingKeyStatesSet, keyStateFlushBatchSize)
+		if err != nil {
+			flushErr = fmt.Errorf("failed to pop pending key states: %w", err)
+			break
+		}
+		if len(members) == 0 {
+			break
+		}
+
+		if err := p.persistKeyStates(members); err != nil {
+			flushErr = err
+			// 放回队列，下次再写入
+			if err := p.store.SAdd(pendingKeyStatesSet, stringsToAny(members)...); err != nil {
+				logrus.WithError(err).Error("Failed to requeue pending key states")
+			}
+			break
+		}
+		flushed += len(members)
+
+		if len(members) < keyStateFlushBatchSize {
+			break
+		}
+	}
+
+	if flushErr != nil {
+		logrus.WithError(flushErr).Error("Failed to persist key states, will retry")
+	} else if flushed > 0 {
+		logrus.WithField("count", flushed).Debug("Persisted key states to database")
+	}
+
+	if flushed == 0 && flushErr == nil {
+		return
+	}
+	p.keyStates.mu.Lock()
+	p.keyStates.stats.LastFlushAt = time.Now()
+	p.keyStates.stats.LastFlushCount = flushed
+	p.keyStates.stats.LastFlushError = ""
+	if flushErr != nil {
+		p.keyStates.stats.LastFlushError = flushErr.Error()
+	}
+	p.keyStates.mu.Unlock()
+}
+
+// persistKeyStates 在一个事务中写入一批 key 的失败次数、冷却次数和状态。
+// 写入的是 store 中的最新值，同一 key 多次变更只写一次。
+func (p *KeyProvider) persistKeyStates(members []string) error {
+	updates := make(map[uint64]map[string]any, len(members))
+	for _, member := range members {
+		keyID, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		details, err := p.store.HGetAll(fmt.Sprintf("key:%d", keyID))
+		if err != nil {
+			return fmt.Errorf("failed to get key details for key %d: %w", keyID, err)
+		}
+		if details["status"] == "" {
+			// key 已被删除
+			continue
+		}
+		updates[keyID] = keyStateUpdates(details)
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return p.executeTransactionWithRetry(func(tx *gorm.DB) error {
+		for keyID, update := range updates {
+			if err := tx.Model(&models.APIKey{}).Where("id = ?", keyID).Updates(update).Error; err != nil {
+				return fmt.Errorf("failed to update key %d in DB: %w", keyID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// keyStateUpdates 将 store 中的 key 详情转换为数据库更新。
+// 只写入写回队列负责的字段，验证退避、生效时间等字段由各自的流程直接写入数据库。
+func keyStateUpdates(details map[string]string) map[string]any {
+	failureCount, _ := strconv.ParseInt(details["failure_count"], 10, 64)
+	cooldownCount, _ := strconv.Atoi(details["cooldown_count"])
+	update := map[string]any{"failure_count": failureCount, "cooldown_count": cooldownCount}
+
+	switch status := details["status"]; status {
+	case models.KeyStatusActive:
+		update["status"] = status
+		update["cooldown_until"] = nil
+		update["quota_reset_at"] = nil
+		// 恢复后清除验证退避状态，下次失效时重新计算
+		update["validation_failures"] = 0
+		update["validation_failing_since"] = nil
+		update["next_validation_at"] = nil
+	case models.KeyStatusInvalid:
+		update["status"] = status
+	case models.KeyStatusCooldown:
+		update["status"] = status
+		update["cooldown_until"] = unixTimeField(details["cooldown_until"])
+	case models.KeyStatusQuotaExhausted:
+		update["status"] = status
+		update["quota_reset_at"] = unixTimeField(details["quota_reset_at"])
+	case models.KeyStatusArchived, models.KeyStatusScheduled, models.KeyStatusExpired:
+		update["status"] = status
+	}
+	return update
+}
+
+// unixTimeField 解析 store 中以 Unix 秒保存的时间，未设置时返回 nil
+func unixTimeField(value string) any {
+	seconds, _ := strconv.ParseInt(value, 10, 64)
+	if seconds <= 0 {
+		return nil
+	}
+	return time.Unix(seconds, 0)
+}
+
+// Stop 停止 KeyProvider 的后台任务，并在退出前将写回队列中的 key 状态写入数据库
+func (p *KeyProvider) Stop(ctx context.Context) {
+	p.StopCacheHitCleanup()
+
+	select {
+	case <-p.keyStates.done:
+		logrus.Info("Key state queue flushed.")
+	case <-ctx.Done():
+		logrus.Warn("Key state queue flush timed out.")
+	}
+}
+
+func stringsToAny(values []string) []any {
+	result := make([]any, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
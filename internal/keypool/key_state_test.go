@@ -0,0 +1,276 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"key-flow/internal/models"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestKeyStateUpdates(t *testing.T) {
+	cooldownUntil := time.Unix(1700000000, 0)
+	quotaResetAt := time.Unix(1700086400, 0)
+	tests := []struct {
+		name    string
+		details map[string]string
+		want    map[string]any
+	}{
+		{
+			name:    "active clears cooldown, quota and validation backoff",
+			details: map[string]string{"status": models.KeyStatusActive, "failure_count": "0", "cooldown_count": "2"},
+			want: map[string]any{
+				"status": models.KeyStatusActive, "failure_count": int64(0), "cooldown_count": 2,
+				"cooldown_until": nil, "quota_reset_at": nil,
+				"validation_failures": 0, "validation_failing_since": nil, "next_validation_at": nil,
+			},
+		},
+		{
+			name:    "invalid",
+			details: map[string]string{"status": models.KeyStatusInvalid, "failure_count": "3"},
+			want:    map[string]any{"status": models.KeyStatusInvalid, "failure_count": int64(3), "cooldown_count": 0},
+		},
+		{
+			name:    "cooldown",
+			details: map[string]string{"status": models.KeyStatusCooldown, "failure_count": "1", "cooldown_count": "1", "cooldown_until": "1700000000"},
+			want:    map[string]any{"status": models.KeyStatusCooldown, "failure_count": int64(1), "cooldown_count": 1, "cooldown_until": cooldownUntil},
+		},
+		{
+			name:    "cooldown without an end time",
+			details: map[string]string{"status": models.KeyStatusCooldown},
+			want:    map[string]any{"status": models.KeyStatusCooldown, "failure_count": int64(0), "cooldown_count": 0, "cooldown_until": nil},
+		},
+		{
+			name:    "quota exhausted",
+			details: map[string]string{"status": models.KeyStatusQuotaExhausted, "quota_reset_at": "1700086400"},
+			want:    map[string]any{"status": models.KeyStatusQuotaExhausted, "failure_count": int64(0), "cooldown_count": 0, "quota_reset_at": quotaResetAt},
+		},
+		{
+			name:    "archived",
+			details: map[string]string{"status": models.KeyStatusArchived},
+			want:    map[string]any{"status": models.KeyStatusArchived, "failure_count": int64(0), "cooldown_count": 0},
+		},
+		{
+			name:    "scheduled",
+			details: map[string]string{"status": models.KeyStatusScheduled},
+			want:    map[string]any{"status": models.KeyStatusScheduled, "failure_count": int64(0), "cooldown_count": 0},
+		},
+		{
+			name:    "expired",
+			details: map[string]string{"status": models.KeyStatusExpired},
+			want:    map[string]any{"status": models.KeyStatusExpired, "failure_count": int64(0), "cooldown_count": 0},
+		},
+		{
+			name:    "unknown status only writes the counters",
+			details: map[string]string{"status": "unknown", "failure_count": "4", "cooldown_count": "1"},
+			want:    map[string]any{"failure_count": int64(4), "cooldown_count": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keyStateUpdates(tt.details); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("keyStateUpdates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// queueKeyStates 在 store 中写入 key 的详情并加入写回队列，模拟已应用的状态变更
+func queueKeyStates(t *testing.T, p *KeyProvider, details map[string]any, keyIDs ...uint) {
+	t.Helper()
+	members := make([]any, len(keyIDs))
+	for i, keyID := range keyIDs {
+		must(t, p.store.HSet(fmt.Sprintf("key:%d", keyID), details))
+		members[i] = strconv.FormatUint(uint64(keyID), 10)
+	}
+	must(t, p.store.SAdd(pendingKeyStatesSet, members...))
+}
+
+// dbKey 返回数据库中的 key
+func dbKey(t *testing.T, p *KeyProvider, keyID uint) models.APIKey {
+	t.Helper()
+	var key models.APIKey
+	must(t, p.db.First(&key, keyID).Error)
+	return key
+}
+
+func pendingKeyStates(t *testing.T, p *KeyProvider) int64 {
+	t.Helper()
+	n, err := p.store.SCard(pendingKeyStatesSet)
+	must(t, err)
+	return n
+}
+
+func TestFlushKeyStatesBatches(t *testing.T) {
+	p := newTestProvider(t)
+	const total = 2*keyStateFlushBatchSize + 201
+	keys := make([]models.APIKey, total)
+	keyIDs := make([]uint, total)
+	for i := range keys {
+		keys[i] = models.APIKey{GroupID: testGroupID, KeyValue: fmt.Sprintf("sk-test-%d", i+1), Status: models.KeyStatusActive}
+	}
+	must(t, p.db.CreateInBatches(keys, 200).Error)
+	for i, key := range keys {
+		keyIDs[i] = key.ID
+	}
+	queueKeyStates(t, p, map[string]any{"status": models.KeyStatusInvalid, "failure_count": 3}, keyIDs...)
+
+	// 按事务统计每批写入的 key 数量
+	var mu sync.Mutex
+	batches := make(map[gorm.ConnPool]int)
+	must(t, p.db.Callback().Update().Before("gorm:update").Register("test:count_batches", func(tx *gorm.DB) {
+		mu.Lock()
+		batches[tx.Statement.ConnPool]++
+		mu.Unlock()
+	}))
+
+	p.flushKeyStates()
+
+	sizes := make([]int, 0, len(batches))
+	for _, size := range batches {
+		sizes = append(sizes, size)
+	}
+	slices.Sort(sizes)
+	if want := []int{201, keyStateFlushBatchSize, keyStateFlushBatchSize}; !slices.Equal(sizes, want) {
+		t.Fatalf("batch sizes = %v, want %v", sizes, want)
+	}
+	if n := pendingKeyStates(t, p); n != 0 {
+		t.Fatalf("pending after flush = %d, want 0", n)
+	}
+	var invalid int64
+	must(t, p.db.Model(&models.APIKey{}).Where("status = ? AND failure_count = 3", models.KeyStatusInvalid).Count(&invalid).Error)
+	if invalid != total {
+		t.Fatalf("persisted keys = %d, want %d", invalid, total)
+	}
+	if stats := p.KeyStateQueueStats(); stats.LastFlushCount != total || stats.LastFlushError != "" || stats.Depth != 0 {
+		t.Fatalf("stats = %+v, want %d flushed without error", stats, total)
+	}
+}
+
+func TestKeyStateQueuedSignalsAtBatchSize(t *testing.T) {
+	p := newTestProvider(t)
+	for range keyStateFlushBatchSize - 1 {
+		p.keyStateQueued()
+	}
+	select {
+	case <-p.keyStates.signal:
+		t.Fatal("flush signalled before the batch size was reached")
+	default:
+	}
+
+	p.keyStateQueued()
+	select {
+	case <-p.keyStates.signal:
+	default:
+		t.Fatal("flush not signalled at the batch size")
+	}
+
+	// 写入后重新计数
+	p.flushKeyStates()
+	p.keyStateQueued()
+	select {
+	case <-p.keyStates.signal:
+		t.Fatal("flush signalled right after a flush")
+	default:
+	}
+}
+
+func TestFlushKeyStatesRequeuesOnError(t *testing.T) {
+	p := newTestProvider(t)
+	addTestKeys(t, p, models.APIKey{}, models.APIKey{})
+	queueKeyStates(t, p, map[string]any{"status": models.KeyStatusInvalid, "failure_count": 3}, 1, 2)
+
+	var failing atomic.Bool
+	failing.Store(true)
+	must(t, p.db.Callback().Update().Before("gorm:update").Register("test:fail", func(tx *gorm.DB) {
+		if failing.Load() {
+			tx.AddError(errors.New("disk I/O error"))
+		}
+	}))
+
+	p.flushKeyStates()
+	if n := pendingKeyStates(t, p); n != 2 {
+		t.Fatalf("pending after failed flush = %d, want 2", n)
+	}
+	if key := dbKey(t, p, 1); key.Status != models.KeyStatusActive {
+		t.Fatalf("status after failed flush = %q, want active", key.Status)
+	}
+	if stats := p.KeyStateQueueStats(); stats.LastFlushError == "" || stats.LastFlushCount != 0 || stats.Depth != 2 {
+		t.Fatalf("stats after failed flush = %+v", stats)
+	}
+
+	failing.Store(false)
+	p.flushKeyStates()
+	if n := pendingKeyStates(t, p); n != 0 {
+		t.Fatalf("pending after retry = %d, want 0", n)
+	}
+	for _, keyID := range []uint{1, 2} {
+		if key := dbKey(t, p, keyID); key.Status != models.KeyStatusInvalid || key.FailureCount != 3 {
+			t.Fatalf("key %d after retry = %q, %d, want invalid, 3", keyID, key.Status, key.FailureCount)
+		}
+	}
+	if stats := p.KeyStateQueueStats(); stats.LastFlushError != "" || stats.LastFlushCount != 2 {
+		t.Fatalf("stats after retry = %+v", stats)
+	}
+}
+
+func TestFlushKeyStatesSkipsDeletedKeys(t *testing.T) {
+	p := newTestProvider(t)
+	addTestKeys(t, p, models.APIKey{}, models.APIKey{})
+	queueKeyStates(t, p, map[string]any{"status": models.KeyStatusInvalid, "failure_count": 3}, 1, 2)
+	// key 1 在写入数据库之前被删除，store 中已没有它的详情；无法解析的成员同样跳过
+	must(t, p.store.Del("key:1"))
+	must(t, p.store.SAdd(pendingKeyStatesSet, "not-a-key"))
+
+	p.flushKeyStates()
+	if n := pendingKeyStates(t, p); n != 0 {
+		t.Fatalf("pending after flush = %d, want 0", n)
+	}
+	if key := dbKey(t, p, 1); key.Status != models.KeyStatusActive || key.FailureCount != 0 {
+		t.Fatalf("deleted key = %q, %d, want untouched", key.Status, key.FailureCount)
+	}
+	if key := dbKey(t, p, 2); key.Status != models.KeyStatusInvalid || key.FailureCount != 3 {
+		t.Fatalf("key 2 = %q, %d, want invalid, 3", key.Status, key.FailureCount)
+	}
+	if stats := p.KeyStateQueueStats(); stats.LastFlushError != "" {
+		t.Fatalf("stats = %+v, want no error", stats)
+	}
+}
+
+func TestStopFlushesKeyStates(t *testing.T) {
+	p := newTestProvider(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cleanupCancel = cancel
+	go p.startKeyStateFlush(ctx)
+
+	addTestKeys(t, p, models.APIKey{})
+	if _, err := p.applyKeyTransition(p.keyMove(1, testGroupID, models.KeyStatusArchived)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 在第一次定时写入之前停止，状态由退出前的最后一次写入落库
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), keyStateFlushInterval/2)
+	defer stopCancel()
+	p.Stop(stopCtx)
+	select {
+	case <-p.keyStates.done:
+	default:
+		t.Fatal("Stop returned before the final flush finished")
+	}
+
+	if key := dbKey(t, p, 1); key.Status != models.KeyStatusArchived {
+		t.Fatalf("status after Stop = %q, want archived", key.Status)
+	}
+	if n := pendingKeyStates(t, p); n != 0 {
+		t.Fatalf("pending after Stop = %d, want 0", n)
+	}
+}
@@ -0,0 +1,114 @@
+package keypool
+
+import (
+	"fmt"
+	"key-flow/internal/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// KeyTagsHeader lets a client restrict key selection to keys carrying all of the listed tags.
+const KeyTagsHeader = "X-Key-Tags"
+
+// SelectConstraints 描述一次选 key 需要满足的条件
+type SelectConstraints struct {
+	Limits RateLimits
+	// Tags 非空时只选择包含全部标签的 key
+	Tags []string
+	// Model 非空时跳过被拒绝访问该模型的 key
+	Model string
+	// Strategy 是分组的选 key 策略，为空时使用加权随机
+	Strategy string
+}
+
+// allowsTags 检查 store 中的 key 详情是否满足标签约束
+func (c SelectConstraints) allowsTags(details map[string]string) bool {
+	if len(c.Tags) == 0 {
+		return true
+	}
+	return models.ParseKeyTags(details["tags"]).HasAll(c.Tags)
+}
+
+// allowsKey 检查 key 是否满足标签约束
+func (c SelectConstraints) allowsKey(apiKey *models.APIKey) bool {
+	return len(c.Tags) == 0 || apiKey.Tags.HasAll(c.Tags)
+}
+
+// keyTagRule 将匹配的模型或代理密钥限制到带有指定标签的 key
+type keyTagRule struct {
+	kind  string // "model" or "proxy_key"
+	value string
+	tags  models.KeyTags
+}
+
+// parseKeyTagRules parses one rule per line: "model:gpt-4o=paid" or "proxy_key:sk-xxx=org-b,paid".
+// Lines starting with # are comments; malformed lines are ignored.
+func parseKeyTagRules(text string) []keyTagRule {
+	var rules []keyTagRule
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kind, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		eq := strings.LastIndex(rest, "=")
+		if eq < 0 {
+			continue
+		}
+
+		rule := keyTagRule{
+			kind:  strings.TrimSpace(kind),
+			value: strings.TrimSpace(rest[:eq]),
+			tags:  models.ParseKeyTags(rest[eq+1:]),
+		}
+		if rule.value == "" || len(rule.tags) == 0 || (rule.kind != "model" && rule.kind != "proxy_key") {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// RequiredKeyTags 合并分组标签规则和请求头中要求的标签
+func RequiredKeyTags(group *models.Group, model, proxyKey, headerValue string) []string {
+	required := models.ParseKeyTags(headerValue)
+
+	for _, rule := range parseKeyTagRules(group.EffectiveConfig.KeyTagRules) {
+		matched := (rule.kind == "model" && model != "" && rule.value == model) ||
+			(rule.kind == "proxy_key" && proxyKey != "" && rule.value == proxyKey)
+		if matched {
+			required = append(required, rule.tags...)
+		}
+	}
+
+	if len(required) == 0 {
+		return nil
+	}
+	return models.NormalizeKeyTags(required)
+}
+
+// UpdateKeyTags 更新单个 key 的标签
+func (p *KeyProvider) UpdateKeyTags(keyID uint, tags []string) error {
+	normalized := models.NormalizeKeyTags(tags)
+
+	return p.executeTransactionWithRetry(func(tx *gorm.DB) error {
+		var key models.APIKey
+		if err := tx.First(&key, keyID).Error; err != nil {
+			return fmt.Errorf("failed to find key %d: %w", keyID, err)
+		}
+
+		if err := tx.Model(&key).Update("tags", normalized).Error; err != nil {
+			return fmt.Errorf("failed to update key tags in DB: %w", err)
+		}
+
+		if err := p.store.HSet(fmt.Sprintf("key:%d", keyID), map[string]any{"tags": normalized.String()}); err != nil {
+			return fmt.Errorf("failed to update key tags in store: %w", err)
+		}
+		return nil
+	})
+}
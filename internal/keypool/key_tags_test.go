@@ -0,0 +1,91 @@
+package keypool
+
+import (
+	app_errors "key-flow/internal/errors"
+	"key-flow/internal/models"
+	"key-flow/internal/types"
+	"slices"
+	"testing"
+)
+
+func TestRequiredKeyTags(t *testing.T) {
+	rules := "# 付费模型只用付费 key\nmodel:gpt-4o=paid\nproxy_key:sk-org-b=org-b,paid\nbroken line\nmodel:=x"
+	tests := []struct {
+		name     string
+		model    string
+		proxyKey string
+		header   string
+		want     []string
+	}{
+		{name: "no match", model: "gpt-4o-mini", proxyKey: "sk-other"},
+		{name: "model rule", model: "gpt-4o", want: []string{"paid"}},
+		{name: "proxy key rule", proxyKey: "sk-org-b", want: []string{"org-b", "paid"}},
+		{name: "rules and header merged", model: "gpt-4o", proxyKey: "sk-org-b", header: "EU, paid", want: []string{"eu", "org-b", "paid"}},
+		{name: "header only", header: "eu", want: []string{"eu"}},
+	}
+
+	group := testGroup(types.SystemSettings{KeyTagRules: rules})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RequiredKeyTags(group, tt.model, tt.proxyKey, tt.header)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("RequiredKeyTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectKeyTags(t *testing.T) {
+	runSelectKeyTests(t, []selectKeyTest{
+		{
+			name:        "requires all tags",
+			keys:        []models.APIKey{{Tags: models.ParseKeyTags("paid")}, {Tags: models.ParseKeyTags("paid,eu")}, {}},
+			constraints: SelectConstraints{Tags: []string{"eu", "paid"}},
+			wantKey:     2,
+		},
+		{
+			name: "tag update applies to selection",
+			keys: []models.APIKey{{Tags: models.ParseKeyTags("paid")}, {}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				must(t, p.UpdateKeyTags(1, nil))
+				must(t, p.UpdateKeyTags(2, []string{"Paid"}))
+			},
+			constraints: SelectConstraints{Tags: []string{"paid"}},
+			wantKey:     2,
+		},
+		{
+			name:        "no key carries the tags",
+			keys:        []models.APIKey{{Tags: models.ParseKeyTags("paid")}},
+			constraints: SelectConstraints{Tags: []string{"free"}},
+			wantErr:     app_errors.ErrNoActiveKeys,
+			wantStatus:  map[uint]string{1: models.KeyStatusActive},
+		},
+	})
+}
+
+func TestKeyTagLikePattern(t *testing.T) {
+	p := newTestProvider(t)
+	addTestKeys(t, p,
+		models.APIKey{Tags: models.ParseKeyTags("gpt_4")},
+		models.APIKey{Tags: models.ParseKeyTags("gpt-4")},
+		models.APIKey{Tags: models.ParseKeyTags("gpt.4,100%")},
+		models.APIKey{Tags: models.ParseKeyTags("100!")},
+	)
+
+	// 标签中的 LIKE 通配符按字面匹配
+	tests := map[string][]uint{
+		"gpt_4": {1},
+		"gpt-4": {2},
+		"100%":  {3},
+		"100!":  {4},
+		"gpt%":  nil,
+	}
+	for tag, want := range tests {
+		var got []uint
+		must(t, p.db.Model(&models.APIKey{}).Where(models.KeyTagLikeCondition, models.KeyTagLikePattern(tag)).
+			Order("id").Pluck("id", &got).Error)
+		if !slices.Equal(got, want) {
+			t.Errorf("keys tagged %q = %v, want %v", tag, got, want)
+		}
+	}
+}
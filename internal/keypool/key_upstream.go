@@ -0,0 +1,27 @@
+package keypool
+
+import (
+	"fmt"
+	"key-flow/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// UpdateKeyUpstream 将 key 绑定到指定上游，upstream 为空时解除绑定
+func (p *KeyProvider) UpdateKeyUpstream(keyID uint, upstream string) error {
+	return p.executeTransactionWithRetry(func(tx *gorm.DB) error {
+		var key models.APIKey
+		if err := tx.First(&key, keyID).Error; err != nil {
+			return fmt.Errorf("failed to find key %d: %w", keyID, err)
+		}
+
+		if err := tx.Model(&key).Update("upstream", upstream).Error; err != nil {
+			return fmt.Errorf("failed to update key upstream in DB: %w", err)
+		}
+
+		if err := p.store.HSet(fmt.Sprintf("key:%d", keyID), map[string]any{"upstream": upstream}); err != nil {
+			return fmt.Errorf("failed to update key upstream in store: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,52 @@
+package keypool
+
+import (
+	"key-flow/internal/models"
+	"testing"
+)
+
+func TestSelectKeyUpstream(t *testing.T) {
+	runSelectKeyTests(t, []selectKeyTest{
+		{
+			name:    "bound upstream is returned with the key",
+			keys:    []models.APIKey{{Upstream: "https://eu.example.com"}},
+			wantKey: 1,
+			check: func(t *testing.T, p *KeyProvider, apiKey *models.APIKey) {
+				if apiKey.Upstream != "https://eu.example.com" {
+					t.Errorf("Upstream = %q, want the bound upstream", apiKey.Upstream)
+				}
+			},
+		},
+		{
+			name: "upstream binding update",
+			keys: []models.APIKey{{Upstream: "https://eu.example.com"}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				must(t, p.UpdateKeyUpstream(1, "https://us.example.com"))
+			},
+			wantKey: 1,
+			check: func(t *testing.T, p *KeyProvider, apiKey *models.APIKey) {
+				if apiKey.Upstream != "https://us.example.com" {
+					t.Errorf("Upstream = %q, want the updated upstream", apiKey.Upstream)
+				}
+				var stored models.APIKey
+				must(t, p.db.First(&stored, 1).Error)
+				if stored.Upstream != "https://us.example.com" {
+					t.Errorf("DB upstream = %q, want the updated upstream", stored.Upstream)
+				}
+			},
+		},
+		{
+			name: "upstream unbound",
+			keys: []models.APIKey{{Upstream: "https://eu.example.com"}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				must(t, p.UpdateKeyUpstream(1, ""))
+			},
+			wantKey: 1,
+			check: func(t *testing.T, p *KeyProvider, apiKey *models.APIKey) {
+				if apiKey.Upstream != "" {
+					t.Errorf("Upstream = %q, want unbound", apiKey.Upstream)
+				}
+			},
+		},
+	})
+}
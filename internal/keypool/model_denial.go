@@ -0,0 +1,99 @@
+package keypool
+
+import (
+	"encoding/json"
+	"fmt"
+	app_errors "key-flow/internal/errors"
+	"key-flow/internal/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func modelDenialKey(keyID uint, model string) string {
+	return fmt.Sprintf("model_denied:key:%d:%s", keyID, strings.ToLower(model))
+}
+
+// groupModelDenialKey 标记分组内至少有一个 key 被拒绝访问该模型，没有标记时选 key 不需要逐个检查
+func groupModelDenialKey(groupID uint, model string) string {
+	return fmt.Sprintf("model_denied:group:%d:%s", groupID, strings.ToLower(model))
+}
+
+// ModelDeniedError is returned when the only keys that could serve a request were denied access to its model.
+// It carries the upstream error of the most recent denial so callers can relay it instead of a generic 503.
+type ModelDeniedError struct {
+	Model      string
+	StatusCode int
+	Message    string
+}
+
+func (e *ModelDeniedError) Error() string {
+	return fmt.Sprintf("all keys are denied access to model %s", e.Model)
+}
+
+// Unwrap 使只检查 ErrNoActiveKeys 的调用方仍按无可用 key 处理
+func (e *ModelDeniedError) Unwrap() error {
+	return app_errors.ErrNoActiveKeys
+}
+
+// modelDenial 是分组标记中保存的最近一次上游拒绝
+type modelDenial struct {
+	StatusCode int    `json:"status_code"`
+	Message    string `json:"message"`
+}
+
+// DenyModel 记录 key 无权访问指定模型，在分组配置的时长内不再为该模型选择此 key，不计入失败次数。
+// statusCode 和 errorMessage 是上游的原始响应，所有 key 都被拒绝时原样返回给客户端。
+func (p *KeyProvider) DenyModel(apiKey *models.APIKey, group *models.Group, model string, statusCode int, errorMessage string) {
+	ttl := time.Duration(group.EffectiveConfig.ModelDenialTTLMinutes) * time.Minute
+	if model == "" || ttl <= 0 {
+		return
+	}
+
+	if err := p.store.Set(modelDenialKey(apiKey.ID, model), []byte("1"), ttl); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "model": model, "error": err}).Error("Failed to record model denial")
+		return
+	}
+	// 分组标记与最近一次拒绝同时过期，覆盖所有仍然有效的 key 级记录
+	denial, _ := json.Marshal(modelDenial{StatusCode: statusCode, Message: errorMessage})
+	if err := p.store.Set(groupModelDenialKey(group.ID, model), denial, ttl); err != nil {
+		logrus.WithFields(logrus.Fields{"groupID": group.ID, "model": model, "error": err}).Error("Failed to record group model denial")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"keyID":   apiKey.ID,
+		"groupID": group.ID,
+		"model":   model,
+		"ttl":     ttl,
+	}).Info("Key denied access to model, skipping it for this model")
+}
+
+// modelDeniedFilter 返回判断 key 是否被拒绝访问 model 的函数
+func (p *KeyProvider) modelDeniedFilter(groupID uint, model string) func(keyID uint) bool {
+	if model == "" {
+		return func(uint) bool { return false }
+	}
+	if exists, err := p.store.Exists(groupModelDenialKey(groupID, model)); err != nil || !exists {
+		return func(uint) bool { return false }
+	}
+	return func(keyID uint) bool {
+		denied, err := p.store.Exists(modelDenialKey(keyID, model))
+		return err == nil && denied
+	}
+}
+
+// modelDeniedError 返回分组最近一次拒绝该模型的上游错误，分组标记已过期或无法解析时使用 404
+func (p *KeyProvider) modelDeniedError(groupID uint, model string) error {
+	denial := modelDenial{StatusCode: http.StatusNotFound}
+	if data, err := p.store.Get(groupModelDenialKey(groupID, model)); err == nil {
+		if err := json.Unmarshal(data, &denial); err != nil || denial.StatusCode == 0 {
+			denial.StatusCode = http.StatusNotFound
+		}
+	}
+	if denial.Message == "" {
+		denial.Message = fmt.Sprintf("model '%s' is not available to any key of this group", model)
+	}
+	return &ModelDeniedError{Model: model, StatusCode: denial.StatusCode, Message: denial.Message}
+}
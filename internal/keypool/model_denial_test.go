@@ -0,0 +1,141 @@
+package keypool
+
+import (
+	"errors"
+	"fmt"
+	app_errors "key-flow/internal/errors"
+	"key-flow/internal/models"
+	"key-flow/internal/types"
+	"net/http"
+	"testing"
+)
+
+const testModelDeniedBody = `{"error":{"message":"The model gpt-4o does not exist or you do not have access to it.","code":"model_not_found"}}`
+
+func TestSelectKeyModelDenial(t *testing.T) {
+	runSelectKeyTests(t, []selectKeyTest{
+		{
+			name: "key denied the requested model",
+			keys: []models.APIKey{{}, {}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				p.DenyModel(&models.APIKey{ID: 1}, testGroup(types.SystemSettings{ModelDenialTTLMinutes: 60}), "GPT-4o", http.StatusNotFound, testModelDeniedBody)
+			},
+			constraints: SelectConstraints{Model: "gpt-4o"},
+			wantKey:     2,
+			// 拒绝访问模型不影响 key 的状态和失败次数
+			check: func(t *testing.T, p *KeyProvider, apiKey *models.APIKey) {
+				if failures := keyDetails(t, p, 1)["failure_count"]; failures != "0" {
+					t.Errorf("denied key failure_count = %q, want 0", failures)
+				}
+			},
+			wantStatus: map[uint]string{1: models.KeyStatusActive, 2: models.KeyStatusActive},
+		},
+		{
+			name: "denial only applies to its model",
+			keys: []models.APIKey{{}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				p.DenyModel(&models.APIKey{ID: 1}, testGroup(types.SystemSettings{ModelDenialTTLMinutes: 60}), "gpt-4o", http.StatusNotFound, testModelDeniedBody)
+			},
+			constraints: SelectConstraints{Model: "gpt-4o-mini"},
+			wantKey:     1,
+		},
+		{
+			name: "every key denied the model",
+			keys: []models.APIKey{{}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				p.DenyModel(&models.APIKey{ID: 1}, testGroup(types.SystemSettings{ModelDenialTTLMinutes: 60}), "gpt-4o", http.StatusNotFound, testModelDeniedBody)
+			},
+			constraints: SelectConstraints{Model: "gpt-4o"},
+			wantErr:     app_errors.ErrNoActiveKeys,
+		},
+		{
+			name: "denial disabled by zero ttl",
+			keys: []models.APIKey{{}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				p.DenyModel(&models.APIKey{ID: 1}, testGroup(types.SystemSettings{}), "gpt-4o", http.StatusNotFound, testModelDeniedBody)
+			},
+			constraints: SelectConstraints{Model: "gpt-4o"},
+			wantKey:     1,
+		},
+	})
+}
+
+func TestSelectKeyModelDeniedError(t *testing.T) {
+	denyGroup := testGroup(types.SystemSettings{ModelDenialTTLMinutes: 60})
+	tests := []struct {
+		name        string
+		keys        []models.APIKey
+		setup       func(t *testing.T, p *KeyProvider)
+		constraints SelectConstraints
+		wantStatus  int // 0 表示不应返回 ModelDeniedError
+		wantMessage string
+	}{
+		{
+			name: "upstream error of the latest denial returned",
+			keys: []models.APIKey{{}, {}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				p.DenyModel(&models.APIKey{ID: 1}, denyGroup, "gpt-4o", http.StatusForbidden, "forbidden")
+				p.DenyModel(&models.APIKey{ID: 2}, denyGroup, "gpt-4o", http.StatusNotFound, testModelDeniedBody)
+			},
+			constraints: SelectConstraints{Model: "gpt-4o"},
+			wantStatus:  http.StatusNotFound,
+			wantMessage: testModelDeniedBody,
+		},
+		{
+			name: "other keys excluded by tags",
+			keys: []models.APIKey{{Tags: models.ParseKeyTags("interactive")}, {Tags: models.ParseKeyTags("batch")}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				p.DenyModel(&models.APIKey{ID: 1}, denyGroup, "gpt-4o", http.StatusNotFound, testModelDeniedBody)
+			},
+			constraints: SelectConstraints{Model: "gpt-4o", Tags: []string{"interactive"}},
+			wantStatus:  http.StatusNotFound,
+			wantMessage: testModelDeniedBody,
+		},
+		{
+			name: "rate limited key takes precedence",
+			keys: []models.APIKey{{}, {}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				p.DenyModel(&models.APIKey{ID: 1}, denyGroup, "gpt-4o", http.StatusNotFound, testModelDeniedBody)
+				must(t, p.store.WindowAdd(rpmWindowKey(2), 1, rateLimitWindow))
+			},
+			constraints: SelectConstraints{Model: "gpt-4o", Limits: RateLimits{RPM: 1}},
+		},
+		{
+			name:        "no denial keeps the generic error",
+			keys:        []models.APIKey{{Status: models.KeyStatusInvalid}},
+			constraints: SelectConstraints{Model: "gpt-4o"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, strategy := range selectStrategies {
+			t.Run(fmt.Sprintf("%s/%s", tt.name, strategy), func(t *testing.T) {
+				p := newTestProvider(t)
+				addTestKeys(t, p, tt.keys...)
+				if tt.setup != nil {
+					tt.setup(t, p)
+				}
+				constraints := tt.constraints
+				constraints.Strategy = strategy
+
+				_, err := p.SelectKey(testGroupID, constraints)
+				var denied *ModelDeniedError
+				if tt.wantStatus == 0 {
+					if errors.As(err, &denied) || err == nil {
+						t.Fatalf("SelectKey() err = %v, want a non model-denied error", err)
+					}
+					return
+				}
+				if !errors.As(err, &denied) {
+					t.Fatalf("SelectKey() err = %v, want ModelDeniedError", err)
+				}
+				if denied.StatusCode != tt.wantStatus || denied.Message != tt.wantMessage {
+					t.Errorf("ModelDeniedError = %d %q, want %d %q", denied.StatusCode, denied.Message, tt.wantStatus, tt.wantMessage)
+				}
+				if !errors.Is(err, app_errors.ErrNoActiveKeys) {
+					t.Errorf("ModelDeniedError should unwrap to ErrNoActiveKeys")
+				}
+			})
+		}
+	}
+}
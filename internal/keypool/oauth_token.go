@@ -0,0 +1,236 @@
+package keypool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	app_errors "key-flow/internal/errors"
+	"key-flow/internal/models"
+	"key-flow/internal/store"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// oauthTokenRefreshSkew 在访问令牌过期前提前刷新，避免请求途中令牌失效
+	oauthTokenRefreshSkew = 5 * time.Minute
+	// oauthDefaultTokenLifetime 用于未返回 expires_in 的令牌端点
+	oauthDefaultTokenLifetime = time.Hour
+	oauthRefreshLockTTL       = 30 * time.Second
+	oauthRefreshWaitInterval  = 200 * time.Millisecond
+)
+
+var oauthHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// oauthToken 是缓存在 store 中的访问令牌，所有节点共享，与 key 一样加密保存
+type oauthToken struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func oauthTokenKey(keyID uint) string {
+	return fmt.Sprintf("oauth_token:key:%d", keyID)
+}
+
+func oauthRefreshLockKey(keyID uint) string {
+	return fmt.Sprintf("oauth_refresh_lock:key:%d", keyID)
+}
+
+// ResolveAccessToken 为 OAuth 凭证填入当前访问令牌，令牌临近过期时用 refresh token 刷新。
+// 普通 key 直接返回 nil。刷新失败时返回的错误应按 key 失败处理。
+func (p *KeyProvider) ResolveAccessToken(ctx context.Context, apiKey *models.APIKey) error {
+	fields := apiKey.CredentialFields()
+	if fields[models.OAuthRefreshTokenField] == "" {
+		return nil
+	}
+
+	for {
+		token, err := p.cachedAccessToken(apiKey.ID)
+		if err != nil {
+			return err
+		}
+		if token != nil && time.Until(token.ExpiresAt) > oauthTokenRefreshSkew {
+			apiKey.AccessToken = token.AccessToken
+			return nil
+		}
+
+		// 同一时间只有一个请求刷新令牌，refresh token 可能在刷新后被轮换
+		locked, err := p.store.SetNX(oauthRefreshLockKey(apiKey.ID), []byte("1"), oauthRefreshLockTTL)
+		if err != nil {
+			return fmt.Errorf("failed to acquire token refresh lock: %w", err)
+		}
+		if locked {
+			defer p.store.Delete(oauthRefreshLockKey(apiKey.ID))
+			token, err = p.refreshAccessToken(ctx, apiKey, fields)
+			if err != nil {
+				return err
+			}
+			apiKey.AccessToken = token.AccessToken
+			return nil
+		}
+
+		// 其他请求正在刷新，尚未过期的旧令牌可以继续使用
+		if token != nil && time.Now().Before(token.ExpiresAt) {
+			apiKey.AccessToken = token.AccessToken
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(oauthRefreshWaitInterval):
+		}
+	}
+}
+
+// cachedAccessToken 返回 store 中缓存的访问令牌，不存在或无法解密时返回 nil
+func (p *KeyProvider) cachedAccessToken(keyID uint) (*oauthToken, error) {
+	data, err := p.store.Get(oauthTokenKey(keyID))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached access token: %w", err)
+	}
+
+	decrypted, err := p.encryptionSvc.Decrypt(string(data))
+	if err != nil {
+		return nil, nil
+	}
+	var token oauthToken
+	if err := json.Unmarshal([]byte(decrypted), &token); err != nil {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// refreshAccessToken 向令牌端点换取新的访问令牌并写入 store
+func (p *KeyProvider) refreshAccessToken(ctx context.Context, apiKey *models.APIKey, fields map[string]string) (*oauthToken, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", fields[models.OAuthRefreshTokenField])
+	for _, name := range []string{models.OAuthClientIDField, models.OAuthClientSecretField, models.OAuthScopeField} {
+		if value := fields[name]; value != "" {
+			form.Set(name, value)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fields[models.OAuthTokenURLField], strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token refresh request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send token refresh request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token refresh response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var oauthErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		message := app_errors.ParseUpstreamError(body)
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			message = strings.TrimSpace(oauthErr.Error + ": " + oauthErr.ErrorDescription)
+		}
+		return nil, &app_errors.ValidationError{StatusCode: resp.StatusCode, Message: "token refresh failed: " + message}
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to parse token refresh response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("token refresh response does not contain an access_token")
+	}
+
+	// 授权服务器轮换了 refresh token 时必须保存新值，旧值已无法再次使用
+	if tokenResp.RefreshToken != "" && tokenResp.RefreshToken != fields[models.OAuthRefreshTokenField] {
+		if err := p.rotateRefreshToken(apiKey, tokenResp.RefreshToken); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to save rotated refresh token")
+		}
+	}
+
+	expiresIn := time.Duration(tokenResp.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = oauthDefaultTokenLifetime
+	}
+	token := &oauthToken{AccessToken: tokenResp.AccessToken, ExpiresAt: time.Now().Add(expiresIn)}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := p.encryptionSvc.Encrypt(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+	if err := p.store.Set(oauthTokenKey(apiKey.ID), []byte(encrypted), expiresIn); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Warn("Failed to cache access token")
+	}
+
+	logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "expiresIn": expiresIn}).Debug("Refreshed OAuth access token")
+	return token, nil
+}
+
+// rotateRefreshToken 将新的 refresh token 写回数据库和 store 中的凭证，其他字段保留原始的 JSON 值
+func (p *KeyProvider) rotateRefreshToken(apiKey *models.APIKey, refreshToken string) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(apiKey.KeyValue), &fields); err != nil {
+		return fmt.Errorf("failed to parse credential: %w", err)
+	}
+	encodedToken, err := json.Marshal(refreshToken)
+	if err != nil {
+		return err
+	}
+	fields[models.OAuthRefreshTokenField] = encodedToken
+	keyValue, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	encryptedKeyValue, err := p.encryptionSvc.Encrypt(string(keyValue))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credential: %w", err)
+	}
+
+	err = p.executeTransactionWithRetry(func(tx *gorm.DB) error {
+		updates := map[string]any{
+			"key_value": encryptedKeyValue,
+			"key_hash":  p.encryptionSvc.Hash(models.CanonicalKeyValue(string(keyValue))),
+		}
+		if err := tx.Model(&models.APIKey{}).Where("id = ?", apiKey.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update credential in DB: %w", err)
+		}
+
+		if err := p.store.HSet(fmt.Sprintf("key:%d", apiKey.ID), map[string]any{"key_string": encryptedKeyValue}); err != nil {
+			return fmt.Errorf("failed to update credential in store: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	apiKey.KeyValue = string(keyValue)
+	return nil
+}
@@ -0,0 +1,244 @@
+package keypool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"key-flow/internal/encryption"
+	app_errors "key-flow/internal/errors"
+	"key-flow/internal/models"
+	"key-flow/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTokenServer 是 OAuth 令牌端点，每次刷新签发编号递增的访问令牌
+type fakeTokenServer struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	refreshTokens []string // 每次刷新收到的 refresh token
+	clientIDs     []string
+	expiresIn     int
+	rotateTo      string // 非空时在响应中返回新的 refresh token
+	failStatus    int    // 非 0 时以该状态码拒绝刷新
+}
+
+func newFakeTokenServer(t *testing.T) *fakeTokenServer {
+	t.Helper()
+	s := &fakeTokenServer{expiresIn: 3600}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "refresh_token" {
+			http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		}
+		if s.failStatus != 0 {
+			w.WriteHeader(s.failStatus)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"refresh token revoked"}`)
+			return
+		}
+		s.refreshTokens = append(s.refreshTokens, r.PostForm.Get("refresh_token"))
+		s.clientIDs = append(s.clientIDs, r.PostForm.Get("client_id"))
+		resp := map[string]any{
+			"access_token": fmt.Sprintf("at-%d", len(s.refreshTokens)),
+			"expires_in":   s.expiresIn,
+			"token_type":   "Bearer",
+		}
+		if s.rotateTo != "" {
+			resp["refresh_token"] = s.rotateTo
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeTokenServer) refreshes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.refreshTokens...)
+}
+
+// addOAuthKey 以加密形式写入一个 OAuth 凭证 key，返回从 store 取出的 key
+func addOAuthKey(t *testing.T, p *KeyProvider, credential string) *models.APIKey {
+	t.Helper()
+	encrypted, err := p.encryptionSvc.Encrypt(credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := models.APIKey{
+		ID:       1,
+		GroupID:  testGroupID,
+		KeyValue: encrypted,
+		KeyHash:  p.encryptionSvc.Hash(models.CanonicalKeyValue(credential)),
+		Status:   models.KeyStatusActive,
+	}
+	must(t, p.db.Create(&key).Error)
+	must(t, p.addKeyToStore(&key))
+	return requestKey(t, p)
+}
+
+// requestKey 和请求一样从 store 取出 key 1
+func requestKey(t *testing.T, p *KeyProvider) *models.APIKey {
+	t.Helper()
+	apiKey, err := p.getKeyDetails(testGroupID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return apiKey
+}
+
+func newEncryptedTestProvider(t *testing.T) *KeyProvider {
+	t.Helper()
+	p := newTestProvider(t)
+	svc, err := encryption.NewService("oauth-test-encryption-key-0123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.encryptionSvc = svc
+	return p
+}
+
+func oauthCredential(tokenURL string) string {
+	return fmt.Sprintf(`{"refresh_token":"rt-1","token_url":%q,"client_id":"client","tenant":{"id":7,"region":"eu"}}`, tokenURL)
+}
+
+func TestResolveAccessToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		expiresIn     int
+		rotateTo      string
+		wantTokens    []string // 两次请求分别得到的访问令牌
+		wantRefreshes []string // 令牌端点收到的 refresh token
+	}{
+		{
+			name:          "cached until near expiry",
+			expiresIn:     3600,
+			wantTokens:    []string{"at-1", "at-1"},
+			wantRefreshes: []string{"rt-1"},
+		},
+		{
+			name:          "refreshed inside the skew",
+			expiresIn:     int(oauthTokenRefreshSkew.Seconds()) - 60,
+			wantTokens:    []string{"at-1", "at-2"},
+			wantRefreshes: []string{"rt-1", "rt-1"},
+		},
+		{
+			name:          "rotated refresh token used for the next refresh",
+			expiresIn:     60,
+			rotateTo:      "rt-2",
+			wantTokens:    []string{"at-1", "at-2"},
+			wantRefreshes: []string{"rt-1", "rt-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeTokenServer(t)
+			srv.expiresIn, srv.rotateTo = tt.expiresIn, tt.rotateTo
+			p := newEncryptedTestProvider(t)
+			addOAuthKey(t, p, oauthCredential(srv.URL))
+
+			for i, want := range tt.wantTokens {
+				apiKey := requestKey(t, p)
+				if err := p.ResolveAccessToken(context.Background(), apiKey); err != nil {
+					t.Fatal(err)
+				}
+				if apiKey.AccessToken != want || apiKey.Secret() != want {
+					t.Fatalf("request %d access token = %q, Secret() = %q, want %q", i+1, apiKey.AccessToken, apiKey.Secret(), want)
+				}
+			}
+			if got := srv.refreshes(); strings.Join(got, ",") != strings.Join(tt.wantRefreshes, ",") {
+				t.Fatalf("refresh tokens sent = %v, want %v", got, tt.wantRefreshes)
+			}
+			if srv.clientIDs[0] != "client" {
+				t.Errorf("client_id = %q, want client", srv.clientIDs[0])
+			}
+
+			// 缓存的访问令牌加密保存
+			cached, err := p.store.Get(oauthTokenKey(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(cached), "at-") {
+				t.Errorf("cached access token stored in plaintext: %s", cached)
+			}
+		})
+	}
+}
+
+func TestRotateRefreshTokenKeepsCredentialFields(t *testing.T) {
+	srv := newFakeTokenServer(t)
+	srv.rotateTo = "rt-2"
+	p := newEncryptedTestProvider(t)
+	apiKey := addOAuthKey(t, p, oauthCredential(srv.URL))
+
+	if err := p.ResolveAccessToken(context.Background(), apiKey); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored models.APIKey
+	must(t, p.db.First(&stored, 1).Error)
+	decrypted, err := p.encryptionSvc.Decrypt(stored.KeyValue)
+	if err != nil {
+		t.Fatalf("stored credential is not encrypted: %v", err)
+	}
+	var fields map[string]json.RawMessage
+	must(t, json.Unmarshal([]byte(decrypted), &fields))
+	want := map[string]string{
+		"refresh_token": `"rt-2"`,
+		"token_url":     fmt.Sprintf("%q", srv.URL),
+		"client_id":     `"client"`,
+		"tenant":        `{"id":7,"region":"eu"}`,
+	}
+	if len(fields) != len(want) {
+		t.Errorf("credential fields = %s, want %v", decrypted, want)
+	}
+	for name, value := range want {
+		if string(fields[name]) != value {
+			t.Errorf("field %s = %s, want %s", name, fields[name], value)
+		}
+	}
+	if stored.KeyHash != p.encryptionSvc.Hash(models.CanonicalKeyValue(decrypted)) {
+		t.Errorf("key_hash not updated for the rotated credential")
+	}
+	if keyString := keyDetails(t, p, 1)["key_string"]; keyString != stored.KeyValue {
+		t.Errorf("store key_string not updated")
+	}
+	if apiKey.CredentialField(models.OAuthRefreshTokenField) != "rt-2" {
+		t.Errorf("request key refresh_token = %q, want rt-2", apiKey.CredentialField(models.OAuthRefreshTokenField))
+	}
+}
+
+func TestResolveAccessTokenFailure(t *testing.T) {
+	srv := newFakeTokenServer(t)
+	srv.failStatus = http.StatusBadRequest
+	p := newEncryptedTestProvider(t)
+	addOAuthKey(t, p, oauthCredential(srv.URL))
+
+	// 刷新失败和上游请求失败一样经 ValidateSingleKey 走 UpdateStatus
+	checker, group := newSampleChecker(t, p, http.NotFoundHandler(), types.SystemSettings{BlacklistThreshold: 1})
+	apiKey := requestKey(t, p)
+	valid, statusCode, err := checker.Validator.ValidateSingleKey(apiKey, group, false)
+	if valid || statusCode != http.StatusBadRequest {
+		t.Fatalf("ValidateSingleKey() = %v, %d, want invalid with 400", valid, statusCode)
+	}
+	var ve *app_errors.ValidationError
+	if !errors.As(err, &ve) || !strings.Contains(ve.Message, "invalid_grant: refresh token revoked") {
+		t.Fatalf("ValidateSingleKey() err = %v, want token refresh ValidationError", err)
+	}
+
+	details := waitForKeyDetails(t, p, 1, func(d map[string]string) bool { return d["status"] == models.KeyStatusInvalid })
+	if details["status"] != models.KeyStatusInvalid {
+		t.Fatalf("key status = %q, want %q", details["status"], models.KeyStatusInvalid)
+	}
+	if _, err := p.store.Get(oauthTokenKey(1)); err == nil {
+		t.Error("failed refresh cached an access token")
+	}
+}
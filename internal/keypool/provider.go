@@ -10,8 +10,10 @@ import (
 	app_errors "key-flow/internal/errors"
 	"key-flow/internal/models"
 	"key-flow/internal/store"
+	"maps"
 	"math/rand"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,7 +21,6 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 // cacheHitRecord 用于跟踪cache_hit条目以便定期清理
@@ -41,6 +42,12 @@ type KeyProvider struct {
 	cacheHitRecords map[string]*cacheHitRecord
 	cacheHitMu      sync.RWMutex
 	cleanupCancel   context.CancelFunc
+
+	// 最近最少使用策略读取和写入最近使用时间时持有，同一节点的并发选 key 不会选中同一个 key
+	lastUsedMu sync.Mutex
+
+	// key 状态写回队列
+	keyStates *keyStateQueue
 }
 
 // NewProvider 创建一个新的 KeyProvider 实例。
@@ -53,9 +60,18 @@ func NewProvider(db *gorm.DB, store store.Store, settingsManager *config.SystemS
 		encryptionSvc:   encryptionSvc,
 		cacheHitRecords: make(map[string]*cacheHitRecord),
 		cleanupCancel:   cancel,
+		keyStates:       newKeyStateQueue(),
 	}
 	// 启动定期清理goroutine
 	go p.startCacheHitCleanup(ctx)
+	// 启动冷却到期恢复goroutine
+	go p.startCooldownSweep(ctx)
+	// 启动 key 生效/过期时间检查goroutine
+	go p.startKeyScheduleSweep(ctx)
+	// 启动配额重置恢复goroutine
+	go p.startQuotaResetSweep(ctx)
+	// 启动 key 状态写回goroutine
+	go p.startKeyStateFlush(ctx)
 	return p
 }
 
@@ -64,8 +80,24 @@ func (p *KeyProvider) GetStore() store.Store {
 	return p.store
 }
 
-// SelectKey 为指定的分组使用加权随机算法选择一个可用的 APIKey。
-func (p *KeyProvider) SelectKey(groupID uint) (*models.APIKey, error) {
+// SelectKey 为指定的分组按选择策略（默认加权随机）选择一个可用的 APIKey，跳过已达到 RPM/TPM 限制、不满足标签约束或被拒绝访问所请求模型的 key。
+// 加权随机和 power_of_two 策略从权重索引中抽取，复杂度为 O(log n)；需要比较所有 key 的策略或抽取未命中时遍历 active 列表。
+// 满足约束的 key 都被拒绝访问所请求模型时返回 ModelDeniedError，携带上游最近一次的模型错误。
+func (p *KeyProvider) SelectKey(groupID uint, constraints SelectConstraints) (*models.APIKey, error) {
+	switch constraints.Strategy {
+	case StrategyLeastInFlight, StrategyLeastRecentlyUsed:
+		return p.selectKeyByScan(groupID, constraints, false)
+	}
+
+	apiKey, err := p.selectKeyByWeightIndex(groupID, constraints)
+	if !errors.Is(err, errWeightIndexMiss) {
+		return apiKey, err
+	}
+	return p.selectKeyByScan(groupID, constraints, errors.Is(err, errWeightIndexEmpty))
+}
+
+// selectKeyByScan 轮转整个 active 列表收集候选 key 后按策略选择，rebuildIndex 时同时把遍历到的 key 写入权重索引
+func (p *KeyProvider) selectKeyByScan(groupID uint, constraints SelectConstraints, rebuildIndex bool) (*models.APIKey, error) {
 	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
 
 	// 1. 获取列表长度
@@ -79,7 +111,7 @@ func (p *KeyProvider) SelectKey(groupID uint) (*models.APIKey, error) {
 
 	// 2. 如果只有一个 key，直接使用简单轮询
 	if listLen == 1 {
-		return p.selectKeyByRotate(groupID, activeKeysListKey)
+		return p.selectKeyByRotate(groupID, activeKeysListKey, constraints)
 	}
 
 	// 3. 收集所有 key 的权重信息
@@ -91,27 +123,47 @@ func (p *KeyProvider) SelectKey(groupID uint) (*models.APIKey, error) {
 		return nil, fmt.Errorf("failed to rotate key from store: %w", err)
 	}
 
-	// 构建 key 列表用于加权选择
-	type keyWeight struct {
-		id     uint64
-		weight int
-	}
-
-	keys := make([]keyWeight, 0, listLen)
+	// 构建候选 key 列表
+	keys := make([]keyCandidate, 0, listLen)
 	totalWeight := 0
+	modelDenied := p.modelDeniedFilter(groupID, constraints.Model)
+	// 记录被限流跳过的 key 中最短的等待时间
+	var minWait time.Duration
+	deniedKeys := 0
+
+	collect := func(keyID uint64) {
+		details, err := p.store.HGetAll(fmt.Sprintf("key:%d", keyID))
+		// active 列表中可能残留非 active 的 key（例如旧版本导入的未生效 key），既不选择也不写入索引
+		if err != nil || details["status"] != models.KeyStatusActive {
+			return
+		}
+		if rebuildIndex {
+			if err := p.indexKey(groupID, uint(keyID), keyWeight(details)); err != nil {
+				logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to rebuild key weight index")
+			}
+		}
+		if !constraints.allowsTags(details) {
+			return
+		}
+		if modelDenied(uint(keyID)) {
+			deniedKeys++
+			return
+		}
+		keyLimits := constraints.Limits.forDetails(details)
+		if wait := p.rateLimitWait(uint(keyID), keyLimits); wait > 0 {
+			if minWait == 0 || wait < minWait {
+				minWait = wait
+			}
+			return
+		}
+		w := keyWeight(details)
+		keys = append(keys, keyCandidate{id: keyID, weight: w, limits: keyLimits})
+		totalWeight += w
+	}
 
 	// 获取第一个 key 的权重
 	firstKeyID, _ := strconv.ParseUint(keyIDStr, 10, 64)
-	firstKeyHash := fmt.Sprintf("key:%d", firstKeyID)
-	firstDetails, err := p.store.HGetAll(firstKeyHash)
-	if err == nil {
-		w, _ := strconv.Atoi(firstDetails["weight"])
-		if w <= 0 {
-			w = 500
-		}
-		keys = append(keys, keyWeight{id: firstKeyID, weight: w})
-		totalWeight += w
-	}
+	collect(firstKeyID)
 
 	// 遍历获取其余 keys 的权重（通过连续 rotate）
 	for i := int64(1); i < listLen; i++ {
@@ -123,55 +175,103 @@ func (p *KeyProvider) SelectKey(groupID uint) (*models.APIKey, error) {
 		if nextKeyID == firstKeyID {
 			break // 已经轮转回来了
 		}
-		keyHash := fmt.Sprintf("key:%d", nextKeyID)
-		details, err := p.store.HGetAll(keyHash)
-		if err == nil {
-			w, _ := strconv.Atoi(details["weight"])
-			if w <= 0 {
-				w = 500
-			}
-			keys = append(keys, keyWeight{id: nextKeyID, weight: w})
-			totalWeight += w
-		}
+		collect(nextKeyID)
 	}
 
 	if len(keys) == 0 || totalWeight == 0 {
+		if minWait > 0 {
+			return nil, &RateLimitedError{RetryAfter: minWait}
+		}
+		if deniedKeys > 0 && len(keys) == 0 {
+			return nil, p.modelDeniedError(groupID, constraints.Model)
+		}
 		return nil, app_errors.ErrNoActiveKeys
 	}
 
-	// 4. 加权随机选择
-	r := rand.Intn(totalWeight)
-	cumulative := 0
-	var selectedKeyID uint64 = keys[0].id
+	for len(keys) > 0 {
+		// 4. 按分组的选择策略选出 key，默认加权随机
+		selected := p.pickKey(groupID, constraints.Strategy, keys, totalWeight)
 
-	for _, k := range keys {
-		cumulative += k.weight
-		if r < cumulative {
-			selectedKeyID = k.id
-			break
+		// 5. 占用并发槽位、记录请求并获取选中 key 的完整信息，并发已满时从剩余 key 中重新选择
+		lease, wait := p.acquireRateLimit(uint(selected.id), selected.limits)
+		if wait == 0 {
+			apiKey, err := p.getKeyDetails(groupID, selected.id)
+			if err != nil {
+				p.releaseRateLimit(uint(selected.id), lease)
+				return nil, err
+			}
+			lease.attach(apiKey)
+			return apiKey, nil
+		}
+		if minWait == 0 || wait < minWait {
+			minWait = wait
 		}
-	}
 
-	// 5. 获取选中 key 的完整信息
-	return p.getKeyDetails(groupID, selectedKeyID)
+		keys = slices.DeleteFunc(keys, func(k keyCandidate) bool { return k.id == selected.id })
+		totalWeight -= selected.weight
+	}
+	return nil, &RateLimitedError{RetryAfter: minWait}
 }
 
 // selectKeyByRotate 使用简单轮询选择 key（O(1) 复杂度，适用于权重相同或未开启缓存命中增强的场景）
-func (p *KeyProvider) selectKeyByRotate(groupID uint, activeKeysListKey string) (*models.APIKey, error) {
-	keyIDStr, err := p.store.Rotate(activeKeysListKey)
-	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			return nil, app_errors.ErrNoActiveKeys
+// 轮询到的 key 达到限制、不满足标签约束或被拒绝访问模型时继续轮询，直到所有 key 都被检查过
+func (p *KeyProvider) selectKeyByRotate(groupID uint, activeKeysListKey string, constraints SelectConstraints) (*models.APIKey, error) {
+	var listLen int64 = -1
+	var minWait time.Duration
+	deniedKeys := 0
+	modelDenied := p.modelDeniedFilter(groupID, constraints.Model)
+
+	for attempt := int64(0); listLen < 0 || attempt < listLen; attempt++ {
+		keyIDStr, err := p.store.Rotate(activeKeysListKey)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return nil, app_errors.ErrNoActiveKeys
+			}
+			return nil, fmt.Errorf("failed to rotate key from store: %w", err)
 		}
-		return nil, fmt.Errorf("failed to rotate key from store: %w", err)
-	}
 
-	keyID, err := strconv.ParseUint(keyIDStr, 10, 64)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse key ID '%s': %w", keyIDStr, err)
+		keyID, err := strconv.ParseUint(keyIDStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key ID '%s': %w", keyIDStr, err)
+		}
+
+		apiKey, err := p.getKeyDetails(groupID, keyID)
+		if err != nil {
+			return nil, err
+		}
+
+		// 与遍历选择一样跳过 active 列表中残留的非 active key
+		usable := apiKey.Status == models.KeyStatusActive && constraints.allowsKey(apiKey)
+		if usable && modelDenied(apiKey.ID) {
+			deniedKeys++
+			usable = false
+		}
+		if usable {
+			lease, wait := p.acquireRateLimit(apiKey.ID, constraints.Limits.ForKey(apiKey))
+			if wait == 0 {
+				lease.attach(apiKey)
+				return apiKey, nil
+			}
+			if minWait == 0 || wait < minWait {
+				minWait = wait
+			}
+		}
+
+		// 仅在出现限流或 key 不满足约束时才获取列表长度，未配置约束时保持 O(1)
+		if listLen < 0 {
+			if listLen, err = p.store.LLen(activeKeysListKey); err != nil {
+				return nil, fmt.Errorf("failed to get active keys list length: %w", err)
+			}
+		}
 	}
 
-	return p.getKeyDetails(groupID, keyID)
+	if minWait > 0 {
+		return nil, &RateLimitedError{RetryAfter: minWait}
+	}
+	if deniedKeys > 0 {
+		return nil, p.modelDeniedError(groupID, constraints.Model)
+	}
+	return nil, app_errors.ErrNoActiveKeys
 }
 
 // getKeyDetails 获取 key 的完整信息
@@ -204,16 +304,31 @@ func (p *KeyProvider) getKeyDetails(groupID uint, keyID uint64) (*models.APIKey,
 		decryptedKeyValue = encryptedKeyValue
 	}
 
+	rpmLimit, _ := strconv.Atoi(keyDetails["rpm_limit"])
+	tpmLimit, _ := strconv.Atoi(keyDetails["tpm_limit"])
+	maxConcurrency, _ := strconv.Atoi(keyDetails["max_concurrency"])
+	tags := models.ParseKeyTags(keyDetails["tags"])
+	upstream := keyDetails["upstream"]
+	cooldownCount, _ := strconv.Atoi(keyDetails["cooldown_count"])
+
 	apiKey := &models.APIKey{
-		ID:           uint(keyID),
-		KeyValue:     decryptedKeyValue,
-		Status:       keyDetails["status"],
-		BaseWeight:   baseWeight,
-		Weight:       weight,
-		FailureCount: failureCount,
-		GroupID:      groupID,
-		CreatedAt:    time.Unix(createdAt, 0),
-	}
+		ID:             uint(keyID),
+		KeyValue:       decryptedKeyValue,
+		Status:         keyDetails["status"],
+		BaseWeight:     baseWeight,
+		Weight:         weight,
+		Tags:           tags,
+		Upstream:       upstream,
+		FailureCount:   failureCount,
+		RPMLimit:       rpmLimit,
+		TPMLimit:       tpmLimit,
+		MaxConcurrency: maxConcurrency,
+		CooldownCount:  cooldownCount,
+		GroupID:        groupID,
+		CreatedAt:      time.Unix(createdAt, 0),
+	}
+	// 结构化凭证只在取出 key 时解析一次，渠道和请求头模板读取字段时复用
+	apiKey.ParseCredential()
 
 	return apiKey, nil
 }
@@ -222,15 +337,17 @@ func (p *KeyProvider) getKeyDetails(groupID uint, keyID uint64) (*models.APIKey,
 // forceDisableOnFailure: 如果为true，失败时直接禁用key，不检查黑名单阈值（用于手动测试）
 func (p *KeyProvider) UpdateStatus(apiKey *models.APIKey, group *models.Group, isSuccess bool, errorMessage string, statusCode int, forceDisableOnFailure bool) {
 	go func() {
-		keyHashKey := fmt.Sprintf("key:%d", apiKey.ID)
-		activeKeysListKey := fmt.Sprintf("group:%d:active_keys", group.ID)
-
 		if isSuccess {
-			if err := p.handleSuccess(apiKey.ID, keyHashKey, activeKeysListKey); err != nil {
+			if err := p.handleSuccess(apiKey.ID, group.ID); err != nil {
 				logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to handle key success")
 			}
 		} else {
-			if app_errors.IsUnCounted(errorMessage) {
+			if !forceDisableOnFailure && IsQuotaExhausted(group, statusCode, errorMessage) {
+				// 配额耗尽的 key 移出轮询直到配额重置，不计入失败次数
+				if err := p.markQuotaExhausted(apiKey, group); err != nil {
+					logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to mark key quota as exhausted")
+				}
+			} else if app_errors.IsUnCounted(errorMessage) {
 				logrus.WithFields(logrus.Fields{
 					"keyID": apiKey.ID,
 					"error": errorMessage,
@@ -247,7 +364,7 @@ func (p *KeyProvider) UpdateStatus(apiKey *models.APIKey, group *models.Group, i
 						}).Warn("Instant disable rule matched, forcing key disable")
 					}
 				}
-				if err := p.handleFailure(apiKey, group, keyHashKey, activeKeysListKey, forceDisableOnFailure); err != nil {
+				if err := p.handleFailure(apiKey, group, forceDisableOnFailure); err != nil {
 					logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to handle key failure")
 				}
 			}
@@ -282,106 +399,41 @@ func (p *KeyProvider) executeTransactionWithRetry(operation func(tx *gorm.DB) er
 	return err
 }
 
-func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey string) error {
-	keyDetails, err := p.store.HGetAll(keyHashKey)
+// handleSuccess 在 store 中原子地重置 key 的失败和冷却次数，失效的 key 同时恢复到 active 列表和权重索引，
+// 数据库由写回队列异步写入
+func (p *KeyProvider) handleSuccess(keyID, groupID uint) error {
+	result, err := p.applyKeyTransition(p.keyTransition(keyID, groupID, store.KeyTransitionSuccess))
 	if err != nil {
-		return fmt.Errorf("failed to get key details from store: %w", err)
+		return err
 	}
-
-	failureCount, _ := strconv.ParseInt(keyDetails["failure_count"], 10, 64)
-	isActive := keyDetails["status"] == models.KeyStatusActive
-
-	if failureCount == 0 && isActive {
-		return nil
+	if result.Restored {
+		logrus.WithField("keyID", keyID).Debug("Key has recovered and is being restored to active pool.")
 	}
-
-	return p.executeTransactionWithRetry(func(tx *gorm.DB) error {
-		var key models.APIKey
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&key, keyID).Error; err != nil {
-			return fmt.Errorf("failed to lock key %d for update: %w", keyID, err)
-		}
-
-		updates := map[string]any{"failure_count": 0}
-		if !isActive {
-			updates["status"] = models.KeyStatusActive
-		}
-
-		if err := tx.Model(&key).Updates(updates).Error; err != nil {
-			return fmt.Errorf("failed to update key in DB: %w", err)
-		}
-
-		if err := p.store.HSet(keyHashKey, updates); err != nil {
-			return fmt.Errorf("failed to update key details in store: %w", err)
-		}
-
-		if !isActive {
-			logrus.WithField("keyID", keyID).Debug("Key has recovered and is being restored to active pool.")
-			if err := p.store.LRem(activeKeysListKey, 0, keyID); err != nil {
-				return fmt.Errorf("failed to LRem key before LPush on recovery: %w", err)
-			}
-			if err := p.store.LPush(activeKeysListKey, keyID); err != nil {
-				return fmt.Errorf("failed to LPush key back to active list: %w", err)
-			}
-		}
-
-		return nil
-	})
+	return nil
 }
 
-func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group, keyHashKey, activeKeysListKey string, forceDisableOnFailure bool) error {
-	keyDetails, err := p.store.HGetAll(keyHashKey)
-	if err != nil {
-		return fmt.Errorf("failed to get key details from store: %w", err)
-	}
-
-	if keyDetails["status"] == models.KeyStatusInvalid {
-		return nil
-	}
-
-	failureCount, _ := strconv.ParseInt(keyDetails["failure_count"], 10, 64)
-
-	// 获取该分组的有效配置
+// handleFailure 在 store 中原子地增加 key 的失败次数，达到黑名单阈值或 forceDisableOnFailure 时禁用 key，
+// 数据库由写回队列异步写入
+func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group, forceDisableOnFailure bool) error {
 	blacklistThreshold := group.EffectiveConfig.BlacklistThreshold
 
-	return p.executeTransactionWithRetry(func(tx *gorm.DB) error {
-		var key models.APIKey
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&key, apiKey.ID).Error; err != nil {
-			return fmt.Errorf("failed to lock key %d for update: %w", apiKey.ID, err)
-		}
-
-		newFailureCount := failureCount + 1
-
-		updates := map[string]any{"failure_count": newFailureCount}
-		// 手动测试失败直接禁用，或者达到黑名单阈值时禁用
-		shouldBlacklist := forceDisableOnFailure || (blacklistThreshold > 0 && newFailureCount >= int64(blacklistThreshold))
-		if shouldBlacklist {
-			updates["status"] = models.KeyStatusInvalid
-		}
-
-		if err := tx.Model(&key).Updates(updates).Error; err != nil {
-			return fmt.Errorf("failed to update key stats in DB: %w", err)
-		}
+	transition := p.keyTransition(apiKey.ID, group.ID, store.KeyTransitionFailure)
+	transition.Threshold = int64(max(blacklistThreshold, 0))
+	// 手动测试失败直接禁用，或者达到黑名单阈值时禁用
+	transition.ForceDisable = forceDisableOnFailure
 
-		if _, err := p.store.HIncrBy(keyHashKey, "failure_count", 1); err != nil {
-			return fmt.Errorf("failed to increment failure count in store: %w", err)
-		}
-
-		if shouldBlacklist {
-			if forceDisableOnFailure {
-				logrus.WithFields(logrus.Fields{"keyID": apiKey.ID}).Warn("Manual test failed, key disabled immediately.")
-			} else {
-				logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "threshold": blacklistThreshold}).Warn("Key has reached blacklist threshold, disabling.")
-			}
-			if err := p.store.LRem(activeKeysListKey, 0, apiKey.ID); err != nil {
-				return fmt.Errorf("failed to LRem key from active list: %w", err)
-			}
-			if err := p.store.HSet(keyHashKey, map[string]any{"status": models.KeyStatusInvalid}); err != nil {
-				return fmt.Errorf("failed to update key status to invalid in store: %w", err)
-			}
+	result, err := p.applyKeyTransition(transition)
+	if err != nil {
+		return err
+	}
+	if result.Disabled {
+		if forceDisableOnFailure {
+			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID}).Warn("Manual test failed, key disabled immediately.")
+		} else {
+			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "threshold": blacklistThreshold}).Warn("Key has reached blacklist threshold, disabling.")
 		}
-
-		return nil
-	})
+	}
+	return nil
 }
 
 // LoadKeysFromDB 从数据库加载所有分组和密钥，并填充到 Store 中。
@@ -390,6 +442,7 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 
 	// 1. 分批从数据库加载并使用 Pipeline 写入 Redis
 	allActiveKeyIDs := make(map[uint][]any)
+	allActiveKeys := make(map[uint][]*models.APIKey)
 	batchSize := 10000
 	var batchKeys []*models.APIKey
 
@@ -415,6 +468,7 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 
 			if key.Status == models.KeyStatusActive {
 				allActiveKeyIDs[key.GroupID] = append(allActiveKeyIDs[key.GroupID], key.ID)
+				allActiveKeys[key.GroupID] = append(allActiveKeys[key.GroupID], key)
 			}
 		}
 
@@ -439,12 +493,40 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 			if err := p.store.LPush(activeKeysListKey, activeIDs...); err != nil {
 				logrus.WithFields(logrus.Fields{"groupID": groupID, "error": err}).Error("Failed to LPush active keys for group")
 			}
+
+			// 重建分组的权重索引
+			p.store.Delete(weightIndexKey(groupID))
+			for _, key := range allActiveKeys[groupID] {
+				if err := p.indexKey(groupID, key.ID, key.Weight); err != nil {
+					logrus.WithFields(logrus.Fields{"groupID": groupID, "keyID": key.ID, "error": err}).Error("Failed to add key to weight index")
+					break
+				}
+			}
 		}
 	}
 
 	return nil
 }
 
+// CacheFingerprint 描述数据库中 key 和分组的当前状态以及缓存中 key 详情的字段。任何写入都会改变记录数
+// 或最新的 updated_at，新版本增加缓存字段时也会改变指纹。内存存储快照和共享存储中的缓存只在指纹与
+// 记录时一致时沿用，否则重新从数据库加载
+func (p *KeyProvider) CacheFingerprint() (string, error) {
+	parts := []string{strings.Join(slices.Sorted(maps.Keys(p.apiKeyToMap(&models.APIKey{}))), ",")}
+	for _, model := range []any{&models.APIKey{}, &models.Group{}} {
+		var count int64
+		var latest any
+		if err := p.db.Model(model).Select("COUNT(*), MAX(updated_at)").Row().Scan(&count, &latest); err != nil {
+			return "", fmt.Errorf("failed to read cache fingerprint: %w", err)
+		}
+		if b, ok := latest.([]byte); ok {
+			latest = string(b)
+		}
+		parts = append(parts, fmt.Sprintf("%d@%v", count, latest))
+	}
+	return strings.Join(parts, ";"), nil
+}
+
 // AddKeys 批量添加新的 Key 到池和数据库中。
 func (p *KeyProvider) AddKeys(groupID uint, keys []models.APIKey) error {
 	if len(keys) == 0 {
@@ -475,7 +557,7 @@ func (p *KeyProvider) RemoveKeys(groupID uint, keyValues []string) (int64, error
 	err := p.db.Transaction(func(tx *gorm.DB) error {
 		var keyHashes []string
 		for _, keyValue := range keyValues {
-			keyHash := p.encryptionSvc.Hash(keyValue)
+			keyHash := p.encryptionSvc.Hash(models.CanonicalKeyValue(keyValue))
 			if keyHash != "" {
 				keyHashes = append(keyHashes, keyHash)
 			}
@@ -514,13 +596,16 @@ func (p *KeyProvider) RemoveKeys(groupID uint, keyValues []string) (int64, error
 	return deletedCount, err
 }
 
-// RestoreKeys 恢复组内所有无效的 Key。
+// restorableKeyStatuses 是可以手动恢复的 key 状态
+var restorableKeyStatuses = []string{models.KeyStatusInvalid, models.KeyStatusArchived}
+
+// RestoreKeys 恢复组内所有无效或已归档的 Key。
 func (p *KeyProvider) RestoreKeys(groupID uint) (int64, error) {
 	var invalidKeys []models.APIKey
 	var restoredCount int64
 
 	err := p.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Where("group_id = ? AND status = ?", groupID, models.KeyStatusInvalid).Find(&invalidKeys).Error; err != nil {
+		if err := tx.Where("group_id = ? AND status IN ?", groupID, restorableKeyStatuses).Find(&invalidKeys).Error; err != nil {
 			return err
 		}
 
@@ -529,19 +614,20 @@ func (p *KeyProvider) RestoreKeys(groupID uint) (int64, error) {
 		}
 
 		updates := map[string]any{
-			"status":        models.KeyStatusActive,
-			"failure_count": 0,
+			"status":                   models.KeyStatusActive,
+			"failure_count":            0,
+			"validation_failures":      0,
+			"validation_failing_since": nil,
+			"next_validation_at":       nil,
 		}
-		result := tx.Model(&models.APIKey{}).Where("group_id = ? AND status = ?", groupID, models.KeyStatusInvalid).Updates(updates)
+		result := tx.Model(&models.APIKey{}).Where("group_id = ? AND status IN ?", groupID, restorableKeyStatuses).Updates(updates)
 		if result.Error != nil {
 			return result.Error
 		}
 		restoredCount = result.RowsAffected
 
 		for _, key := range invalidKeys {
-			key.Status = models.KeyStatusActive
-			key.FailureCount = 0
-			if err := p.addKeyToStore(&key); err != nil {
+			if err := p.restoreKeyInStore(&key); err != nil {
 				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to restore key in store after DB update, rolling back transaction")
 				return err
 			}
@@ -564,7 +650,7 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 	err := p.db.Transaction(func(tx *gorm.DB) error {
 		var keyHashes []string
 		for _, keyValue := range keyValues {
-			keyHash := p.encryptionSvc.Hash(keyValue)
+			keyHash := p.encryptionSvc.Hash(models.CanonicalKeyValue(keyValue))
 			if keyHash != "" {
 				keyHashes = append(keyHashes, keyHash)
 			}
@@ -574,7 +660,7 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 			return nil
 		}
 
-		if err := tx.Where("group_id = ? AND key_hash IN ? AND status = ?", groupID, keyHashes, models.KeyStatusInvalid).Find(&keysToRestore).Error; err != nil {
+		if err := tx.Where("group_id = ? AND key_hash IN ? AND status IN ?", groupID, keyHashes, restorableKeyStatuses).Find(&keysToRestore).Error; err != nil {
 			return err
 		}
 
@@ -585,8 +671,11 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 		keyIDsToRestore := pluckIDs(keysToRestore)
 
 		updates := map[string]any{
-			"status":        models.KeyStatusActive,
-			"failure_count": 0,
+			"status":                   models.KeyStatusActive,
+			"failure_count":            0,
+			"validation_failures":      0,
+			"validation_failing_since": nil,
+			"next_validation_at":       nil,
 		}
 		result := tx.Model(&models.APIKey{}).Where("id IN ?", keyIDsToRestore).Updates(updates)
 		if result.Error != nil {
@@ -595,9 +684,7 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 		restoredCount = result.RowsAffected
 
 		for _, key := range keysToRestore {
-			key.Status = models.KeyStatusActive
-			key.FailureCount = 0
-			if err := p.addKeyToStore(&key); err != nil {
+			if err := p.restoreKeyInStore(&key); err != nil {
 				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to restore key in store after DB update")
 				return err
 			}
@@ -609,6 +696,31 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 	return restoredCount, err
 }
 
+// restoreKeyInStore 在 store 中原子地将手动恢复的 key 移回 active 列表和权重索引。
+// store 中没有该 key 时按数据库中的记录重新写入。
+func (p *KeyProvider) restoreKeyInStore(key *models.APIKey) error {
+	t := p.keyMove(key.ID, key.GroupID, models.KeyStatusActive, restorableKeyStatuses...)
+	t.Set = map[string]any{"failure_count": 0}
+	result, err := p.applyKeyTransition(t)
+	if err != nil || result.Changed {
+		return err
+	}
+
+	exists, err := p.store.Exists(fmt.Sprintf("key:%d", key.ID))
+	if err != nil || exists {
+		return err
+	}
+	key.Status = models.KeyStatusActive
+	key.FailureCount = 0
+	return p.addKeyToStore(key)
+}
+
+// DisableKeyInStore 在 store 中原子地将手动禁用的 key 设为失效，并移出活跃列表和权重索引
+func (p *KeyProvider) DisableKeyInStore(keyID, groupID uint) error {
+	_, err := p.applyKeyTransition(p.keyMove(keyID, groupID, models.KeyStatusInvalid))
+	return err
+}
+
 // RemoveInvalidKeys 移除组内所有无效的 Key。
 func (p *KeyProvider) RemoveInvalidKeys(groupID uint) (int64, error) {
 	return p.removeKeysByStatus(groupID, models.KeyStatusInvalid)
@@ -678,6 +790,20 @@ func (p *KeyProvider) RemoveKeysFromStore(groupID uint, keyIDs []uint) error {
 		}).Error("Failed to delete active keys list")
 		return err
 	}
+	if err := p.store.Delete(weightIndexKey(groupID)); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"groupID": groupID,
+			"error":   err,
+		}).Error("Failed to delete key weight index")
+		return err
+	}
+	if err := p.store.Delete(lastUsedKey(groupID)); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"groupID": groupID,
+			"error":   err,
+		}).Error("Failed to delete key last used times")
+		return err
+	}
 
 	// 第二步：批量删除所有相关的key hash
 	for _, keyID := range keyIDs {
@@ -716,6 +842,9 @@ func (p *KeyProvider) addKeyToStore(key *models.APIKey) error {
 		if err := p.store.LPush(activeKeysListKey, key.ID); err != nil {
 			return fmt.Errorf("failed to LPush key %d to group %d: %w", key.ID, key.GroupID, err)
 		}
+		if err := p.indexKey(key.GroupID, key.ID, keyDetails["weight"].(int)); err != nil {
+			return fmt.Errorf("failed to add key %d to weight index of group %d: %w", key.ID, key.GroupID, err)
+		}
 	}
 	return nil
 }
@@ -747,11 +876,16 @@ func (p *KeyProvider) addKeysToCacheBatch(groupID uint, keys []models.APIKey) er
 		}
 	}
 
-	// 2. 收集所有密钥 ID
+	// 2. 收集活跃密钥 ID，未到生效时间或已过期的 key 只写入详情，由定期检查在生效时加入轮询
 	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
-	activeKeyIDs := make([]any, len(keys))
+	activeKeyIDs := make([]any, 0, len(keys))
 	for i := range keys {
-		activeKeyIDs[i] = keys[i].ID
+		if keys[i].Status == models.KeyStatusActive {
+			activeKeyIDs = append(activeKeyIDs, keys[i].ID)
+		}
+	}
+	if len(activeKeyIDs) == 0 {
+		return nil
 	}
 
 	// 3. 批量 LPush 活跃密钥
@@ -759,6 +893,16 @@ func (p *KeyProvider) addKeysToCacheBatch(groupID uint, keys []models.APIKey) er
 		return fmt.Errorf("failed to batch LPush keys to group %d: %w", groupID, err)
 	}
 
+	// 4. 加入权重索引
+	for i := range keys {
+		if keys[i].Status != models.KeyStatusActive {
+			continue
+		}
+		if err := p.indexKey(groupID, keys[i].ID, keys[i].Weight); err != nil {
+			return fmt.Errorf("failed to add key %d to weight index of group %d: %w", keys[i].ID, groupID, err)
+		}
+	}
+
 	return nil
 }
 
@@ -768,6 +912,12 @@ func (p *KeyProvider) removeKeyFromStore(keyID, groupID uint) error {
 	if err := p.store.LRem(activeKeysListKey, 0, keyID); err != nil {
 		logrus.WithFields(logrus.Fields{"keyID": keyID, "groupID": groupID, "error": err}).Error("Failed to LRem key from active list")
 	}
+	if err := p.unindexKey(groupID, keyID); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "groupID": groupID, "error": err}).Error("Failed to remove key from weight index")
+	}
+	if err := p.store.HDel(lastUsedKey(groupID), strconv.FormatUint(uint64(keyID), 10)); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "groupID": groupID, "error": err}).Error("Failed to remove key last used time")
+	}
 
 	keyHashKey := fmt.Sprintf("key:%d", keyID)
 	if err := p.store.Delete(keyHashKey); err != nil {
@@ -787,14 +937,20 @@ func (p *KeyProvider) apiKeyToMap(key *models.APIKey) map[string]any {
 		weight = baseWeight
 	}
 	return map[string]any{
-		"id":            fmt.Sprint(key.ID),
-		"key_string":    key.KeyValue,
-		"status":        key.Status,
-		"base_weight":   baseWeight,
-		"weight":        weight,
-		"failure_count": key.FailureCount,
-		"group_id":      key.GroupID,
-		"created_at":    key.CreatedAt.Unix(),
+		"id":              fmt.Sprint(key.ID),
+		"key_string":      key.KeyValue,
+		"status":          key.Status,
+		"base_weight":     baseWeight,
+		"weight":          weight,
+		"failure_count":   key.FailureCount,
+		"tags":            key.Tags.String(),
+		"upstream":        key.Upstream,
+		"rpm_limit":       key.RPMLimit,
+		"tpm_limit":       key.TPMLimit,
+		"max_concurrency": key.MaxConcurrency,
+		"cooldown_count":  key.CooldownCount,
+		"group_id":        key.GroupID,
+		"created_at":      key.CreatedAt.Unix(),
 	}
 }
 
@@ -834,6 +990,7 @@ func (p *KeyProvider) UpdateKeyWeight(keyID uint, weight int) error {
 		}); err != nil {
 			return fmt.Errorf("failed to update key weight in store: %w", err)
 		}
+		p.updateIndexedWeight(key.GroupID, keyID, weight)
 
 		// 清除该key的缓存命中记录
 		p.clearCacheHitRecordsForKey(keyID)
@@ -890,6 +1047,7 @@ func (p *KeyProvider) UpdateKeysWeight(groupID uint, keyHashes []string, weight
 					"error": err,
 				}).Error("Failed to update key weight in store")
 			}
+			p.updateIndexedWeight(key.GroupID, key.ID, weight)
 			// 清除该key的缓存命中记录
 			p.clearCacheHitRecordsForKey(key.ID)
 		}
@@ -938,6 +1096,7 @@ func (p *KeyProvider) ResetKeysWeight(groupID uint) (int64, error) {
 					"error": err,
 				}).Error("Failed to reset key weight in store")
 			}
+			p.updateIndexedWeight(groupID, key.ID, defaultWeight)
 			// 清除该key的缓存命中记录
 			p.clearCacheHitRecordsForKey(key.ID)
 		}
@@ -971,6 +1130,7 @@ func (p *KeyProvider) ResetSingleKeyWeight(keyID uint) error {
 		if err := p.store.HSet(keyHashKey, map[string]any{"weight": baseWeight}); err != nil {
 			return fmt.Errorf("failed to reset key weight in store: %w", err)
 		}
+		p.updateIndexedWeight(key.GroupID, keyID, baseWeight)
 
 		// 清除该key的缓存命中记录
 		p.clearCacheHitRecordsForKey(keyID)
@@ -981,47 +1141,59 @@ func (p *KeyProvider) ResetSingleKeyWeight(keyID uint) error {
 
 // SelectKeyWithCacheHit 支持缓存命中的key选择（含 Session ID 绑定 + 动态 TTL）
 // 仅在 Anthropic+Claude 模型且请求体包含 cache_control 标记时才要求 cache_control，其他渠道直接启用
-func (p *KeyProvider) SelectKeyWithCacheHit(groupID uint, bodyBytes []byte, headers http.Header, enableCacheHit bool, channelType string) (*models.APIKey, error) {
-	// 没开启缓存命中增强，权重不会变化，直接使用简单轮询，跳过 O(n) 权重计算
-	if !enableCacheHit {
-		activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
-		return p.selectKeyByRotate(groupID, activeKeysListKey)
+// constraints 中的 Tags/Model 由调用方设置，RPM/TPM 限制取自分组配置
+func (p *KeyProvider) SelectKeyWithCacheHit(group *models.Group, bodyBytes []byte, headers http.Header, constraints SelectConstraints) (*models.APIKey, error) {
+	groupID := group.ID
+	constraints.Limits = GroupRateLimits(group)
+	constraints.Strategy = group.EffectiveConfig.KeySelectionStrategy
+
+	// 没开启缓存命中增强，权重不会变化，轮询策略直接使用简单轮询，跳过 O(n) 权重计算
+	if !group.EffectiveConfig.EnableCacheHitEnhancement {
+		return p.selectKeyByStrategy(groupID, constraints)
 	}
 
 	// Anthropic+Claude 模型需要请求体包含 cache_control 标记才启用缓存命中增强
-	if RequiresCacheControl(channelType, bodyBytes) {
+	if RequiresCacheControl(group.ChannelType, bodyBytes) {
 		ccResult := DetectCacheControl(bodyBytes)
 		if !ccResult.Found {
-			activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
-			return p.selectKeyByRotate(groupID, activeKeysListKey)
+			return p.selectKeyByStrategy(groupID, constraints)
 		}
-		return p.selectKeyWithTTL(groupID, bodyBytes, headers, ccResult.TTL)
+		return p.selectKeyWithTTL(groupID, bodyBytes, headers, ccResult.TTL, constraints)
 	}
 
 	// 非 Anthropic+Claude：直接启用缓存命中增强，使用默认 TTL
-	return p.selectKeyWithTTL(groupID, bodyBytes, headers, defaultCacheTTL)
+	return p.selectKeyWithTTL(groupID, bodyBytes, headers, defaultCacheTTL, constraints)
+}
+
+// selectKeyByStrategy 不使用缓存命中增强时选 key：轮询策略使用 O(1) 简单轮询，其他策略需要比较所有 key
+func (p *KeyProvider) selectKeyByStrategy(groupID uint, constraints SelectConstraints) (*models.APIKey, error) {
+	if constraints.Strategy == "" || constraints.Strategy == StrategyRoundRobin {
+		activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+		return p.selectKeyByRotate(groupID, activeKeysListKey, constraints)
+	}
+	return p.SelectKey(groupID, constraints)
 }
 
 // selectKeyWithTTL 使用指定 TTL 执行缓存命中增强选 key
-func (p *KeyProvider) selectKeyWithTTL(groupID uint, bodyBytes []byte, headers http.Header, ttl time.Duration) (*models.APIKey, error) {
+func (p *KeyProvider) selectKeyWithTTL(groupID uint, bodyBytes []byte, headers http.Header, ttl time.Duration, constraints SelectConstraints) (*models.APIKey, error) {
 	sessionID := ExtractSessionID(bodyBytes, headers)
 
 	// 有 Session ID → session 绑定优先
 	if sessionID != "" {
-		return p.selectKeyBySession(groupID, sessionID, bodyBytes, ttl)
+		return p.selectKeyBySession(groupID, sessionID, bodyBytes, ttl, constraints)
 	}
 
 	// 无 Session ID → 内容哈希匹配（无门槛限制）
 	messages, _ := ExtractMessages(bodyBytes)
 	if len(messages) > 0 {
-		return p.selectKeyByHash(groupID, messages, ttl)
+		return p.selectKeyByHash(groupID, messages, ttl, constraints)
 	}
 
-	return p.SelectKey(groupID)
+	return p.SelectKey(groupID, constraints)
 }
 
 // selectKeyBySession 基于 Session ID 绑定选择 key
-func (p *KeyProvider) selectKeyBySession(groupID uint, sessionID string, bodyBytes []byte, ttl time.Duration) (*models.APIKey, error) {
+func (p *KeyProvider) selectKeyBySession(groupID uint, sessionID string, bodyBytes []byte, ttl time.Duration, constraints SelectConstraints) (*models.APIKey, error) {
 	cacheKey := fmt.Sprintf("session:group:%d:sid:%s", groupID, sessionID)
 
 	// 1. 尝试命中已有 session 绑定
@@ -1031,6 +1203,20 @@ func (p *KeyProvider) selectKeyBySession(groupID uint, sessionID string, bodyByt
 		if err := json.Unmarshal(data, &entry); err == nil {
 			apiKey, err := p.getKeyDetails(groupID, uint64(entry.KeyID))
 			if err == nil && apiKey.Status == models.KeyStatusActive {
+				if !constraints.allowsKey(apiKey) || p.modelDeniedFilter(groupID, constraints.Model)(apiKey.ID) {
+					// 绑定的 key 不满足本次请求的标签约束或无权访问模型，临时使用其他 key，保留 session 绑定
+					return p.SelectKey(groupID, constraints)
+				}
+				lease, wait := p.acquireRateLimit(apiKey.ID, constraints.Limits.ForKey(apiKey))
+				if wait > 0 {
+					// 绑定的 key 已达到限制，本次临时使用其他 key，保留 session 绑定
+					logrus.WithFields(logrus.Fields{
+						"groupID": groupID,
+						"keyID":   entry.KeyID,
+					}).Debug("Cache hit enhancement: session key is rate limited, selecting another key")
+					return p.SelectKey(groupID, constraints)
+				}
+
 				// 命中有效 key，刷新 TTL
 				newExpTime := time.Now().Add(ttl).Unix()
 				entry.ExpTime = newExpTime
@@ -1049,6 +1235,7 @@ func (p *KeyProvider) selectKeyBySession(groupID uint, sessionID string, bodyByt
 					"keyID":     entry.KeyID,
 					"sessionID": sessionID[:8] + "...",
 				}).Debug("Cache hit enhancement: session hit, refreshed TTL")
+				lease.attach(apiKey)
 				return apiKey, nil
 			}
 			// key 已失效，删除条目并恢复权重
@@ -1059,7 +1246,7 @@ func (p *KeyProvider) selectKeyBySession(groupID uint, sessionID string, bodyByt
 	}
 
 	// 2. 未命中 → 选新 key
-	key, err := p.SelectKey(groupID)
+	key, err := p.SelectKey(groupID, constraints)
 	if err != nil {
 		return nil, err
 	}
@@ -1093,19 +1280,12 @@ func (p *KeyProvider) selectKeyBySession(groupID uint, sessionID string, bodyByt
 			"keyID":     key.ID,
 			"sessionID": sessionID[:8] + "...",
 		}).Debug("Cache hit enhancement: created new session binding")
-	} else {
-		// 已被其他请求写入，获取该 key
-		data, err := p.store.Get(cacheKey)
-		if err == nil && data != nil {
-			var existing CacheHitEntry
-			if err := json.Unmarshal(data, &existing); err == nil {
-				if apiKey, err := p.getKeyDetails(groupID, uint64(existing.KeyID)); err == nil && apiKey.Status == models.KeyStatusActive {
-					return apiKey, nil
-				}
-			}
-		}
-		// 读不到或已失效，返回当前选中的 key
+	} else if bound := p.acquireBoundKey(groupID, cacheKey, constraints); bound != nil {
+		// 已被其他请求写入，改用绑定的 key，归还本次选中 key 占用的并发槽位和 RPM 计数
+		p.releaseRateLimit(key.ID, keyRateLimitLease(key))
+		return bound, nil
 	}
+	// 绑定的 key 读不到、已失效或已达到限制时，返回当前选中的 key
 
 	// 同时为 hash 创建绑定（session→hash 双重覆盖）
 	messages, _ := ExtractMessages(bodyBytes)
@@ -1120,8 +1300,34 @@ func (p *KeyProvider) selectKeyBySession(groupID uint, sessionID string, bodyByt
 	return key, nil
 }
 
+// acquireBoundKey 读取 session 已绑定的 key，与命中绑定时一样检查约束并占用并发槽位和限流计数，
+// key 不可用时返回 nil
+func (p *KeyProvider) acquireBoundKey(groupID uint, cacheKey string, constraints SelectConstraints) *models.APIKey {
+	data, err := p.store.Get(cacheKey)
+	if err != nil || data == nil {
+		return nil
+	}
+	var entry CacheHitEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	apiKey, err := p.getKeyDetails(groupID, uint64(entry.KeyID))
+	if err != nil || apiKey.Status != models.KeyStatusActive {
+		return nil
+	}
+	if !constraints.allowsKey(apiKey) || p.modelDeniedFilter(groupID, constraints.Model)(apiKey.ID) {
+		return nil
+	}
+	lease, wait := p.acquireRateLimit(apiKey.ID, constraints.Limits.ForKey(apiKey))
+	if wait > 0 {
+		return nil
+	}
+	lease.attach(apiKey)
+	return apiKey
+}
+
 // selectKeyByHash 基于内容哈希匹配选择 key（无门槛限制）
-func (p *KeyProvider) selectKeyByHash(groupID uint, messages []json.RawMessage, ttl time.Duration) (*models.APIKey, error) {
+func (p *KeyProvider) selectKeyByHash(groupID uint, messages []json.RawMessage, ttl time.Duration, constraints SelectConstraints) (*models.APIKey, error) {
 	// 尝试匹配：dropCount = 2, 4, 6
 	for _, dropCount := range []int{2, 4, 6} {
 		hash := CalculatePromptHash(messages, dropCount)
@@ -1141,6 +1347,16 @@ func (p *KeyProvider) selectKeyByHash(groupID uint, messages []json.RawMessage,
 				continue
 			}
 
+			// key 不满足约束或已达到限制时不复用，保留缓存条目
+			if !constraints.allowsKey(apiKey) || p.modelDeniedFilter(groupID, constraints.Model)(apiKey.ID) {
+				continue
+			}
+			lease, wait := p.acquireRateLimit(apiKey.ID, constraints.Limits.ForKey(apiKey))
+			if wait > 0 {
+				continue
+			}
+			lease.attach(apiKey)
+
 			// 记录新hash（如果与命中的不同）
 			newHash := CalculatePromptHash(messages, 2)
 			if newHash != "" && newHash != hash {
@@ -1164,7 +1380,7 @@ func (p *KeyProvider) selectKeyByHash(groupID uint, messages []json.RawMessage,
 	}
 
 	// 未命中：随机选key，记录hash，权重-1
-	key, err := p.SelectKey(groupID)
+	key, err := p.SelectKey(groupID, constraints)
 	if err != nil {
 		return nil, err
 	}
@@ -1286,6 +1502,9 @@ func (p *KeyProvider) AdjustKeyWeightAsync(keyID uint, delta int) {
 			newWeight = baseWeight
 		}
 		p.store.HSet(keyHashKey, map[string]any{"weight": newWeight})
+		if groupID, err := strconv.ParseUint(details["group_id"], 10, 64); err == nil {
+			p.updateIndexedWeight(uint(groupID), keyID, newWeight)
+		}
 	}()
 }
 
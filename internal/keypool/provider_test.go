@@ -0,0 +1,393 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"key-flow/internal/encryption"
+	app_errors "key-flow/internal/errors"
+	"key-flow/internal/models"
+	"key-flow/internal/store"
+	"key-flow/internal/types"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testGroupID = 1
+
+// newTestProvider 返回使用内存 store 和临时 SQLite 数据库的 KeyProvider，不启动后台任务
+func newTestProvider(t *testing.T) *KeyProvider {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "keys.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.APIKey{}); err != nil {
+		t.Fatal(err)
+	}
+	encryptionSvc, err := encryption.NewService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &KeyProvider{
+		db:              db,
+		store:           store.NewMemoryStore(),
+		encryptionSvc:   encryptionSvc,
+		cacheHitRecords: make(map[string]*cacheHitRecord),
+		keyStates:       newKeyStateQueue(),
+	}
+}
+
+// addTestKeys 将 key 写入数据库和 store，ID 按顺序从 1 开始，未设置状态的 key 为 active
+func addTestKeys(t *testing.T, p *KeyProvider, keys ...models.APIKey) {
+	t.Helper()
+	for i := range keys {
+		key := &keys[i]
+		key.ID = uint(i + 1)
+		key.GroupID = testGroupID
+		key.KeyValue = fmt.Sprintf("sk-test-%d", key.ID)
+		if key.Status == "" {
+			key.Status = models.KeyStatusActive
+		}
+		if err := p.db.Create(key).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := p.addKeyToStore(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// keyDetails 返回 store 中 key 的详情
+func keyDetails(t *testing.T, p *KeyProvider, keyID uint) map[string]string {
+	t.Helper()
+	details, err := p.store.HGetAll(fmt.Sprintf("key:%d", keyID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return details
+}
+
+func testGroup(cfg types.SystemSettings) *models.Group {
+	return &models.Group{ID: testGroupID, Name: "test", EffectiveConfig: cfg}
+}
+
+// selectStrategies 是选 key 测试覆盖的策略，加权随机和 power_of_two 走权重索引，其余遍历 active 列表
+var selectStrategies = []string{"", StrategyPowerOfTwo, StrategyLeastInFlight, StrategyLeastRecentlyUsed}
+
+// selectKeyTest 描述一次 SelectKey 调用的场景，各功能的测试共用 runSelectKeyTests 执行
+type selectKeyTest struct {
+	name        string
+	keys        []models.APIKey
+	setup       func(t *testing.T, p *KeyProvider)
+	constraints SelectConstraints
+	wantKey     uint  // 0 表示不应选出 key
+	wantErr     error // wantKey 为 0 且 wantErr 为空时期望 RateLimitedError
+	check       func(t *testing.T, p *KeyProvider, apiKey *models.APIKey)
+	wantStatus  map[uint]string
+}
+
+// runSelectKeyTests 对每个场景和每种选择策略分别建立 provider 并执行 SelectKey
+func runSelectKeyTests(t *testing.T, tests []selectKeyTest) {
+	t.Helper()
+	for _, tt := range tests {
+		for _, strategy := range selectStrategies {
+			t.Run(fmt.Sprintf("%s/%s", tt.name, strategy), func(t *testing.T) {
+				p := newTestProvider(t)
+				addTestKeys(t, p, tt.keys...)
+				if tt.setup != nil {
+					tt.setup(t, p)
+				}
+
+				constraints := tt.constraints
+				constraints.Strategy = strategy
+				apiKey, err := p.SelectKey(testGroupID, constraints)
+				switch {
+				case tt.wantKey != 0:
+					if err != nil || apiKey.ID != tt.wantKey {
+						t.Fatalf("SelectKey() = %v, %v, want key %d", apiKey, err, tt.wantKey)
+					}
+				case tt.wantErr != nil:
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("SelectKey() err = %v, want %v", err, tt.wantErr)
+					}
+				default:
+					var rateLimited *RateLimitedError
+					if !errors.As(err, &rateLimited) || rateLimited.RetryAfter <= 0 {
+						t.Fatalf("SelectKey() err = %v, want RateLimitedError", err)
+					}
+				}
+
+				if tt.check != nil {
+					tt.check(t, p, apiKey)
+				}
+				for keyID, want := range tt.wantStatus {
+					if status := keyDetails(t, p, keyID)["status"]; status != want {
+						t.Errorf("key %d status = %q, want %q", keyID, status, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSelectKey(t *testing.T) {
+	runSelectKeyTests(t, []selectKeyTest{
+		{
+			name:       "skips inactive keys",
+			keys:       []models.APIKey{{Status: models.KeyStatusInvalid}, {}},
+			wantKey:    2,
+			wantStatus: map[uint]string{1: models.KeyStatusInvalid, 2: models.KeyStatusActive},
+		},
+		{
+			name:    "no active keys",
+			keys:    []models.APIKey{{Status: models.KeyStatusInvalid}},
+			wantErr: app_errors.ErrNoActiveKeys,
+		},
+	})
+}
+
+func TestUpdateStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          models.APIKey
+		config       types.SystemSettings
+		success      bool
+		errorMessage string
+		statusCode   int
+		force        bool
+		wantStatus   string
+		wantFailures string
+		wantSelected bool // key 更新后是否仍能被选中
+	}{
+		{
+			name:         "success resets failures",
+			key:          models.APIKey{FailureCount: 2},
+			success:      true,
+			wantStatus:   models.KeyStatusActive,
+			wantFailures: "0",
+			wantSelected: true,
+		},
+		{
+			name:         "success restores invalid key",
+			key:          models.APIKey{Status: models.KeyStatusInvalid, FailureCount: 3},
+			success:      true,
+			wantStatus:   models.KeyStatusActive,
+			wantFailures: "0",
+			wantSelected: true,
+		},
+		{
+			name:         "failure below threshold",
+			key:          models.APIKey{FailureCount: 1},
+			config:       types.SystemSettings{BlacklistThreshold: 3},
+			statusCode:   500,
+			wantStatus:   models.KeyStatusActive,
+			wantFailures: "2",
+			wantSelected: true,
+		},
+		{
+			name:         "failure reaching threshold disables key",
+			key:          models.APIKey{FailureCount: 2},
+			config:       types.SystemSettings{BlacklistThreshold: 3},
+			statusCode:   500,
+			wantStatus:   models.KeyStatusInvalid,
+			wantFailures: "3",
+		},
+		{
+			name:         "zero threshold never disables",
+			key:          models.APIKey{FailureCount: 10},
+			statusCode:   500,
+			wantStatus:   models.KeyStatusActive,
+			wantFailures: "11",
+			wantSelected: true,
+		},
+		{
+			name:         "forced disable",
+			config:       types.SystemSettings{BlacklistThreshold: 3},
+			statusCode:   401,
+			force:        true,
+			wantStatus:   models.KeyStatusInvalid,
+			wantFailures: "1",
+		},
+		{
+			name:         "instant disable rule",
+			config:       types.SystemSettings{BlacklistThreshold: 3, EnableInstantDisable: true, InstantDisableRules: "status:401"},
+			statusCode:   401,
+			wantStatus:   models.KeyStatusInvalid,
+			wantFailures: "1",
+		},
+		{
+			name:         "uncounted error",
+			config:       types.SystemSettings{BlacklistThreshold: 1},
+			errorMessage: "Resource has been exhausted (e.g. check quota).",
+			statusCode:   429,
+			wantStatus:   models.KeyStatusActive,
+			wantFailures: "0",
+			wantSelected: true,
+		},
+		{
+			name:         "failure of invalid key is not counted",
+			key:          models.APIKey{Status: models.KeyStatusInvalid, FailureCount: 3},
+			config:       types.SystemSettings{BlacklistThreshold: 3},
+			statusCode:   500,
+			wantStatus:   models.KeyStatusInvalid,
+			wantFailures: "3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			addTestKeys(t, p, tt.key)
+			apiKey := &models.APIKey{ID: 1, GroupID: testGroupID}
+
+			p.UpdateStatus(apiKey, testGroup(tt.config), tt.success, tt.errorMessage, tt.statusCode, tt.force)
+			details := waitForKeyDetails(t, p, 1, func(details map[string]string) bool {
+				return details["status"] == tt.wantStatus && details["failure_count"] == tt.wantFailures
+			})
+			if details["status"] != tt.wantStatus || details["failure_count"] != tt.wantFailures {
+				t.Fatalf("status = %q, failure_count = %q, want %q, %q",
+					details["status"], details["failure_count"], tt.wantStatus, tt.wantFailures)
+			}
+
+			selected, err := p.SelectKey(testGroupID, SelectConstraints{})
+			if tt.wantSelected != (err == nil && selected.ID == 1) {
+				t.Fatalf("SelectKey() after update = %v, %v, want selected %v", selected, err, tt.wantSelected)
+			}
+		})
+	}
+}
+
+// waitForKeyDetails 等待异步的状态更新写入 store，直到 done 返回 true 或超时，返回最后读到的详情。
+// 期望值与更新前相同时再等待一小段时间，确认更新没有在之后改变状态
+func waitForKeyDetails(t *testing.T, p *KeyProvider, keyID uint, done func(map[string]string) bool) map[string]string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		details := keyDetails(t, p, keyID)
+		if done(details) {
+			time.Sleep(20 * time.Millisecond)
+			return keyDetails(t, p, keyID)
+		}
+		if time.Now().After(deadline) {
+			return details
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheFingerprintSnapshot(t *testing.T) {
+	tests := []struct {
+		name         string
+		change       func(t *testing.T, p *KeyProvider)
+		wantRestored bool
+	}{
+		{name: "database unchanged", wantRestored: true},
+		{
+			name: "key added",
+			change: func(t *testing.T, p *KeyProvider) {
+				must(t, p.db.Create(&models.APIKey{GroupID: testGroupID, KeyValue: "sk-test-2", Status: models.KeyStatusActive}).Error)
+			},
+		},
+		{
+			name: "key updated",
+			change: func(t *testing.T, p *KeyProvider) {
+				must(t, p.db.Model(&models.APIKey{ID: 1}).Updates(map[string]any{
+					"status":     models.KeyStatusInvalid,
+					"updated_at": time.Now().Add(time.Second),
+				}).Error)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			must(t, p.db.AutoMigrate(&models.Group{}))
+			addTestKeys(t, p, models.APIKey{})
+			cfg := testConfigManager{snapshot: types.SnapshotConfig{Interval: 60, Path: filepath.Join(t.TempDir(), "store.snapshot")}}
+
+			// 第一次启动没有快照，停止时保存带指纹的快照
+			sn := store.NewSnapshotter(cfg, p.store)
+			if sn.Restore(p.CacheFingerprint) {
+				t.Fatal("Restore() without a snapshot = true")
+			}
+			sn.Stop(context.Background())
+
+			if tt.change != nil {
+				tt.change(t, p)
+			}
+
+			// 重启：和 app 一样，快照被拒绝时清空缓存并从数据库重新加载
+			p.store = store.NewMemoryStore()
+			sn = store.NewSnapshotter(cfg, p.store)
+			restored := sn.Restore(p.CacheFingerprint)
+			if restored != tt.wantRestored {
+				t.Fatalf("Restore() = %v, want %v", restored, tt.wantRestored)
+			}
+			if !restored {
+				must(t, p.store.Clear())
+				must(t, p.LoadKeysFromDB())
+			}
+
+			var dbKeys []models.APIKey
+			must(t, p.db.Find(&dbKeys).Error)
+			for _, key := range dbKeys {
+				if status := keyDetails(t, p, key.ID)["status"]; status != key.Status {
+					t.Fatalf("key %d status = %q, want %q", key.ID, status, key.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestStructuredCredentialKey(t *testing.T) {
+	const credential = `{"api_key":"sk-1","org_id":"org"}`
+	p := newTestProvider(t)
+	key := models.APIKey{
+		ID:       1,
+		GroupID:  testGroupID,
+		KeyValue: credential,
+		KeyHash:  p.encryptionSvc.Hash(models.CanonicalKeyValue(credential)),
+		Status:   models.KeyStatusActive,
+	}
+	must(t, p.db.Create(&key).Error)
+	must(t, p.addKeyToStore(&key))
+
+	apiKey, err := p.getKeyDetails(testGroupID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret := apiKey.Secret(); secret != "sk-1" {
+		t.Errorf("Secret() = %q, want sk-1", secret)
+	}
+	if org := apiKey.CredentialField("org_id"); org != "org" {
+		t.Errorf("CredentialField(org_id) = %q, want org", org)
+	}
+
+	// 解析结果随 KeyValue 失效，例如 refresh token 轮换后
+	apiKey.KeyValue = `{"api_key":"sk-2"}`
+	if secret := apiKey.Secret(); secret != "sk-2" {
+		t.Errorf("Secret() after KeyValue change = %q, want sk-2", secret)
+	}
+
+	// 字段顺序和空白不同的同一凭证按相同的 hash 去重
+	deleted, err := p.RemoveKeys(testGroupID, []string{"{ \"org_id\": \"org\",\n  \"api_key\": \"sk-1\" }"})
+	if err != nil || deleted != 1 {
+		t.Fatalf("RemoveKeys() = %d, %v, want 1", deleted, err)
+	}
+}
@@ -0,0 +1,130 @@
+package keypool
+
+import (
+	"context"
+	app_errors "key-flow/internal/errors"
+	"key-flow/internal/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// quotaResetSweepInterval 是扫描已到配额重置时间的 key 的间隔
+const quotaResetSweepInterval = time.Minute
+
+// IsQuotaExhausted 检查上游错误是否命中分组的配额耗尽规则，规则格式与立即禁用规则相同
+func IsQuotaExhausted(group *models.Group, statusCode int, errorMsg string) bool {
+	rules := app_errors.ParseInstantDisableRules(group.EffectiveConfig.QuotaExhaustedRules)
+	return app_errors.ShouldInstantDisable(rules, statusCode, errorMsg)
+}
+
+// nextQuotaReset 返回 now 之后分组配置的下一个配额重置时间
+func nextQuotaReset(group *models.Group, now time.Time) time.Time {
+	cfg := group.EffectiveConfig
+
+	loc, err := time.LoadLocation(cfg.QuotaResetTimezone)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"group": group.Name, "timezone": cfg.QuotaResetTimezone}).Warn("Invalid quota reset time zone, using UTC")
+		loc = time.UTC
+	}
+	clock, err := time.Parse("15:04", cfg.QuotaResetTime)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"group": group.Name, "time": cfg.QuotaResetTime}).Warn("Invalid quota reset time, using midnight")
+		clock = time.Time{}
+	}
+
+	local := now.In(loc)
+	reset := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	if !reset.After(now) {
+		reset = time.Date(local.Year(), local.Month(), local.Day()+1, clock.Hour(), clock.Minute(), 0, 0, loc)
+	}
+	return reset
+}
+
+// MarkQuotaExhausted 异步地将 key 移出轮询直到下一个配额重置时间，到期后自动恢复
+func (p *KeyProvider) MarkQuotaExhausted(apiKey *models.APIKey, group *models.Group) {
+	go func() {
+		if err := p.markQuotaExhausted(apiKey, group); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to mark key quota as exhausted")
+		}
+	}()
+}
+
+func (p *KeyProvider) markQuotaExhausted(apiKey *models.APIKey, group *models.Group) error {
+	resetAt := nextQuotaReset(group, time.Now())
+
+	// 只处理仍在轮询中的 key，数据库由写回队列异步写入
+	t := p.keyMove(apiKey.ID, group.ID, models.KeyStatusQuotaExhausted, models.KeyStatusActive, models.KeyStatusCooldown)
+	t.Set = map[string]any{"quota_reset_at": resetAt.Unix()}
+	t.Unset = []string{"cooldown_until"}
+	result, err := p.applyKeyTransition(t)
+	if err != nil || !result.Changed {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"keyID":   apiKey.ID,
+		"groupID": group.ID,
+		"resetAt": resetAt,
+	}).Info("Key quota exhausted, removed from rotation until reset")
+
+	time.AfterFunc(time.Until(resetAt), func() {
+		if _, err := p.restoreQuotaExhaustedKey(apiKey.ID, group.ID); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to restore key after quota reset")
+		}
+	})
+	return nil
+}
+
+// restoreQuotaExhaustedKey 按 store 中的状态在配额重置后将 key 放回活跃列表，不需要重新验证，
+// 数据库由写回队列异步写入。返回 key 是否被恢复
+func (p *KeyProvider) restoreQuotaExhaustedKey(keyID, groupID uint) (bool, error) {
+	t := p.keyMove(keyID, groupID, models.KeyStatusActive, models.KeyStatusQuotaExhausted)
+	t.DueField = "quota_reset_at"
+	t.DueAt = time.Now().Unix()
+	t.Unset = []string{"quota_reset_at"}
+
+	result, err := p.applyKeyTransition(t)
+	return result.Changed, err
+}
+
+// restoreResetQuotas 恢复所有已到配额重置时间的 key。数据库只用于查找候选 key，是否恢复由 store 中的状态决定
+func (p *KeyProvider) restoreResetQuotas() {
+	var keys []models.APIKey
+	if err := p.db.Model(&models.APIKey{}).Select("id", "group_id").
+		Where("status = ? AND (quota_reset_at IS NULL OR quota_reset_at <= ?)", models.KeyStatusQuotaExhausted, time.Now()).
+		Find(&keys).Error; err != nil {
+		logrus.WithError(err).Error("Failed to query keys due for quota reset")
+		return
+	}
+
+	restored := 0
+	for _, key := range keys {
+		ok, err := p.restoreQuotaExhaustedKey(key.ID, key.GroupID)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to restore key after quota reset")
+			continue
+		}
+		if ok {
+			restored++
+		}
+	}
+	if restored > 0 {
+		logrus.WithField("count", restored).Info("Restored keys after quota reset")
+	}
+}
+
+// startQuotaResetSweep 定期恢复配额已重置的 key，兜底其他节点或重启前标记的 key
+func (p *KeyProvider) startQuotaResetSweep(ctx context.Context) {
+	ticker := time.NewTicker(quotaResetSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.restoreResetQuotas()
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package keypool
+
+import (
+	"key-flow/internal/models"
+	"key-flow/internal/types"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNextQuotaReset(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		clock    string
+		timezone string
+		want     time.Time
+	}{
+		{name: "later today", clock: "18:00", timezone: "UTC", want: time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)},
+		{name: "already passed today", clock: "09:00", timezone: "UTC", want: time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)},
+		{name: "exactly now moves to tomorrow", clock: "15:30", timezone: "UTC", want: time.Date(2024, 3, 11, 15, 30, 0, 0, time.UTC)},
+		{name: "group time zone", clock: "00:00", timezone: "Asia/Shanghai", want: time.Date(2024, 3, 10, 16, 0, 0, 0, time.UTC)},
+		{name: "invalid values fall back to UTC midnight", clock: "25:99", timezone: "Nowhere/City", want: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := testGroup(types.SystemSettings{QuotaResetTime: tt.clock, QuotaResetTimezone: tt.timezone})
+			if got := nextQuotaReset(group, now); !got.Equal(tt.want) {
+				t.Fatalf("nextQuotaReset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaExhaustedKey(t *testing.T) {
+	config := types.SystemSettings{
+		BlacklistThreshold:  1,
+		QuotaExhaustedRules: "keyword:daily quota",
+		QuotaResetTime:      "00:00",
+		QuotaResetTimezone:  "UTC",
+	}
+	tests := []struct {
+		name         string
+		key          models.APIKey
+		errorMessage string
+		force        bool
+		wantStatus   string
+	}{
+		{name: "active key", key: models.APIKey{Status: models.KeyStatusActive}, errorMessage: "Daily quota exceeded", wantStatus: models.KeyStatusQuotaExhausted},
+		{name: "cooling key", key: models.APIKey{Status: models.KeyStatusCooldown}, errorMessage: "daily quota exceeded", wantStatus: models.KeyStatusQuotaExhausted},
+		{name: "invalid key stays invalid", key: models.APIKey{Status: models.KeyStatusInvalid}, errorMessage: "daily quota exceeded", wantStatus: models.KeyStatusInvalid},
+		{name: "other errors count as failures", key: models.APIKey{Status: models.KeyStatusActive}, errorMessage: "server error", wantStatus: models.KeyStatusInvalid},
+		{name: "manual test ignores quota rules", key: models.APIKey{Status: models.KeyStatusActive}, errorMessage: "daily quota exceeded", force: true, wantStatus: models.KeyStatusInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			addTestKeys(t, p, tt.key, models.APIKey{})
+			if tt.key.Status == models.KeyStatusCooldown {
+				must(t, p.store.HSet("key:1", map[string]any{"cooldown_until": time.Now().Add(time.Hour).Unix()}))
+			}
+			group := testGroup(config)
+
+			p.UpdateStatus(&models.APIKey{ID: 1, GroupID: testGroupID}, group, false, tt.errorMessage, 429, tt.force)
+			details := waitForKeyDetails(t, p, 1, func(details map[string]string) bool {
+				return details["status"] == tt.wantStatus
+			})
+			if details["status"] != tt.wantStatus {
+				t.Fatalf("status = %q, want %q", details["status"], tt.wantStatus)
+			}
+			if tt.wantStatus != models.KeyStatusQuotaExhausted {
+				return
+			}
+
+			// 配额耗尽不计入失败次数，冷却被配额重置取代
+			if details["failure_count"] != "0" || details["cooldown_until"] != "" {
+				t.Fatalf("failure_count = %q, cooldown_until = %q, want 0 and unset", details["failure_count"], details["cooldown_until"])
+			}
+			resetAt, _ := strconv.ParseInt(details["quota_reset_at"], 10, 64)
+			if want := nextQuotaReset(group, time.Now()).Unix(); resetAt != want {
+				t.Fatalf("quota_reset_at = %d, want %d", resetAt, want)
+			}
+			for _, strategy := range selectStrategies {
+				apiKey, err := p.SelectKey(testGroupID, SelectConstraints{Strategy: strategy})
+				if err != nil || apiKey.ID != 2 {
+					t.Fatalf("SelectKey(%q) while exhausted = %v, %v, want key 2", strategy, apiKey, err)
+				}
+			}
+
+			// 重置时间之前不恢复，之后恢复为 active
+			if restored, err := p.restoreQuotaExhaustedKey(1, testGroupID); err != nil || restored {
+				t.Fatalf("restore before reset = %v, %v, want false", restored, err)
+			}
+			must(t, p.store.HSet("key:1", map[string]any{"quota_reset_at": time.Now().Add(-time.Second).Unix()}))
+			if restored, err := p.restoreQuotaExhaustedKey(1, testGroupID); err != nil || !restored {
+				t.Fatalf("restore after reset = %v, %v, want true", restored, err)
+			}
+			details = keyDetails(t, p, 1)
+			if details["status"] != models.KeyStatusActive || details["quota_reset_at"] != "" {
+				t.Fatalf("after reset status = %q, quota_reset_at = %q, want active and unset", details["status"], details["quota_reset_at"])
+			}
+		})
+	}
+}
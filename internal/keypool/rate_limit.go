@@ -0,0 +1,208 @@
+package keypool
+
+import (
+	"fmt"
+	"key-flow/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// rateLimitWindow 是 RPM/TPM 滑动窗口的长度
+const rateLimitWindow = time.Minute
+
+// RateLimits 描述单个 key 的每分钟请求数、token 数和并发请求数上限，0 表示不限制
+type RateLimits struct {
+	RPM         int
+	TPM         int
+	Concurrency int
+}
+
+// RateLimitedError is returned when every active key of a group is at its rate limit.
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("all keys are rate limited, retry after %s", e.RetryAfter)
+}
+
+// GroupRateLimits 返回分组为每个 key 配置的默认限制
+func GroupRateLimits(group *models.Group) RateLimits {
+	return RateLimits{
+		RPM:         group.EffectiveConfig.KeyRPMLimit,
+		TPM:         group.EffectiveConfig.KeyTPMLimit,
+		Concurrency: group.EffectiveConfig.KeyMaxConcurrency,
+	}
+}
+
+// ForKey 返回 key 的有效限制，key 单独设置的限制优先于分组默认值
+func (l RateLimits) ForKey(apiKey *models.APIKey) RateLimits {
+	return l.withOverrides(apiKey.RPMLimit, apiKey.TPMLimit, apiKey.MaxConcurrency)
+}
+
+// forDetails 与 ForKey 相同，但读取 store 中的 key 详情
+func (l RateLimits) forDetails(details map[string]string) RateLimits {
+	rpm, _ := strconv.Atoi(details["rpm_limit"])
+	tpm, _ := strconv.Atoi(details["tpm_limit"])
+	concurrency, _ := strconv.Atoi(details["max_concurrency"])
+	return l.withOverrides(rpm, tpm, concurrency)
+}
+
+func (l RateLimits) withOverrides(rpm, tpm, concurrency int) RateLimits {
+	if rpm > 0 {
+		l.RPM = rpm
+	}
+	if tpm > 0 {
+		l.TPM = tpm
+	}
+	if concurrency > 0 {
+		l.Concurrency = concurrency
+	}
+	return l
+}
+
+// IsZero 表示没有 RPM/TPM 限制，并发限制由槽位单独控制
+func (l RateLimits) IsZero() bool {
+	return l.RPM <= 0 && l.TPM <= 0
+}
+
+func rpmWindowKey(keyID uint) string {
+	return fmt.Sprintf("ratelimit:key:%d:rpm", keyID)
+}
+
+func tpmWindowKey(keyID uint) string {
+	return fmt.Sprintf("ratelimit:key:%d:tpm", keyID)
+}
+
+// rateLimitWait 返回 key 需要等待多久才能再次使用，0 表示当前可用
+func (p *KeyProvider) rateLimitWait(keyID uint, limits RateLimits) time.Duration {
+	if limits.IsZero() {
+		return 0
+	}
+
+	var wait time.Duration
+	check := func(windowKey string, limit int) {
+		if limit <= 0 {
+			return
+		}
+		used, oldest, err := p.store.WindowUsage(windowKey, rateLimitWindow)
+		if err != nil {
+			// 计数读取失败时放行，避免 store 故障导致所有请求被拒绝
+			logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to read rate limit window")
+			return
+		}
+		if used < int64(limit) {
+			return
+		}
+		wait = max(wait, windowWait(oldest))
+	}
+
+	check(rpmWindowKey(keyID), limits.RPM)
+	check(tpmWindowKey(keyID), limits.TPM)
+	return wait
+}
+
+// windowWait 返回窗口中最早的请求移出窗口还需等待的时间，至少一秒
+func windowWait(oldest time.Time) time.Duration {
+	return max(time.Until(oldest.Add(rateLimitWindow)), time.Second)
+}
+
+// rateLimitLease 是 acquireRateLimit 对 key 的占用：并发槽位的 token 和这次请求计入的 RPM 窗口桶，
+// 未占用的部分为零值
+type rateLimitLease struct {
+	concurrencyToken string
+	rpmBucket        time.Time
+}
+
+// attach 将占用记录到选中的 key 上，请求结束时由 TrackKeyRequest 释放并发槽位
+func (l rateLimitLease) attach(apiKey *models.APIKey) {
+	apiKey.ConcurrencyToken = l.concurrencyToken
+	apiKey.RPMBucket = l.rpmBucket
+}
+
+// keyRateLimitLease 返回选 key 时记录在 key 上的占用
+func keyRateLimitLease(apiKey *models.APIKey) rateLimitLease {
+	return rateLimitLease{concurrencyToken: apiKey.ConcurrencyToken, rpmBucket: apiKey.RPMBucket}
+}
+
+// acquireRateLimit 检查 key 是否未达到限制，可用时占用一个并发槽位并记录一次请求。
+// 并发槽位和 RPM 的检查和计数都在 store 中原子完成，多个节点同时选中同一个 key 时不会超出限制；
+// TPM 在请求结束后才知道用量，只检查不计数。
+// wait 大于 0 表示 key 当前不可用。
+func (p *KeyProvider) acquireRateLimit(keyID uint, limits RateLimits) (rateLimitLease, time.Duration) {
+	if wait := p.rateLimitWait(keyID, RateLimits{TPM: limits.TPM}); wait > 0 {
+		return rateLimitLease{}, wait
+	}
+	token, ok := p.acquireConcurrencySlot(keyID, limits.Concurrency)
+	if !ok {
+		return rateLimitLease{}, concurrencyRetryAfter
+	}
+	lease := rateLimitLease{concurrencyToken: token}
+	if limits.RPM > 0 {
+		added, bucket, err := p.store.WindowTryAdd(rpmWindowKey(keyID), 1, int64(limits.RPM), rateLimitWindow)
+		switch {
+		case err != nil:
+			// 计数失败时放行，避免 store 故障导致所有请求被拒绝
+			logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to record request in rate limit window")
+		case !added:
+			p.releaseConcurrencySlot(keyID, token)
+			return rateLimitLease{}, windowWait(bucket)
+		default:
+			lease.rpmBucket = bucket
+		}
+	}
+	return lease, 0
+}
+
+// releaseRateLimit 撤销 acquireRateLimit 对未使用的 key 的占用：释放并发槽位，并从记录这次请求的
+// RPM 窗口桶中减去它。撤销发生在之后的某一秒时也只影响原来的桶，不会抵消其他请求的计数。
+func (p *KeyProvider) releaseRateLimit(keyID uint, lease rateLimitLease) {
+	p.releaseConcurrencySlot(keyID, lease.concurrencyToken)
+	if lease.rpmBucket.IsZero() {
+		return
+	}
+	if err := p.store.WindowRemove(rpmWindowKey(keyID), lease.rpmBucket, 1); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to remove request from rate limit window")
+	}
+}
+
+// RecordTokenUsage 将一次请求消耗的 token 计入 key 的 TPM 窗口
+func (p *KeyProvider) RecordTokenUsage(apiKey *models.APIKey, limits RateLimits, tokens int64) {
+	if tokens <= 0 || limits.ForKey(apiKey).TPM <= 0 {
+		return
+	}
+	if err := p.store.WindowAdd(tpmWindowKey(apiKey.ID), tokens, rateLimitWindow); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Warn("Failed to record token usage")
+	}
+}
+
+// UpdateKeyRateLimits 更新单个 key 的 RPM/TPM/并发限制，0 表示使用分组默认值
+func (p *KeyProvider) UpdateKeyRateLimits(keyID uint, rpmLimit, tpmLimit, maxConcurrency int) error {
+	if rpmLimit < 0 || tpmLimit < 0 || maxConcurrency < 0 {
+		return fmt.Errorf("rate limits cannot be negative")
+	}
+
+	return p.executeTransactionWithRetry(func(tx *gorm.DB) error {
+		var key models.APIKey
+		if err := tx.First(&key, keyID).Error; err != nil {
+			return fmt.Errorf("failed to find key %d: %w", keyID, err)
+		}
+
+		updates := map[string]any{
+			"rpm_limit":       rpmLimit,
+			"tpm_limit":       tpmLimit,
+			"max_concurrency": maxConcurrency,
+		}
+		if err := tx.Model(&key).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update key rate limits in DB: %w", err)
+		}
+
+		if err := p.store.HSet(fmt.Sprintf("key:%d", keyID), updates); err != nil {
+			return fmt.Errorf("failed to update key rate limits in store: %w", err)
+		}
+		return nil
+	})
+}
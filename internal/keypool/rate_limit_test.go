@@ -0,0 +1,65 @@
+package keypool
+
+import (
+	"key-flow/internal/models"
+	"testing"
+)
+
+func TestSelectKeyRateLimits(t *testing.T) {
+	runSelectKeyTests(t, []selectKeyTest{
+		{
+			name: "rpm limit reached",
+			keys: []models.APIKey{{RPMLimit: 1}, {}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				must(t, p.store.WindowAdd(rpmWindowKey(1), 1, rateLimitWindow))
+			},
+			wantKey:    2,
+			wantStatus: map[uint]string{1: models.KeyStatusActive, 2: models.KeyStatusActive},
+		},
+		{
+			name: "tpm limit reached",
+			keys: []models.APIKey{{TPMLimit: 100}, {}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				must(t, p.store.WindowAdd(tpmWindowKey(1), 100, rateLimitWindow))
+			},
+			wantKey: 2,
+		},
+		{
+			name: "key limit overrides group default",
+			keys: []models.APIKey{{}, {RPMLimit: 5}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				must(t, p.store.WindowAdd(rpmWindowKey(1), 1, rateLimitWindow))
+				must(t, p.store.WindowAdd(rpmWindowKey(2), 1, rateLimitWindow))
+			},
+			constraints: SelectConstraints{Limits: RateLimits{RPM: 1}},
+			wantKey:     2,
+		},
+		{
+			name: "all keys rate limited",
+			keys: []models.APIKey{{RPMLimit: 1}, {TPMLimit: 10}},
+			setup: func(t *testing.T, p *KeyProvider) {
+				must(t, p.store.WindowAdd(rpmWindowKey(1), 1, rateLimitWindow))
+				must(t, p.store.WindowAdd(tpmWindowKey(2), 10, rateLimitWindow))
+			},
+			wantStatus: map[uint]string{1: models.KeyStatusActive, 2: models.KeyStatusActive},
+		},
+		{
+			name:    "pick is counted against rpm",
+			keys:    []models.APIKey{{RPMLimit: 2}},
+			wantKey: 1,
+			check: func(t *testing.T, p *KeyProvider, apiKey *models.APIKey) {
+				if used, _, _ := p.store.WindowUsage(rpmWindowKey(1), rateLimitWindow); used != 1 {
+					t.Errorf("rpm usage = %d, want 1", used)
+				}
+				if apiKey.RPMBucket.IsZero() {
+					t.Error("RPMBucket should record the counted bucket")
+				}
+				// 撤销选择只减去这次计数
+				p.releaseRateLimit(apiKey.ID, keyRateLimitLease(apiKey))
+				if used, _, _ := p.store.WindowUsage(rpmWindowKey(1), rateLimitWindow); used != 0 {
+					t.Errorf("rpm usage after release = %d, want 0", used)
+				}
+			},
+		},
+	})
+}
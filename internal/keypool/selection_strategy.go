@@ -0,0 +1,209 @@
+package keypool
+
+import (
+	"fmt"
+	"key-flow/internal/models"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// 分组的选 key 策略
+const (
+	StrategyRoundRobin        = "round_robin"
+	StrategyLeastInFlight     = "least_in_flight"
+	StrategyLeastRecentlyUsed = "least_recently_used"
+	StrategyPowerOfTwo        = "power_of_two"
+)
+
+// keyCandidate 是一次选 key 中满足约束的候选 key
+type keyCandidate struct {
+	id     uint64
+	weight int
+	limits RateLimits
+}
+
+// inFlightKey 记录 key 正在处理的请求，存放在 store 中供所有节点共享。
+// 每个请求是信号量的一个持有者，请求进行中定期续约，节点崩溃后未释放的持有者在租约到期后不再计数。
+// 每个 key 一个信号量，选 key 时只需读取候选 key 的计数。
+func inFlightKey(groupID, keyID uint) string {
+	return fmt.Sprintf("group:%d:in_flight:%d", groupID, keyID)
+}
+
+// lastUsedKey 记录分组内每个 key 最近一次被选中的时间（毫秒时间戳），key 离开 active 列表或被删除时移除
+func lastUsedKey(groupID uint) string {
+	return fmt.Sprintf("group:%d:last_used", groupID)
+}
+
+// usesInFlight 判断策略是否需要统计进行中的请求数
+func usesInFlight(strategy string) bool {
+	return strategy == StrategyLeastInFlight || strategy == StrategyPowerOfTwo
+}
+
+// TrackKeyRequest 记录 key 开始处理一个请求并续约选 key 时占用的并发槽位，
+// 返回的函数在请求结束时调用，重复调用只生效一次。
+// 只有分组策略需要时才写入统计，默认轮询策略没有额外开销。
+func (p *KeyProvider) TrackKeyRequest(group *models.Group, apiKey *models.APIKey) func() {
+	strategy := group.EffectiveConfig.KeySelectionStrategy
+
+	releaseSlot := p.holdConcurrencySlot(apiKey)
+	releaseInFlight := func() {}
+	if usesInFlight(strategy) {
+		releaseInFlight = p.holdInFlight(group.ID, apiKey.ID)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			releaseSlot()
+			releaseInFlight()
+		})
+	}
+}
+
+// holdInFlight 记录 key 开始处理一个请求并在请求进行中续约，返回的函数停止续约并删除记录
+func (p *KeyProvider) holdInFlight(groupID, keyID uint) func() {
+	semaphoreKey := inFlightKey(groupID, keyID)
+	token := uuid.NewString()
+	// 进行中的请求数不设上限，信号量只用于按租约计数
+	record := func() error {
+		_, err := p.store.SemaphoreAcquire(semaphoreKey, token, math.MaxInt64, concurrencyLeaseTTL)
+		return err
+	}
+	if err := record(); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to record key in-flight request")
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(concurrencyLeaseRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				renewed, err := p.store.SemaphoreRenew(semaphoreKey, token, concurrencyLeaseTTL)
+				if err == nil && !renewed {
+					// 续约前租约已过期，重新记录，请求仍在进行中
+					err = record()
+				}
+				if err != nil {
+					logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to renew key in-flight request")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		// 等待续约结束后再删除，避免续约把已删除的记录重新写回
+		close(done)
+		<-stopped
+		if err := p.store.SemaphoreRelease(semaphoreKey, token); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to remove key in-flight request")
+		}
+	}
+}
+
+// inFlightCounts 读取候选 key 未过期的进行中请求数，每个候选 key 读取一次，与分组内 key 的总数无关。
+// 读取失败的 key 视为 0
+func (p *KeyProvider) inFlightCounts(groupID uint, keys ...keyCandidate) map[uint64]int64 {
+	counts := make(map[uint64]int64, len(keys))
+	for _, k := range keys {
+		count, err := p.store.SemaphoreCount(inFlightKey(groupID, uint(k.id)))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": k.id, "error": err}).Warn("Failed to load key in-flight requests")
+			continue
+		}
+		counts[k.id] = count
+	}
+	return counts
+}
+
+// pickKey 按策略从候选 key 中选择一个，轮询策略下使用加权随机
+func (p *KeyProvider) pickKey(groupID uint, strategy string, keys []keyCandidate, totalWeight int) keyCandidate {
+	switch strategy {
+	case StrategyLeastInFlight:
+		inFlight := p.inFlightCounts(groupID, keys...)
+		// 按权重比较进行中的请求数，相同时保留轮转顺序中靠前的 key
+		selected := keys[0]
+		for _, k := range keys[1:] {
+			if inFlight[k.id]*int64(selected.weight) < inFlight[selected.id]*int64(k.weight) {
+				selected = k
+			}
+		}
+		return selected
+	case StrategyLeastRecentlyUsed:
+		return p.pickLeastRecentlyUsed(groupID, keys)
+	case StrategyPowerOfTwo:
+		a := pickWeighted(keys, totalWeight)
+		b := pickWeighted(keys, totalWeight)
+		inFlight := p.inFlightCounts(groupID, a, b)
+		if inFlight[b.id]*int64(a.weight) < inFlight[a.id]*int64(b.weight) {
+			return b
+		}
+		return a
+	default:
+		return pickWeighted(keys, totalWeight)
+	}
+}
+
+// pickLeastRecentlyUsed 选择最久未被选中的 key 并在同一临界区内记录选中时间，
+// 并发的选 key 能看到刚被选中的 key，不会都选中同一个最旧的 key
+func (p *KeyProvider) pickLeastRecentlyUsed(groupID uint, keys []keyCandidate) keyCandidate {
+	p.lastUsedMu.Lock()
+	defer p.lastUsedMu.Unlock()
+
+	lastUsed := p.selectionStats(lastUsedKey(groupID))
+	selected := keys[0]
+	for _, k := range keys[1:] {
+		if lastUsed[k.id] < lastUsed[selected.id] {
+			selected = k
+		}
+	}
+
+	field := strconv.FormatUint(selected.id, 10)
+	if err := p.store.HSet(lastUsedKey(groupID), map[string]any{field: time.Now().UnixMilli()}); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": selected.id, "error": err}).Warn("Failed to record key last used time")
+	}
+	return selected
+}
+
+// selectionStats 读取策略使用的每个 key 的统计值，读取失败时视为全部为 0
+func (p *KeyProvider) selectionStats(hashKey string) map[uint64]int64 {
+	values, err := p.store.HGetAll(hashKey)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"key": hashKey, "error": err}).Warn("Failed to load key selection stats")
+		return nil
+	}
+	stats := make(map[uint64]int64, len(values))
+	for field, value := range values {
+		id, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		stats[id], _ = strconv.ParseInt(value, 10, 64)
+	}
+	return stats
+}
+
+// pickWeighted 加权随机选择一个候选 key
+func pickWeighted(keys []keyCandidate, totalWeight int) keyCandidate {
+	r := rand.Intn(totalWeight)
+	cumulative := 0
+	for _, k := range keys {
+		cumulative += k.weight
+		if r < cumulative {
+			return k
+		}
+	}
+	return keys[0]
+}
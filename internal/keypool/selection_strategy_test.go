@@ -0,0 +1,96 @@
+package keypool
+
+import (
+	"key-flow/internal/models"
+	"sync"
+	"testing"
+)
+
+func TestSelectKeyByInFlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []models.APIKey
+		inFlight map[uint]int
+		wantKey  uint
+	}{
+		{name: "fewest in-flight requests", keys: []models.APIKey{{}, {}}, inFlight: map[uint]int{1: 2, 2: 1}, wantKey: 2},
+		{name: "relative to weight", keys: []models.APIKey{{Weight: 900}, {Weight: 100}}, inFlight: map[uint]int{1: 3, 2: 1}, wantKey: 1},
+		{name: "idle key", keys: []models.APIKey{{}, {}}, inFlight: map[uint]int{2: 1}, wantKey: 1},
+	}
+
+	// power_of_two 随机抽取候选 key，结果不确定，这里只测试 least_in_flight
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			addTestKeys(t, p, tt.keys...)
+			for keyID, n := range tt.inFlight {
+				for range n {
+					defer p.holdInFlight(testGroupID, keyID)()
+				}
+			}
+
+			apiKey, err := p.SelectKey(testGroupID, SelectConstraints{Strategy: StrategyLeastInFlight})
+			if err != nil || apiKey.ID != tt.wantKey {
+				t.Fatalf("SelectKey() = %v, %v, want key %d", apiKey, err, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestSelectKeyByLastUsed(t *testing.T) {
+	p := newTestProvider(t)
+	addTestKeys(t, p, models.APIKey{}, models.APIKey{}, models.APIKey{})
+
+	// 选中时即记录使用时间，并发选 key 不会选中同一个最旧的 key
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		selected = make(map[uint]int)
+	)
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			apiKey, err := p.SelectKey(testGroupID, SelectConstraints{Strategy: StrategyLeastRecentlyUsed})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			selected[apiKey.ID]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(selected) != 3 {
+		t.Fatalf("concurrent selections = %v, want each key once", selected)
+	}
+
+	// 离开 active 列表或被删除的 key 不再保留使用时间
+	must(t, p.DisableKeyInStore(2, testGroupID))
+	must(t, p.removeKeyFromStore(3, testGroupID))
+	lastUsed, err := p.store.HGetAll(lastUsedKey(testGroupID))
+	must(t, err)
+	if _, ok := lastUsed["1"]; !ok || len(lastUsed) != 1 {
+		t.Fatalf("last used times = %v, want only key 1", lastUsed)
+	}
+}
+
+func TestHoldInFlight(t *testing.T) {
+	p := newTestProvider(t)
+	candidates := []keyCandidate{{id: 1}, {id: 2}}
+
+	releaseA := p.holdInFlight(testGroupID, 1)
+	releaseB := p.holdInFlight(testGroupID, 1)
+	if counts := p.inFlightCounts(testGroupID, candidates...); counts[1] != 2 || counts[2] != 0 {
+		t.Fatalf("inFlightCounts() = %v, want key 1: 2", counts)
+	}
+	releaseA()
+	if counts := p.inFlightCounts(testGroupID, candidates...); counts[1] != 1 {
+		t.Fatalf("inFlightCounts() after release = %v, want key 1: 1", counts)
+	}
+	releaseB()
+	if counts := p.inFlightCounts(testGroupID, candidates...); counts[1] != 0 {
+		t.Fatalf("inFlightCounts() after all releases = %v, want key 1: 0", counts)
+	}
+}
@@ -18,10 +18,11 @@ import (
 
 // KeyTestResult holds the validation result for a single key.
 type KeyTestResult struct {
-	KeyValue   string `json:"key_value"`
-	IsValid    bool   `json:"is_valid"`
-	Error      string `json:"error,omitempty"`
-	StatusCode int    `json:"status_code"`
+	KeyValue        string `json:"key_value"`
+	IsValid         bool   `json:"is_valid"`
+	Error           string `json:"error,omitempty"`
+	StatusCode      int    `json:"status_code"`
+	FailedAssertion string `json:"failed_assertion,omitempty"`
 }
 
 // KeyValidator provides methods to validate API keys.
@@ -67,7 +68,12 @@ func (s *KeyValidator) ValidateSingleKey(key *models.APIKey, group *models.Group
 		return false, 0, fmt.Errorf("failed to get channel for group %s: %w", group.Name, err)
 	}
 
-	isValid, validationErr := ch.ValidateKey(ctx, key, group)
+	// OAuth 凭证先刷新访问令牌，刷新失败按验证失败处理
+	var isValid bool
+	validationErr := s.keypoolProvider.ResolveAccessToken(ctx, key)
+	if validationErr == nil {
+		isValid, validationErr = ch.ValidateKey(ctx, key, group)
+	}
 
 	var errorMsg string
 	var statusCode int
@@ -104,7 +110,7 @@ func (s *KeyValidator) TestMultipleKeys(group *models.Group, keyValues []string)
 	// Generate hashes for all key values
 	var keyHashes []string
 	for _, keyValue := range keyValues {
-		keyHash := s.encryptionSvc.Hash(keyValue)
+		keyHash := s.encryptionSvc.Hash(models.CanonicalKeyValue(keyValue))
 		if keyHash == "" {
 			continue
 		}
@@ -126,7 +132,7 @@ func (s *KeyValidator) TestMultipleKeys(group *models.Group, keyValues []string)
 	}
 
 	for i, kv := range keyValues {
-		keyHash := s.encryptionSvc.Hash(kv)
+		keyHash := s.encryptionSvc.Hash(models.CanonicalKeyValue(kv))
 		apiKey, exists := existingKeyMap[keyHash]
 		if !exists {
 			results[i] = KeyTestResult{
@@ -149,6 +155,10 @@ func (s *KeyValidator) TestMultipleKeys(group *models.Group, keyValues []string)
 		}
 		if validationErr != nil {
 			results[i].Error = validationErr.Error()
+			var ve *app_errors.ValidationError
+			if errors.As(validationErr, &ve) {
+				results[i].FailedAssertion = ve.Assertion
+			}
 		}
 	}
 
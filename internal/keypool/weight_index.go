@@ -0,0 +1,140 @@
+package keypool
+
+import (
+	"errors"
+	"fmt"
+	"key-flow/internal/models"
+	"key-flow/internal/store"
+	"slices"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// weightIndexMaxDraws 是一次选 key 最多从权重索引抽取的次数，抽到的 key 都不满足约束时回退到完整遍历
+const weightIndexMaxDraws = 8
+
+var (
+	// errWeightIndexMiss 表示权重索引无法给出可用的 key，需要回退到完整遍历
+	errWeightIndexMiss = errors.New("weight index draw missed")
+	// errWeightIndexEmpty 表示分组的权重索引不存在（如从旧版本升级），完整遍历时顺便重建
+	errWeightIndexEmpty = fmt.Errorf("weight index is empty: %w", errWeightIndexMiss)
+)
+
+// weightIndexKey 是分组内 active key 的权重索引，与 active_keys 列表保持同步，
+// 加权随机选 key 时按权重直接抽取，不需要轮转整个列表
+func weightIndexKey(groupID uint) string {
+	return fmt.Sprintf("group:%d:key_weights", groupID)
+}
+
+// keyWeight 从 key 详情中读取当前权重，未设置时使用默认权重
+func keyWeight(details map[string]string) int {
+	w, _ := strconv.Atoi(details["weight"])
+	if w <= 0 {
+		w = 500
+	}
+	return w
+}
+
+// indexKey 将 active key 加入分组的权重索引，已存在时更新权重
+func (p *KeyProvider) indexKey(groupID, keyID uint, weight int) error {
+	if weight <= 0 {
+		weight = 500
+	}
+	return p.store.WSet(weightIndexKey(groupID), strconv.FormatUint(uint64(keyID), 10), int64(weight))
+}
+
+// indexKeyFromStore 按 store 中 key 详情的权重将 key 加入权重索引，用于 key 恢复为 active 时
+func (p *KeyProvider) indexKeyFromStore(groupID, keyID uint) error {
+	details, err := p.store.HGetAll(fmt.Sprintf("key:%d", keyID))
+	if err != nil {
+		return fmt.Errorf("failed to get key details for weight index: %w", err)
+	}
+	return p.indexKey(groupID, keyID, keyWeight(details))
+}
+
+// unindexKey 将 key 从分组的权重索引中移除
+func (p *KeyProvider) unindexKey(groupID, keyID uint) error {
+	return p.store.WSet(weightIndexKey(groupID), strconv.FormatUint(uint64(keyID), 10), 0)
+}
+
+// updateIndexedWeight 同步索引中 key 的权重，不在索引中（非 active）的 key 保持不变
+func (p *KeyProvider) updateIndexedWeight(groupID, keyID uint, weight int) {
+	if err := p.store.WUpdate(weightIndexKey(groupID), strconv.FormatUint(uint64(keyID), 10), int64(weight)); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "groupID": groupID, "error": err}).Warn("Failed to update key weight in weight index")
+	}
+}
+
+// selectKeyByWeightIndex 从权重索引中按权重抽取 key，每次抽取只读取被抽中 key 的详情。
+// power_of_two 策略抽取两个不同的 key 并选择进行中请求较少的一个。
+// 索引为空或抽取的 key 都不可用时返回 errWeightIndexMiss，由调用方回退到完整遍历。
+// 抽取时已失效的 key 会从索引中移除，不会反复被抽中。
+func (p *KeyProvider) selectKeyByWeightIndex(groupID uint, constraints SelectConstraints) (*models.APIKey, error) {
+	need := 1
+	if constraints.Strategy == StrategyPowerOfTwo {
+		need = 2
+	}
+
+	indexKey := weightIndexKey(groupID)
+	modelDenied := p.modelDeniedFilter(groupID, constraints.Model)
+	candidates := make([]keyCandidate, 0, need)
+
+	for draw := 0; draw < weightIndexMaxDraws && len(candidates) < need; draw++ {
+		member, err := p.store.WRandom(indexKey)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return nil, errWeightIndexEmpty
+			}
+			logrus.WithFields(logrus.Fields{"groupID": groupID, "error": err}).Warn("Failed to draw key from weight index")
+			return nil, errWeightIndexMiss
+		}
+
+		keyID, err := strconv.ParseUint(member, 10, 64)
+		if err != nil || slices.ContainsFunc(candidates, func(k keyCandidate) bool { return k.id == keyID }) {
+			continue
+		}
+
+		details, err := p.store.HGetAll(fmt.Sprintf("key:%d", keyID))
+		if err != nil {
+			continue
+		}
+		if details["status"] != models.KeyStatusActive {
+			// key 已被删除或停用但索引未同步，顺便移除
+			if err := p.unindexKey(groupID, uint(keyID)); err != nil {
+				logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to remove inactive key from weight index")
+			}
+			continue
+		}
+		if !constraints.allowsTags(details) || modelDenied(uint(keyID)) {
+			continue
+		}
+		keyLimits := constraints.Limits.forDetails(details)
+		if p.rateLimitWait(uint(keyID), keyLimits) > 0 {
+			continue
+		}
+		candidates = append(candidates, keyCandidate{id: keyID, weight: keyWeight(details), limits: keyLimits})
+	}
+
+	if len(candidates) == 2 {
+		a, b := candidates[0], candidates[1]
+		inFlight := p.inFlightCounts(groupID, a, b)
+		if inFlight[b.id]*int64(a.weight) < inFlight[a.id]*int64(b.weight) {
+			candidates[0], candidates[1] = b, a
+		}
+	}
+
+	for _, selected := range candidates {
+		lease, wait := p.acquireRateLimit(uint(selected.id), selected.limits)
+		if wait > 0 {
+			continue
+		}
+		apiKey, err := p.getKeyDetails(groupID, selected.id)
+		if err != nil {
+			p.releaseRateLimit(uint(selected.id), lease)
+			return nil, err
+		}
+		lease.attach(apiKey)
+		return apiKey, nil
+	}
+	return nil, errWeightIndexMiss
+}
@@ -0,0 +1,99 @@
+package keypool
+
+import (
+	"fmt"
+	"key-flow/internal/encryption"
+	"key-flow/internal/models"
+	"key-flow/internal/store"
+	"os"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// benchmarkStores 返回参与基准测试的 store。设置 TEST_REDIS_DSN 时加入 Redis，
+// 测试会清空其中的数据，不要指向生产实例。
+func benchmarkStores(b *testing.B) map[string]store.Store {
+	stores := map[string]store.Store{"memory": store.NewMemoryStore()}
+	dsn := os.Getenv("TEST_REDIS_DSN")
+	if dsn == "" {
+		return stores
+	}
+	opts, err := redis.ParseURL(dsn)
+	if err != nil {
+		b.Fatalf("invalid TEST_REDIS_DSN: %v", err)
+	}
+	client := redis.NewClient(opts)
+	b.Cleanup(func() { client.Close() })
+	stores["redis"] = store.NewRedisStore(client)
+	return stores
+}
+
+// loadBenchmarkKeys 写入 n 个 active key 的详情、active 列表和权重索引
+func loadBenchmarkKeys(b *testing.B, p *KeyProvider, groupID uint, n int) {
+	if err := p.store.Clear(); err != nil {
+		b.Fatal(err)
+	}
+	listKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	for i := 1; i <= n; i++ {
+		keyID := uint(i)
+		weight := 100 + i%900
+		if err := p.store.HSet(fmt.Sprintf("key:%d", keyID), map[string]any{
+			"id":            keyID,
+			"key_string":    fmt.Sprintf("sk-bench-%d", keyID),
+			"status":        models.KeyStatusActive,
+			"failure_count": 0,
+			"group_id":      groupID,
+			"weight":        weight,
+			"base_weight":   weight,
+		}); err != nil {
+			b.Fatal(err)
+		}
+		if err := p.store.LPush(listKey, keyID); err != nil {
+			b.Fatal(err)
+		}
+		if err := p.indexKey(groupID, keyID, weight); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSelectKey 比较权重索引的 O(log n) 抽取与此前按 active 列表遍历计算权重、简单轮询的选 key 开销
+func BenchmarkSelectKey(b *testing.B) {
+	const groupID = 1
+	listKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	selectors := []struct {
+		name     string
+		selectFn func(p *KeyProvider) (*models.APIKey, error)
+	}{
+		{"weight_index", func(p *KeyProvider) (*models.APIKey, error) {
+			return p.selectKeyByWeightIndex(groupID, SelectConstraints{})
+		}},
+		{"scan", func(p *KeyProvider) (*models.APIKey, error) {
+			return p.selectKeyByScan(groupID, SelectConstraints{}, false)
+		}},
+		{"rotate", func(p *KeyProvider) (*models.APIKey, error) {
+			return p.selectKeyByRotate(groupID, listKey, SelectConstraints{})
+		}},
+	}
+
+	encryptionSvc, err := encryption.NewService("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for storeName, s := range benchmarkStores(b) {
+		p := &KeyProvider{store: s, encryptionSvc: encryptionSvc}
+		for _, n := range []int{10, 100, 1000} {
+			loadBenchmarkKeys(b, p, groupID, n)
+			for _, sel := range selectors {
+				b.Run(fmt.Sprintf("%s/%s/keys=%d", storeName, sel.name, n), func(b *testing.B) {
+					for range b.N {
+						if _, err := sel.selectFn(p); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		}
+	}
+}
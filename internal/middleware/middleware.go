@@ -162,6 +162,8 @@ func ProxyAuth(gm *services.GroupManager) gin.HandlerFunc {
 		_, existsInGroup := group.ProxyKeysMap[key]
 
 		if existsInEffective || existsInGroup {
+			// 供按代理密钥选择标签使用
+			c.Set("proxyKey", key)
 			c.Next()
 			return
 		}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// CredentialSecretField is the field of a structured credential that channels send as the API key.
+const CredentialSecretField = "api_key"
+
+// Fields of an OAuth credential. The access token is refreshed from the token URL with the refresh token.
+const (
+	OAuthRefreshTokenField = "refresh_token"
+	OAuthTokenURLField     = "token_url"
+	OAuthClientIDField     = "client_id"
+	OAuthClientSecretField = "client_secret"
+	OAuthScopeField        = "scope"
+)
+
+// ParseCredentialFields parses a structured credential, a JSON object of named fields stored as the
+// key value. It returns nil for a plain key. Non-string values are kept in their JSON form.
+func ParseCredentialFields(keyValue string) map[string]string {
+	keyValue = strings.TrimSpace(keyValue)
+	if !strings.HasPrefix(keyValue, "{") {
+		return nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(keyValue), &raw); err != nil {
+		return nil
+	}
+
+	fields := make(map[string]string, len(raw))
+	for name, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			fields[name] = s
+		} else {
+			fields[name] = string(value)
+		}
+	}
+	return fields
+}
+
+// CanonicalKeyValue returns the form of a key value that its key_hash is computed from. Structured
+// credentials are re-encoded with sorted fields and no insignificant whitespace, so the same credential
+// deduplicates regardless of field order or formatting. Plain keys are only trimmed.
+func CanonicalKeyValue(keyValue string) string {
+	keyValue = strings.TrimSpace(keyValue)
+	if !strings.HasPrefix(keyValue, "{") {
+		return keyValue
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(keyValue), &raw); err != nil {
+		return keyValue
+	}
+	// json.Marshal sorts map keys and compacts raw values
+	canonical, err := json.Marshal(raw)
+	if err != nil {
+		return keyValue
+	}
+	return string(canonical)
+}
+
+// credentialCache holds the fields parsed from the key value they were parsed from.
+type credentialCache struct {
+	source string
+	fields map[string]string
+}
+
+// ParseCredential parses the key's structured credential once and caches its fields for
+// CredentialFields, CredentialField, IsOAuthCredential and Secret. The cache is ignored once
+// KeyValue changes.
+func (k *APIKey) ParseCredential() {
+	k.credential = &credentialCache{source: k.KeyValue, fields: ParseCredentialFields(k.KeyValue)}
+}
+
+// CredentialFields returns the fields of the key's structured credential, or nil for a plain key.
+// The returned map is shared with the cache and must not be modified.
+func (k *APIKey) CredentialFields() map[string]string {
+	if c := k.credential; c != nil && c.source == k.KeyValue {
+		return c.fields
+	}
+	return ParseCredentialFields(k.KeyValue)
+}
+
+// CredentialField returns a named field of the key's structured credential, or "" if it is absent.
+func (k *APIKey) CredentialField(name string) string {
+	return k.CredentialFields()[name]
+}
+
+// IsOAuthCredential reports whether the key is an OAuth credential whose access token is obtained
+// from a refresh token.
+func (k *APIKey) IsOAuthCredential() bool {
+	return k.CredentialField(OAuthRefreshTokenField) != ""
+}
+
+// Secret returns the current access token of an OAuth credential, the api_key field of a structured
+// credential, or the key value itself for a plain key and for credentials without that field, such as
+// service account files.
+func (k *APIKey) Secret() string {
+	if k.AccessToken != "" {
+		return k.AccessToken
+	}
+	if secret := k.CredentialField(CredentialSecretField); secret != "" {
+		return secret
+	}
+	return k.KeyValue
+}
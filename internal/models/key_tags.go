@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// KeyTags is the set of tags attached to an API key. It is stored as ",tag1,tag2," so that
+// a single tag can be matched with LIKE '%,tag,%' on every supported database.
+type KeyTags []string
+
+// ParseKeyTags splits a comma, semicolon or pipe separated tag list into normalized tags.
+func ParseKeyTags(text string) KeyTags {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || r == '|'
+	})
+	return NormalizeKeyTags(fields)
+}
+
+// NormalizeKeyTags trims, lower-cases, de-duplicates and sorts tags.
+func NormalizeKeyTags(tags []string) KeyTags {
+	result := make(KeyTags, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// HasAll reports whether every required tag is present.
+func (t KeyTags) HasAll(required []string) bool {
+	for _, tag := range required {
+		if !slices.Contains(t, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// String joins the tags with commas.
+func (t KeyTags) String() string {
+	return strings.Join(t, ",")
+}
+
+// KeyTagLikeCondition is the WHERE condition to use with KeyTagLikePattern. '!' is the escape
+// character rather than a backslash, which MySQL would also treat as a string literal escape.
+const KeyTagLikeCondition = "tags LIKE ? ESCAPE '!'"
+
+// keyTagLikeEscaper escapes the LIKE wildcards, which tags may contain.
+var keyTagLikeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// KeyTagLikePattern returns the LIKE pattern matching keys that have the given tag, for KeyTagLikeCondition.
+func KeyTagLikePattern(tag string) string {
+	return "%," + keyTagLikeEscaper.Replace(strings.ToLower(strings.TrimSpace(tag))) + ",%"
+}
+
+// Value implements driver.Valuer.
+func (t KeyTags) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return "", nil
+	}
+	return "," + t.String() + ",", nil
+}
+
+// Scan implements sql.Scanner.
+func (t *KeyTags) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*t = nil
+	case string:
+		*t = ParseKeyTags(v)
+	case []byte:
+		*t = ParseKeyTags(string(v))
+	default:
+		return fmt.Errorf("unsupported type %T for KeyTags", value)
+	}
+	return nil
+}
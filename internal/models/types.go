@@ -9,8 +9,14 @@ import (
 
 // Key状态
 const (
-	KeyStatusActive  = "active"
-	KeyStatusInvalid = "invalid"
+	KeyStatusActive    = "active"
+	KeyStatusInvalid   = "invalid"
+	KeyStatusCooldown  = "cooldown"
+	KeyStatusArchived  = "archived"
+	KeyStatusScheduled = "scheduled"
+	KeyStatusExpired   = "expired"
+	// KeyStatusQuotaExhausted 表示 key 的每日配额已用尽，等待分组配置的重置时间
+	KeyStatusQuotaExhausted = "quota_exhausted"
 )
 
 // SystemSetting 对应 system_settings 表
@@ -25,25 +31,41 @@ type SystemSetting struct {
 
 // GroupConfig 存储特定于分组的配置
 type GroupConfig struct {
-	RequestTimeout               *int    `json:"request_timeout,omitempty"`
-	IdleConnTimeout              *int    `json:"idle_conn_timeout,omitempty"`
-	ConnectTimeout               *int    `json:"connect_timeout,omitempty"`
-	MaxIdleConns                 *int    `json:"max_idle_conns,omitempty"`
-	MaxIdleConnsPerHost          *int    `json:"max_idle_conns_per_host,omitempty"`
-	ResponseHeaderTimeout        *int    `json:"response_header_timeout,omitempty"`
-	ProxyURL                     *string `json:"proxy_url,omitempty"`
-	MaxRetries                   *int    `json:"max_retries,omitempty"`
-	BlacklistThreshold           *int    `json:"blacklist_threshold,omitempty"`
-	BlacklistConsecutiveMode     *bool   `json:"blacklist_consecutive_mode,omitempty"`
-	KeyValidationIntervalMinutes *int    `json:"key_validation_interval_minutes,omitempty"`
-	KeyValidationConcurrency     *int    `json:"key_validation_concurrency,omitempty"`
-	KeyValidationTimeoutSeconds  *int    `json:"key_validation_timeout_seconds,omitempty"`
-	EnableRequestBodyLogging     *bool   `json:"enable_request_body_logging,omitempty"`
-	RequestBodyLogMode           *string `json:"request_body_log_mode,omitempty"`
-	DisableRequestBodyTruncate   *bool   `json:"disable_request_body_truncate,omitempty"`
-	EnableCacheHitEnhancement    *bool   `json:"enable_cache_hit_enhancement,omitempty"`
-	EnableInstantDisable         *bool   `json:"enable_instant_disable,omitempty"`
-	InstantDisableRules          *string `json:"instant_disable_rules,omitempty"`
+	RequestTimeout                 *int    `json:"request_timeout,omitempty"`
+	IdleConnTimeout                *int    `json:"idle_conn_timeout,omitempty"`
+	ConnectTimeout                 *int    `json:"connect_timeout,omitempty"`
+	MaxIdleConns                   *int    `json:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost            *int    `json:"max_idle_conns_per_host,omitempty"`
+	ResponseHeaderTimeout          *int    `json:"response_header_timeout,omitempty"`
+	ProxyURL                       *string `json:"proxy_url,omitempty"`
+	MaxRetries                     *int    `json:"max_retries,omitempty"`
+	BlacklistThreshold             *int    `json:"blacklist_threshold,omitempty"`
+	BlacklistConsecutiveMode       *bool   `json:"blacklist_consecutive_mode,omitempty"`
+	KeyValidationIntervalMinutes   *int    `json:"key_validation_interval_minutes,omitempty"`
+	KeyValidationConcurrency       *int    `json:"key_validation_concurrency,omitempty"`
+	KeyValidationTimeoutSeconds    *int    `json:"key_validation_timeout_seconds,omitempty"`
+	EnableRequestBodyLogging       *bool   `json:"enable_request_body_logging,omitempty"`
+	RequestBodyLogMode             *string `json:"request_body_log_mode,omitempty"`
+	DisableRequestBodyTruncate     *bool   `json:"disable_request_body_truncate,omitempty"`
+	EnableCacheHitEnhancement      *bool   `json:"enable_cache_hit_enhancement,omitempty"`
+	EnableInstantDisable           *bool   `json:"enable_instant_disable,omitempty"`
+	InstantDisableRules            *string `json:"instant_disable_rules,omitempty"`
+	KeyRPMLimit                    *int    `json:"key_rpm_limit,omitempty"`
+	KeyTPMLimit                    *int    `json:"key_tpm_limit,omitempty"`
+	KeyMaxConcurrency              *int    `json:"key_max_concurrency,omitempty"`
+	KeyCooldownSeconds             *int    `json:"key_cooldown_seconds,omitempty"`
+	KeyCooldownMaxSeconds          *int    `json:"key_cooldown_max_seconds,omitempty"`
+	KeyCooldownExponential         *bool   `json:"key_cooldown_exponential,omitempty"`
+	KeyValidationBackoffMaxMinutes *int    `json:"key_validation_backoff_max_minutes,omitempty"`
+	KeyArchiveAfterDays            *int    `json:"key_archive_after_days,omitempty"`
+	ActiveKeySamplePercent         *int    `json:"active_key_sample_percent,omitempty"`
+	ActiveKeySampleSkipMinutes     *int    `json:"active_key_sample_skip_minutes,omitempty"`
+	QuotaExhaustedRules            *string `json:"quota_exhausted_rules,omitempty"`
+	QuotaResetTime                 *string `json:"quota_reset_time,omitempty"`
+	QuotaResetTimezone             *string `json:"quota_reset_timezone,omitempty"`
+	KeyTagRules                    *string `json:"key_tag_rules,omitempty"`
+	ModelDenialTTLMinutes          *int    `json:"model_denial_ttl_minutes,omitempty"`
+	KeySelectionStrategy           *string `json:"key_selection_strategy,omitempty"`
 }
 
 // HeaderRule defines a single rule for header manipulation.
@@ -103,6 +125,7 @@ type Group struct {
 	HeaderRules         datatypes.JSON       `gorm:"type:json" json:"header_rules"`
 	ModelRedirectRules  datatypes.JSONMap    `gorm:"type:json" json:"model_redirect_rules"`
 	ModelRedirectStrict bool                 `gorm:"default:false" json:"model_redirect_strict"`
+	ChannelConfig       datatypes.JSONMap    `gorm:"type:json" json:"channel_config"`
 	APIKeys             []APIKey             `gorm:"foreignKey:GroupID" json:"api_keys"`
 	SubGroups           []GroupSubGroup      `gorm:"-" json:"sub_groups,omitempty"`
 	LastValidatedAt     *time.Time           `json:"last_validated_at"`
@@ -117,19 +140,41 @@ type Group struct {
 
 // APIKey 对应 api_keys 表
 type APIKey struct {
-	ID           uint       `gorm:"primaryKey;autoIncrement;index:idx_api_keys_group_last_used_id,priority:3" json:"id"`
-	KeyValue     string     `gorm:"type:text;not null" json:"key_value"`
-	KeyHash      string     `gorm:"type:varchar(128);index" json:"key_hash"`
-	GroupID      uint       `gorm:"not null;index;index:idx_api_keys_group_last_used_id,priority:1" json:"group_id"`
-	Status       string     `gorm:"type:varchar(50);not null;default:'active';index" json:"status"`
-	BaseWeight   int        `gorm:"not null;default:500" json:"base_weight"`
-	Weight       int        `gorm:"not null;default:500" json:"weight"`
-	Notes        string     `gorm:"type:varchar(255);default:''" json:"notes"`
-	RequestCount int64      `gorm:"not null;default:0" json:"request_count"`
-	FailureCount int64      `gorm:"not null;default:0" json:"failure_count"`
-	LastUsedAt   *time.Time `gorm:"index:idx_api_keys_group_last_used_id,priority:2" json:"last_used_at"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID                     uint       `gorm:"primaryKey;autoIncrement;index:idx_api_keys_group_last_used_id,priority:3" json:"id"`
+	KeyValue               string     `gorm:"type:text;not null" json:"key_value"`
+	KeyHash                string     `gorm:"type:varchar(128);index" json:"key_hash"`
+	GroupID                uint       `gorm:"not null;index;index:idx_api_keys_group_last_used_id,priority:1" json:"group_id"`
+	Status                 string     `gorm:"type:varchar(50);not null;default:'active';index" json:"status"`
+	BaseWeight             int        `gorm:"not null;default:500" json:"base_weight"`
+	Weight                 int        `gorm:"not null;default:500" json:"weight"`
+	Notes                  string     `gorm:"type:varchar(255);default:''" json:"notes"`
+	Tags                   KeyTags    `gorm:"type:varchar(512);not null;default:''" json:"tags"`
+	Upstream               string     `gorm:"type:varchar(500);not null;default:''" json:"upstream"`
+	RequestCount           int64      `gorm:"not null;default:0" json:"request_count"`
+	FailureCount           int64      `gorm:"not null;default:0" json:"failure_count"`
+	RPMLimit               int        `gorm:"not null;default:0" json:"rpm_limit"`
+	TPMLimit               int        `gorm:"not null;default:0" json:"tpm_limit"`
+	MaxConcurrency         int        `gorm:"not null;default:0" json:"max_concurrency"`
+	CooldownUntil          *time.Time `gorm:"index" json:"cooldown_until"`
+	CooldownCount          int        `gorm:"not null;default:0" json:"cooldown_count"`
+	ValidationFailures     int        `gorm:"not null;default:0" json:"validation_failures"`
+	ValidationFailingSince *time.Time `json:"validation_failing_since"`
+	NextValidationAt       *time.Time `gorm:"index" json:"next_validation_at"`
+	ActivatesAt            *time.Time `gorm:"index" json:"activates_at"`
+	ExpiresAt              *time.Time `gorm:"index" json:"expires_at"`
+	QuotaResetAt           *time.Time `gorm:"index" json:"quota_reset_at"`
+	LastUsedAt             *time.Time `gorm:"index:idx_api_keys_group_last_used_id,priority:2" json:"last_used_at"`
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+
+	// AccessToken 是 OAuth 凭证当前的访问令牌，由 key 池刷新后填入，不持久化
+	AccessToken string `gorm:"-" json:"-"`
+	// ConcurrencyToken 是选 key 时占用的并发槽位，为空表示未占用，只有持有者能续约和释放槽位
+	ConcurrencyToken string `gorm:"-" json:"-"`
+	// RPMBucket 是选 key 时记录这次请求的 RPM 窗口桶，撤销选择时从同一个桶中减去
+	RPMBucket time.Time `gorm:"-" json:"-"`
+	// credential 缓存从 KeyValue 解析出的结构化凭证字段，见 ParseCredential
+	credential *credentialCache
 }
 
 // RequestType 请求类型常量
@@ -185,6 +230,7 @@ type DashboardStatsResponse struct {
 	RPM              StatCard          `json:"rpm"`
 	RequestCount     StatCard          `json:"request_count"`
 	ErrorRate        StatCard          `json:"error_rate"`
+	KeyStateQueue    StatCard          `json:"key_state_queue"` // 待写回数据库的 key 状态数量，SubValue 为最近一次写入的数量
 	SecurityWarnings []SecurityWarning `json:"security_warnings"`
 }
 
@@ -6,9 +6,14 @@ import (
 	"encoding/json"
 	app_errors "key-flow/internal/errors"
 	"key-flow/internal/models"
+	"key-flow/internal/response"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
@@ -42,6 +47,16 @@ func logUpstreamError(context string, err error) {
 	}
 }
 
+// writeUpstreamError relays an upstream error body to the client, keeping JSON bodies as-is.
+func writeUpstreamError(c *gin.Context, statusCode int, errorMessage string) {
+	var errorJSON map[string]any
+	if err := json.Unmarshal([]byte(errorMessage), &errorJSON); err == nil {
+		c.JSON(statusCode, errorJSON)
+		return
+	}
+	response.Error(c, app_errors.NewAPIErrorWithUpstream(statusCode, "UPSTREAM_ERROR", errorMessage))
+}
+
 // handleGzipCompression checks for gzip encoding and decompresses the body if necessary.
 func handleGzipCompression(resp *http.Response, bodyBytes []byte) []byte {
 	if resp.Header.Get("Content-Encoding") == "gzip" {
@@ -61,3 +76,34 @@ func handleGzipCompression(resp *http.Response, bodyBytes []byte) []byte {
 	}
 	return bodyBytes
 }
+
+// parseRetryAfter reads the upstream retry hint from Retry-After (seconds or HTTP date)
+// or retry-after-ms. It returns 0 when the response carries no usable hint.
+func parseRetryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	if ms := strings.TrimSpace(resp.Header.Get("Retry-After-Ms")); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v > 0 {
+			return time.Duration(v * float64(time.Millisecond))
+		}
+	}
+
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -4,11 +4,12 @@ package proxy
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"key-flow/internal/channel"
@@ -126,18 +127,82 @@ func (ps *ProxyServer) executeRequestWithRetry(
 ) {
 	cfg := group.EffectiveConfig
 
-	// 获取缓存命中增强配置
-	enableCacheHit := cfg.EnableCacheHitEnhancement
-
-	apiKey, err := ps.keyProvider.SelectKeyWithCacheHit(group.ID, bodyBytes, c.Request.Header, enableCacheHit, group.ChannelType)
+	model := channelHandler.ExtractModel(c, bodyBytes)
+	apiKey, err := ps.keyProvider.SelectKeyWithCacheHit(group, bodyBytes, c.Request.Header, keypool.SelectConstraints{
+		Tags:  keypool.RequiredKeyTags(group, model, c.GetString("proxyKey"), c.GetHeader(keypool.KeyTagsHeader)),
+		Model: model,
+	})
 	if err != nil {
+		var rateLimited *keypool.RateLimitedError
+		if errors.As(err, &rateLimited) {
+			// 所有 key 都达到 RPM/TPM 限制，直接返回 429，不再消耗重试次数
+			logrus.Debugf("All keys of group %s are rate limited, retry after %s", group.Name, rateLimited.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(rateLimited.RetryAfter.Seconds()))))
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrKeysRateLimited, err.Error()))
+			ps.logRequest(c, originalGroup, group, nil, startTime, http.StatusTooManyRequests, err, isStream, "", channelHandler, bodyBytes, models.RequestTypeFinal)
+			return
+		}
+		var modelDenied *keypool.ModelDeniedError
+		if errors.As(err, &modelDenied) {
+			// 所有可用 key 都无权访问该模型，返回上游的模型错误而不是 503
+			logrus.Debugf("All keys of group %s are denied access to model %s", group.Name, modelDenied.Model)
+			ps.logRequest(c, originalGroup, group, nil, startTime, modelDenied.StatusCode, err, isStream, "", channelHandler, bodyBytes, models.RequestTypeFinal)
+			writeUpstreamError(c, modelDenied.StatusCode, modelDenied.Message)
+			return
+		}
 		logrus.Errorf("Failed to select a key for group %s on attempt %d: %v", group.Name, retryCount+1, err)
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrNoKeysAvailable, err.Error()))
 		ps.logRequest(c, originalGroup, group, nil, startTime, http.StatusServiceUnavailable, err, isStream, "", channelHandler, bodyBytes, models.RequestTypeFinal)
 		return
 	}
 
-	upstreamURL, err := channelHandler.BuildUpstreamURL(c.Request.URL, originalGroup.Name)
+	// 记录 key 正在处理的请求并持有其并发槽位，请求结束（包括流式响应和客户端断开）时释放
+	finishKeyRequest := ps.keyProvider.TrackKeyRequest(group, apiKey)
+	defer finishKeyRequest()
+
+	// failKey 处理发送请求之前的 key 失败：计入 key 失败次数，换下一个 key 重试，最后一次尝试时返回错误
+	failKey := func(statusCode int, err error) {
+		ps.keyProvider.UpdateStatus(apiKey, group, false, err.Error(), statusCode, false)
+
+		isLastAttempt := retryCount >= cfg.MaxRetries
+		requestType := models.RequestTypeRetry
+		if isLastAttempt {
+			requestType = models.RequestTypeFinal
+		}
+		ps.logRequest(c, originalGroup, group, apiKey, startTime, statusCode, err, isStream, "", channelHandler, bodyBytes, requestType)
+
+		if isLastAttempt {
+			response.Error(c, app_errors.NewAPIErrorWithUpstream(statusCode, "UPSTREAM_ERROR", err.Error()))
+			return
+		}
+		finishKeyRequest()
+		ps.executeRequestWithRetry(c, channelHandler, originalGroup, group, bodyBytes, isStream, startTime, retryCount+1)
+	}
+
+	if err := ps.keyProvider.ResolveAccessToken(c.Request.Context(), apiKey); err != nil {
+		if app_errors.IsIgnorableError(err) {
+			ps.logRequest(c, originalGroup, group, apiKey, startTime, 499, err, isStream, "", channelHandler, bodyBytes, models.RequestTypeFinal)
+			return
+		}
+
+		// 访问令牌刷新失败按 key 失败处理，换下一个 key 重试
+		statusCode := http.StatusBadGateway
+		var ve *app_errors.ValidationError
+		if errors.As(err, &ve) && ve.StatusCode > 0 {
+			statusCode = ve.StatusCode
+		}
+		logrus.Debugf("Failed to refresh access token (attempt %d/%d) for key %d: %v", retryCount+1, cfg.MaxRetries, apiKey.ID, err)
+		failKey(statusCode, err)
+		return
+	}
+
+	upstreamURL, err := channelHandler.BuildUpstreamURL(c.Request.URL, originalGroup.Name, apiKey)
+	if errors.Is(err, channel.ErrBoundUpstreamMissing) {
+		// key 绑定的上游已从分组中移除，key 无法使用，换下一个 key 重试
+		logrus.Debugf("Key %d is bound to a removed upstream (attempt %d/%d): %v", apiKey.ID, retryCount+1, cfg.MaxRetries, err)
+		failKey(http.StatusBadGateway, err)
+		return
+	}
 	if err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to build upstream URL: %v", err)))
 		return
@@ -167,6 +232,7 @@ func (ps *ProxyServer) executeRequestWithRetry(
 	req.Header.Del("Authorization")
 	req.Header.Del("X-Api-Key")
 	req.Header.Del("X-Goog-Api-Key")
+	req.Header.Del(keypool.KeyTagsHeader)
 
 	// Apply model redirection
 	finalBodyBytes, err := channelHandler.ApplyModelRedirect(req, bodyBytes, group)
@@ -182,23 +248,25 @@ func (ps *ProxyServer) executeRequestWithRetry(
 		req.ContentLength = int64(len(finalBodyBytes))
 	}
 
-	channelHandler.ModifyRequest(req, apiKey, group)
+	// 签名或换取 token 失败时不发送请求，按请求失败处理：计入 key 失败次数并换 key 重试
+	var resp *http.Response
+	if err = channelHandler.ModifyRequest(req, apiKey, group); err == nil {
+		// Apply custom header rules
+		if len(group.HeaderRuleList) > 0 {
+			headerCtx := utils.NewHeaderVariableContextFromGin(c, group, apiKey)
+			utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
+		}
 
-	// Apply custom header rules
-	if len(group.HeaderRuleList) > 0 {
-		headerCtx := utils.NewHeaderVariableContextFromGin(c, group, apiKey)
-		utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
-	}
+		var client *http.Client
+		if isStream {
+			client = channelHandler.GetStreamClient()
+			req.Header.Set("X-Accel-Buffering", "no")
+		} else {
+			client = channelHandler.GetHTTPClient()
+		}
 
-	var client *http.Client
-	if isStream {
-		client = channelHandler.GetStreamClient()
-		req.Header.Set("X-Accel-Buffering", "no")
-	} else {
-		client = channelHandler.GetHTTPClient()
+		resp, err = client.Do(req)
 	}
-
-	resp, err := client.Do(req)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -235,8 +303,19 @@ func (ps *ProxyServer) executeRequestWithRetry(
 			logrus.Debugf("Request failed with status %d (attempt %d/%d) for key %s. Parsed Error: %s", statusCode, retryCount+1, cfg.MaxRetries, utils.MaskAPIKey(apiKey.KeyValue), parsedError)
 		}
 
-		// 使用解析后的错误信息更新密钥状态
-		ps.keyProvider.UpdateStatus(apiKey, group, false, parsedError, statusCode, false) // 代理请求，不强制禁用
+		if err == nil && model != "" && cfg.ModelDenialTTLMinutes > 0 && app_errors.IsModelDenied(statusCode, errorMessage) {
+			// key 无权访问该模型：只对该模型跳过此 key，不计入失败次数
+			ps.keyProvider.DenyModel(apiKey, group, model, statusCode, errorMessage)
+		} else if err == nil && keypool.IsQuotaExhausted(group, statusCode, parsedError) {
+			// 每日配额耗尽：移出轮询直到分组配置的重置时间
+			ps.keyProvider.MarkQuotaExhausted(apiKey, group)
+		} else if err == nil && cfg.KeyCooldownSeconds > 0 && app_errors.IsCooldownError(statusCode, parsedError) {
+			// 限流或临时配额错误：冷却 key，到期自动恢复，不计入失败次数
+			ps.keyProvider.CooldownKey(apiKey, group, parseRetryAfter(resp))
+		} else {
+			// 使用解析后的错误信息更新密钥状态
+			ps.keyProvider.UpdateStatus(apiKey, group, false, parsedError, statusCode, false) // 代理请求，不强制禁用
+		}
 
 		// 判断是否为最后一次尝试
 		isLastAttempt := retryCount >= cfg.MaxRetries
@@ -249,21 +328,17 @@ func (ps *ProxyServer) executeRequestWithRetry(
 
 		// 如果是最后一次尝试，直接返回错误，不再递归
 		if isLastAttempt {
-			var errorJSON map[string]any
-			if err := json.Unmarshal([]byte(errorMessage), &errorJSON); err == nil {
-				c.JSON(statusCode, errorJSON)
-			} else {
-				response.Error(c, app_errors.NewAPIErrorWithUpstream(statusCode, "UPSTREAM_ERROR", errorMessage))
-			}
+			writeUpstreamError(c, statusCode, errorMessage)
 			return
 		}
 
+		finishKeyRequest()
 		ps.executeRequestWithRetry(c, channelHandler, originalGroup, group, bodyBytes, isStream, startTime, retryCount+1)
 		return
 	}
 
-	// 连续错误模式下，请求成功时重置错误计数
-	if group.EffectiveConfig.BlacklistConsecutiveMode {
+	// 连续错误模式下，请求成功时重置错误计数；冷却过的 key 成功后重置连续冷却次数
+	if group.EffectiveConfig.BlacklistConsecutiveMode || apiKey.CooldownCount > 0 {
 		ps.keyProvider.UpdateStatus(apiKey, group, true, "", 0, false)
 	}
 	logrus.Debugf("Request for group %s succeeded on attempt %d with key %s", group.Name, retryCount+1, utils.MaskAPIKey(apiKey.KeyValue))
@@ -272,6 +347,20 @@ func (ps *ProxyServer) executeRequestWithRetry(
 	if shouldInterceptModelList(c.Request.URL.Path, c.Request.Method) {
 		ps.handleModelListResponse(c, resp, group, channelHandler)
 	} else {
+		if transformer, ok := channelHandler.(channel.ResponseTransformer); ok {
+			transformer.TransformResponse(resp, isStream)
+		}
+
+		// 配置了 TPM 限制时统计上游返回的 token 用量
+		limits := keypool.GroupRateLimits(group)
+		if limits.ForKey(apiKey).TPM > 0 {
+			usageReader := newTokenUsageReader(resp, isStream)
+			resp.Body = usageReader
+			defer func() {
+				ps.keyProvider.RecordTokenUsage(apiKey, limits, usageReader.Tokens())
+			}()
+		}
+
 		for key, values := range resp.Header {
 			for _, value := range values {
 				c.Header(key, value)
@@ -366,7 +455,7 @@ func (ps *ProxyServer) logRequest(
 			logEntry.KeyValue = encryptedKeyValue
 		}
 		// 添加 KeyHash 用于反查
-		logEntry.KeyHash = ps.encryptionSvc.Hash(apiKey.KeyValue)
+		logEntry.KeyHash = ps.encryptionSvc.Hash(models.CanonicalKeyValue(apiKey.KeyValue))
 	}
 
 	if finalError != nil {
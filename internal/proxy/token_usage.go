@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// maxUsageBodySize 非流式响应最多缓存这么多字节用于解析 usage
+const maxUsageBodySize = 4 * 1024 * 1024
+
+// usagePayload covers the usage fields of the OpenAI, Anthropic and Gemini response formats.
+type usagePayload struct {
+	Usage         *usageFields `json:"usage"`
+	UsageMetadata *struct {
+		PromptTokenCount     int64 `json:"promptTokenCount"`
+		CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+		TotalTokenCount      int64 `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+	// Anthropic message_start 事件
+	Message *struct {
+		Usage *usageFields `json:"usage"`
+	} `json:"message"`
+	// OpenAI Responses API 的 response.completed 事件
+	Response *struct {
+		Usage *usageFields `json:"usage"`
+	} `json:"response"`
+}
+
+type usageFields struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	InputTokens      int64 `json:"input_tokens"`
+	OutputTokens     int64 `json:"output_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+// tokenUsage accumulates usage reports. Streaming formats report cumulative values,
+// so the largest value seen for each counter is kept.
+type tokenUsage struct {
+	input  int64
+	output int64
+	total  int64
+}
+
+func (u *tokenUsage) observeFields(f *usageFields) {
+	if f == nil {
+		return
+	}
+	u.input = max(u.input, f.PromptTokens, f.InputTokens)
+	u.output = max(u.output, f.CompletionTokens, f.OutputTokens)
+	u.total = max(u.total, f.TotalTokens)
+}
+
+// observe extracts usage from a single JSON document.
+func (u *tokenUsage) observe(data []byte) {
+	var p usagePayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return
+	}
+	u.observeFields(p.Usage)
+	if p.Message != nil {
+		u.observeFields(p.Message.Usage)
+	}
+	if p.Response != nil {
+		u.observeFields(p.Response.Usage)
+	}
+	if m := p.UsageMetadata; m != nil {
+		u.input = max(u.input, m.PromptTokenCount)
+		u.output = max(u.output, m.CandidatesTokenCount)
+		u.total = max(u.total, m.TotalTokenCount)
+	}
+}
+
+func (u *tokenUsage) tokens() int64 {
+	return max(u.total, u.input+u.output)
+}
+
+// tokenUsageReader passes the upstream body through unchanged while collecting token usage.
+// Streaming bodies are parsed line by line, other bodies are parsed once fully read.
+type tokenUsageReader struct {
+	io.ReadCloser
+	resp     *http.Response
+	isStream bool
+	buf      bytes.Buffer
+	usage    tokenUsage
+}
+
+func newTokenUsageReader(resp *http.Response, isStream bool) *tokenUsageReader {
+	return &tokenUsageReader{
+		ReadCloser: resp.Body,
+		resp:       resp,
+		isStream:   isStream,
+	}
+}
+
+func (r *tokenUsageReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if r.isStream {
+			r.buf.Write(p[:n])
+			r.consumeLines()
+		} else if r.buf.Len() < maxUsageBodySize {
+			r.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// consumeLines parses the complete SSE data lines in the buffer.
+func (r *tokenUsageReader) consumeLines() {
+	for {
+		idx := bytes.IndexByte(r.buf.Bytes(), '\n')
+		if idx < 0 {
+			return
+		}
+		line := bytes.TrimSpace(r.buf.Next(idx + 1))
+		if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			r.usage.observe(bytes.TrimSpace(data))
+		} else if len(line) > 0 && line[0] == '{' {
+			// 部分上游以 JSON Lines 形式返回流
+			r.usage.observe(line)
+		}
+	}
+}
+
+// Tokens returns the total tokens reported by the upstream, or 0 when unknown.
+func (r *tokenUsageReader) Tokens() int64 {
+	if r.isStream {
+		if r.buf.Len() > 0 {
+			r.buf.WriteByte('\n')
+			r.consumeLines()
+		}
+		return r.usage.tokens()
+	}
+
+	r.usage.observe(handleGzipCompression(r.resp, r.buf.Bytes()))
+	return r.usage.tokens()
+}
@@ -146,6 +146,9 @@ func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Ser
 		keys.POST("/clear-request-count", serverHandler.ClearRequestCount)
 		keys.PUT("/:id/notes", serverHandler.UpdateKeyNotes)
 		keys.PUT("/:id/weight", serverHandler.UpdateKeyWeight)
+		keys.PUT("/:id/rate-limits", serverHandler.UpdateKeyRateLimits)
+		keys.PUT("/:id/tags", serverHandler.UpdateKeyTags)
+		keys.PUT("/:id/upstream", serverHandler.UpdateKeyUpstream)
 		keys.POST("/:id/reset-weight", serverHandler.ResetKeyWeight)
 		keys.POST("/:id/clear-stats", serverHandler.ClearKeyStats)
 		keys.POST("/:id/disable", serverHandler.DisableKey)
@@ -154,6 +157,9 @@ func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Ser
 	// Tasks
 	api.GET("/tasks/status", serverHandler.GetTaskStatus)
 
+	// 集群
+	api.GET("/cluster/leader", serverHandler.GetLeaderStatus)
+
 	// 仪表板和日志
 	dashboard := api.Group("/dashboard")
 	{
@@ -233,7 +233,7 @@ func (s *AggregateGroupService) AddSubGroups(ctx context.Context, groupID uint,
 	}
 
 	// 触发缓存更新
-	if err := s.groupManager.Invalidate(); err != nil {
+	if err := s.groupManager.InvalidateGroup(groupID); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache after adding sub groups")
 	}
 
@@ -285,7 +285,7 @@ func (s *AggregateGroupService) UpdateSubGroupWeight(ctx context.Context, groupI
 	}
 
 	// 触发缓存更新
-	if err := s.groupManager.Invalidate(); err != nil {
+	if err := s.groupManager.InvalidateGroup(groupID); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache after updating sub group weight")
 	}
 
@@ -319,7 +319,7 @@ func (s *AggregateGroupService) DeleteSubGroup(ctx context.Context, groupID, sub
 	}
 
 	// 触发缓存更新
-	if err := s.groupManager.Invalidate(); err != nil {
+	if err := s.groupManager.InvalidateGroup(groupID); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache after deleting sub group")
 	}
 
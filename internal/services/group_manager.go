@@ -9,6 +9,7 @@ import (
 	"key-flow/internal/store"
 	"key-flow/internal/syncer"
 	"key-flow/internal/utils"
+	"strconv"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -47,106 +48,188 @@ func (gm *GroupManager) Initialize() error {
 		if err := gm.db.Find(&groups).Error; err != nil {
 			return nil, fmt.Errorf("failed to load groups from db: %w", err)
 		}
+		return gm.buildGroups(groups)
+	}
 
-		// Load all sub-group relationships for aggregate groups (only valid ones with weight > 0)
-		var allSubGroups []models.GroupSubGroup
-		if err := gm.db.Where("weight > 0").Find(&allSubGroups).Error; err != nil {
-			return nil, fmt.Errorf("failed to load valid sub groups: %w", err)
+	afterReload := func(newCache map[string]*models.Group) {
+		gm.subGroupManager.RebuildSelectors(newCache)
+	}
+
+	afterEntryReload := func(newCache map[string]*models.Group, key string) {
+		id, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			gm.subGroupManager.RebuildSelectors(newCache)
+			return
 		}
+		gm.subGroupManager.RebuildSelectorsFor(newCache, uint(id))
+	}
+
+	syncer, err := syncer.NewKeyedCacheSyncer(
+		loader,
+		gm.reloadGroup,
+		gm.store,
+		GroupUpdateChannel,
+		logrus.WithField("syncer", "groups"),
+		afterReload,
+		afterEntryReload,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create group syncer: %w", err)
+	}
+	gm.syncer = syncer
+	return nil
+}
 
-		// Group sub-groups by aggregate group ID
-		subGroupsByAggregateID := make(map[uint][]models.GroupSubGroup)
-		for _, sg := range allSubGroups {
-			subGroupsByAggregateID[sg.GroupID] = append(subGroupsByAggregateID[sg.GroupID], sg)
+// reloadGroup reloads the group whose ID is key, together with the aggregate groups that use it
+// as a sub-group so that their sub-group names stay current. A group that no longer exists is removed.
+func (gm *GroupManager) reloadGroup(current map[string]*models.Group, key string) (map[string]*models.Group, error) {
+	id, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid group cache key %q: %w", key, err)
+	}
+
+	affected := map[uint]struct{}{uint(id): {}}
+	for _, group := range current {
+		for _, sg := range group.SubGroups {
+			if sg.SubGroupID == uint(id) {
+				affected[group.ID] = struct{}{}
+			}
 		}
+	}
+	ids := make([]uint, 0, len(affected))
+	for groupID := range affected {
+		ids = append(ids, groupID)
+	}
+
+	var groups []*models.Group
+	if err := gm.db.Where("id IN ?", ids).Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to load groups from db: %w", err)
+	}
+	reloaded, err := gm.buildGroups(groups)
+	if err != nil {
+		return nil, err
+	}
 
-		// Create group ID to group object mapping for sub-group lookups
-		groupByID := make(map[uint]*models.Group)
-		for _, group := range groups {
-			groupByID[group.ID] = group
+	// 复制一份新的 map，读者可能仍在使用 current
+	groupMap := make(map[string]*models.Group, len(current)+len(reloaded))
+	for name, group := range current {
+		if _, ok := affected[group.ID]; !ok {
+			groupMap[name] = group
 		}
+	}
+	for name, group := range reloaded {
+		groupMap[name] = group
+	}
+	return groupMap, nil
+}
 
-		groupMap := make(map[string]*models.Group, len(groups))
-		for _, group := range groups {
-			g := *group
-			g.EffectiveConfig = gm.settingsManager.GetEffectiveConfig(g.Config)
-			g.ProxyKeysMap = utils.StringToSet(g.ProxyKeys, ",")
-
-			// Parse header rules with error handling
-			if len(group.HeaderRules) > 0 {
-				if err := json.Unmarshal(group.HeaderRules, &g.HeaderRuleList); err != nil {
-					logrus.WithError(err).WithField("group_name", g.Name).Warn("Failed to parse header rules for group")
-					g.HeaderRuleList = []models.HeaderRule{}
-				}
-			} else {
+// buildGroups resolves the cached fields of the given groups and attaches the sub-groups of aggregate groups.
+func (gm *GroupManager) buildGroups(groups []*models.Group) (map[string]*models.Group, error) {
+	var aggregateIDs []uint
+	for _, group := range groups {
+		if group.GroupType == "aggregate" {
+			aggregateIDs = append(aggregateIDs, group.ID)
+		}
+	}
+
+	// Load sub-group relationships for aggregate groups (only valid ones with weight > 0)
+	var allSubGroups []models.GroupSubGroup
+	if len(aggregateIDs) > 0 {
+		if err := gm.db.Where("weight > 0 AND group_id IN ?", aggregateIDs).Find(&allSubGroups).Error; err != nil {
+			return nil, fmt.Errorf("failed to load valid sub groups: %w", err)
+		}
+	}
+
+	// Group sub-groups by aggregate group ID
+	subGroupsByAggregateID := make(map[uint][]models.GroupSubGroup)
+	for _, sg := range allSubGroups {
+		subGroupsByAggregateID[sg.GroupID] = append(subGroupsByAggregateID[sg.GroupID], sg)
+	}
+
+	// Create group ID to name mapping for sub-group lookups
+	groupNameByID := make(map[uint]string, len(groups))
+	for _, group := range groups {
+		groupNameByID[group.ID] = group.Name
+	}
+	var missingIDs []uint
+	for _, sg := range allSubGroups {
+		if _, ok := groupNameByID[sg.SubGroupID]; !ok {
+			missingIDs = append(missingIDs, sg.SubGroupID)
+		}
+	}
+	if len(missingIDs) > 0 {
+		var subGroups []models.Group
+		if err := gm.db.Select("id", "name").Where("id IN ?", missingIDs).Find(&subGroups).Error; err != nil {
+			return nil, fmt.Errorf("failed to load sub group names: %w", err)
+		}
+		for _, sg := range subGroups {
+			groupNameByID[sg.ID] = sg.Name
+		}
+	}
+
+	groupMap := make(map[string]*models.Group, len(groups))
+	for _, group := range groups {
+		g := *group
+		g.EffectiveConfig = gm.settingsManager.GetEffectiveConfig(g.Config)
+		g.ProxyKeysMap = utils.StringToSet(g.ProxyKeys, ",")
+
+		// Parse header rules with error handling
+		if len(group.HeaderRules) > 0 {
+			if err := json.Unmarshal(group.HeaderRules, &g.HeaderRuleList); err != nil {
+				logrus.WithError(err).WithField("group_name", g.Name).Warn("Failed to parse header rules for group")
 				g.HeaderRuleList = []models.HeaderRule{}
 			}
+		} else {
+			g.HeaderRuleList = []models.HeaderRule{}
+		}
 
-			// Parse model redirect rules with error handling
-			g.ModelRedirectMap = make(map[string]string)
-			if len(group.ModelRedirectRules) > 0 {
-				hasInvalidRules := false
-				for key, value := range group.ModelRedirectRules {
-					if valueStr, ok := value.(string); ok {
-						g.ModelRedirectMap[key] = valueStr
-					} else {
-						logrus.WithFields(logrus.Fields{
-							"group_name": g.Name,
-							"rule_key":   key,
-							"value_type": fmt.Sprintf("%T", value),
-							"value":      value,
-						}).Error("Invalid model redirect rule value type, skipping this rule")
-						hasInvalidRules = true
-					}
-				}
-				if hasInvalidRules {
-					logrus.WithField("group_name", g.Name).Warn("Group has invalid model redirect rules, some rules were skipped. Please check the configuration.")
+		// Parse model redirect rules with error handling
+		g.ModelRedirectMap = make(map[string]string)
+		if len(group.ModelRedirectRules) > 0 {
+			hasInvalidRules := false
+			for key, value := range group.ModelRedirectRules {
+				if valueStr, ok := value.(string); ok {
+					g.ModelRedirectMap[key] = valueStr
+				} else {
+					logrus.WithFields(logrus.Fields{
+						"group_name": g.Name,
+						"rule_key":   key,
+						"value_type": fmt.Sprintf("%T", value),
+						"value":      value,
+					}).Error("Invalid model redirect rule value type, skipping this rule")
+					hasInvalidRules = true
 				}
 			}
+			if hasInvalidRules {
+				logrus.WithField("group_name", g.Name).Warn("Group has invalid model redirect rules, some rules were skipped. Please check the configuration.")
+			}
+		}
 
-			// Load sub-groups for aggregate groups
-			if g.GroupType == "aggregate" {
-				if subGroups, ok := subGroupsByAggregateID[g.ID]; ok {
-					g.SubGroups = make([]models.GroupSubGroup, len(subGroups))
-					for i, sg := range subGroups {
-						g.SubGroups[i] = sg
-						if subGroup, exists := groupByID[sg.SubGroupID]; exists {
-							g.SubGroups[i].SubGroupName = subGroup.Name
-						}
+		// Load sub-groups for aggregate groups
+		if g.GroupType == "aggregate" {
+			if subGroups, ok := subGroupsByAggregateID[g.ID]; ok {
+				g.SubGroups = make([]models.GroupSubGroup, len(subGroups))
+				for i, sg := range subGroups {
+					g.SubGroups[i] = sg
+					if name, exists := groupNameByID[sg.SubGroupID]; exists {
+						g.SubGroups[i].SubGroupName = name
 					}
 				}
 			}
-
-			groupMap[g.Name] = &g
-			logrus.WithFields(logrus.Fields{
-				"group_name":               g.Name,
-				"effective_config":         g.EffectiveConfig,
-				"header_rules_count":       len(g.HeaderRuleList),
-				"model_redirect_rules_count": len(g.ModelRedirectMap),
-				"model_redirect_strict":    g.ModelRedirectStrict,
-				"sub_group_count":          len(g.SubGroups),
-			}).Debug("Loaded group with effective config")
 		}
 
-		return groupMap, nil
-	}
-
-	afterReload := func(newCache map[string]*models.Group) {
-		gm.subGroupManager.RebuildSelectors(newCache)
+		groupMap[g.Name] = &g
+		logrus.WithFields(logrus.Fields{
+			"group_name":               g.Name,
+			"effective_config":         g.EffectiveConfig,
+			"header_rules_count":       len(g.HeaderRuleList),
+			"model_redirect_rules_count": len(g.ModelRedirectMap),
+			"model_redirect_strict":    g.ModelRedirectStrict,
+			"sub_group_count":          len(g.SubGroups),
+		}).Debug("Loaded group with effective config")
 	}
 
-	syncer, err := syncer.NewCacheSyncer(
-		loader,
-		gm.store,
-		GroupUpdateChannel,
-		logrus.WithField("syncer", "groups"),
-		afterReload,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create group syncer: %w", err)
-	}
-	gm.syncer = syncer
-	return nil
+	return groupMap, nil
 }
 
 // GetGroupByName retrieves a single group by its name from the cache.
@@ -171,6 +254,14 @@ func (gm *GroupManager) Invalidate() error {
 	return gm.syncer.Invalidate()
 }
 
+// InvalidateGroup triggers a reload of a single group, and of the aggregate groups using it, across all instances.
+func (gm *GroupManager) InvalidateGroup(groupID uint) error {
+	if gm.syncer == nil {
+		return fmt.Errorf("GroupManager is not initialized")
+	}
+	return gm.syncer.InvalidateEntry(strconv.FormatUint(uint64(groupID), 10))
+}
+
 // Stop gracefully stops the GroupManager's background syncer.
 func (gm *GroupManager) Stop(ctx context.Context) {
 	if gm.syncer != nil {
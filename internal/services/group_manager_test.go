@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"key-flow/internal/config"
+	"key-flow/internal/models"
+	"key-flow/internal/store"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestGroupManager 返回使用内存 store 和临时 SQLite 数据库的 GroupManager，数据库中预置 groups
+func newTestGroupManager(t *testing.T, groups []models.Group, subGroups []models.GroupSubGroup) (*GroupManager, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "groups.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Group{}, &models.GroupSubGroup{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range groups {
+		groups[i].Upstreams = datatypes.JSON(`[]`)
+	}
+	if err := db.Create(&groups).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(subGroups) > 0 {
+		if err := db.Create(&subGroups).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := store.NewMemoryStore()
+	gm := NewGroupManager(db, s, config.NewSystemSettingsManager(), NewSubGroupManager(s))
+	if err := gm.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { gm.Stop(context.Background()) })
+	return gm, db
+}
+
+func cachedGroup(t *testing.T, gm *GroupManager, name string) *models.Group {
+	t.Helper()
+	group, err := gm.GetGroupByName(name)
+	if err != nil {
+		t.Fatalf("GetGroupByName(%s) error = %v", name, err)
+	}
+	return group
+}
+
+// invalidateGroupAndWait 发布单个分组的失效通知并等待 done 成立。
+// 监听协程异步订阅，订阅之前发布的通知会丢失，因此未生效时重新发布
+func invalidateGroupAndWait(t *testing.T, gm *GroupManager, groupID uint, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if err := gm.InvalidateGroup(groupID); err != nil {
+			t.Fatal(err)
+		}
+		for range 10 {
+			if done() {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	t.Fatal("group cache was not reloaded")
+}
+
+func TestInvalidateGroup(t *testing.T) {
+	gm, db := newTestGroupManager(t,
+		[]models.Group{
+			{ID: 1, Name: "a", DisplayName: "A"},
+			{ID: 2, Name: "b", DisplayName: "B"},
+			{ID: 3, Name: "agg", GroupType: "aggregate"},
+		},
+		[]models.GroupSubGroup{{GroupID: 3, SubGroupID: 1, Weight: 1}},
+	)
+	// 先等监听协程订阅：订阅前丢失的通知会造成版本断档，下一条通知触发全量重新加载
+	if err := db.Create(&models.Group{ID: 4, Name: "ready", Upstreams: datatypes.JSON(`[]`)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	invalidateGroupAndWait(t, gm, 4, func() bool {
+		_, err := gm.GetGroupByName("ready")
+		return err == nil
+	})
+
+	b := cachedGroup(t, gm, "b")
+	agg := cachedGroup(t, gm, "agg")
+	if len(agg.SubGroups) != 1 || agg.SubGroups[0].SubGroupName != "a" {
+		t.Fatalf("agg sub groups = %+v", agg.SubGroups)
+	}
+
+	// 重命名 a，同时修改 b 但不发布 b 的失效通知
+	if err := db.Model(&models.Group{ID: 1}).Updates(map[string]any{"name": "a2", "display_name": "A2"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&models.Group{ID: 2}).Update("display_name", "B2").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	invalidateGroupAndWait(t, gm, 1, func() bool {
+		_, err := gm.GetGroupByName("a2")
+		return err == nil
+	})
+
+	if _, err := gm.GetGroupByName("a"); err == nil {
+		t.Fatal("group a is still cached under its old name")
+	}
+	if got := cachedGroup(t, gm, "a2").DisplayName; got != "A2" {
+		t.Fatalf("a2 display name = %q, want A2", got)
+	}
+	// 使用 a 的聚合分组一并重新加载，子分组名称随之更新
+	if got := cachedGroup(t, gm, "agg"); got == agg || got.SubGroups[0].SubGroupName != "a2" {
+		t.Fatalf("agg reloaded = %v, sub groups = %+v, want reloaded with a2", got != agg, got.SubGroups)
+	}
+	// 其他分组保持原有的缓存对象，不会读到数据库中未通知的修改
+	if got := cachedGroup(t, gm, "b"); got != b || got.DisplayName != "B" {
+		t.Fatalf("b = %p %q, want the cached %p with display name B", got, got.DisplayName, b)
+	}
+}
@@ -96,6 +96,7 @@ type GroupCreateParams struct {
 	ParamOverrides      map[string]any
 	ModelRedirectRules  map[string]string
 	ModelRedirectStrict bool
+	ChannelConfig       map[string]any
 	Config              map[string]any
 	HeaderRules         []models.HeaderRule
 	ProxyKeys           string
@@ -118,6 +119,7 @@ type GroupUpdateParams struct {
 	ParamOverrides      map[string]any
 	ModelRedirectRules  map[string]string
 	ModelRedirectStrict *bool
+	ChannelConfig       map[string]any
 	Config              map[string]any
 	HeaderRules         *[]models.HeaderRule
 	ProxyKeys           *string
@@ -230,6 +232,10 @@ func (s *GroupService) CreateGroup(ctx context.Context, params GroupCreateParams
 		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_model_redirect", map[string]any{"error": err.Error()})
 	}
 
+	if err := channel.ValidateChannelConfig(channelType, params.ChannelConfig); err != nil {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_channel_config", map[string]any{"error": err.Error()})
+	}
+
 	group := models.Group{
 		Name:                name,
 		DisplayName:         strings.TrimSpace(params.DisplayName),
@@ -243,6 +249,7 @@ func (s *GroupService) CreateGroup(ctx context.Context, params GroupCreateParams
 		ParamOverrides:      params.ParamOverrides,
 		ModelRedirectRules:  convertToJSONMap(params.ModelRedirectRules),
 		ModelRedirectStrict: params.ModelRedirectStrict,
+		ChannelConfig:       datatypes.JSONMap(params.ChannelConfig),
 		Config:              cleanedConfig,
 		HeaderRules:         headerRulesJSON,
 		ProxyKeys:           strings.TrimSpace(params.ProxyKeys),
@@ -262,7 +269,7 @@ func (s *GroupService) CreateGroup(ctx context.Context, params GroupCreateParams
 		return nil, app_errors.ParseDBError(err)
 	}
 
-	if err := s.groupManager.Invalidate(); err != nil {
+	if err := s.groupManager.InvalidateGroup(group.ID); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
 	}
 
@@ -313,6 +320,9 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 		if err != nil {
 			return nil, err
 		}
+		if err := s.checkBoundUpstreams(tx, group.ID, cleanedUpstreams); err != nil {
+			return nil, err
+		}
 		group.Upstreams = cleanedUpstreams
 	}
 
@@ -386,6 +396,15 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 		group.ModelRedirectStrict = *params.ModelRedirectStrict
 	}
 
+	if params.ChannelConfig != nil {
+		group.ChannelConfig = datatypes.JSONMap(params.ChannelConfig)
+	}
+
+	// Channel config is validated against the final channel type, which may have changed in this update
+	if err := channel.ValidateChannelConfig(group.ChannelType, group.ChannelConfig); err != nil {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_channel_config", map[string]any{"error": err.Error()})
+	}
+
 	if params.ValidationEndpoint != nil {
 		validationEndpoint := strings.TrimSpace(*params.ValidationEndpoint)
 		if !isValidValidationEndpoint(validationEndpoint) {
@@ -425,7 +444,7 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 		return nil, app_errors.ErrDatabase
 	}
 
-	if err := s.groupManager.Invalidate(); err != nil {
+	if err := s.groupManager.InvalidateGroup(group.ID); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
 	}
 
@@ -486,7 +505,7 @@ func (s *GroupService) DeleteGroup(ctx context.Context, id uint) error {
 	}
 	tx = nil
 
-	if err := s.groupManager.Invalidate(); err != nil {
+	if err := s.groupManager.InvalidateGroup(id); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
 	}
 
@@ -558,13 +577,13 @@ func (s *GroupService) CopyGroup(ctx context.Context, sourceGroupID uint, copyKe
 	}
 	tx = nil
 
-	if err := s.groupManager.Invalidate(); err != nil {
+	if err := s.groupManager.InvalidateGroup(newGroup.ID); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
 	}
 
 	if len(sourceKeyValues) > 0 {
 		keysText := strings.Join(sourceKeyValues, "\n")
-		if _, err := s.keyImportSvc.StartImportTask(&newGroup, keysText); err != nil {
+		if _, err := s.keyImportSvc.StartImportTask(&newGroup, keysText, KeyImportOptions{}); err != nil {
 			logrus.WithContext(ctx).WithFields(logrus.Fields{
 				"groupId":  newGroup.ID,
 				"keyCount": len(sourceKeyValues),
@@ -957,6 +976,39 @@ func (s *GroupService) validateAndCleanUpstreams(upstreams json.RawMessage) (dat
 	return datatypes.JSON(cleanedUpstreams), nil
 }
 
+// checkBoundUpstreams rejects an upstreams update that removes an upstream keys of the group are still bound to.
+// Bound keys are only valid on their upstream and would fail every request until they are rebound.
+func (s *GroupService) checkBoundUpstreams(tx *gorm.DB, groupID uint, upstreams datatypes.JSON) error {
+	var defs []struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(upstreams, &defs); err != nil {
+		return NewI18nError(app_errors.ErrValidation, "validation.invalid_upstreams", map[string]any{"error": err.Error()})
+	}
+	configured := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		configured[def.URL] = true
+	}
+
+	var bound []string
+	if err := tx.Model(&models.APIKey{}).Where("group_id = ? AND upstream <> ''", groupID).
+		Distinct().Pluck("upstream", &bound).Error; err != nil {
+		return app_errors.ParseDBError(err)
+	}
+	for _, upstream := range bound {
+		if configured[upstream] {
+			continue
+		}
+		var count int64
+		if err := tx.Model(&models.APIKey{}).Where("group_id = ? AND upstream = ?", groupID, upstream).Count(&count).Error; err != nil {
+			return app_errors.ParseDBError(err)
+		}
+		return NewI18nError(app_errors.ErrValidation, "validation.upstream_has_bound_keys",
+			map[string]any{"upstream": upstream, "count": count})
+	}
+	return nil
+}
+
 func calculateRequestStats(total, failed int64) RequestStats {
 	stats := RequestStats{
 		TotalRequests:  total,
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"key-flow/internal/models"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestCheckBoundUpstreams(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "keys.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.APIKey{}); err != nil {
+		t.Fatal(err)
+	}
+	keys := []models.APIKey{
+		{GroupID: 1, KeyValue: "sk-1", KeyHash: "h1", Upstream: "https://a.example.com"},
+		{GroupID: 1, KeyValue: "sk-2", KeyHash: "h2", Upstream: "https://a.example.com"},
+		{GroupID: 1, KeyValue: "sk-3", KeyHash: "h3"},
+		{GroupID: 2, KeyValue: "sk-4", KeyHash: "h4", Upstream: "https://c.example.com"},
+	}
+	if err := db.Create(&keys).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		upstreams string
+		wantCount int64 // 为 0 表示应允许更新
+	}{
+		{name: "bound upstream kept", upstreams: `[{"url":"https://a.example.com","weight":0},{"url":"https://b.example.com","weight":1}]`},
+		{name: "bound upstream removed", upstreams: `[{"url":"https://b.example.com","weight":1}]`, wantCount: 2},
+	}
+
+	s := &GroupService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.checkBoundUpstreams(db, 1, datatypes.JSON(tt.upstreams))
+			if tt.wantCount == 0 {
+				if err != nil {
+					t.Fatalf("checkBoundUpstreams() = %v, want nil", err)
+				}
+				return
+			}
+			var i18nErr *I18nError
+			if !errors.As(err, &i18nErr) || i18nErr.MessageID != "validation.upstream_has_bound_keys" {
+				t.Fatalf("checkBoundUpstreams() = %v, want upstream_has_bound_keys", err)
+			}
+			if i18nErr.Template["upstream"] != "https://a.example.com" || i18nErr.Template["count"] != tt.wantCount {
+				t.Errorf("error template = %v", i18nErr.Template)
+			}
+		})
+	}
+}
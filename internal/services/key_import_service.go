@@ -29,7 +29,7 @@ func NewKeyImportService(taskService *TaskService, keyService *KeyService) *KeyI
 
 // StartImportTask initiates a new asynchronous key import task.
 // Supports format: key:weight (e.g., "sk-xxx:10") or just key (default weight 500)
-func (s *KeyImportService) StartImportTask(group *models.Group, keysText string) (*TaskStatus, error) {
+func (s *KeyImportService) StartImportTask(group *models.Group, keysText string, options KeyImportOptions) (*TaskStatus, error) {
 	keysWithWeight := s.KeyService.ParseKeysWithWeightFromText(keysText)
 	if len(keysWithWeight) == 0 {
 		return nil, fmt.Errorf("no valid keys found in the input text")
@@ -40,19 +40,19 @@ func (s *KeyImportService) StartImportTask(group *models.Group, keysText string)
 		return nil, err
 	}
 
-	go s.runImport(group, keysWithWeight)
+	go s.runImport(group, keysWithWeight, options)
 
 	return initialStatus, nil
 }
 
-func (s *KeyImportService) runImport(group *models.Group, keys []KeyWithWeight) {
+func (s *KeyImportService) runImport(group *models.Group, keys []KeyWithWeight, options KeyImportOptions) {
 	progressCallback := func(processed int) {
 		if err := s.TaskService.UpdateProgress(processed); err != nil {
 			logrus.Warnf("Failed to update task progress for group %d: %v", group.ID, err)
 		}
 	}
 
-	addedCount, ignoredCount, err := s.KeyService.processAndCreateKeysWithWeight(group.ID, keys, progressCallback)
+	addedCount, ignoredCount, err := s.KeyService.processAndCreateKeysWithWeight(group.ID, keys, options, progressCallback)
 	if err != nil {
 		if endErr := s.TaskService.EndTask(nil, err); endErr != nil {
 			logrus.Errorf("Failed to end task with error for group %d: %v (original error: %v)", group.ID, endErr, err)
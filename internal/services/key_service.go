@@ -1,15 +1,20 @@
 package services
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"key-flow/internal/channel"
 	"key-flow/internal/encryption"
+	app_errors "key-flow/internal/errors"
 	"key-flow/internal/keypool"
 	"key-flow/internal/models"
 	"io"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -48,10 +53,27 @@ type UpdateWeightResult struct {
 	TotalInGroup int64 `json:"total_in_group"`
 }
 
-// KeyWithWeight represents a key with its weight
+// KeyWithWeight represents a key with its weight and optional per-key tags and upstream binding
 type KeyWithWeight struct {
-	Key    string
-	Weight int
+	Key      string
+	Weight   int
+	Tags     models.KeyTags
+	Upstream string
+}
+
+// KeySchedule is the optional validity window applied to newly added keys.
+type KeySchedule struct {
+	ActivatesAt *time.Time `json:"activates_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// KeyImportOptions holds the settings applied to every key of an import.
+type KeyImportOptions struct {
+	KeySchedule
+	// Tags are added to the per-key tags parsed from the import text.
+	Tags []string `json:"tags,omitempty"`
+	// Upstream binds keys without a per-key upstream to one of the group's upstream URLs.
+	Upstream string `json:"upstream,omitempty"`
 }
 
 // KeyService provides services related to API keys.
@@ -75,7 +97,7 @@ func NewKeyService(db *gorm.DB, keyProvider *keypool.KeyProvider, keyValidator *
 // AddMultipleKeys handles the business logic of creating new keys from a text block.
 // Supports format: key:weight (e.g., "sk-xxx:10") or just key (default weight 500)
 // deprecated: use KeyImportService for large imports
-func (s *KeyService) AddMultipleKeys(groupID uint, keysText string) (*AddKeysResult, error) {
+func (s *KeyService) AddMultipleKeys(groupID uint, keysText string, options KeyImportOptions) (*AddKeysResult, error) {
 	keysWithWeight := s.ParseKeysWithWeightFromText(keysText)
 	if len(keysWithWeight) > maxRequestKeys {
 		return nil, fmt.Errorf("batch size exceeds the limit of %d keys, got %d", maxRequestKeys, len(keysWithWeight))
@@ -84,7 +106,7 @@ func (s *KeyService) AddMultipleKeys(groupID uint, keysText string) (*AddKeysRes
 		return nil, fmt.Errorf("no valid keys found in the input text")
 	}
 
-	addedCount, ignoredCount, err := s.processAndCreateKeysWithWeight(groupID, keysWithWeight, nil)
+	addedCount, ignoredCount, err := s.processAndCreateKeysWithWeight(groupID, keysWithWeight, options, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -111,15 +133,38 @@ func (s *KeyService) processAndCreateKeys(
 	for i, k := range keys {
 		keysWithWeight[i] = KeyWithWeight{Key: k, Weight: 500}
 	}
-	return s.processAndCreateKeysWithWeight(groupID, keysWithWeight, progressCallback)
+	return s.processAndCreateKeysWithWeight(groupID, keysWithWeight, KeyImportOptions{}, progressCallback)
 }
 
 // processAndCreateKeysWithWeight is the lowest-level reusable function for adding keys with weight.
 func (s *KeyService) processAndCreateKeysWithWeight(
 	groupID uint,
 	keys []KeyWithWeight,
+	options KeyImportOptions,
 	progressCallback func(processed int),
 ) (addedCount int, ignoredCount int, err error) {
+	// 不在有效期窗口内的 key 不进入活跃列表，由定时任务切换状态
+	status := keypool.ScheduledStatus(options.ActivatesAt, options.ExpiresAt, time.Now())
+	if status == "" {
+		status = models.KeyStatusActive
+	}
+
+	// 绑定的上游必须是分组中配置的上游
+	var upstreams map[string]bool
+	if options.Upstream != "" || slices.ContainsFunc(keys, func(kw KeyWithWeight) bool { return kw.Upstream != "" }) {
+		if upstreams, err = s.groupUpstreamURLs(groupID); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// 结构化凭证需要符合分组渠道的字段定义
+	var channelType string
+	if slices.ContainsFunc(keys, func(kw KeyWithWeight) bool { return models.ParseCredentialFields(kw.Key) != nil }) {
+		if err := s.DB.Model(&models.Group{}).Where("id = ?", groupID).Pluck("channel_type", &channelType).Error; err != nil {
+			return 0, 0, err
+		}
+	}
+
 	// 1. Get existing key hashes in the group for deduplication
 	var existingHashes []string
 	if err := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Pluck("key_hash", &existingHashes).Error; err != nil {
@@ -136,13 +181,20 @@ func (s *KeyService) processAndCreateKeysWithWeight(
 
 	for _, kw := range keys {
 		trimmedKey := strings.TrimSpace(kw.Key)
-		if trimmedKey == "" || uniqueNewKeys[trimmedKey] || !s.isValidKeyFormat(trimmedKey) {
+		if trimmedKey == "" || !s.isValidKeyFormat(trimmedKey) {
 			continue
 		}
 
+		if channelType != "" {
+			if err := channel.ValidateCredential(channelType, trimmedKey); err != nil {
+				logrus.WithError(err).WithField("groupID", groupID).Warn("Invalid structured credential, skipping")
+				continue
+			}
+		}
+
 		// Generate hash for deduplication check
-		keyHash := s.EncryptionSvc.Hash(trimmedKey)
-		if existingHashMap[keyHash] {
+		keyHash := s.EncryptionSvc.Hash(models.CanonicalKeyValue(trimmedKey))
+		if existingHashMap[keyHash] || uniqueNewKeys[keyHash] {
 			continue
 		}
 
@@ -152,6 +204,15 @@ func (s *KeyService) processAndCreateKeysWithWeight(
 			continue
 		}
 
+		upstream := kw.Upstream
+		if upstream == "" {
+			upstream = options.Upstream
+		}
+		if upstream != "" && !upstreams[upstream] {
+			logrus.WithField("upstream", upstream).Warn("Key is bound to an upstream not configured in the group, skipping")
+			continue
+		}
+
 		weight := kw.Weight
 		if weight < 1 {
 		weight = 500
@@ -159,13 +220,17 @@ func (s *KeyService) processAndCreateKeysWithWeight(
 		weight = 5000
 		}
 
-		uniqueNewKeys[trimmedKey] = true
+		uniqueNewKeys[keyHash] = true
 		newKeysToCreate = append(newKeysToCreate, models.APIKey{
 			GroupID:  groupID,
 			KeyValue: encryptedKey,
-			KeyHash:  keyHash,
-			Status:   models.KeyStatusActive,
-			Weight:   weight,
+			KeyHash:     keyHash,
+			Status:      status,
+			Weight:      weight,
+			Tags:        models.ParseKeyTags(kw.Tags.String() + "," + strings.Join(options.Tags, ",")),
+			Upstream:    upstream,
+			ActivatesAt: options.ActivatesAt,
+			ExpiresAt:   options.ExpiresAt,
 		})
 	}
 
@@ -204,8 +269,10 @@ func (s *KeyService) ParseKeysFromText(text string) []string {
 	return keys
 }
 
-// ParseKeysWithWeightFromText parses a string of keys with optional weights.
-// Supports format: key:weight (e.g., "sk-xxx:10") or just key (default weight 500)
+// ParseKeysWithWeightFromText parses a string of keys with optional weights and tags.
+// Supports format: key:weight#tag1|tag2 (e.g., "sk-xxx:10#paid"), key:weight or just key (default weight 500),
+// and CSV text whose header row starts with "key". Tags are only read after a weight, so a key that contains
+// '#' is imported unchanged.
 func (s *KeyService) ParseKeysWithWeightFromText(text string) []KeyWithWeight {
 	var result []KeyWithWeight
 
@@ -220,6 +287,19 @@ func (s *KeyService) ParseKeysWithWeightFromText(text string) []KeyWithWeight {
 		return result
 	}
 
+	// JSON 对象形式的凭据（如服务账号 JSON），每个对象作为一个密钥
+	if objects := parseJSONObjectKeys(text); len(objects) > 0 {
+		for _, key := range objects {
+			result = append(result, KeyWithWeight{Key: key, Weight: 500})
+		}
+		return result
+	}
+
+	// 带表头的 CSV（与导出的 CSV 格式兼容）
+	if records, ok := s.parseCSVKeys(text); ok {
+		return records
+	}
+
 	// 通用解析：通过分隔符分割文本
 	delimiters := regexp.MustCompile(`[\s,;\n\r\t]+`)
 	splitKeys := delimiters.Split(strings.TrimSpace(text), -1)
@@ -233,28 +313,108 @@ func (s *KeyService) ParseKeysWithWeightFromText(text string) []KeyWithWeight {
 	return result
 }
 
-// parseKeyWithWeight parses a single key string with optional weight suffix
-// Format: key:weight or just key
+// parseJSONObjectKeys parses a JSON object, an array of JSON objects or JSON lines (one object per line)
+// into compact JSON key strings.
+func parseJSONObjectKeys(text string) []string {
+	text = strings.TrimSpace(text)
+	var rawObjects []json.RawMessage
+	if strings.HasPrefix(text, "{") {
+		if json.Valid([]byte(text)) {
+			rawObjects = []json.RawMessage{json.RawMessage(text)}
+		} else {
+			for _, line := range strings.Split(text, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					rawObjects = append(rawObjects, json.RawMessage(line))
+				}
+			}
+		}
+	} else if json.Unmarshal([]byte(text), &rawObjects) != nil {
+		return nil
+	}
+
+	var keys []string
+	for _, raw := range rawObjects {
+		var obj map[string]any
+		if err := json.Unmarshal(raw, &obj); err != nil || len(obj) == 0 {
+			return nil
+		}
+		compact, err := json.Marshal(obj)
+		if err != nil {
+			return nil
+		}
+		keys = append(keys, string(compact))
+	}
+	return keys
+}
+
+// parseCSVKeys parses CSV text with a header row containing a "key" column and optional
+// "weight", "tags" and "upstream" columns. Other columns, such as those of an exported file, are ignored.
+func (s *KeyService) parseCSVKeys(text string) ([]KeyWithWeight, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(strings.ToLower(text), "key,") {
+		return nil, false
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, false
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var result []KeyWithWeight
+	for _, record := range records[1:] {
+		key := field(record, "key")
+		if !s.isValidKeyFormat(key) {
+			continue
+		}
+		weight, err := strconv.Atoi(field(record, "weight"))
+		if err != nil || weight < 1 || weight > 1000 {
+			weight = 500
+		}
+		result = append(result, KeyWithWeight{
+			Key:      key,
+			Weight:   weight,
+			Tags:     models.ParseKeyTags(field(record, "tags")),
+			Upstream: field(record, "upstream"),
+		})
+	}
+	return result, true
+}
+
+// parseKeyWithWeight parses a single key string with optional weight and tags suffix
+// Format: key:weight#tag1|tag2, key:weight or just key. The tags suffix requires the weight,
+// otherwise '#' is part of the key.
 func (s *KeyService) parseKeyWithWeight(input string) *KeyWithWeight {
 	input = strings.TrimSpace(input)
 	if input == "" {
 		return nil
 	}
 
-	// 检查是否包含权重后缀 (:数字)
-	// 从最后一个冒号开始检查，因为密钥本身可能包含冒号
-	lastColonIdx := strings.LastIndex(input, ":")
-	if lastColonIdx > 0 && lastColonIdx < len(input)-1 {
-		potentialWeight := input[lastColonIdx+1:]
-		weight, err := strconv.Atoi(potentialWeight)
-		if err == nil && weight >= 1 && weight <= 1000 {
-			key := strings.TrimSpace(input[:lastColonIdx])
-			if s.isValidKeyFormat(key) {
-				return &KeyWithWeight{Key: key, Weight: weight}
-			}
+	// 标签后缀 (:weight#tag1|tag2)，文本导入时逗号和分号会被当作 key 之间的分隔符
+	if hashIdx := strings.LastIndex(input, "#"); hashIdx > 0 {
+		if kw := s.parseKeyWeight(strings.TrimSpace(input[:hashIdx])); kw != nil {
+			kw.Tags = models.ParseKeyTags(input[hashIdx+1:])
+			return kw
 		}
 	}
 
+	if kw := s.parseKeyWeight(input); kw != nil {
+		return kw
+	}
+
 	// 没有权重后缀，使用默认权重 500
 	if s.isValidKeyFormat(input) {
 		return &KeyWithWeight{Key: input, Weight: 500}
@@ -263,6 +423,24 @@ func (s *KeyService) parseKeyWithWeight(input string) *KeyWithWeight {
 	return nil
 }
 
+// parseKeyWeight parses "key:weight", returning nil when input does not end with a valid weight.
+func (s *KeyService) parseKeyWeight(input string) *KeyWithWeight {
+	// 从最后一个冒号开始检查，因为密钥本身可能包含冒号
+	lastColonIdx := strings.LastIndex(input, ":")
+	if lastColonIdx <= 0 || lastColonIdx == len(input)-1 {
+		return nil
+	}
+	weight, err := strconv.Atoi(input[lastColonIdx+1:])
+	if err != nil || weight < 1 || weight > 1000 {
+		return nil
+	}
+	key := strings.TrimSpace(input[:lastColonIdx])
+	if !s.isValidKeyFormat(key) {
+		return nil
+	}
+	return &KeyWithWeight{Key: key, Weight: weight}
+}
+
 // filterValidKeys validates and filters potential API keys
 func (s *KeyService) filterValidKeys(keys []string) []string {
 	var validKeys []string
@@ -371,14 +549,23 @@ func (s *KeyService) DeleteMultipleKeys(groupID uint, keysText string) (*DeleteK
 	}, nil
 }
 
-// ListKeysInGroupQuery builds a query to list all keys within a specific group, filtered by status.
-func (s *KeyService) ListKeysInGroupQuery(groupID uint, statusFilter string, searchHash string, sortBy string, sortOrder string) *gorm.DB {
+// ListKeysInGroupQuery builds a query to list all keys within a specific group, filtered by status
+// and optionally by keys expiring before the given time or carrying the given tag.
+func (s *KeyService) ListKeysInGroupQuery(groupID uint, statusFilter string, searchHash string, expiresBefore *time.Time, tag string, sortBy string, sortOrder string) *gorm.DB {
 	query := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID)
 
 	if statusFilter != "" {
 		query = query.Where("status = ?", statusFilter)
 	}
 
+	if expiresBefore != nil {
+		query = query.Where("expires_at IS NOT NULL AND expires_at <= ?", *expiresBefore)
+	}
+
+	if tag != "" {
+		query = query.Where(models.KeyTagLikeCondition, models.KeyTagLikePattern(tag))
+	}
+
 	if searchHash != "" {
 		query = query.Where("key_hash = ?", searchHash)
 	}
@@ -393,6 +580,8 @@ func (s *KeyService) ListKeysInGroupQuery(groupID uint, statusFilter string, sea
 		query = query.Order("request_count " + sortOrder)
 	case "failure_count":
 		query = query.Order("failure_count " + sortOrder)
+	case "expires_at":
+		query = query.Order("expires_at IS NULL, expires_at " + sortOrder + ", id " + sortOrder)
 	case "last_used_at":
 		if isPostgres {
 			if sortOrder == "asc" {
@@ -455,17 +644,31 @@ func (s *KeyService) TestMultipleKeys(group *models.Group, keysText string) ([]k
 }
 
 // StreamKeysToWriter fetches keys from the database in batches and writes them to the provided writer.
-func (s *KeyService) StreamKeysToWriter(groupID uint, statusFilter string, writer io.Writer) error {
-	query := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Select("id, key_value")
+// The text format writes one key per line, the csv format adds status, weight, validity window, tags and upstream columns.
+// A non-empty tag limits the export to keys carrying that tag.
+func (s *KeyService) StreamKeysToWriter(groupID uint, statusFilter string, tag string, format string, writer io.Writer) error {
+	query := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Select("id, key_value, status, weight, tags, upstream, activates_at, expires_at")
+	if tag != "" {
+		query = query.Where(models.KeyTagLikeCondition, models.KeyTagLikePattern(tag))
+	}
 
 	switch statusFilter {
-	case models.KeyStatusActive, models.KeyStatusInvalid:
+	case models.KeyStatusActive, models.KeyStatusInvalid, models.KeyStatusCooldown, models.KeyStatusArchived,
+		models.KeyStatusScheduled, models.KeyStatusExpired, models.KeyStatusQuotaExhausted:
 		query = query.Where("status = ?", statusFilter)
 	case "all":
 	default:
 		return fmt.Errorf("invalid status filter: %s", statusFilter)
 	}
 
+	var csvWriter *csv.Writer
+	if format == "csv" {
+		csvWriter = csv.NewWriter(writer)
+		if err := csvWriter.Write([]string{"key", "status", "weight", "activates_at", "expires_at", "tags", "upstream"}); err != nil {
+			return err
+		}
+	}
+
 	var keys []models.APIKey
 	err := query.FindInBatches(&keys, chunkSize, func(tx *gorm.DB, batch int) error {
 		for _, key := range keys {
@@ -474,14 +677,48 @@ func (s *KeyService) StreamKeysToWriter(groupID uint, statusFilter string, write
 				logrus.WithError(err).WithField("key_id", key.ID).Error("Failed to decrypt key for streaming, skipping")
 				continue
 			}
-			if _, err := writer.Write([]byte(decryptedKey + "\n")); err != nil {
+			if csvWriter == nil {
+				if _, err := writer.Write([]byte(decryptedKey + "\n")); err != nil {
+					return err
+				}
+				continue
+			}
+			record := []string{
+				decryptedKey,
+				key.Status,
+				strconv.Itoa(key.Weight),
+				formatOptionalTime(key.ActivatesAt),
+				formatOptionalTime(key.ExpiresAt),
+				key.Tags.String(),
+				key.Upstream,
+			}
+			if err := csvWriter.Write(record); err != nil {
 				return err
 			}
 		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
 		return nil
 	}).Error
+	if err != nil {
+		return err
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return nil
+}
 
-	return err
+// formatOptionalTime formats t as RFC 3339, or returns an empty string when t is nil.
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
 }
 
 // UpdateKeyWeight updates the weight of a single key by ID
@@ -489,6 +726,64 @@ func (s *KeyService) UpdateKeyWeight(keyID uint, weight int) error {
 	return s.KeyProvider.UpdateKeyWeight(keyID, weight)
 }
 
+// UpdateKeyRateLimits updates the RPM/TPM and concurrency limits of a single key by ID
+func (s *KeyService) UpdateKeyRateLimits(keyID uint, rpmLimit, tpmLimit, maxConcurrency int) error {
+	return s.KeyProvider.UpdateKeyRateLimits(keyID, rpmLimit, tpmLimit, maxConcurrency)
+}
+
+// UpdateKeyTags replaces the tags of a single key by ID
+func (s *KeyService) UpdateKeyTags(keyID uint, tags []string) error {
+	return s.KeyProvider.UpdateKeyTags(keyID, tags)
+}
+
+// UpdateKeyUpstream binds a single key to one of its group's upstreams, or unbinds it when upstream is empty.
+func (s *KeyService) UpdateKeyUpstream(keyID uint, upstream string) error {
+	var key models.APIKey
+	if err := s.DB.Select("id, group_id").First(&key, keyID).Error; err != nil {
+		return err
+	}
+	if err := s.ValidateKeyUpstream(key.GroupID, upstream); err != nil {
+		return err
+	}
+	return s.KeyProvider.UpdateKeyUpstream(keyID, upstream)
+}
+
+// ValidateKeyUpstream checks that upstream is empty or one of the group's configured upstream URLs.
+func (s *KeyService) ValidateKeyUpstream(groupID uint, upstream string) error {
+	if upstream == "" {
+		return nil
+	}
+	upstreams, err := s.groupUpstreamURLs(groupID)
+	if err != nil {
+		return err
+	}
+	if !upstreams[upstream] {
+		return NewI18nError(app_errors.ErrValidation, "validation.key_upstream_not_in_group", map[string]any{"upstream": upstream})
+	}
+	return nil
+}
+
+// groupUpstreamURLs returns the set of upstream URLs configured for the group, including those with weight 0.
+func (s *KeyService) groupUpstreamURLs(groupID uint) (map[string]bool, error) {
+	var group models.Group
+	if err := s.DB.Select("id, upstreams").First(&group, groupID).Error; err != nil {
+		return nil, err
+	}
+
+	var defs []struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(group.Upstreams, &defs); err != nil {
+		return nil, fmt.Errorf("failed to parse upstreams of group %d: %w", groupID, err)
+	}
+
+	upstreams := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		upstreams[def.URL] = true
+	}
+	return upstreams, nil
+}
+
 // UpdateKeysWeight updates the weight of multiple keys from a text block
 func (s *KeyService) UpdateKeysWeight(groupID uint, keysText string, weight int) (*UpdateWeightResult, error) {
 	if weight < 1 || weight > 1000 {
@@ -506,7 +801,7 @@ func (s *KeyService) UpdateKeysWeight(groupID uint, keysText string, weight int)
 	// 生成 key hashes
 	var keyHashes []string
 	for _, key := range keys {
-		keyHash := s.EncryptionSvc.Hash(strings.TrimSpace(key))
+		keyHash := s.EncryptionSvc.Hash(models.CanonicalKeyValue(key))
 		if keyHash != "" {
 			keyHashes = append(keyHashes, keyHash)
 		}
@@ -582,12 +877,6 @@ func (s *KeyService) DisableKey(keyID uint) error {
 		return err
 	}
 
-	// Update store - remove from active list and update status
-	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", key.GroupID)
-	keyHashKey := fmt.Sprintf("key:%d", keyID)
-
-	s.KeyProvider.GetStore().LRem(activeKeysListKey, 0, keyID)
-	s.KeyProvider.GetStore().HSet(keyHashKey, map[string]any{"status": models.KeyStatusInvalid})
-
-	return nil
+	// Update store - remove from active list and weight index and update status
+	return s.KeyProvider.DisableKeyInStore(keyID, key.GroupID)
 }
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseKeyWithWeight(t *testing.T) {
+	tests := []struct {
+		input      string
+		wantKey    string
+		wantWeight int
+		wantTags   []string
+	}{
+		{input: "sk-1", wantKey: "sk-1", wantWeight: 500},
+		{input: "sk-1:10", wantKey: "sk-1", wantWeight: 10},
+		{input: "sk-1:10#Paid|eu", wantKey: "sk-1", wantWeight: 10, wantTags: []string{"eu", "paid"}},
+		{input: "AKIA:secret:20#batch", wantKey: "AKIA:secret", wantWeight: 20, wantTags: []string{"batch"}},
+		// 没有权重时 '#' 属于 key
+		{input: "sk-1#paid", wantKey: "sk-1#paid", wantWeight: 500},
+		{input: "abc#def:10", wantKey: "abc#def", wantWeight: 10},
+		{input: "abc#def:0#paid", wantKey: "abc#def:0#paid", wantWeight: 500},
+	}
+
+	s := &KeyService{}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := s.parseKeyWithWeight(tt.input)
+			if got == nil {
+				t.Fatal("parseKeyWithWeight() = nil")
+			}
+			if got.Key != tt.wantKey || got.Weight != tt.wantWeight || !slices.Equal(got.Tags, tt.wantTags) {
+				t.Fatalf("parseKeyWithWeight() = {%q %d %v}, want {%q %d %v}", got.Key, got.Weight, got.Tags, tt.wantKey, tt.wantWeight, tt.wantTags)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"key-flow/internal/config"
+	"key-flow/internal/election"
 	"key-flow/internal/models"
 	"sync"
 	"time"
@@ -15,6 +16,7 @@ import (
 type LogCleanupService struct {
 	db              *gorm.DB
 	settingsManager *config.SystemSettingsManager
+	fence           election.Fence
 	stopCh          chan struct{}
 	wg              sync.WaitGroup
 }
@@ -28,8 +30,11 @@ func NewLogCleanupService(db *gorm.DB, settingsManager *config.SystemSettingsMan
 	}
 }
 
-// Start 启动日志清理服务
-func (s *LogCleanupService) Start() {
+// Start 启动日志清理服务，可在 Stop 之后再次启动
+func (s *LogCleanupService) Start(fence election.Fence) {
+	s.wg.Wait()
+	s.fence = fence
+	s.stopCh = make(chan struct{})
 	s.wg.Add(1)
 	go s.run()
 	logrus.Debug("Log cleanup service started")
@@ -83,6 +88,12 @@ func (s *LogCleanupService) cleanupExpiredLogs() {
 		return
 	}
 
+	// 删除前确认仍是 leader，锁已被新 leader 接管时由新 leader 清理
+	if !s.fence.Valid() {
+		logrus.WithField("token", s.fence.Token()).Warn("Leadership lost, skipping request log cleanup")
+		return
+	}
+
 	// 计算过期时间点
 	cutoffTime := time.Now().AddDate(0, 0, -retentionDays).UTC()
 
@@ -45,7 +45,7 @@ func (s *LogService) logFiltersScope(c *gin.Context) func(db *gorm.DB) *gorm.DB
 			db = db.Where("group_name LIKE ?", "%"+groupName+"%")
 		}
 		if keyValue := c.Query("key_value"); keyValue != "" {
-			keyHash := s.EncryptionSvc.Hash(keyValue)
+			keyHash := s.EncryptionSvc.Hash(models.CanonicalKeyValue(keyValue))
 			db = db.Where("key_hash = ?", keyHash)
 		}
 		if model := c.Query("model"); model != "" {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"key-flow/internal/config"
+	"key-flow/internal/election"
 	"key-flow/internal/models"
 	"key-flow/internal/store"
 	"strings"
@@ -28,6 +29,7 @@ type RequestLogService struct {
 	db              *gorm.DB
 	store           store.Store
 	settingsManager *config.SystemSettingsManager
+	fence           election.Fence
 	stopChan        chan struct{}
 	wg              sync.WaitGroup
 	ticker          *time.Ticker
@@ -43,8 +45,12 @@ func NewRequestLogService(db *gorm.DB, store store.Store, sm *config.SystemSetti
 	}
 }
 
-// Start initializes the service and starts the periodic flush routine
-func (s *RequestLogService) Start() {
+// Start initializes the service and starts the periodic flush routine.
+// 成为 leader 时会重新启动，先等待上一轮的循环退出再创建新的 stopChan
+func (s *RequestLogService) Start(fence election.Fence) {
+	s.wg.Wait()
+	s.fence = fence
+	s.stopChan = make(chan struct{})
 	s.wg.Add(1)
 	go s.runLoop()
 }
@@ -138,6 +144,12 @@ func (s *RequestLogService) flush() {
 	logrus.Debug("Master starting to flush request logs...")
 
 	for {
+		// 每批写入前确认仍是 leader，锁已被新 leader 接管时剩余的日志由新 leader 写入
+		if !s.fence.Valid() {
+			logrus.WithField("token", s.fence.Token()).Warn("Leadership lost, stopping request log flush")
+			return
+		}
+
 		keys, err := s.store.SPopN(PendingLogKeysSet, DefaultLogFlushBatchSize)
 		if err != nil {
 			logrus.Errorf("Failed to pop pending log keys from store: %v", err)
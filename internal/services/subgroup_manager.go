@@ -75,6 +75,46 @@ func (m *SubGroupManager) RebuildSelectors(groups map[string]*models.Group) {
 	logrus.WithField("new_count", len(newSelectors)).Debug("Rebuilt selectors for aggregate groups")
 }
 
+// RebuildSelectorsFor rebuilds only the selectors affected by a change to the given group:
+// the group itself and the aggregate groups that use it as a sub-group before or after the change.
+func (m *SubGroupManager) RebuildSelectorsFor(groups map[string]*models.Group, groupID uint) {
+	byID := make(map[uint]*models.Group, len(groups))
+	affected := map[uint]struct{}{groupID: {}}
+	for _, group := range groups {
+		byID[group.ID] = group
+		for _, sg := range group.SubGroups {
+			if sg.SubGroupID == groupID {
+				affected[group.ID] = struct{}{}
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, sel := range m.selectors {
+		for _, item := range sel.subGroups {
+			if item.subGroupID == groupID {
+				affected[id] = struct{}{}
+			}
+		}
+	}
+
+	for id := range affected {
+		delete(m.selectors, id)
+		if group, ok := byID[id]; ok {
+			if sel := m.createSelector(group); sel != nil {
+				m.selectors[id] = sel
+			}
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"group_id":       groupID,
+		"affected_count": len(affected),
+	}).Debug("Rebuilt selectors for changed group")
+}
+
 // getSelector retrieves or creates a selector for the aggregate group
 func (m *SubGroupManager) getSelector(group *models.Group) *selector {
 	m.mu.RLock()
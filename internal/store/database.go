@@ -0,0 +1,716 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// databaseStoreJanitorInterval 是清理过期条目和旧消息的间隔
+	databaseStoreJanitorInterval = time.Minute
+	// databaseStoreMessageRetention 是轮询模式下消息保留的时间，订阅者在此期间内都能读到
+	databaseStoreMessageRetention = time.Minute
+	// databaseStorePollInterval 是轮询模式下订阅者检查新消息的间隔
+	databaseStorePollInterval = time.Second
+	// databaseStorePollWindow 是轮询时重新读取的时间范围。自增 ID 在写入时分配、提交后才可见，
+	// ID 较小的消息可能晚于较大的提交，游标之下这段时间内的消息会再次读取并按 ID 去重
+	databaseStorePollWindow = 10 * time.Second
+)
+
+// storeEntry is one row of the database store. Every key is a set of rows sharing Name:
+//   - K/V: a single row with an empty Field, the value in Value and an optional ExpiresAt
+//   - hash: one row per field
+//   - list: one row per element, Field a random ID and Score its position (head has the lowest), and a lock row
+//   - set: one row per member in Field
+//   - sliding window: one row per one-second bucket, the amount in Score, expiring with the window
+//   - semaphore: one row per holder in Field, expiring with its lease, and a lock row
+//
+// A lock row has an empty Field, see lockKey.
+//   - weighted set: one row per member in Field, the weight in Score
+type storeEntry struct {
+	Name      string `gorm:"primaryKey;size:255"`
+	Field     string `gorm:"primaryKey;size:255"`
+	Value     []byte
+	Score     int64
+	ExpiresAt *int64 `gorm:"index"` // Unix-nano timestamp. NULL for no expiry.
+}
+
+func (storeEntry) TableName() string {
+	return "store_entries"
+}
+
+// DatabaseStore is a Store backed by the application database, for multi-node deployments without Redis.
+// Operations that read and modify a key run in a transaction with row locks.
+// On PostgreSQL pub/sub uses LISTEN/NOTIFY; on MySQL and SQLite subscribers poll a message table.
+// MySQL 8.0 or later is required for window functions and SKIP LOCKED.
+type DatabaseStore struct {
+	db      *gorm.DB
+	dialect string
+	// SQLite 同一时间只允许一个写事务，进程内串行化写事务，避免事务升级写锁时返回 database is locked
+	writeMu   sync.Mutex
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewDatabaseStore creates the store tables if needed and returns a DatabaseStore on db.
+func NewDatabaseStore(db *gorm.DB) (*DatabaseStore, error) {
+	if err := db.AutoMigrate(&storeEntry{}, &storeMessage{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate store tables: %w", err)
+	}
+
+	s := &DatabaseStore{
+		db:      db,
+		dialect: db.Dialector.Name(),
+		stopCh:  make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.runJanitor()
+	return s, nil
+}
+
+// transaction runs fn in a transaction, serialized within the process on SQLite.
+func (s *DatabaseStore) transaction(fn func(tx *gorm.DB) error) error {
+	if s.dialect == "sqlite" {
+		s.writeMu.Lock()
+		defer s.writeMu.Unlock()
+	}
+	return s.db.Transaction(fn)
+}
+
+// forUpdate locks the selected rows until the transaction ends. SQLite locks the whole database instead.
+func (s *DatabaseStore) forUpdate(tx *gorm.DB, options ...string) *gorm.DB {
+	if s.dialect == "sqlite" {
+		return tx
+	}
+	locking := clause.Locking{Strength: clause.LockingStrengthUpdate}
+	if len(options) > 0 {
+		locking.Options = options[0]
+	}
+	return tx.Clauses(locking)
+}
+
+// lockField is the field of the row that serializes read-modify-write operations on a list or semaphore.
+// List elements and semaphore holders are never empty strings.
+const lockField = ""
+
+// lockKey creates the lock row of key if needed and locks it until the transaction ends, so concurrent
+// transactions on the same key run one at a time even when the key has no other rows to lock.
+func (s *DatabaseStore) lockKey(tx *gorm.DB, key string) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&storeEntry{Name: key, Field: lockField}).Error; err != nil {
+		return err
+	}
+	var lock storeEntry
+	return s.forUpdate(tx).Where("name = ? AND field = ?", key, lockField).Take(&lock).Error
+}
+
+// entries selects the unexpired rows of key.
+func entries(tx *gorm.DB, key string) *gorm.DB {
+	return tx.Model(&storeEntry{}).Where("name = ? AND (expires_at IS NULL OR expires_at > ?)", key, time.Now().UnixNano())
+}
+
+// expiresAt converts a TTL to the ExpiresAt column value.
+func expiresAt(ttl time.Duration) *int64 {
+	if ttl <= 0 {
+		return nil
+	}
+	at := time.Now().Add(ttl).UnixNano()
+	return &at
+}
+
+// runJanitor periodically deletes expired entries and messages every subscriber has had time to read.
+func (s *DatabaseStore) runJanitor() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(databaseStoreJanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.transaction(func(tx *gorm.DB) error {
+				return tx.Where("expires_at <= ?", time.Now().UnixNano()).Delete(&storeEntry{}).Error
+			}); err != nil {
+				logrus.WithError(err).Warn("Database store: failed to delete expired entries")
+			}
+			if err := s.transaction(func(tx *gorm.DB) error {
+				return tx.Where("created_at < ?", time.Now().Add(-databaseStoreMessageRetention)).Delete(&storeMessage{}).Error
+			}); err != nil {
+				logrus.WithError(err).Warn("Database store: failed to delete old messages")
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// Close stops the background cleanup. The database connection is owned by the caller and stays open.
+func (s *DatabaseStore) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stopCh)
+		s.wg.Wait()
+	})
+	return nil
+}
+
+// Set stores a key-value pair, replacing whatever the key held.
+func (s *DatabaseStore) Set(key string, value []byte, ttl time.Duration) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("name = ?", key).Delete(&storeEntry{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&storeEntry{Name: key, Value: value, ExpiresAt: expiresAt(ttl)}).Error
+	})
+}
+
+// Get retrieves a value by its key.
+func (s *DatabaseStore) Get(key string) ([]byte, error) {
+	var found []storeEntry
+	if err := entries(s.db, key).Where("field = ?", "").Limit(1).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, ErrNotFound
+	}
+	return found[0].Value, nil
+}
+
+// Delete removes a value by its key.
+func (s *DatabaseStore) Delete(key string) error {
+	return s.Del(key)
+}
+
+// Del removes multiple values by their keys.
+func (s *DatabaseStore) Del(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.transaction(func(tx *gorm.DB) error {
+		return tx.Where("name IN ?", keys).Delete(&storeEntry{}).Error
+	})
+}
+
+// Exists checks if a key exists.
+func (s *DatabaseStore) Exists(key string) (bool, error) {
+	var count int64
+	err := entries(s.db, key).Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// SetNX sets a key-value pair if the key does not already exist. The primary key makes it atomic across nodes.
+func (s *DatabaseStore) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	var created bool
+	err := s.transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("name = ? AND field = ? AND expires_at <= ?", key, "", time.Now().UnixNano()).Delete(&storeEntry{}).Error; err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&storeEntry{Name: key, Value: value, ExpiresAt: expiresAt(ttl)})
+		created = result.RowsAffected == 1
+		return result.Error
+	})
+	return created, err
+}
+
+// --- LEASE operations ---
+
+// RenewLease resets the TTL of key if it still holds value.
+func (s *DatabaseStore) RenewLease(key string, value []byte, ttl time.Duration) (bool, error) {
+	var renewed bool
+	err := s.transaction(func(tx *gorm.DB) error {
+		result := entries(tx, key).Where("field = ? AND value = ?", "", value).Update("expires_at", expiresAt(ttl))
+		renewed = result.RowsAffected == 1
+		return result.Error
+	})
+	return renewed, err
+}
+
+// ReleaseLease deletes key if it still holds value.
+func (s *DatabaseStore) ReleaseLease(key string, value []byte) (bool, error) {
+	var released bool
+	err := s.transaction(func(tx *gorm.DB) error {
+		result := tx.Where("name = ? AND field = ? AND value = ? AND (expires_at IS NULL OR expires_at > ?)", key, "", value, time.Now().UnixNano()).
+			Delete(&storeEntry{})
+		released = result.RowsAffected == 1
+		return result.Error
+	})
+	return released, err
+}
+
+// --- HASH operations ---
+
+func (s *DatabaseStore) HSet(key string, values map[string]any) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return hset(tx, key, values)
+	})
+}
+
+func hset(tx *gorm.DB, key string, values map[string]any) error {
+	if len(values) == 0 {
+		return nil
+	}
+	rows := make([]storeEntry, 0, len(values))
+	for field, value := range values {
+		rows = append(rows, storeEntry{Name: key, Field: field, Value: []byte(fmt.Sprint(value))})
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}, {Name: "field"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value"}),
+	}).Create(&rows).Error
+}
+
+func (s *DatabaseStore) HGetAll(key string) (map[string]string, error) {
+	return hgetall(s.db, key)
+}
+
+func hgetall(tx *gorm.DB, key string) (map[string]string, error) {
+	var rows []storeEntry
+	if err := entries(tx, key).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	hash := make(map[string]string, len(rows))
+	for _, row := range rows {
+		hash[row.Field] = string(row.Value)
+	}
+	return hash, nil
+}
+
+func (s *DatabaseStore) HIncrBy(key, field string, incr int64) (int64, error) {
+	var newVal int64
+	err := s.transaction(func(tx *gorm.DB) error {
+		// 先确保字段存在再加锁读取，避免并发首次写入时主键冲突
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&storeEntry{Name: key, Field: field, Value: []byte("0")}).Error; err != nil {
+			return err
+		}
+		var row storeEntry
+		if err := s.forUpdate(tx).Where("name = ? AND field = ?", key, field).Take(&row).Error; err != nil {
+			return err
+		}
+		currentVal, _ := strconv.ParseInt(string(row.Value), 10, 64)
+		newVal = currentVal + incr
+		return tx.Model(&storeEntry{}).Where("name = ? AND field = ?", key, field).
+			Update("value", []byte(strconv.FormatInt(newVal, 10))).Error
+	})
+	return newVal, err
+}
+
+func (s *DatabaseStore) HDel(key string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return s.transaction(func(tx *gorm.DB) error {
+		return tx.Where("name = ? AND field IN ?", key, fields).Delete(&storeEntry{}).Error
+	})
+}
+
+// --- LIST operations ---
+
+func (s *DatabaseStore) LPush(key string, values ...any) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return s.lpush(tx, key, values...)
+	})
+}
+
+func (s *DatabaseStore) lpush(tx *gorm.DB, key string, values ...any) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if err := s.lockKey(tx, key); err != nil {
+		return err
+	}
+	head, err := listHead(tx, key)
+	if err != nil {
+		return err
+	}
+	rows := make([]storeEntry, len(values))
+	for i, value := range values {
+		rows[i] = storeEntry{Name: key, Field: uuid.NewString(), Value: []byte(fmt.Sprint(value)), Score: head - int64(i) - 1}
+	}
+	return tx.Create(&rows).Error
+}
+
+// listHead returns the position of the first element of a list, or 0 for an empty list.
+// Elements are always below 0, so the lock row at 0 does not change the result.
+// The caller must hold the list's lock row.
+func listHead(tx *gorm.DB, key string) (int64, error) {
+	var head sql.NullInt64
+	if err := tx.Model(&storeEntry{}).Where("name = ?", key).Select("MIN(score)").Row().Scan(&head); err != nil {
+		return 0, err
+	}
+	return head.Int64, nil
+}
+
+// LRem removes elements equal to value: all of them for count 0, the first count from the head
+// for count > 0 and from the tail for count < 0.
+func (s *DatabaseStore) LRem(key string, count int64, value any) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return lrem(tx, key, count, value)
+	})
+}
+
+func lrem(tx *gorm.DB, key string, count int64, value any) error {
+	strValue := []byte(fmt.Sprint(value))
+	if count == 0 {
+		return tx.Where("name = ? AND value = ?", key, strValue).Delete(&storeEntry{}).Error
+	}
+
+	order := "score ASC"
+	if count < 0 {
+		order, count = "score DESC", -count
+	}
+	var fields []string
+	if err := tx.Model(&storeEntry{}).Where("name = ? AND value = ?", key, strValue).
+		Order(order).Limit(int(count)).Pluck("field", &fields).Error; err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return tx.Where("name = ? AND field IN ?", key, fields).Delete(&storeEntry{}).Error
+}
+
+// Rotate moves the last element of a list to its head and returns it.
+func (s *DatabaseStore) Rotate(key string) (string, error) {
+	var item string
+	err := s.transaction(func(tx *gorm.DB) error {
+		if err := s.lockKey(tx, key); err != nil {
+			return err
+		}
+		var tail []storeEntry
+		if err := tx.Where("name = ? AND field <> ?", key, lockField).Order("score DESC").Limit(1).Find(&tail).Error; err != nil {
+			return err
+		}
+		if len(tail) == 0 {
+			return ErrNotFound
+		}
+		head, err := listHead(tx, key)
+		if err != nil {
+			return err
+		}
+		item = string(tail[0].Value)
+		return tx.Model(&storeEntry{}).Where("name = ? AND field = ?", key, tail[0].Field).Update("score", head-1).Error
+	})
+	return item, err
+}
+
+// LLen returns the length of a list.
+func (s *DatabaseStore) LLen(key string) (int64, error) {
+	var count int64
+	err := entries(s.db, key).Where("field <> ?", lockField).Count(&count).Error
+	return count, err
+}
+
+// --- SET operations ---
+
+// SAdd adds members to a set.
+func (s *DatabaseStore) SAdd(key string, members ...any) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return sadd(tx, key, members...)
+	})
+}
+
+func sadd(tx *gorm.DB, key string, members ...any) error {
+	if len(members) == 0 {
+		return nil
+	}
+	rows := make([]storeEntry, len(members))
+	for i, member := range members {
+		rows[i] = storeEntry{Name: key, Field: fmt.Sprint(member)}
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// SPopN removes and returns up to count members from a set. Members locked by a concurrent
+// SPopN on another node are skipped, so each member is returned to only one caller.
+func (s *DatabaseStore) SPopN(key string, count int64) ([]string, error) {
+	popped := []string{}
+	err := s.transaction(func(tx *gorm.DB) error {
+		if err := s.forUpdate(tx, clause.LockingOptionsSkipLocked).Model(&storeEntry{}).
+			Where("name = ?", key).Limit(int(count)).Pluck("field", &popped).Error; err != nil {
+			return err
+		}
+		if len(popped) == 0 {
+			return nil
+		}
+		return tx.Where("name = ? AND field IN ?", key, popped).Delete(&storeEntry{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return popped, nil
+}
+
+// SCard returns the number of members in a set.
+func (s *DatabaseStore) SCard(key string) (int64, error) {
+	var count int64
+	err := entries(s.db, key).Count(&count).Error
+	return count, err
+}
+
+// --- SLIDING WINDOW operations ---
+
+// WindowAdd records amount in the bucket of the current second. Each bucket expires once it leaves the window.
+func (s *DatabaseStore) WindowAdd(key string, amount int64, window time.Duration) error {
+	now := time.Now().Unix()
+	bucketExpiresAt := time.Unix(now+windowSeconds(window)+1, 0).UnixNano()
+	return s.transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "name"}, {Name: "field"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"score":      gorm.Expr("store_entries.score + ?", amount),
+				"expires_at": bucketExpiresAt,
+			}),
+		}).Create(&storeEntry{Name: key, Field: strconv.FormatInt(now, 10), Score: amount, ExpiresAt: &bucketExpiresAt}).Error
+	})
+}
+
+// WindowTryAdd records amount if the window is below limit. The current bucket is created first
+// and every bucket of the window is locked, so concurrent calls for the same key run one at a time.
+func (s *DatabaseStore) WindowTryAdd(key string, amount, limit int64, window time.Duration) (bool, time.Time, error) {
+	now := time.Now().Unix()
+	bucketField := strconv.FormatInt(now, 10)
+	bucketExpiresAt := time.Unix(now+windowSeconds(window)+1, 0).UnixNano()
+
+	var added bool
+	var oldest time.Time
+	err := s.transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&storeEntry{Name: key, Field: bucketField, ExpiresAt: &bucketExpiresAt}).Error; err != nil {
+			return err
+		}
+		var rows []storeEntry
+		if err := s.forUpdate(entries(tx, key)).Find(&rows).Error; err != nil {
+			return err
+		}
+
+		buckets := make(map[int64]int64, len(rows))
+		for _, row := range rows {
+			if bucket, err := strconv.ParseInt(row.Field, 10, 64); err == nil {
+				buckets[bucket] = row.Score
+			}
+		}
+		var used int64
+		used, oldest, _ = sumWindowBuckets(buckets, now-windowSeconds(window))
+		if used >= limit {
+			return nil
+		}
+
+		added = true
+		oldest = time.Unix(now, 0)
+		return tx.Model(&storeEntry{}).Where("name = ? AND field = ?", key, bucketField).
+			Updates(map[string]any{"score": gorm.Expr("score + ?", amount), "expires_at": bucketExpiresAt}).Error
+	})
+	return added, oldest, err
+}
+
+// WindowRemove subtracts amount from the bucket starting at bucket if it is still recorded. The row is
+// kept at zero rather than deleted, so a concurrent WindowTryAdd that created it still finds it.
+func (s *DatabaseStore) WindowRemove(key string, bucket time.Time, amount int64) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return entries(tx, key).Where("field = ?", strconv.FormatInt(bucket.Unix(), 10)).
+			Update("score", gorm.Expr("score - ?", amount)).Error
+	})
+}
+
+// WindowUsage returns the sum of the buckets within the window and the start of the oldest one.
+func (s *DatabaseStore) WindowUsage(key string, window time.Duration) (int64, time.Time, error) {
+	var rows []storeEntry
+	if err := entries(s.db, key).Find(&rows).Error; err != nil {
+		return 0, time.Time{}, err
+	}
+
+	buckets := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		bucket, err := strconv.ParseInt(row.Field, 10, 64)
+		if err != nil {
+			continue
+		}
+		buckets[bucket] = row.Score
+	}
+
+	return sumWindowBuckets(buckets, time.Now().Unix()-windowSeconds(window))
+}
+
+// --- SEMAPHORE operations ---
+
+// SemaphoreAcquire adds holder if fewer than limit unexpired holders hold the semaphore. The lock row is
+// locked first, so concurrent acquires of the same key run one at a time even with no holders.
+func (s *DatabaseStore) SemaphoreAcquire(key, holder string, limit int64, ttl time.Duration) (bool, error) {
+	var acquired bool
+	err := s.transaction(func(tx *gorm.DB) error {
+		if err := s.lockKey(tx, key); err != nil {
+			return err
+		}
+
+		var held int64
+		if err := entries(tx, key).Where("field <> ?", lockField).Count(&held).Error; err != nil {
+			return err
+		}
+		if held >= limit {
+			return nil
+		}
+
+		acquired = true
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}, {Name: "field"}},
+			DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+		}).Create(&storeEntry{Name: key, Field: holder, ExpiresAt: expiresAt(ttl)}).Error
+	})
+	return acquired, err
+}
+
+// SemaphoreRenew resets the expiry of holder if it still holds the semaphore.
+func (s *DatabaseStore) SemaphoreRenew(key, holder string, ttl time.Duration) (bool, error) {
+	var renewed bool
+	err := s.transaction(func(tx *gorm.DB) error {
+		result := entries(tx, key).Where("field = ?", holder).Update("expires_at", expiresAt(ttl))
+		renewed = result.RowsAffected == 1
+		return result.Error
+	})
+	return renewed, err
+}
+
+// SemaphoreRelease removes holder from the semaphore.
+func (s *DatabaseStore) SemaphoreRelease(key, holder string) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return tx.Where("name = ? AND field = ?", key, holder).Delete(&storeEntry{}).Error
+	})
+}
+
+// SemaphoreCount returns the number of unexpired holders of the semaphore.
+func (s *DatabaseStore) SemaphoreCount(key string) (int64, error) {
+	var count int64
+	err := entries(s.db, key).Where("field <> ?", lockField).Count(&count).Error
+	return count, err
+}
+
+// --- WEIGHTED SET operations ---
+
+// WSet adds member to a weighted set or updates its weight. A weight of 0 or less removes it.
+func (s *DatabaseStore) WSet(key, member string, weight int64) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return wset(tx, key, member, weight, false)
+	})
+}
+
+// WUpdate updates the weight of a member already in the set and ignores other members.
+func (s *DatabaseStore) WUpdate(key, member string, weight int64) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return wset(tx, key, member, weight, true)
+	})
+}
+
+func wset(tx *gorm.DB, key, member string, weight int64, onlyExisting bool) error {
+	switch {
+	case weight <= 0:
+		return tx.Where("name = ? AND field = ?", key, member).Delete(&storeEntry{}).Error
+	case onlyExisting:
+		return tx.Model(&storeEntry{}).Where("name = ? AND field = ?", key, member).Update("score", weight).Error
+	default:
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}, {Name: "field"}},
+			DoUpdates: clause.AssignmentColumns([]string{"score"}),
+		}).Create(&storeEntry{Name: key, Field: member, Score: weight}).Error
+	}
+}
+
+// weightedRandomSQL picks the member whose cumulative weight range contains a random fraction of the total.
+const weightedRandomSQL = `SELECT field FROM (
+	SELECT field, SUM(score) OVER (ORDER BY field) AS cumulative, SUM(score) OVER () AS total
+	FROM store_entries WHERE name = ?
+) weighted WHERE cumulative > total * ? ORDER BY cumulative LIMIT 1`
+
+// WRandom draws a member with probability proportional to its weight. The running sum is computed
+// by the database, so only the drawn member is transferred, but every row of the set is read: O(n).
+func (s *DatabaseStore) WRandom(key string) (string, error) {
+	var members []string
+	if err := s.db.Raw(weightedRandomSQL, key, rand.Float64()).Scan(&members).Error; err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
+		return "", ErrNotFound
+	}
+	return members[0], nil
+}
+
+// --- KEY STATE operations ---
+
+// ApplyKeyTransition applies a key state transition in a transaction holding the key's row locks.
+func (s *DatabaseStore) ApplyKeyTransition(t KeyTransition) (KeyTransitionResult, error) {
+	var result KeyTransitionResult
+	err := s.transaction(func(tx *gorm.DB) error {
+		var rows []storeEntry
+		if err := s.forUpdate(tx).Where("name = ?", t.HashKey).Find(&rows).Error; err != nil {
+			return err
+		}
+		hash := make(map[string]string, len(rows))
+		for _, row := range rows {
+			hash[row.Field] = string(row.Value)
+		}
+
+		var plan keyTransitionPlan
+		plan, result = planKeyTransition(t, hash)
+		if !result.Changed {
+			return nil
+		}
+
+		values := make(map[string]any, len(plan.set))
+		for field, value := range plan.set {
+			values[field] = value
+		}
+		if err := hset(tx, t.HashKey, values); err != nil {
+			return err
+		}
+		if len(plan.unset) > 0 {
+			if err := tx.Where("name = ? AND field IN ?", t.HashKey, plan.unset).Delete(&storeEntry{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if plan.join || plan.leave {
+			if err := lrem(tx, t.ListKey, 0, t.KeyID); err != nil {
+				return err
+			}
+		}
+		switch {
+		case plan.join:
+			if err := s.lpush(tx, t.ListKey, t.KeyID); err != nil {
+				return err
+			}
+			if err := wset(tx, t.IndexKey, t.KeyID, plan.weight, false); err != nil {
+				return err
+			}
+		case plan.leave:
+			if err := wset(tx, t.IndexKey, t.KeyID, 0, false); err != nil {
+				return err
+			}
+			if t.StatsKey != "" {
+				if err := tx.Where("name = ? AND field = ?", t.StatsKey, t.KeyID).Delete(&storeEntry{}).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return sadd(tx, t.PendingKey, t.KeyID)
+	})
+	if err != nil {
+		return KeyTransitionResult{}, err
+	}
+	return result, nil
+}
+
+// Clear clears all data.
+func (s *DatabaseStore) Clear() error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&storeEntry{}).Error
+	})
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// storeMessage is a published message in the polling table used by MySQL and SQLite.
+type storeMessage struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	Channel   string `gorm:"size:255;index"`
+	Payload   []byte
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (storeMessage) TableName() string {
+	return "store_messages"
+}
+
+// databaseSubscription implements the Subscription interface for the database store.
+type databaseSubscription struct {
+	msgChan chan *Message
+	cancel  context.CancelFunc
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newDatabaseSubscription(cancel context.CancelFunc) *databaseSubscription {
+	return &databaseSubscription{
+		msgChan: make(chan *Message, 10),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// Channel returns the message channel for the subscription.
+func (ds *databaseSubscription) Channel() <-chan *Message {
+	return ds.msgChan
+}
+
+// Close stops receiving and waits for the receiving goroutine to exit.
+func (ds *databaseSubscription) Close() error {
+	ds.once.Do(func() {
+		ds.cancel()
+		<-ds.done
+	})
+	return nil
+}
+
+// deliver sends msg to the subscriber and reports false once the subscription is closed.
+func (ds *databaseSubscription) deliver(ctx context.Context, msg *Message) bool {
+	select {
+	case ds.msgChan <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// finish is deferred by the receiving goroutine.
+func (ds *databaseSubscription) finish() {
+	close(ds.msgChan)
+	close(ds.done)
+}
+
+// Publish sends a message to a given channel.
+func (s *DatabaseStore) Publish(channel string, message []byte) error {
+	if s.dialect == "postgres" {
+		// NOTIFY 的消息上限约 8000 字节，store 中的消息都是简短的通知
+		return s.db.Exec("SELECT pg_notify(?, ?)", channel, string(message)).Error
+	}
+	return s.transaction(func(tx *gorm.DB) error {
+		return tx.Create(&storeMessage{Channel: channel, Payload: message}).Error
+	})
+}
+
+// Subscribe listens for messages on a given channel. Only messages published after it returns are received.
+func (s *DatabaseStore) Subscribe(channel string) (Subscription, error) {
+	if s.dialect == "postgres" {
+		return s.listen(channel)
+	}
+	return s.poll(channel)
+}
+
+// listen holds a dedicated connection that LISTENs on the channel. The connection is discarded
+// rather than returned to the pool when the subscription closes.
+func (s *DatabaseStore) listen(channel string) (Subscription, error) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to get connection for channel %s: %w", channel, err)
+	}
+
+	sub := newDatabaseSubscription(cancel)
+	ready := make(chan error, 1)
+	go func() {
+		defer sub.finish()
+		defer conn.Close()
+
+		err := conn.Raw(func(driverConn any) error {
+			stdConn, ok := driverConn.(*stdlib.Conn)
+			if !ok {
+				return fmt.Errorf("unexpected postgres driver connection %T", driverConn)
+			}
+			pgConn := stdConn.Conn()
+			if _, err := pgConn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+				return err
+			}
+			ready <- nil
+
+			for {
+				notification, err := pgConn.WaitForNotification(ctx)
+				if err != nil {
+					return errors.Join(driver.ErrBadConn, err)
+				}
+				if !sub.deliver(ctx, &Message{Channel: channel, Payload: []byte(notification.Payload)}) {
+					return driver.ErrBadConn
+				}
+			}
+		})
+
+		select {
+		case ready <- err:
+		default:
+		}
+		if err != nil && ctx.Err() == nil {
+			logrus.WithError(err).WithField("channel", channel).Error("Database store: postgres listener stopped")
+		}
+	}()
+
+	if err := <-ready; err != nil {
+		cancel()
+		<-sub.done
+		return nil, fmt.Errorf("failed to subscribe to channel %s: %w", channel, err)
+	}
+	return sub, nil
+}
+
+// poll reads new rows of the message table for the channel every databaseStorePollInterval.
+// Rows below the cursor created within databaseStorePollWindow are read again, so a message that
+// commits after one with a higher ID is still delivered. Delivered IDs are skipped.
+func (s *DatabaseStore) poll(channel string) (Subscription, error) {
+	var lastID uint64
+	if err := s.db.Model(&storeMessage{}).Select("COALESCE(MAX(id), 0)").Row().Scan(&lastID); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to channel %s: %w", channel, err)
+	}
+	// 订阅前已提交的消息不再投递
+	var existing []storeMessage
+	if err := s.db.Select("id", "created_at").
+		Where("channel = ? AND created_at >= ?", channel, time.Now().Add(-databaseStorePollWindow)).
+		Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to subscribe to channel %s: %w", channel, err)
+	}
+	seen := make(map[uint64]time.Time, len(existing))
+	for _, msg := range existing {
+		seen[msg.ID] = msg.CreatedAt
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := newDatabaseSubscription(cancel)
+	go func() {
+		defer sub.finish()
+
+		ticker := time.NewTicker(databaseStorePollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			since := time.Now().Add(-databaseStorePollWindow)
+			var messages []storeMessage
+			if err := s.db.WithContext(ctx).
+				Where("channel = ? AND (id > ? OR created_at >= ?)", channel, lastID, since).
+				Order("id").Find(&messages).Error; err != nil {
+				if ctx.Err() == nil {
+					logrus.WithError(err).WithField("channel", channel).Warn("Database store: failed to poll messages")
+				}
+				continue
+			}
+			for _, msg := range messages {
+				if _, ok := seen[msg.ID]; ok {
+					continue
+				}
+				seen[msg.ID] = msg.CreatedAt
+				lastID = max(lastID, msg.ID)
+				if !sub.deliver(ctx, &Message{Channel: channel, Payload: msg.Payload}) {
+					return
+				}
+			}
+			// 早于窗口且不大于游标的消息不会再被读到
+			for id, createdAt := range seen {
+				if createdAt.Before(since) {
+					delete(seen, id)
+				}
+			}
+		}
+	}()
+	return sub, nil
+}
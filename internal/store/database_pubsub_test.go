@@ -0,0 +1,48 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+// receive 等待订阅收到下一条消息
+func receive(t *testing.T, sub Subscription) string {
+	t.Helper()
+	select {
+	case msg := <-sub.Channel():
+		return string(msg.Payload)
+	case <-time.After(3 * databaseStorePollInterval):
+		t.Fatal("no message received")
+		return ""
+	}
+}
+
+func TestDatabaseStorePollOutOfOrderCommit(t *testing.T) {
+	s := testStores(t)["database"](t).(*DatabaseStore)
+	must(t, s.Publish("c", []byte("before")))
+
+	sub, err := s.Subscribe("c")
+	must(t, err)
+	defer sub.Close()
+
+	must(t, s.db.Create(&storeMessage{ID: 10, Channel: "c", Payload: []byte("first")}).Error)
+	if got := receive(t, sub); got != "first" {
+		t.Fatalf("received %q, want first", got)
+	}
+
+	// ID 较小的消息晚于游标提交，仍然投递，已投递和订阅前的消息不重复投递
+	must(t, s.db.Create(&storeMessage{ID: 5, Channel: "c", Payload: []byte("late")}).Error)
+	if got := receive(t, sub); got != "late" {
+		t.Fatalf("received %q, want late", got)
+	}
+	must(t, s.Publish("c", []byte("next")))
+	if got := receive(t, sub); got != "next" {
+		t.Fatalf("received %q, want next", got)
+	}
+
+	select {
+	case msg := <-sub.Channel():
+		t.Fatalf("unexpected message %q", msg.Payload)
+	case <-time.After(2 * databaseStorePollInterval):
+	}
+}
@@ -7,11 +7,28 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 // NewStore creates a new store based on the application configuration.
-func NewStore(cfg types.ConfigManager) (Store, error) {
+// STORE_TYPE selects the backend explicitly; when it is empty Redis is used if REDIS_DSN is set,
+// otherwise the in-memory store.
+func NewStore(cfg types.ConfigManager, db *gorm.DB) (Store, error) {
 	redisDSN := cfg.GetRedisDSN()
+
+	switch cfg.GetStoreType() {
+	case "database":
+		s, err := NewDatabaseStore(db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create database store: %w", err)
+		}
+		logrus.Infof("Using database store (%s).", db.Dialector.Name())
+		return s, nil
+	case "memory":
+		logrus.Info("Using in-memory store.")
+		return NewMemoryStore(), nil
+	}
+
 	if redisDSN != "" {
 		opts, err := redis.ParseURL(redisDSN)
 		if err != nil {
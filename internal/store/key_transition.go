@@ -0,0 +1,164 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// defaultKeyWeight is the weight used for the weight index when a key hash has no weight.
+const defaultKeyWeight = 500
+
+// KeyTransitionKind selects what ApplyKeyTransition does to a key.
+type KeyTransitionKind string
+
+const (
+	// KeyTransitionSuccess resets the failure and cooldown counts. A key whose status is one of
+	// RestoreStatuses is restored to ActiveStatus and joins the active list and the weight index;
+	// keys with any other status are left untouched.
+	KeyTransitionSuccess KeyTransitionKind = "success"
+	// KeyTransitionFailure increments the failure count. The key is moved to DisabledStatus and leaves
+	// the active list and the weight index when ForceDisable is set or the count reaches Threshold.
+	KeyTransitionFailure KeyTransitionKind = "failure"
+	// KeyTransitionMove changes the key's status to ToStatus. The key joins the active list and the
+	// weight index when ToStatus is ActiveStatus and leaves them otherwise.
+	KeyTransitionMove KeyTransitionKind = "move"
+)
+
+// KeyTransition describes an atomic change to a key's state in the store. The key's details hash,
+// its group's active list, weight index and selection stats, and the set of keys waiting to be
+// persisted are updated together, so concurrent changes on several nodes cannot double count
+// failures or leave the list and the hash out of sync.
+//
+// The store does not know the meaning of key statuses: the caller passes the active status and
+// the statuses each transition applies to.
+type KeyTransition struct {
+	HashKey    string // key details hash
+	ListKey    string // active key list of the key's group
+	IndexKey   string // weight index of the key's group
+	PendingKey string // set of key IDs whose state still has to be written to the database
+	// StatsKey is an optional hash of per-key selection stats of the key's group. The KeyID field is
+	// deleted when the key leaves the active list.
+	StatsKey string
+	KeyID    string
+
+	Kind KeyTransitionKind
+	// ActiveStatus is the status of the keys in the active list and the weight index.
+	ActiveStatus string
+	// SkipStatuses are the statuses of keys the transition leaves untouched.
+	SkipStatuses []string
+
+	// RestoreStatuses are the statuses a success restores to ActiveStatus.
+	RestoreStatuses []string
+
+	// DisabledStatus is the status a failure moves the key to.
+	DisabledStatus string
+	// Threshold disables the key when its failure count reaches it. 0 never disables.
+	Threshold    int64
+	ForceDisable bool
+
+	// FromStatuses limits a move to keys with one of these statuses. Empty allows any status.
+	// A key that already has ToStatus is never moved.
+	FromStatuses []string
+	ToStatus     string
+	// DueField names a hash field holding a Unix time in seconds. The move applies only once that
+	// time is not after DueAt; an unset or zero field counts as due.
+	DueField string
+	DueAt    int64
+	// Set and Unset are hash fields written or deleted together with the new status.
+	Set   map[string]any
+	Unset []string
+}
+
+// KeyTransitionResult reports what ApplyKeyTransition changed.
+type KeyTransitionResult struct {
+	Changed      bool  // the key state changed and the key was added to the pending set
+	FailureCount int64 // failure count after the transition
+	Disabled     bool  // the failure disabled the key
+	Restored     bool  // the key joined the active list and the weight index
+}
+
+// keyTransitionPlan is the change a transition makes to a key whose details hash has been read.
+// It is shared by the stores that apply transitions in Go.
+type keyTransitionPlan struct {
+	set    map[string]string
+	unset  []string
+	join   bool // push the key to the head of the active list and add it to the weight index
+	leave  bool // remove the key from the active list, the weight index and the selection stats
+	weight int64
+}
+
+// planKeyTransition decides how t changes the key with the given details hash. The returned
+// result has Changed false when the transition does not apply.
+func planKeyTransition(t KeyTransition, hash map[string]string) (keyTransitionPlan, KeyTransitionResult) {
+	var plan keyTransitionPlan
+	var result KeyTransitionResult
+
+	status := hash["status"]
+	if status == "" || slices.Contains(t.SkipStatuses, status) {
+		return plan, result
+	}
+
+	failureCount, _ := strconv.ParseInt(hash["failure_count"], 10, 64)
+	plan.set = make(map[string]string)
+	switch t.Kind {
+	case KeyTransitionSuccess:
+		cooldownCount, _ := strconv.ParseInt(hash["cooldown_count"], 10, 64)
+		if status != t.ActiveStatus && !slices.Contains(t.RestoreStatuses, status) {
+			return plan, result
+		}
+		if status == t.ActiveStatus && failureCount == 0 && cooldownCount == 0 {
+			return plan, result
+		}
+		failureCount = 0
+		plan.set["failure_count"] = "0"
+		plan.set["cooldown_count"] = "0"
+		if status != t.ActiveStatus {
+			plan.set["status"] = t.ActiveStatus
+			plan.join = true
+		}
+
+	case KeyTransitionFailure:
+		failureCount++
+		plan.set["failure_count"] = strconv.FormatInt(failureCount, 10)
+		if t.ForceDisable || (t.Threshold > 0 && failureCount >= t.Threshold) {
+			plan.set["status"] = t.DisabledStatus
+			plan.leave = true
+			result.Disabled = true
+		}
+
+	case KeyTransitionMove:
+		if status == t.ToStatus || (len(t.FromStatuses) > 0 && !slices.Contains(t.FromStatuses, status)) {
+			return plan, result
+		}
+		if t.DueField != "" {
+			if due, _ := strconv.ParseInt(hash[t.DueField], 10, 64); due > t.DueAt {
+				return plan, result
+			}
+		}
+		for field, value := range t.Set {
+			plan.set[field] = fmt.Sprint(value)
+		}
+		plan.set["status"] = t.ToStatus
+		plan.unset = t.Unset
+		if t.ToStatus == t.ActiveStatus {
+			plan.join = true
+		} else {
+			plan.leave = true
+		}
+
+	default:
+		return plan, result
+	}
+
+	if plan.join {
+		plan.weight, _ = strconv.ParseInt(hash["weight"], 10, 64)
+		if plan.weight <= 0 {
+			plan.weight = defaultKeyWeight
+		}
+		result.Restored = true
+	}
+	result.Changed = true
+	result.FailureCount = failureCount
+	return plan, result
+}
@@ -2,6 +2,8 @@ package store
 
 import (
 	"fmt"
+	"math/rand"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
@@ -146,6 +148,45 @@ func (s *MemoryStore) SetNX(key string, value []byte, ttl time.Duration) (bool,
 	return true, nil
 }
 
+// --- LEASE operations ---
+
+// RenewLease resets the TTL of key if it still holds value.
+func (s *MemoryStore) RenewLease(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.holdsLocked(key, value) {
+		return false, nil
+	}
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().UnixNano() + ttl.Nanoseconds()
+	}
+	s.data[key] = memoryStoreItem{value: value, expiresAt: expiresAt}
+	return true, nil
+}
+
+// ReleaseLease deletes key if it still holds value.
+func (s *MemoryStore) ReleaseLease(key string, value []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.holdsLocked(key, value) {
+		return false, nil
+	}
+	delete(s.data, key)
+	return true, nil
+}
+
+// holdsLocked reports whether key holds an unexpired value equal to value. The caller must hold s.mu.
+func (s *MemoryStore) holdsLocked(key string, value []byte) bool {
+	item, ok := s.data[key].(memoryStoreItem)
+	if !ok || (item.expiresAt > 0 && time.Now().UnixNano() > item.expiresAt) {
+		return false
+	}
+	return string(item.value) == string(value)
+}
+
 // --- HASH operations ---
 
 func (s *MemoryStore) HSet(key string, values map[string]any) error {
@@ -217,6 +258,28 @@ func (s *MemoryStore) HIncrBy(key, field string, incr int64) (int64, error) {
 	return newVal, nil
 }
 
+func (s *MemoryStore) HDel(key string, fields ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rawHash, exists := s.data[key]
+	if !exists {
+		return nil
+	}
+	hash, ok := rawHash.(map[string]string)
+	if !ok {
+		return fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	for _, field := range fields {
+		delete(hash, field)
+	}
+	if len(hash) == 0 {
+		delete(s.data, key)
+	}
+	return nil
+}
+
 // --- LIST operations ---
 
 func (s *MemoryStore) LPush(key string, values ...any) error {
@@ -235,9 +298,10 @@ func (s *MemoryStore) LPush(key string, values ...any) error {
 		}
 	}
 
+	// 与 Redis 的 LPUSH 一致，逐个插入到头部，最后一个值位于列表头
 	strValues := make([]string, len(values))
 	for i, v := range values {
-		strValues[i] = fmt.Sprint(v)
+		strValues[len(values)-1-i] = fmt.Sprint(v)
 	}
 
 	s.data[key] = append(strValues, list...) // Prepend
@@ -377,6 +441,384 @@ func (s *MemoryStore) SPopN(key string, count int64) ([]string, error) {
 	return popped, nil
 }
 
+// SCard returns the number of members in a set.
+func (s *MemoryStore) SCard(key string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rawSet, exists := s.data[key]
+	if !exists {
+		return 0, nil
+	}
+
+	set, ok := rawSet.(map[string]struct{})
+	if !ok {
+		return 0, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	return int64(len(set)), nil
+}
+
+// --- SLIDING WINDOW operations ---
+
+// windowSeconds converts a window duration to whole seconds, with a minimum of one second.
+func windowSeconds(window time.Duration) int64 {
+	return max(int64(window/time.Second), 1)
+}
+
+// WindowAdd records amount in the bucket of the current second and drops expired buckets.
+func (s *MemoryStore) WindowAdd(key string, amount int64, window time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets, err := s.windowBucketsLocked(key, window)
+	if err != nil {
+		return err
+	}
+	buckets[time.Now().Unix()] += amount
+	return nil
+}
+
+// WindowTryAdd records amount if the window is below limit, under the store lock.
+func (s *MemoryStore) WindowTryAdd(key string, amount, limit int64, window time.Duration) (bool, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets, err := s.windowBucketsLocked(key, window)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	now := time.Now().Unix()
+	used, oldest, _ := sumWindowBuckets(buckets, now-windowSeconds(window))
+	if used >= limit {
+		return false, oldest, nil
+	}
+	buckets[now] += amount
+	return true, time.Unix(now, 0), nil
+}
+
+// WindowRemove subtracts amount from the bucket starting at bucket if it is still recorded.
+func (s *MemoryStore) WindowRemove(key string, bucket time.Time, amount int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets, err := lockedValue[map[int64]int64](s, key)
+	if err != nil || buckets == nil {
+		return err
+	}
+	second := bucket.Unix()
+	if _, ok := buckets[second]; !ok {
+		return nil
+	}
+	buckets[second] -= amount
+	if buckets[second] <= 0 {
+		delete(buckets, second)
+	}
+	return nil
+}
+
+// windowBucketsLocked returns the buckets of a sliding window, creating it if needed and dropping
+// the buckets that left the window. The caller must hold s.mu.
+func (s *MemoryStore) windowBucketsLocked(key string, window time.Duration) (map[int64]int64, error) {
+	rawBuckets, exists := s.data[key]
+	if !exists {
+		buckets := make(map[int64]int64)
+		s.data[key] = buckets
+		return buckets, nil
+	}
+	buckets, ok := rawBuckets.(map[int64]int64)
+	if !ok {
+		return nil, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	cutoff := time.Now().Unix() - windowSeconds(window)
+	for bucket := range buckets {
+		if bucket <= cutoff {
+			delete(buckets, bucket)
+		}
+	}
+	return buckets, nil
+}
+
+// WindowUsage returns the sum of the buckets within the window and the start of the oldest one.
+func (s *MemoryStore) WindowUsage(key string, window time.Duration) (int64, time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rawBuckets, exists := s.data[key]
+	if !exists {
+		return 0, time.Time{}, nil
+	}
+
+	buckets, ok := rawBuckets.(map[int64]int64)
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	return sumWindowBuckets(buckets, time.Now().Unix()-windowSeconds(window))
+}
+
+// sumWindowBuckets sums the buckets newer than cutoff and finds the oldest of them.
+func sumWindowBuckets(buckets map[int64]int64, cutoff int64) (int64, time.Time, error) {
+	var total int64
+	var oldest int64
+	for bucket, amount := range buckets {
+		if bucket <= cutoff {
+			continue
+		}
+		total += amount
+		if oldest == 0 || bucket < oldest {
+			oldest = bucket
+		}
+	}
+	if oldest == 0 {
+		return total, time.Time{}, nil
+	}
+	return total, time.Unix(oldest, 0), nil
+}
+
+// --- SEMAPHORE operations ---
+
+// SemaphoreAcquire adds holder if fewer than limit unexpired holders hold the semaphore, under the store lock.
+// A semaphore is a map from holder to its Unix-nano expiry.
+func (s *MemoryStore) SemaphoreAcquire(key, holder string, limit int64, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	holders, err := s.semaphoreLocked(key)
+	if err != nil {
+		return false, err
+	}
+	if int64(len(holders)) >= limit {
+		return false, nil
+	}
+	holders[holder] = time.Now().Add(ttl).UnixNano()
+	return true, nil
+}
+
+// SemaphoreRenew resets the expiry of holder if it still holds the semaphore.
+func (s *MemoryStore) SemaphoreRenew(key, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	holders, err := s.semaphoreLocked(key)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := holders[holder]; !ok {
+		return false, nil
+	}
+	holders[holder] = time.Now().Add(ttl).UnixNano()
+	return true, nil
+}
+
+// SemaphoreRelease removes holder from the semaphore.
+func (s *MemoryStore) SemaphoreRelease(key, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	holders, err := lockedValue[map[string]int64](s, key)
+	if err != nil || holders == nil {
+		return err
+	}
+	delete(holders, holder)
+	if len(holders) == 0 {
+		delete(s.data, key)
+	}
+	return nil
+}
+
+// SemaphoreCount returns the number of unexpired holders of the semaphore.
+func (s *MemoryStore) SemaphoreCount(key string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	holders, err := lockedValue[map[string]int64](s, key)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UnixNano()
+	var count int64
+	for _, expiresAt := range holders {
+		if expiresAt > now {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// semaphoreLocked returns the holders of a semaphore, creating it if needed and dropping
+// the expired holders. The caller must hold s.mu.
+func (s *MemoryStore) semaphoreLocked(key string) (map[string]int64, error) {
+	holders, err := lockedValue[map[string]int64](s, key)
+	if err != nil {
+		return nil, err
+	}
+	if holders == nil {
+		holders = make(map[string]int64)
+		s.data[key] = holders
+	}
+	now := time.Now().UnixNano()
+	for holder, expiresAt := range holders {
+		if expiresAt <= now {
+			delete(holders, holder)
+		}
+	}
+	return holders, nil
+}
+
+// --- WEIGHTED SET operations ---
+
+// WSet adds member to a weighted set or updates its weight. A weight of 0 or less removes it.
+func (s *MemoryStore) WSet(key, member string, weight int64) error {
+	return s.setWeight(key, member, weight, false)
+}
+
+// WUpdate updates the weight of a member already in the set and ignores other members.
+func (s *MemoryStore) WUpdate(key, member string, weight int64) error {
+	return s.setWeight(key, member, weight, true)
+}
+
+func (s *MemoryStore) setWeight(key, member string, weight int64, onlyExisting bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ws *weightedSet
+	rawSet, exists := s.data[key]
+	if !exists {
+		if weight <= 0 || onlyExisting {
+			return nil
+		}
+		ws = newWeightedSet()
+		s.data[key] = ws
+	} else {
+		var ok bool
+		ws, ok = rawSet.(*weightedSet)
+		if !ok {
+			return fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+		}
+	}
+
+	ws.set(member, weight, onlyExisting)
+	if len(ws.members) == 0 {
+		delete(s.data, key)
+	}
+	return nil
+}
+
+// WRandom draws a member with probability proportional to its weight.
+func (s *MemoryStore) WRandom(key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rawSet, exists := s.data[key]
+	if !exists {
+		return "", ErrNotFound
+	}
+	ws, ok := rawSet.(*weightedSet)
+	if !ok {
+		return "", fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	total := ws.total()
+	if total <= 0 {
+		return "", ErrNotFound
+	}
+	return ws.find(rand.Int63n(total)), nil
+}
+
+// --- KEY STATE operations ---
+
+// ApplyKeyTransition applies a key state transition while holding the store lock.
+func (s *MemoryStore) ApplyKeyTransition(t KeyTransition) (KeyTransitionResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := lockedValue[map[string]string](s, t.HashKey)
+	if err != nil || hash == nil {
+		return KeyTransitionResult{}, err
+	}
+	list, err := lockedValue[[]string](s, t.ListKey)
+	if err != nil {
+		return KeyTransitionResult{}, err
+	}
+	ws, err := lockedValue[*weightedSet](s, t.IndexKey)
+	if err != nil {
+		return KeyTransitionResult{}, err
+	}
+	pending, err := lockedValue[map[string]struct{}](s, t.PendingKey)
+	if err != nil {
+		return KeyTransitionResult{}, err
+	}
+	var stats map[string]string
+	if t.StatsKey != "" {
+		if stats, err = lockedValue[map[string]string](s, t.StatsKey); err != nil {
+			return KeyTransitionResult{}, err
+		}
+	}
+
+	plan, result := planKeyTransition(t, hash)
+	if !result.Changed {
+		return result, nil
+	}
+
+	for field, value := range plan.set {
+		hash[field] = value
+	}
+	for _, field := range plan.unset {
+		delete(hash, field)
+	}
+
+	withoutKey := slices.DeleteFunc(slices.Clone(list), func(item string) bool { return item == t.KeyID })
+	switch {
+	case plan.join:
+		s.data[t.ListKey] = append([]string{t.KeyID}, withoutKey...)
+		if ws == nil {
+			ws = newWeightedSet()
+			s.data[t.IndexKey] = ws
+		}
+		ws.set(t.KeyID, plan.weight, false)
+	case plan.leave:
+		if list != nil {
+			s.data[t.ListKey] = withoutKey
+		}
+		if ws != nil {
+			ws.set(t.KeyID, 0, false)
+			if len(ws.members) == 0 {
+				delete(s.data, t.IndexKey)
+			}
+		}
+		if stats != nil {
+			delete(stats, t.KeyID)
+			if len(stats) == 0 {
+				delete(s.data, t.StatsKey)
+			}
+		}
+	}
+
+	if pending == nil {
+		pending = make(map[string]struct{})
+		s.data[t.PendingKey] = pending
+	}
+	pending[t.KeyID] = struct{}{}
+	return result, nil
+}
+
+// lockedValue returns the value of key as T, or the zero value if the key does not exist.
+// The caller must hold s.mu.
+func lockedValue[T any](s *MemoryStore, key string) (T, error) {
+	var zero T
+	raw, exists := s.data[key]
+	if !exists {
+		return zero, nil
+	}
+	value, ok := raw.(T)
+	if !ok {
+		return zero, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+	return value, nil
+}
+
 // --- Pub/Sub operations ---
 
 // memorySubscription implements the Subscription interface for the in-memory store.
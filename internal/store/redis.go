@@ -2,8 +2,11 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
+	"strconv"
 	"sync"
 	"time"
 
@@ -81,6 +84,34 @@ func (s *RedisStore) SetNX(key string, value []byte, ttl time.Duration) (bool, e
 	return s.client.SetNX(context.Background(), s.prefixKey(key), value, ttl).Result()
 }
 
+// --- LEASE operations ---
+
+var renewLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RenewLease resets the TTL of key if it still holds value.
+func (s *RedisStore) RenewLease(key string, value []byte, ttl time.Duration) (bool, error) {
+	renewed, err := renewLeaseScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, value, ttl.Milliseconds()).Int()
+	return renewed == 1, err
+}
+
+// ReleaseLease deletes key if it still holds value.
+func (s *RedisStore) ReleaseLease(key string, value []byte) (bool, error) {
+	released, err := releaseLeaseScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, value).Int()
+	return released == 1, err
+}
+
 // Close closes the Redis client connection.
 func (s *RedisStore) Close() error {
 	return s.client.Close()
@@ -100,6 +131,13 @@ func (s *RedisStore) HIncrBy(key, field string, incr int64) (int64, error) {
 	return s.client.HIncrBy(context.Background(), s.prefixKey(key), field, incr).Result()
 }
 
+func (s *RedisStore) HDel(key string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return s.client.HDel(context.Background(), s.prefixKey(key), fields...).Err()
+}
+
 // --- LIST operations ---
 
 func (s *RedisStore) LPush(key string, values ...any) error {
@@ -137,6 +175,452 @@ func (s *RedisStore) SPopN(key string, count int64) ([]string, error) {
 	return s.client.SPopN(context.Background(), s.prefixKey(key), count).Result()
 }
 
+func (s *RedisStore) SCard(key string) (int64, error) {
+	return s.client.SCard(context.Background(), s.prefixKey(key)).Result()
+}
+
+// --- SLIDING WINDOW operations ---
+
+// windowAddScript increments the current second's bucket, drops expired buckets and refreshes the TTL.
+var windowAddScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[3])
+for _, field in ipairs(redis.call('HKEYS', KEYS[1])) do
+	if tonumber(field) <= now - window then
+		redis.call('HDEL', KEYS[1], field)
+	end
+end
+redis.call('EXPIRE', KEYS[1], window + 1)
+return 1
+`)
+
+// WindowAdd records amount in the bucket of the current second.
+func (s *RedisStore) WindowAdd(key string, amount int64, window time.Duration) error {
+	now := time.Now().Unix()
+	return windowAddScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, now, windowSeconds(window), amount).Err()
+}
+
+// windowTryAddScript drops expired buckets and sums the rest. Below the limit it increments the
+// current second's bucket, refreshes the TTL and returns {1, bucket}; otherwise it returns {0, oldest bucket}.
+var windowTryAddScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[4])
+local used, oldest = 0, 0
+local fields = redis.call('HGETALL', KEYS[1])
+for i = 1, #fields, 2 do
+	local bucket = tonumber(fields[i])
+	if bucket then
+		if bucket <= now - window then
+			redis.call('HDEL', KEYS[1], fields[i])
+		else
+			used = used + (tonumber(fields[i + 1]) or 0)
+			if oldest == 0 or bucket < oldest then
+				oldest = bucket
+			end
+		end
+	end
+end
+if used >= limit then
+	return {0, oldest}
+end
+redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[3])
+redis.call('EXPIRE', KEYS[1], window + 1)
+return {1, now}
+`)
+
+// WindowTryAdd checks the window and records amount in a single script.
+func (s *RedisStore) WindowTryAdd(key string, amount, limit int64, window time.Duration) (bool, time.Time, error) {
+	now := time.Now().Unix()
+	values, err := windowTryAddScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, now, windowSeconds(window), amount, limit).Int64Slice()
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if values[1] == 0 {
+		return values[0] == 1, time.Time{}, nil
+	}
+	return values[0] == 1, time.Unix(values[1], 0), nil
+}
+
+// windowRemoveScript subtracts from a bucket only if it still exists and deletes it once it reaches zero.
+var windowRemoveScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 and redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2]) <= 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// WindowRemove subtracts amount from the bucket starting at bucket if it is still recorded.
+func (s *RedisStore) WindowRemove(key string, bucket time.Time, amount int64) error {
+	return windowRemoveScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, bucket.Unix(), -amount).Err()
+}
+
+// WindowUsage returns the sum of the buckets within the window and the start of the oldest one.
+func (s *RedisStore) WindowUsage(key string, window time.Duration) (int64, time.Time, error) {
+	fields, err := s.client.HGetAll(context.Background(), s.prefixKey(key)).Result()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	buckets := make(map[int64]int64, len(fields))
+	for field, value := range fields {
+		bucket, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		amount, _ := strconv.ParseInt(value, 10, 64)
+		buckets[bucket] = amount
+	}
+
+	return sumWindowBuckets(buckets, time.Now().Unix()-windowSeconds(window))
+}
+
+// --- SEMAPHORE operations ---
+
+// semaphoreAcquireScript keeps the holders in a sorted set scored by their expiry in milliseconds. It drops
+// the expired holders and adds the holder below the limit. The key lives as long as its latest holder.
+var semaphoreAcquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[4])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now + ttl, ARGV[2])
+if redis.call('PTTL', KEYS[1]) < ttl then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// semaphoreRenewScript resets the expiry of a holder that has not expired yet.
+var semaphoreRenewScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[3])
+local expiresAt = redis.call('ZSCORE', KEYS[1], ARGV[2])
+if not expiresAt or tonumber(expiresAt) <= now then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now + ttl, ARGV[2])
+if redis.call('PTTL', KEYS[1]) < ttl then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// SemaphoreAcquire adds holder if fewer than limit unexpired holders hold the semaphore, in a single script.
+func (s *RedisStore) SemaphoreAcquire(key, holder string, limit int64, ttl time.Duration) (bool, error) {
+	acquired, err := semaphoreAcquireScript.Run(context.Background(), s.client, []string{s.prefixKey(key)},
+		time.Now().UnixMilli(), holder, limit, ttl.Milliseconds()).Int()
+	return acquired == 1, err
+}
+
+// SemaphoreRenew resets the expiry of holder if it still holds the semaphore.
+func (s *RedisStore) SemaphoreRenew(key, holder string, ttl time.Duration) (bool, error) {
+	renewed, err := semaphoreRenewScript.Run(context.Background(), s.client, []string{s.prefixKey(key)},
+		time.Now().UnixMilli(), holder, ttl.Milliseconds()).Int()
+	return renewed == 1, err
+}
+
+// SemaphoreRelease removes holder from the semaphore.
+func (s *RedisStore) SemaphoreRelease(key, holder string) error {
+	return s.client.ZRem(context.Background(), s.prefixKey(key), holder).Err()
+}
+
+// SemaphoreCount returns the number of unexpired holders of the semaphore.
+func (s *RedisStore) SemaphoreCount(key string) (int64, error) {
+	return s.client.ZCount(context.Background(), s.prefixKey(key), "("+strconv.FormatInt(time.Now().UnixMilli(), 10), "+inf").Result()
+}
+
+// --- WEIGHTED SET operations ---
+
+// A weighted set is a hash holding a Fenwick tree over the member weights: "n" is the number of
+// members, and for each 1-based position "p:<pos>" is the member, "w:<pos>" its weight and
+// "t:<pos>" the tree's partial sum. "m:<member>" maps a member back to its position.
+// A removed member is replaced by the last one so the tree stays dense.
+
+// weightedSetLua defines wset(key, member, weight, onlyExisting), which adds, updates or removes
+// a member. It is shared by the scripts that maintain a weighted set.
+const weightedSetLua = `
+local function wset(key, member, weight, onlyExisting)
+	local n = tonumber(redis.call('HGET', key, 'n') or '0')
+
+	local function add(pos, delta)
+		while pos <= n do
+			redis.call('HINCRBY', key, 't:' .. pos, delta)
+			pos = pos + bit.band(pos, -pos)
+		end
+	end
+
+	local function prefix(pos)
+		local sum = 0
+		while pos > 0 do
+			sum = sum + tonumber(redis.call('HGET', key, 't:' .. pos) or '0')
+			pos = pos - bit.band(pos, -pos)
+		end
+		return sum
+	end
+
+	local pos = tonumber(redis.call('HGET', key, 'm:' .. member) or '0')
+	if weight <= 0 then
+		if pos == 0 then
+			return 0
+		end
+		if pos ~= n then
+			local lastMember = redis.call('HGET', key, 'p:' .. n)
+			local lastWeight = tonumber(redis.call('HGET', key, 'w:' .. n))
+			add(pos, lastWeight - tonumber(redis.call('HGET', key, 'w:' .. pos)))
+			redis.call('HSET', key, 'p:' .. pos, lastMember, 'w:' .. pos, lastWeight, 'm:' .. lastMember, pos)
+		end
+		redis.call('HDEL', key, 'm:' .. member, 'p:' .. n, 'w:' .. n, 't:' .. n)
+		if n == 1 then
+			redis.call('DEL', key)
+		else
+			redis.call('HSET', key, 'n', n - 1)
+		end
+		return 1
+	end
+
+	if pos > 0 then
+		add(pos, weight - tonumber(redis.call('HGET', key, 'w:' .. pos)))
+		redis.call('HSET', key, 'w:' .. pos, weight)
+		return 1
+	end
+	if onlyExisting then
+		return 0
+	end
+
+	pos = n + 1
+	local partial = weight + prefix(pos - 1) - prefix(pos - bit.band(pos, -pos))
+	redis.call('HSET', key, 'n', pos, 'm:' .. member, pos, 'p:' .. pos, member, 'w:' .. pos, weight, 't:' .. pos, partial)
+	return 1
+end
+`
+
+// weightedSetScript adds, updates or removes a member. ARGV[3] == "1" ignores members not in the set.
+var weightedSetScript = redis.NewScript(weightedSetLua + `
+return wset(KEYS[1], ARGV[1], tonumber(ARGV[2]), ARGV[3] == '1')
+`)
+
+// weightedRandomScript walks down the Fenwick tree to the member whose cumulative weight range
+// contains ARGV[1] * total, where ARGV[1] is a random number in [0, 1).
+var weightedRandomScript = redis.NewScript(`
+local key = KEYS[1]
+local n = tonumber(redis.call('HGET', key, 'n') or '0')
+if n == 0 then
+	return false
+end
+
+local total = 0
+local i = n
+while i > 0 do
+	total = total + tonumber(redis.call('HGET', key, 't:' .. i) or '0')
+	i = i - bit.band(i, -i)
+end
+if total <= 0 then
+	return false
+end
+
+local r = math.floor(tonumber(ARGV[1]) * total)
+local step = 1
+while step * 2 <= n do
+	step = step * 2
+end
+local pos = 0
+while step > 0 do
+	local nxt = pos + step
+	if nxt <= n then
+		local t = tonumber(redis.call('HGET', key, 't:' .. nxt))
+		if t <= r then
+			pos = nxt
+			r = r - t
+		end
+	end
+	step = math.floor(step / 2)
+end
+return redis.call('HGET', key, 'p:' .. (pos + 1))
+`)
+
+// WSet adds member to a weighted set or updates its weight. A weight of 0 or less removes it.
+func (s *RedisStore) WSet(key, member string, weight int64) error {
+	return weightedSetScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, member, weight, 0).Err()
+}
+
+// WUpdate updates the weight of a member already in the set and ignores other members.
+func (s *RedisStore) WUpdate(key, member string, weight int64) error {
+	return weightedSetScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, member, weight, 1).Err()
+}
+
+// WRandom draws a member with probability proportional to its weight.
+func (s *RedisStore) WRandom(key string) (string, error) {
+	member, err := weightedRandomScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, rand.Float64()).Text()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return member, err
+}
+
+// --- KEY STATE operations ---
+
+// keyTransitionScript applies a KeyTransition. KEYS are the details hash, the active list, the weight
+// index, the pending set and, if the transition has one, the selection stats hash; ARGV[1] is the transition as keyTransitionArgs JSON and ARGV[2] the default
+// weight. It follows planKeyTransition and returns {changed, failure count, disabled, restored}.
+var keyTransitionScript = redis.NewScript(weightedSetLua + `
+local hashKey, listKey, indexKey, pendingKey, statsKey = KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5]
+local t = cjson.decode(ARGV[1])
+local defaultWeight = tonumber(ARGV[2])
+local member = t.key_id
+
+local function contains(list, value)
+	for _, item in ipairs(list) do
+		if item == value then
+			return true
+		end
+	end
+	return false
+end
+
+local status = redis.call('HGET', hashKey, 'status')
+if not status or contains(t.skip, status) then
+	return {0, 0, 0, 0}
+end
+
+local failureCount = tonumber(redis.call('HGET', hashKey, 'failure_count') or '0') or 0
+local set, unset = {}, {}
+local join, leave, disabled = false, false, 0
+if t.kind == 'success' then
+	local cooldownCount = tonumber(redis.call('HGET', hashKey, 'cooldown_count') or '0') or 0
+	if status ~= t.active and not contains(t.restore, status) then
+		return {0, 0, 0, 0}
+	end
+	if status == t.active and failureCount == 0 and cooldownCount == 0 then
+		return {0, 0, 0, 0}
+	end
+	failureCount = 0
+	set['failure_count'] = 0
+	set['cooldown_count'] = 0
+	if status ~= t.active then
+		set['status'] = t.active
+		join = true
+	end
+elseif t.kind == 'failure' then
+	failureCount = failureCount + 1
+	set['failure_count'] = failureCount
+	if t.force or (t.threshold > 0 and failureCount >= t.threshold) then
+		set['status'] = t.disabled
+		leave = true
+		disabled = 1
+	end
+elseif t.kind == 'move' then
+	if status == t.to or (#t.from > 0 and not contains(t.from, status)) then
+		return {0, 0, 0, 0}
+	end
+	if t.due_field ~= '' and (tonumber(redis.call('HGET', hashKey, t.due_field) or '0') or 0) > t.due_at then
+		return {0, 0, 0, 0}
+	end
+	for field, value in pairs(t.set) do
+		set[field] = value
+	end
+	set['status'] = t.to
+	unset = t.unset
+	if t.to == t.active then
+		join = true
+	else
+		leave = true
+	end
+else
+	return {0, 0, 0, 0}
+end
+
+for field, value in pairs(set) do
+	redis.call('HSET', hashKey, field, value)
+end
+if #unset > 0 then
+	redis.call('HDEL', hashKey, unpack(unset))
+end
+if join or leave then
+	redis.call('LREM', listKey, 0, member)
+end
+if join then
+	redis.call('LPUSH', listKey, member)
+	local weight = tonumber(redis.call('HGET', hashKey, 'weight') or '0') or 0
+	if weight <= 0 then
+		weight = defaultWeight
+	end
+	wset(indexKey, member, weight, false)
+elseif leave then
+	wset(indexKey, member, 0, false)
+	if statsKey then
+		redis.call('HDEL', statsKey, member)
+	end
+end
+
+redis.call('SADD', pendingKey, member)
+return {1, failureCount, disabled, join and 1 or 0}
+`)
+
+// keyTransitionArgs is the JSON form of a KeyTransition passed to keyTransitionScript.
+// Lists and maps are never nil, so they decode to empty Lua tables rather than cjson.null.
+type keyTransitionArgs struct {
+	KeyID     string            `json:"key_id"`
+	Kind      KeyTransitionKind `json:"kind"`
+	Active    string            `json:"active"`
+	Skip      []string          `json:"skip"`
+	Restore   []string          `json:"restore"`
+	Disabled  string            `json:"disabled"`
+	Threshold int64             `json:"threshold"`
+	Force     bool              `json:"force"`
+	From      []string          `json:"from"`
+	To        string            `json:"to"`
+	DueField  string            `json:"due_field"`
+	DueAt     int64             `json:"due_at"`
+	Set       map[string]string `json:"set"`
+	Unset     []string          `json:"unset"`
+}
+
+// ApplyKeyTransition applies a key state transition in a single script.
+func (s *RedisStore) ApplyKeyTransition(t KeyTransition) (KeyTransitionResult, error) {
+	args := keyTransitionArgs{
+		KeyID:     t.KeyID,
+		Kind:      t.Kind,
+		Active:    t.ActiveStatus,
+		Skip:      append([]string{}, t.SkipStatuses...),
+		Restore:   append([]string{}, t.RestoreStatuses...),
+		Disabled:  t.DisabledStatus,
+		Threshold: t.Threshold,
+		Force:     t.ForceDisable,
+		From:      append([]string{}, t.FromStatuses...),
+		To:        t.ToStatus,
+		DueField:  t.DueField,
+		DueAt:     t.DueAt,
+		Set:       make(map[string]string, len(t.Set)),
+		Unset:     append([]string{}, t.Unset...),
+	}
+	for field, value := range t.Set {
+		args.Set[field] = fmt.Sprint(value)
+	}
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return KeyTransitionResult{}, fmt.Errorf("failed to marshal key transition: %w", err)
+	}
+
+	keys := []string{s.prefixKey(t.HashKey), s.prefixKey(t.ListKey), s.prefixKey(t.IndexKey), s.prefixKey(t.PendingKey)}
+	if t.StatsKey != "" {
+		keys = append(keys, s.prefixKey(t.StatsKey))
+	}
+	values, err := keyTransitionScript.Run(context.Background(), s.client, keys, payload, defaultKeyWeight).Int64Slice()
+	if err != nil {
+		return KeyTransitionResult{}, err
+	}
+	return KeyTransitionResult{
+		Changed:      values[0] == 1,
+		FailureCount: values[1],
+		Disabled:     values[2] == 1,
+		Restored:     values[3] == 1,
+	}, nil
+}
+
 // --- Pipeliner implementation ---
 
 type redisPipeliner struct {
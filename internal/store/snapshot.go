@@ -0,0 +1,377 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"key-flow/internal/types"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	snapshotMagic = "KFSNAP"
+	// snapshotVersion 在快照格式变化时递增，旧版本的快照会被忽略
+	snapshotVersion uint32 = 1
+)
+
+// errSnapshotVersion is returned for a snapshot written in another format version.
+var errSnapshotVersion = errors.New("unsupported snapshot version")
+
+// SnapshotMeta describes a snapshot file.
+type SnapshotMeta struct {
+	SavedAt time.Time
+	// Fingerprint is the database state the snapshot was taken against. A snapshot is only
+	// restored while the database still has the same fingerprint.
+	Fingerprint string
+}
+
+// snapshotEntry holds one key of a MemoryStore. Exactly one of the value fields is set, matching Kind.
+type snapshotEntry struct {
+	Kind      string
+	Value     []byte
+	ExpiresAt int64
+	Hash      map[string]string
+	List      []string
+	Set       []string
+	Window    map[int64]int64
+	Members   []string
+	Weights   []int64
+}
+
+const (
+	snapshotKindValue    = "value"
+	snapshotKindHash     = "hash"
+	snapshotKindList     = "list"
+	snapshotKindSet      = "set"
+	snapshotKindWindow   = "window"
+	snapshotKindWeighted = "weighted"
+)
+
+// SaveSnapshot writes all unexpired keys with their TTLs to path. The file is written to a
+// temporary file first and renamed into place, so a crash never leaves a partial snapshot.
+func (s *MemoryStore) SaveSnapshot(path string, meta SnapshotMeta) error {
+	entries := s.snapshotEntries()
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := writeSnapshot(w, meta, entries); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+
+	// 同步目录，确保 rename 本身落盘
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// snapshotEntries copies the unexpired keys while holding the read lock.
+func (s *MemoryStore) snapshotEntries() map[string]snapshotEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UnixNano()
+	entries := make(map[string]snapshotEntry, len(s.data))
+	for key, raw := range s.data {
+		switch v := raw.(type) {
+		case memoryStoreItem:
+			if v.expiresAt > 0 && now > v.expiresAt {
+				continue
+			}
+			entries[key] = snapshotEntry{Kind: snapshotKindValue, Value: v.value, ExpiresAt: v.expiresAt}
+		case map[string]string:
+			hash := make(map[string]string, len(v))
+			for field, value := range v {
+				hash[field] = value
+			}
+			entries[key] = snapshotEntry{Kind: snapshotKindHash, Hash: hash}
+		case []string:
+			entries[key] = snapshotEntry{Kind: snapshotKindList, List: append([]string(nil), v...)}
+		case map[string]struct{}:
+			members := make([]string, 0, len(v))
+			for member := range v {
+				members = append(members, member)
+			}
+			entries[key] = snapshotEntry{Kind: snapshotKindSet, Set: members}
+		case map[int64]int64:
+			buckets := make(map[int64]int64, len(v))
+			for bucket, amount := range v {
+				buckets[bucket] = amount
+			}
+			entries[key] = snapshotEntry{Kind: snapshotKindWindow, Window: buckets}
+		case map[string]int64:
+			// 信号量的持有者是进行中的请求，重启后不再存在，不写入快照
+			continue
+		case *weightedSet:
+			entries[key] = snapshotEntry{
+				Kind:    snapshotKindWeighted,
+				Members: append([]string(nil), v.members...),
+				Weights: append([]int64(nil), v.weights...),
+			}
+		}
+	}
+	return entries
+}
+
+// writeSnapshot writes the header, the metadata and the entries.
+func writeSnapshot(w io.Writer, meta SnapshotMeta, entries map[string]snapshotEntry) error {
+	if _, err := io.WriteString(w, snapshotMagic); err != nil {
+		return fmt.Errorf("failed to write snapshot header: %w", err)
+	}
+	if err := binary.Write(w, binary.BigEndian, snapshotVersion); err != nil {
+		return fmt.Errorf("failed to write snapshot header: %w", err)
+	}
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(meta); err != nil {
+		return fmt.Errorf("failed to encode snapshot metadata: %w", err)
+	}
+	if err := enc.Encode(entries); err != nil {
+		return fmt.Errorf("failed to encode snapshot entries: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot replaces the store's data with the snapshot at path if accept returns true for
+// its metadata. Keys that expired since the snapshot was taken are dropped.
+// It reports whether the snapshot was loaded.
+func (s *MemoryStore) LoadSnapshot(path string, accept func(SnapshotMeta) bool) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	header := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, header); err != nil || string(header) != snapshotMagic {
+		return false, fmt.Errorf("not a snapshot file: %s", path)
+	}
+	var version uint32
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return false, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	if version != snapshotVersion {
+		return false, fmt.Errorf("%w %d", errSnapshotVersion, version)
+	}
+
+	dec := gob.NewDecoder(r)
+	var meta SnapshotMeta
+	if err := dec.Decode(&meta); err != nil {
+		return false, fmt.Errorf("failed to decode snapshot metadata: %w", err)
+	}
+	if !accept(meta) {
+		return false, nil
+	}
+	var entries map[string]snapshotEntry
+	if err := dec.Decode(&entries); err != nil {
+		return false, fmt.Errorf("failed to decode snapshot entries: %w", err)
+	}
+
+	now := time.Now().UnixNano()
+	data := make(map[string]any, len(entries))
+	for key, entry := range entries {
+		switch entry.Kind {
+		case snapshotKindValue:
+			if entry.ExpiresAt > 0 && now > entry.ExpiresAt {
+				continue
+			}
+			data[key] = memoryStoreItem{value: entry.Value, expiresAt: entry.ExpiresAt}
+		case snapshotKindHash:
+			if entry.Hash == nil {
+				entry.Hash = make(map[string]string)
+			}
+			data[key] = entry.Hash
+		case snapshotKindList:
+			data[key] = entry.List
+		case snapshotKindSet:
+			set := make(map[string]struct{}, len(entry.Set))
+			for _, member := range entry.Set {
+				set[member] = struct{}{}
+			}
+			data[key] = set
+		case snapshotKindWindow:
+			if entry.Window == nil {
+				entry.Window = make(map[int64]int64)
+			}
+			data[key] = entry.Window
+		case snapshotKindWeighted:
+			ws := newWeightedSet()
+			for i, member := range entry.Members {
+				ws.set(member, entry.Weights[i], false)
+			}
+			if len(ws.members) > 0 {
+				data[key] = ws
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.data = data
+	s.mu.Unlock()
+	return true, nil
+}
+
+// Snapshotter periodically saves a MemoryStore to a file so that a single-node install can restore
+// its cache on restart instead of reloading it from the database.
+// It does nothing unless snapshots are configured and the store is a MemoryStore.
+type Snapshotter struct {
+	store       *MemoryStore
+	path        string
+	interval    time.Duration
+	fingerprint func() (string, error)
+	stopCh      chan struct{}
+	wg          sync.WaitGroup
+}
+
+// NewSnapshotter creates a Snapshotter from the snapshot configuration.
+func NewSnapshotter(cfg types.ConfigManager, st Store) *Snapshotter {
+	snapshotConfig := cfg.GetSnapshotConfig()
+	sn := &Snapshotter{
+		path:     snapshotConfig.Path,
+		interval: time.Duration(snapshotConfig.Interval) * time.Second,
+		stopCh:   make(chan struct{}),
+	}
+	if memoryStore, ok := st.(*MemoryStore); ok && sn.interval > 0 && sn.path != "" {
+		sn.store = memoryStore
+	}
+	return sn
+}
+
+// Enabled reports whether snapshots are taken.
+func (sn *Snapshotter) Enabled() bool {
+	return sn.store != nil
+}
+
+// Restore loads the snapshot if it was taken against the database state described by fingerprint,
+// which is also recorded in the snapshots saved from now on. It reports whether the store was restored;
+// on false the caller loads the cache from the database as usual.
+func (sn *Snapshotter) Restore(fingerprint func() (string, error)) bool {
+	if !sn.Enabled() {
+		return false
+	}
+	sn.fingerprint = fingerprint
+
+	current, err := fingerprint()
+	if err != nil {
+		logrus.WithError(err).Warn("Memory snapshot: failed to read database state, loading from database")
+		return false
+	}
+
+	var meta SnapshotMeta
+	restored, err := sn.store.LoadSnapshot(sn.path, func(m SnapshotMeta) bool {
+		meta = m
+		return m.Fingerprint == current
+	})
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		logrus.Info("Memory snapshot: no snapshot found, loading from database")
+	case err != nil:
+		logrus.WithError(err).Warn("Memory snapshot: failed to load snapshot, loading from database")
+	case !restored:
+		logrus.Infof("Memory snapshot: snapshot from %s is older than the database, loading from database", meta.SavedAt.Format(time.RFC3339))
+	default:
+		logrus.Infof("Memory snapshot: restored snapshot from %s", meta.SavedAt.Format(time.RFC3339))
+	}
+	return restored
+}
+
+// Start begins taking snapshots periodically.
+func (sn *Snapshotter) Start() {
+	if !sn.Enabled() {
+		return
+	}
+	sn.wg.Add(1)
+	go sn.run()
+}
+
+func (sn *Snapshotter) run() {
+	defer sn.wg.Done()
+
+	ticker := time.NewTicker(sn.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := sn.save(); err != nil {
+				logrus.WithError(err).Warn("Memory snapshot: failed to save snapshot")
+			}
+		case <-sn.stopCh:
+			return
+		}
+	}
+}
+
+// save records the database fingerprint before copying the store, so changes made to the
+// database while the snapshot is taken make it stale rather than silently lost.
+func (sn *Snapshotter) save() error {
+	meta := SnapshotMeta{SavedAt: time.Now()}
+	if sn.fingerprint != nil {
+		fingerprint, err := sn.fingerprint()
+		if err != nil {
+			return fmt.Errorf("failed to read database state: %w", err)
+		}
+		meta.Fingerprint = fingerprint
+	}
+	return sn.store.SaveSnapshot(sn.path, meta)
+}
+
+// Stop stops the periodic snapshots and takes a final one. It is called after the other services
+// have stopped so the snapshot includes their last writes.
+func (sn *Snapshotter) Stop(ctx context.Context) {
+	if !sn.Enabled() {
+		return
+	}
+	close(sn.stopCh)
+	sn.wg.Wait()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sn.save()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			logrus.WithError(err).Error("Memory snapshot: failed to save final snapshot")
+			return
+		}
+		logrus.Info("Memory snapshot saved.")
+	case <-ctx.Done():
+		logrus.Warn("Memory snapshot: final snapshot timed out.")
+	}
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testSnapshotter 创建一个直接指向 s 和 path 的 Snapshotter，不依赖配置
+func testSnapshotter(s *MemoryStore, path string) *Snapshotter {
+	return &Snapshotter{store: s, path: path, interval: time.Hour, stopCh: make(chan struct{})}
+}
+
+// valueExpiry 返回内存存储中值的过期时间，0 表示永不过期
+func valueExpiry(t *testing.T, s *MemoryStore, key string) int64 {
+	t.Helper()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.data[key].(memoryStoreItem)
+	if !ok {
+		t.Fatalf("%s is not a value", key)
+	}
+	return item.expiresAt
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.snapshot")
+	src := NewMemoryStore()
+	must(t, src.Set("ttl", []byte("v1"), time.Hour))
+	must(t, src.Set("forever", []byte("v2"), 0))
+	must(t, src.HSet("hash", map[string]any{"status": "active", "failure_count": 2}))
+	must(t, src.LPush("list", 1, 2, 3))
+	must(t, src.SAdd("set", "a", "b"))
+	must(t, src.WindowAdd("window", 5, time.Minute))
+	must(t, src.WSet("weighted", "1", 10))
+	must(t, src.WSet("weighted", "2", 20))
+	if ok, err := src.SemaphoreAcquire("semaphore", "holder", 1, time.Minute); err != nil || !ok {
+		t.Fatalf("SemaphoreAcquire = %v, %v", ok, err)
+	}
+
+	savedAt := time.Now()
+	must(t, src.SaveSnapshot(path, SnapshotMeta{SavedAt: savedAt, Fingerprint: "fp"}))
+
+	dst := NewMemoryStore()
+	must(t, dst.Set("stale", []byte("x"), 0))
+	var meta SnapshotMeta
+	restored, err := dst.LoadSnapshot(path, func(m SnapshotMeta) bool {
+		meta = m
+		return true
+	})
+	if err != nil || !restored {
+		t.Fatalf("LoadSnapshot = %v, %v, want restored", restored, err)
+	}
+	if meta.Fingerprint != "fp" || !meta.SavedAt.Equal(savedAt) {
+		t.Fatalf("meta = %+v", meta)
+	}
+
+	// 加载会替换原有数据
+	if _, err := dst.Get("stale"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(stale) error = %v, want ErrNotFound", err)
+	}
+	for key, want := range map[string]string{"ttl": "v1", "forever": "v2"} {
+		if got, err := dst.Get(key); err != nil || string(got) != want {
+			t.Fatalf("Get(%s) = %q, %v, want %q", key, got, err, want)
+		}
+	}
+	// TTL 按绝对过期时间保存，恢复后剩余时间不变
+	if got, want := valueExpiry(t, dst, "ttl"), valueExpiry(t, src, "ttl"); got != want {
+		t.Fatalf("ttl expiresAt = %d, want %d", got, want)
+	}
+	if got := valueExpiry(t, dst, "forever"); got != 0 {
+		t.Fatalf("forever expiresAt = %d, want 0", got)
+	}
+
+	hash, err := dst.HGetAll("hash")
+	if err != nil || hash["status"] != "active" || hash["failure_count"] != "2" {
+		t.Fatalf("HGetAll(hash) = %v, %v", hash, err)
+	}
+	if got := listMembers(t, dst, "list"); !slices.Equal(got, []string{"1", "2", "3"}) {
+		t.Fatalf("list rotation order = %v", got)
+	}
+	if n, err := dst.SCard("set"); err != nil || n != 2 {
+		t.Fatalf("SCard(set) = %d, %v, want 2", n, err)
+	}
+	if usage, _, err := dst.WindowUsage("window", time.Minute); err != nil || usage != 5 {
+		t.Fatalf("WindowUsage(window) = %d, %v, want 5", usage, err)
+	}
+	if member, err := dst.WRandom("weighted"); err != nil || (member != "1" && member != "2") {
+		t.Fatalf("WRandom(weighted) = %q, %v", member, err)
+	}
+	must(t, dst.WUpdate("weighted", "2", 0))
+	if member, err := dst.WRandom("weighted"); err != nil || member != "1" {
+		t.Fatalf("WRandom(weighted) after removing 2 = %q, %v, want 1", member, err)
+	}
+
+	// 信号量持有者是进行中的请求，不会被恢复
+	if n, err := dst.SemaphoreCount("semaphore"); err != nil || n != 0 {
+		t.Fatalf("SemaphoreCount(semaphore) = %d, %v, want 0", n, err)
+	}
+}
+
+func TestSnapshotExpiry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.snapshot")
+	src := NewMemoryStore()
+	must(t, src.Set("expired", []byte("x"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	must(t, src.Set("short", []byte("x"), 50*time.Millisecond))
+	must(t, src.Set("long", []byte("x"), time.Hour))
+	must(t, src.SaveSnapshot(path, SnapshotMeta{SavedAt: time.Now()}))
+
+	// 保存时已过期的 key 不写入快照
+	entries := src.snapshotEntries()
+	if _, ok := entries["expired"]; ok {
+		t.Fatal("expired key was written to the snapshot")
+	}
+
+	// 快照保存期间过期的 key 在加载时丢弃
+	time.Sleep(100 * time.Millisecond)
+	dst := NewMemoryStore()
+	if restored, err := dst.LoadSnapshot(path, func(SnapshotMeta) bool { return true }); err != nil || !restored {
+		t.Fatalf("LoadSnapshot = %v, %v, want restored", restored, err)
+	}
+	dst.mu.RLock()
+	_, shortLoaded := dst.data["short"]
+	dst.mu.RUnlock()
+	if shortLoaded {
+		t.Fatal("key expired while persisted was loaded")
+	}
+	if _, err := dst.Get("long"); err != nil {
+		t.Fatalf("Get(long) error = %v", err)
+	}
+}
+
+func TestSnapshotVersionMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.snapshot")
+	src := NewMemoryStore()
+	must(t, src.Set("k", []byte("snapshot"), 0))
+	must(t, src.SaveSnapshot(path, SnapshotMeta{SavedAt: time.Now(), Fingerprint: "fp"}))
+
+	// 改写文件头中的版本号，模拟旧格式的快照
+	data, err := os.ReadFile(path)
+	must(t, err)
+	binary.BigEndian.PutUint32(data[len(snapshotMagic):], snapshotVersion+1)
+	must(t, os.WriteFile(path, data, 0644))
+
+	dst := NewMemoryStore()
+	must(t, dst.Set("k", []byte("current"), 0))
+	restored, err := dst.LoadSnapshot(path, func(SnapshotMeta) bool {
+		t.Fatal("accept called for a snapshot of another version")
+		return true
+	})
+	if !errors.Is(err, errSnapshotVersion) || restored {
+		t.Fatalf("LoadSnapshot = %v, %v, want errSnapshotVersion", restored, err)
+	}
+	if got, err := dst.Get("k"); err != nil || string(got) != "current" {
+		t.Fatalf("Get(k) = %q, %v, want the data kept", got, err)
+	}
+
+	if testSnapshotter(dst, path).Restore(func() (string, error) { return "fp", nil }) {
+		t.Fatal("Restore accepted a snapshot of another version")
+	}
+}
+
+func TestSnapshotterRestore(t *testing.T) {
+	tests := []struct {
+		name         string
+		saved        bool
+		fingerprint  func() (string, error)
+		wantRestored bool
+	}{
+		{name: "matching fingerprint", saved: true, fingerprint: func() (string, error) { return "fp", nil }, wantRestored: true},
+		{name: "database changed", saved: true, fingerprint: func() (string, error) { return "fp2", nil }},
+		{name: "fingerprint error", saved: true, fingerprint: func() (string, error) { return "", errors.New("db down") }},
+		{name: "no snapshot", fingerprint: func() (string, error) { return "fp", nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store.snapshot")
+			if tt.saved {
+				src := NewMemoryStore()
+				must(t, src.Set("k", []byte("snapshot"), 0))
+				must(t, src.SaveSnapshot(path, SnapshotMeta{SavedAt: time.Now(), Fingerprint: "fp"}))
+			}
+
+			dst := NewMemoryStore()
+			must(t, dst.Set("k", []byte("current"), 0))
+			sn := testSnapshotter(dst, path)
+			if restored := sn.Restore(tt.fingerprint); restored != tt.wantRestored {
+				t.Fatalf("Restore() = %v, want %v", restored, tt.wantRestored)
+			}
+
+			// 未恢复时保留原有数据，由调用方清空缓存并从数据库重新加载
+			want := "current"
+			if tt.wantRestored {
+				want = "snapshot"
+			}
+			if got, err := dst.Get("k"); err != nil || string(got) != want {
+				t.Fatalf("Get(k) = %q, %v, want %q", got, err, want)
+			}
+
+			// 之后保存的快照记录新的指纹
+			if fp, err := tt.fingerprint(); err == nil {
+				must(t, sn.save())
+				var meta SnapshotMeta
+				if _, err := NewMemoryStore().LoadSnapshot(path, func(m SnapshotMeta) bool {
+					meta = m
+					return false
+				}); err != nil {
+					t.Fatal(err)
+				}
+				if meta.Fingerprint != fp {
+					t.Fatalf("saved fingerprint = %q, want %q", meta.Fingerprint, fp)
+				}
+			}
+		})
+	}
+}
@@ -40,10 +40,17 @@ type Store interface {
 	// SetNX sets a key-value pair if the key does not already exist.
 	SetNX(key string, value []byte, ttl time.Duration) (bool, error)
 
+	// LEASE operations
+	// RenewLease resets the TTL of key if it still holds value, and reports whether it did.
+	RenewLease(key string, value []byte, ttl time.Duration) (bool, error)
+	// ReleaseLease deletes key if it still holds value, and reports whether it did.
+	ReleaseLease(key string, value []byte) (bool, error)
+
 	// HASH operations
 	HSet(key string, values map[string]any) error
 	HGetAll(key string) (map[string]string, error)
 	HIncrBy(key, field string, incr int64) (int64, error)
+	HDel(key string, fields ...string) error
 
 	// LIST operations
 	LPush(key string, values ...any) error
@@ -54,6 +61,46 @@ type Store interface {
 	// SET operations
 	SAdd(key string, members ...any) error
 	SPopN(key string, count int64) ([]string, error)
+	SCard(key string) (int64, error)
+
+	// SLIDING WINDOW operations
+	// WindowAdd records amount in the current one-second bucket of a sliding window counter.
+	WindowAdd(key string, amount int64, window time.Duration) error
+	// WindowUsage returns the sum recorded within the window and the start time of its oldest bucket.
+	WindowUsage(key string, window time.Duration) (int64, time.Time, error)
+	// WindowTryAdd records amount like WindowAdd only if the sum within the window is below limit,
+	// checking and adding atomically. On success it returns true and the start time of the bucket
+	// amount was recorded in; when the window is full it returns false and the start time of its
+	// oldest bucket.
+	WindowTryAdd(key string, amount, limit int64, window time.Duration) (bool, time.Time, error)
+	// WindowRemove subtracts amount from the bucket starting at bucket, undoing an add to that bucket.
+	// It does nothing once the bucket has left the window, so an undo never lowers newer buckets.
+	WindowRemove(key string, bucket time.Time, amount int64) error
+
+	// SEMAPHORE operations
+	// SemaphoreAcquire adds holder to the semaphore if fewer than limit unexpired holders hold it,
+	// checking and adding atomically. A holder expires ttl after it acquired or last renewed.
+	SemaphoreAcquire(key, holder string, limit int64, ttl time.Duration) (bool, error)
+	// SemaphoreRenew resets the expiry of holder if it still holds the semaphore, and reports whether it did.
+	SemaphoreRenew(key, holder string, ttl time.Duration) (bool, error)
+	// SemaphoreRelease removes holder from the semaphore.
+	SemaphoreRelease(key, holder string) error
+	// SemaphoreCount returns the number of unexpired holders of the semaphore.
+	SemaphoreCount(key string) (int64, error)
+
+	// WEIGHTED SET operations
+	// WSet adds member to a weighted set or updates its weight. A weight of 0 or less removes it.
+	WSet(key, member string, weight int64) error
+	// WUpdate updates the weight of a member already in the set and ignores other members.
+	WUpdate(key, member string, weight int64) error
+	// WRandom draws a member with probability proportional to its weight. It takes O(log n) in the
+	// memory and Redis stores; the database store scans the rows of the set, which takes O(n).
+	// It returns ErrNotFound for an empty set.
+	WRandom(key string) (string, error)
+
+	// KEY STATE operations
+	// ApplyKeyTransition atomically applies a change to a key's state, see KeyTransition.
+	ApplyKeyTransition(t KeyTransition) (KeyTransitionResult, error)
 
 	// Close closes the store and releases any underlying resources.
 	Close() error
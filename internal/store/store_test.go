@@ -0,0 +1,671 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testStores 返回一致性测试覆盖的 store，每次调用都创建空的实例。设置 TEST_REDIS_DSN 时加入 Redis，
+// 测试会清空其中的数据，不要指向生产实例。
+func testStores(t *testing.T) map[string]func(t *testing.T) Store {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store {
+			return NewMemoryStore()
+		},
+		"database": func(t *testing.T) Store {
+			db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "store.db")), &gorm.Config{
+				Logger: logger.Default.LogMode(logger.Silent),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := NewDatabaseStore(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	}
+
+	dsn := os.Getenv("TEST_REDIS_DSN")
+	if dsn == "" {
+		return stores
+	}
+	opts, err := redis.ParseURL(dsn)
+	if err != nil {
+		t.Fatalf("invalid TEST_REDIS_DSN: %v", err)
+	}
+	stores["redis"] = func(t *testing.T) Store {
+		client := redis.NewClient(opts)
+		t.Cleanup(func() { client.Close() })
+		s := NewRedisStore(client)
+		if err := s.Clear(); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	return stores
+}
+
+// runConformance 在每个 store 上运行 test
+func runConformance(t *testing.T, test func(t *testing.T, s Store)) {
+	for name, newStore := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			test(t, newStore(t))
+		})
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreKeyValue(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		if _, err := s.Get("missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+		}
+
+		must(t, s.Set("k", []byte("v"), 0))
+		if value, err := s.Get("k"); err != nil || string(value) != "v" {
+			t.Fatalf("Get(k) = %q, %v", value, err)
+		}
+		must(t, s.Delete("k"))
+		if exists, err := s.Exists("k"); err != nil || exists {
+			t.Fatalf("Exists(k) after Delete = %v, %v", exists, err)
+		}
+
+		must(t, s.Set("ttl", []byte("v"), 100*time.Millisecond))
+		time.Sleep(200 * time.Millisecond)
+		if _, err := s.Get("ttl"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get(ttl) after expiry error = %v, want ErrNotFound", err)
+		}
+
+		tests := []struct {
+			value []byte
+			ttl   time.Duration
+			want  bool
+		}{
+			{[]byte("first"), 100 * time.Millisecond, true},
+			{[]byte("second"), 0, false},
+		}
+		for _, tt := range tests {
+			if ok, err := s.SetNX("nx", tt.value, tt.ttl); err != nil || ok != tt.want {
+				t.Fatalf("SetNX(%s) = %v, %v, want %v", tt.value, ok, err, tt.want)
+			}
+		}
+		time.Sleep(200 * time.Millisecond)
+		if ok, err := s.SetNX("nx", []byte("third"), 0); err != nil || !ok {
+			t.Fatalf("SetNX after expiry = %v, %v, want true", ok, err)
+		}
+	})
+}
+
+func TestStoreLease(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		if ok, err := s.SetNX("lease", []byte("holder"), time.Minute); err != nil || !ok {
+			t.Fatalf("SetNX(lease) = %v, %v", ok, err)
+		}
+
+		tests := []struct {
+			name  string
+			op    func() (bool, error)
+			want  bool
+			exist bool
+		}{
+			{"renew by other", func() (bool, error) { return s.RenewLease("lease", []byte("other"), time.Minute) }, false, true},
+			{"renew by holder", func() (bool, error) { return s.RenewLease("lease", []byte("holder"), time.Minute) }, true, true},
+			{"release by other", func() (bool, error) { return s.ReleaseLease("lease", []byte("other")) }, false, true},
+			{"release by holder", func() (bool, error) { return s.ReleaseLease("lease", []byte("holder")) }, true, false},
+			{"renew released", func() (bool, error) { return s.RenewLease("lease", []byte("holder"), time.Minute) }, false, false},
+			{"release released", func() (bool, error) { return s.ReleaseLease("lease", []byte("holder")) }, false, false},
+		}
+		for _, tt := range tests {
+			ok, err := tt.op()
+			if err != nil || ok != tt.want {
+				t.Fatalf("%s = %v, %v, want %v", tt.name, ok, err, tt.want)
+			}
+			if exists, err := s.Exists("lease"); err != nil || exists != tt.exist {
+				t.Fatalf("%s: Exists = %v, %v, want %v", tt.name, exists, err, tt.exist)
+			}
+		}
+
+		// 过期的租约不能再续约
+		must(t, s.Set("expiring", []byte("holder"), 100*time.Millisecond))
+		time.Sleep(200 * time.Millisecond)
+		if ok, err := s.RenewLease("expiring", []byte("holder"), time.Minute); err != nil || ok {
+			t.Fatalf("RenewLease after expiry = %v, %v, want false", ok, err)
+		}
+	})
+}
+
+func TestStoreHash(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		if hash, err := s.HGetAll("missing"); err != nil || len(hash) != 0 {
+			t.Fatalf("HGetAll(missing) = %v, %v", hash, err)
+		}
+
+		must(t, s.HSet("h", map[string]any{"a": 1, "b": "x"}))
+		if n, err := s.HIncrBy("h", "a", 2); err != nil || n != 3 {
+			t.Fatalf("HIncrBy(a) = %d, %v, want 3", n, err)
+		}
+		if n, err := s.HIncrBy("h", "c", -1); err != nil || n != -1 {
+			t.Fatalf("HIncrBy(c) = %d, %v, want -1", n, err)
+		}
+		must(t, s.HDel("h", "b", "missing"))
+
+		hash, err := s.HGetAll("h")
+		must(t, err)
+		want := map[string]string{"a": "3", "c": "-1"}
+		if len(hash) != len(want) || hash["a"] != want["a"] || hash["c"] != want["c"] {
+			t.Fatalf("HGetAll(h) = %v, want %v", hash, want)
+		}
+	})
+}
+
+// listMembers 通过轮转读取整个列表，轮转一圈后列表顺序不变
+func listMembers(t *testing.T, s Store, key string) []string {
+	t.Helper()
+	n, err := s.LLen(key)
+	must(t, err)
+	members := make([]string, 0, n)
+	for range n {
+		member, err := s.Rotate(key)
+		must(t, err)
+		members = append(members, member)
+	}
+	return members
+}
+
+func TestStoreList(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		if _, err := s.Rotate("missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Rotate(missing) error = %v, want ErrNotFound", err)
+		}
+
+		must(t, s.LPush("l", 1, 2, 3))
+		// 列表为 [3 2 1]，Rotate 从尾部取出并放回头部
+		if got := listMembers(t, s, "l"); !slices.Equal(got, []string{"1", "2", "3"}) {
+			t.Fatalf("rotation order = %v", got)
+		}
+		must(t, s.LRem("l", 0, 2))
+		if got := listMembers(t, s, "l"); !slices.Equal(got, []string{"1", "3"}) {
+			t.Fatalf("after LRem = %v", got)
+		}
+	})
+}
+
+func TestStoreListConcurrent(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		const pushes = 20
+		if n, err := s.LLen("l"); err != nil || n != 0 {
+			t.Fatalf("LLen of empty list = %d, %v", n, err)
+		}
+
+		// 并发的 LPush 和 Rotate 不能写入相同的位置
+		var wg sync.WaitGroup
+		for i := range pushes {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				if err := s.LPush("l", i); err != nil {
+					t.Error(err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				if _, err := s.Rotate("l"); err != nil && !errors.Is(err, ErrNotFound) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		got := listMembers(t, s, "l")
+		if len(got) != pushes {
+			t.Fatalf("LLen = %d, want %d", len(got), pushes)
+		}
+		// 完整轮转一圈后每个元素各出现一次，位置重复的元素会被跳过或取出两次
+		slices.Sort(got)
+		if got = slices.Compact(got); len(got) != pushes {
+			t.Fatalf("rotation returned %d distinct members, want %d", len(got), pushes)
+		}
+	})
+}
+
+func TestStoreSet(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		must(t, s.SAdd("s", "a", "b", "c", "a"))
+		if n, err := s.SCard("s"); err != nil || n != 3 {
+			t.Fatalf("SCard = %d, %v, want 3", n, err)
+		}
+		popped, err := s.SPopN("s", 2)
+		must(t, err)
+		if len(popped) != 2 || popped[0] == popped[1] {
+			t.Fatalf("SPopN = %v", popped)
+		}
+		rest, err := s.SPopN("s", 10)
+		must(t, err)
+		if all := append(popped, rest...); len(all) != 3 || !slices.Contains(all, "a") || !slices.Contains(all, "b") || !slices.Contains(all, "c") {
+			t.Fatalf("popped members = %v", all)
+		}
+		if n, err := s.SCard("s"); err != nil || n != 0 {
+			t.Fatalf("SCard after pop = %d, %v", n, err)
+		}
+	})
+}
+
+func TestStoreWindow(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		if used, oldest, err := s.WindowUsage("w", time.Minute); err != nil || used != 0 || !oldest.IsZero() {
+			t.Fatalf("WindowUsage(empty) = %d, %v, %v", used, oldest, err)
+		}
+
+		must(t, s.WindowAdd("w", 3, time.Minute))
+		must(t, s.WindowAdd("w", 2, time.Minute))
+		used, oldest, err := s.WindowUsage("w", time.Minute)
+		must(t, err)
+		if used != 5 || time.Since(oldest) > 2*time.Second {
+			t.Fatalf("WindowUsage = %d, %v", used, oldest)
+		}
+
+		tests := []struct {
+			limit     int64
+			wantAdded bool
+			wantUsed  int64
+		}{
+			{5, false, 5},
+			{6, true, 6},
+			{6, false, 6},
+		}
+		for _, tt := range tests {
+			added, oldest, err := s.WindowTryAdd("w", 1, tt.limit, time.Minute)
+			must(t, err)
+			// 成功时返回记录这次计数的桶，失败时返回最早的桶，两者都在窗口内
+			if added != tt.wantAdded || time.Since(oldest) > 2*time.Second {
+				t.Fatalf("WindowTryAdd(limit %d) = %v, %v, want %v", tt.limit, added, oldest, tt.wantAdded)
+			}
+			if used, _, _ := s.WindowUsage("w", time.Minute); used != tt.wantUsed {
+				t.Fatalf("WindowUsage after WindowTryAdd(limit %d) = %d, want %d", tt.limit, used, tt.wantUsed)
+			}
+		}
+	})
+}
+
+func TestStoreWindowTryAddConcurrent(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		const limit = 10
+		var added atomic.Int64
+		var wg sync.WaitGroup
+		for range 3 * limit {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, _, err := s.WindowTryAdd("w", 1, limit, time.Minute)
+				if err != nil {
+					t.Error(err)
+				}
+				if ok {
+					added.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if added.Load() != limit {
+			t.Fatalf("%d concurrent adds passed, want %d", added.Load(), limit)
+		}
+		if used, _, _ := s.WindowUsage("w", time.Minute); used != limit {
+			t.Fatalf("WindowUsage = %d, want %d", used, limit)
+		}
+	})
+}
+
+func TestStoreWindowRemove(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		must(t, s.WindowRemove("missing", time.Now(), 1))
+
+		added, bucket, err := s.WindowTryAdd("w", 1, 10, time.Minute)
+		if err != nil || !added {
+			t.Fatalf("WindowTryAdd = %v, %v", added, err)
+		}
+		// 之后的请求落在其他桶中，撤销只减去原来的桶
+		must(t, s.WindowAdd("w", 2, time.Minute))
+		must(t, s.WindowRemove("w", bucket.Add(-10*time.Second), 1))
+		if used, _, _ := s.WindowUsage("w", time.Minute); used != 3 {
+			t.Fatalf("WindowUsage after removing an unrecorded bucket = %d, want 3", used)
+		}
+		must(t, s.WindowRemove("w", bucket, 1))
+		if used, _, _ := s.WindowUsage("w", time.Minute); used != 2 {
+			t.Fatalf("WindowUsage after WindowRemove = %d, want 2", used)
+		}
+	})
+}
+
+func TestStoreSemaphore(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		tests := []struct {
+			name string
+			op   func() (bool, error)
+			want bool
+		}{
+			{"acquire a", func() (bool, error) { return s.SemaphoreAcquire("sem", "a", 2, time.Minute) }, true},
+			{"acquire b", func() (bool, error) { return s.SemaphoreAcquire("sem", "b", 2, time.Minute) }, true},
+			{"acquire c when full", func() (bool, error) { return s.SemaphoreAcquire("sem", "c", 2, time.Minute) }, false},
+			{"renew a", func() (bool, error) { return s.SemaphoreRenew("sem", "a", time.Minute) }, true},
+			{"renew c", func() (bool, error) { return s.SemaphoreRenew("sem", "c", time.Minute) }, false},
+			{"release a", func() (bool, error) { return true, s.SemaphoreRelease("sem", "a") }, true},
+			{"renew released a", func() (bool, error) { return s.SemaphoreRenew("sem", "a", time.Minute) }, false},
+			{"acquire c after release", func() (bool, error) { return s.SemaphoreAcquire("sem", "c", 2, time.Minute) }, true},
+		}
+		for _, tt := range tests {
+			if ok, err := tt.op(); err != nil || ok != tt.want {
+				t.Fatalf("%s = %v, %v, want %v", tt.name, ok, err, tt.want)
+			}
+		}
+		if count, err := s.SemaphoreCount("sem"); err != nil || count != 2 {
+			t.Fatalf("SemaphoreCount = %d, %v, want 2", count, err)
+		}
+
+		// 过期的持有者不占用槽位，也不能再续约
+		if ok, err := s.SemaphoreAcquire("expiring", "a", 1, 100*time.Millisecond); err != nil || !ok {
+			t.Fatalf("SemaphoreAcquire(expiring) = %v, %v", ok, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+		if ok, err := s.SemaphoreRenew("expiring", "a", time.Minute); err != nil || ok {
+			t.Fatalf("SemaphoreRenew after expiry = %v, %v, want false", ok, err)
+		}
+		if count, err := s.SemaphoreCount("expiring"); err != nil || count != 0 {
+			t.Fatalf("SemaphoreCount after expiry = %d, %v, want 0", count, err)
+		}
+		if ok, err := s.SemaphoreAcquire("expiring", "b", 1, time.Minute); err != nil || !ok {
+			t.Fatalf("SemaphoreAcquire after expiry = %v, %v, want true", ok, err)
+		}
+	})
+}
+
+func TestStoreSemaphoreConcurrent(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		const limit = 5
+		var acquired atomic.Int64
+		var wg sync.WaitGroup
+		for i := range 3 * limit {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := s.SemaphoreAcquire("sem", strconv.Itoa(i), limit, time.Minute)
+				if err != nil {
+					t.Error(err)
+				}
+				if ok {
+					acquired.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if acquired.Load() != limit {
+			t.Fatalf("%d concurrent acquires passed, want %d", acquired.Load(), limit)
+		}
+	})
+}
+
+func TestStoreWeightedSet(t *testing.T) {
+	runConformance(t, func(t *testing.T, s Store) {
+		if _, err := s.WRandom("ws"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("WRandom(empty) error = %v, want ErrNotFound", err)
+		}
+
+		must(t, s.WSet("ws", "a", 1))
+		must(t, s.WSet("ws", "b", 3))
+		must(t, s.WUpdate("ws", "c", 100)) // 不在集合中的成员不会被加入
+
+		const draws = 2000
+		counts := map[string]int{}
+		for range draws {
+			member, err := s.WRandom("ws")
+			must(t, err)
+			counts[member]++
+		}
+		if counts["c"] > 0 || len(counts) != 2 {
+			t.Fatalf("draw counts = %v", counts)
+		}
+		if ratio := float64(counts["b"]) / draws; ratio < 0.7 || ratio > 0.8 {
+			t.Fatalf("b drawn %.2f of the time, want about 0.75", ratio)
+		}
+
+		must(t, s.WUpdate("ws", "b", 1))
+		must(t, s.WSet("ws", "a", 0))
+		for range 20 {
+			if member, err := s.WRandom("ws"); err != nil || member != "b" {
+				t.Fatalf("WRandom after removing a = %q, %v", member, err)
+			}
+		}
+		must(t, s.WSet("ws", "b", 0))
+		if _, err := s.WRandom("ws"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("WRandom after removing all error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestStoreKeyTransition(t *testing.T) {
+	const (
+		hashKey    = "key:1"
+		listKey    = "group:1:active_keys"
+		indexKey   = "group:1:key_weights"
+		pendingKey = "pending"
+		statsKey   = "group:1:last_used"
+	)
+	transition := func(kind KeyTransitionKind) KeyTransition {
+		return KeyTransition{
+			HashKey: hashKey, ListKey: listKey, IndexKey: indexKey, PendingKey: pendingKey, StatsKey: statsKey, KeyID: "1",
+			Kind: kind, ActiveStatus: "active",
+		}
+	}
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name       string
+		hash       map[string]any
+		transition func() KeyTransition
+		want       KeyTransitionResult
+		wantHash   map[string]string // 期望的字段值，空字符串表示字段不存在
+		wantActive bool              // 变更后 key 是否在 active 列表和权重索引中
+	}{
+		{
+			name:       "success resets counts of active key",
+			hash:       map[string]any{"status": "active", "failure_count": 2, "cooldown_count": 1},
+			transition: func() KeyTransition { return transition(KeyTransitionSuccess) },
+			want:       KeyTransitionResult{Changed: true},
+			wantHash:   map[string]string{"status": "active", "failure_count": "0", "cooldown_count": "0"},
+			wantActive: true,
+		},
+		{
+			name:       "success without counts is a no-op",
+			hash:       map[string]any{"status": "active", "failure_count": 0},
+			transition: func() KeyTransition { return transition(KeyTransitionSuccess) },
+			want:       KeyTransitionResult{},
+			wantHash:   map[string]string{"status": "active"},
+			wantActive: true,
+		},
+		{
+			name: "success restores listed status",
+			hash: map[string]any{"status": "invalid", "failure_count": 3},
+			transition: func() KeyTransition {
+				tr := transition(KeyTransitionSuccess)
+				tr.RestoreStatuses = []string{"invalid"}
+				return tr
+			},
+			want:       KeyTransitionResult{Changed: true, Restored: true},
+			wantHash:   map[string]string{"status": "active", "failure_count": "0"},
+			wantActive: true,
+		},
+		{
+			name: "success leaves other statuses",
+			hash: map[string]any{"status": "cooldown", "failure_count": 1},
+			transition: func() KeyTransition {
+				tr := transition(KeyTransitionSuccess)
+				tr.RestoreStatuses = []string{"invalid"}
+				return tr
+			},
+			want:     KeyTransitionResult{},
+			wantHash: map[string]string{"status": "cooldown", "failure_count": "1"},
+		},
+		{
+			name: "failure below threshold counts",
+			hash: map[string]any{"status": "active", "failure_count": 1},
+			transition: func() KeyTransition {
+				tr := transition(KeyTransitionFailure)
+				tr.DisabledStatus, tr.Threshold = "invalid", 3
+				return tr
+			},
+			want:       KeyTransitionResult{Changed: true, FailureCount: 2},
+			wantHash:   map[string]string{"status": "active", "failure_count": "2"},
+			wantActive: true,
+		},
+		{
+			name: "failure at threshold disables",
+			hash: map[string]any{"status": "active", "failure_count": 2},
+			transition: func() KeyTransition {
+				tr := transition(KeyTransitionFailure)
+				tr.DisabledStatus, tr.Threshold = "invalid", 3
+				return tr
+			},
+			want:     KeyTransitionResult{Changed: true, FailureCount: 3, Disabled: true},
+			wantHash: map[string]string{"status": "invalid", "failure_count": "3"},
+		},
+		{
+			name: "failure skips listed status",
+			hash: map[string]any{"status": "invalid", "failure_count": 3},
+			transition: func() KeyTransition {
+				tr := transition(KeyTransitionFailure)
+				tr.DisabledStatus, tr.ForceDisable, tr.SkipStatuses = "invalid", true, []string{"invalid"}
+				return tr
+			},
+			want:     KeyTransitionResult{},
+			wantHash: map[string]string{"status": "invalid", "failure_count": "3"},
+		},
+		{
+			name: "move sets and unsets fields",
+			hash: map[string]any{"status": "active", "cooldown_until": 5},
+			transition: func() KeyTransition {
+				tr := transition(KeyTransitionMove)
+				tr.ToStatus, tr.FromStatuses = "quota_exhausted", []string{"active"}
+				tr.Set, tr.Unset = map[string]any{"quota_reset_at": now + 60}, []string{"cooldown_until"}
+				return tr
+			},
+			want:     KeyTransitionResult{Changed: true},
+			wantHash: map[string]string{"status": "quota_exhausted", "cooldown_until": "", "quota_reset_at": strconv.FormatInt(now+60, 10)},
+		},
+		{
+			name: "move from other status is a no-op",
+			hash: map[string]any{"status": "invalid"},
+			transition: func() KeyTransition {
+				tr := transition(KeyTransitionMove)
+				tr.ToStatus, tr.FromStatuses = "cooldown", []string{"active"}
+				return tr
+			},
+			want:     KeyTransitionResult{},
+			wantHash: map[string]string{"status": "invalid"},
+		},
+		{
+			name: "move before due time is a no-op",
+			hash: map[string]any{"status": "cooldown", "cooldown_until": now + 60},
+			transition: func() KeyTransition {
+				tr := transition(KeyTransitionMove)
+				tr.ToStatus, tr.FromStatuses = "active", []string{"cooldown"}
+				tr.DueField, tr.DueAt, tr.Unset = "cooldown_until", now, []string{"cooldown_until"}
+				return tr
+			},
+			want:     KeyTransitionResult{},
+			wantHash: map[string]string{"status": "cooldown", "cooldown_until": strconv.FormatInt(now+60, 10)},
+		},
+		{
+			name: "move after due time restores",
+			hash: map[string]any{"status": "cooldown", "cooldown_until": now - 1, "weight": 200},
+			transition: func() KeyTransition {
+				tr := transition(KeyTransitionMove)
+				tr.ToStatus, tr.FromStatuses = "active", []string{"cooldown"}
+				tr.DueField, tr.DueAt, tr.Unset = "cooldown_until", now, []string{"cooldown_until"}
+				return tr
+			},
+			want:       KeyTransitionResult{Changed: true, Restored: true},
+			wantHash:   map[string]string{"status": "active", "cooldown_until": "", "weight": "200"},
+			wantActive: true,
+		},
+		{
+			name:       "missing key is a no-op",
+			transition: func() KeyTransition { return transition(KeyTransitionSuccess) },
+			want:       KeyTransitionResult{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runConformance(t, func(t *testing.T, s Store) {
+				if tt.hash != nil {
+					must(t, s.HSet(hashKey, tt.hash))
+					if tt.hash["status"] == "active" {
+						must(t, s.LPush(listKey, 1))
+						must(t, s.WSet(indexKey, "1", 500))
+					}
+				}
+				must(t, s.HSet(statsKey, map[string]any{"1": 100, "2": 200}))
+
+				got, err := s.ApplyKeyTransition(tt.transition())
+				must(t, err)
+				if got != tt.want {
+					t.Fatalf("result = %+v, want %+v", got, tt.want)
+				}
+
+				hash, err := s.HGetAll(hashKey)
+				must(t, err)
+				for field, want := range tt.wantHash {
+					if hash[field] != want {
+						t.Fatalf("hash[%s] = %q, want %q (hash %v)", field, hash[field], want, hash)
+					}
+				}
+
+				members := listMembers(t, s, listKey)
+				if inList := slices.Equal(members, []string{"1"}); inList != tt.wantActive {
+					t.Fatalf("active list = %v, want key listed %v", members, tt.wantActive)
+				}
+				member, err := s.WRandom(indexKey)
+				if inIndex := err == nil && member == "1"; inIndex != tt.wantActive {
+					t.Fatalf("weight index draw = %q, %v, want key indexed %v", member, err, tt.wantActive)
+				}
+
+				// 离开 active 列表的 key 删除选 key 统计，其他 key 的统计不受影响
+				stats, err := s.HGetAll(statsKey)
+				must(t, err)
+				left := tt.want.Changed && !tt.wantActive
+				if _, kept := stats["1"]; kept == left || stats["2"] != "200" {
+					t.Fatalf("selection stats = %v, want key stats removed %v", stats, left)
+				}
+
+				// 只有发生变更的 key 进入待写回集合
+				pending, err := s.SCard(pendingKey)
+				must(t, err)
+				if wantPending := tt.want.Changed; (pending == 1) != wantPending {
+					t.Fatalf("pending set size = %d, want key pending %v", pending, wantPending)
+				}
+			})
+		})
+	}
+}
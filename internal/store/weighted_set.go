@@ -0,0 +1,97 @@
+package store
+
+// weightedSet is a set of members with positive integer weights. A Fenwick tree over the weights
+// gives O(log n) weight updates and weighted draws. A removed member is replaced by the last one,
+// so the tree always covers exactly the current members.
+type weightedSet struct {
+	positions map[string]int // member -> 1-based position
+	members   []string
+	weights   []int64
+	tree      []int64 // tree[pos-1] is the Fenwick partial sum ending at pos
+}
+
+func newWeightedSet() *weightedSet {
+	return &weightedSet{positions: make(map[string]int)}
+}
+
+func lowbit(i int) int {
+	return i & -i
+}
+
+// add adds delta to the weight at pos.
+func (ws *weightedSet) add(pos int, delta int64) {
+	for i := pos; i <= len(ws.tree); i += lowbit(i) {
+		ws.tree[i-1] += delta
+	}
+}
+
+// prefix returns the sum of the weights at positions 1..pos.
+func (ws *weightedSet) prefix(pos int) int64 {
+	var sum int64
+	for i := pos; i > 0; i -= lowbit(i) {
+		sum += ws.tree[i-1]
+	}
+	return sum
+}
+
+// set adds or updates a member. A weight of 0 or less removes it.
+// With onlyExisting, members not in the set are ignored.
+func (ws *weightedSet) set(member string, weight int64, onlyExisting bool) {
+	pos, exists := ws.positions[member]
+	switch {
+	case weight <= 0:
+		if exists {
+			ws.remove(pos)
+		}
+	case exists:
+		ws.add(pos, weight-ws.weights[pos-1])
+		ws.weights[pos-1] = weight
+	case !onlyExisting:
+		pos = len(ws.members) + 1
+		partial := weight + ws.prefix(pos-1) - ws.prefix(pos-lowbit(pos))
+		ws.members = append(ws.members, member)
+		ws.weights = append(ws.weights, weight)
+		ws.tree = append(ws.tree, partial)
+		ws.positions[member] = pos
+	}
+}
+
+// remove moves the last member into pos and shrinks the tree by one.
+func (ws *weightedSet) remove(pos int) {
+	last := len(ws.members)
+	delete(ws.positions, ws.members[pos-1])
+	if pos != last {
+		lastMember, lastWeight := ws.members[last-1], ws.weights[last-1]
+		ws.add(pos, lastWeight-ws.weights[pos-1])
+		ws.members[pos-1] = lastMember
+		ws.weights[pos-1] = lastWeight
+		ws.positions[lastMember] = pos
+	}
+	// 最后一个位置的部分和只覆盖它之前的位置，直接截断即可
+	ws.members = ws.members[:last-1]
+	ws.weights = ws.weights[:last-1]
+	ws.tree = ws.tree[:last-1]
+}
+
+// total returns the sum of all weights.
+func (ws *weightedSet) total() int64 {
+	return ws.prefix(len(ws.tree))
+}
+
+// find returns the member whose cumulative weight range contains r, for 0 <= r < total().
+func (ws *weightedSet) find(r int64) string {
+	n := len(ws.tree)
+	step := 1
+	for step*2 <= n {
+		step *= 2
+	}
+
+	pos := 0
+	for ; step > 0; step /= 2 {
+		if next := pos + step; next <= n && ws.tree[next-1] <= r {
+			pos = next
+			r -= ws.tree[next-1]
+		}
+	}
+	return ws.members[pos]
+}
@@ -1,7 +1,9 @@
 package syncer
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -13,17 +15,35 @@ import (
 // LoaderFunc defines a generic function signature for loading data from the source of truth (e.g., database).
 type LoaderFunc[T any] func() (T, error)
 
+// EntryLoaderFunc reloads the single entry identified by key and returns the updated cache.
+// It must not modify current, which may still be read concurrently.
+type EntryLoaderFunc[T any] func(current T, key string) (T, error)
+
+// invalidation is the payload published on the syncer's channel. An empty Key asks for a full reload.
+type invalidation struct {
+	Key     string `json:"key,omitempty"`
+	Version int64  `json:"version"`
+}
+
 // CacheSyncer is a generic service that manages in-memory caching and cross-instance synchronization.
+//
+// Every invalidation carries a version taken from a counter in the store. A node applies a keyed
+// invalidation incrementally only when its version directly follows the last one it has seen;
+// on any gap (a missed or reordered message, a cleared store) it falls back to a full reload.
 type CacheSyncer[T any] struct {
-	mu          sync.RWMutex
-	cache       T
-	loader      LoaderFunc[T]
-	store       store.Store
-	channelName string
-	logger      *logrus.Entry
-	stopChan    chan struct{}
-	wg          sync.WaitGroup
-	afterReload func(newValue T)
+	mu               sync.RWMutex
+	cache            T
+	loader           LoaderFunc[T]
+	entryLoader      EntryLoaderFunc[T]
+	store            store.Store
+	channelName      string
+	logger           *logrus.Entry
+	stopChan         chan struct{}
+	wg               sync.WaitGroup
+	afterReload      func(newValue T)
+	afterEntryReload func(newValue T, key string)
+	// version 是缓存已包含的最后一个失效版本，只在初始化和监听协程中读写
+	version int64
 }
 
 // NewCacheSyncer creates and initializes a new CacheSyncer.
@@ -33,14 +53,30 @@ func NewCacheSyncer[T any](
 	channelName string,
 	logger *logrus.Entry,
 	afterReload func(newValue T),
+) (*CacheSyncer[T], error) {
+	return NewKeyedCacheSyncer(loader, nil, store, channelName, logger, afterReload, nil)
+}
+
+// NewKeyedCacheSyncer creates a CacheSyncer that also supports InvalidateEntry. Nodes reload only the
+// changed entry through entryLoader and then call afterEntryReload instead of afterReload.
+func NewKeyedCacheSyncer[T any](
+	loader LoaderFunc[T],
+	entryLoader EntryLoaderFunc[T],
+	store store.Store,
+	channelName string,
+	logger *logrus.Entry,
+	afterReload func(newValue T),
+	afterEntryReload func(newValue T, key string),
 ) (*CacheSyncer[T], error) {
 	s := &CacheSyncer[T]{
-		loader:      loader,
-		store:       store,
-		channelName: channelName,
-		logger:      logger,
-		stopChan:    make(chan struct{}),
-		afterReload: afterReload,
+		loader:           loader,
+		entryLoader:      entryLoader,
+		store:            store,
+		channelName:      channelName,
+		logger:           logger,
+		stopChan:         make(chan struct{}),
+		afterReload:      afterReload,
+		afterEntryReload: afterEntryReload,
 	}
 
 	if err := s.reload(); err != nil {
@@ -63,7 +99,50 @@ func (s *CacheSyncer[T]) Get() T {
 // Invalidate publishes a notification to all instances to reload their cache.
 func (s *CacheSyncer[T]) Invalidate() error {
 	s.logger.Debug("publishing invalidation notification")
-	return s.store.Publish(s.channelName, []byte("reload"))
+	return s.publish("")
+}
+
+// InvalidateEntry publishes a notification to all instances to reload the entry identified by key.
+// It must be called after the change has been committed to the source of truth.
+func (s *CacheSyncer[T]) InvalidateEntry(key string) error {
+	if s.entryLoader == nil {
+		return s.Invalidate()
+	}
+	s.logger.WithField("key", key).Debug("publishing entry invalidation notification")
+	return s.publish(key)
+}
+
+// publish allocates the next version and publishes the invalidation.
+func (s *CacheSyncer[T]) publish(key string) error {
+	version, err := s.store.HIncrBy(s.versionKey(), "version", 1)
+	if err != nil {
+		// 无法分配版本时退化为不带版本的通知，所有节点都会全量重新加载
+		s.logger.Warnf("failed to allocate invalidation version, requesting full reload: %v", err)
+		return s.store.Publish(s.channelName, []byte("reload"))
+	}
+
+	payload, err := json.Marshal(invalidation{Key: key, Version: version})
+	if err != nil {
+		return fmt.Errorf("failed to marshal invalidation: %w", err)
+	}
+	return s.store.Publish(s.channelName, payload)
+}
+
+// versionKey is the store hash holding the channel's invalidation counter.
+func (s *CacheSyncer[T]) versionKey() string {
+	return s.channelName + ":version"
+}
+
+// currentVersion reads the channel's invalidation counter, 0 if it has not been set.
+func (s *CacheSyncer[T]) currentVersion() (int64, error) {
+	fields, err := s.store.HGetAll(s.versionKey())
+	if err != nil {
+		return 0, err
+	}
+	if fields["version"] == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(fields["version"], 10, 64)
 }
 
 // Stop gracefully shuts down the syncer's background goroutine.
@@ -76,6 +155,15 @@ func (s *CacheSyncer[T]) Stop() {
 // reload fetches the latest data using the loader function and updates the cache.
 func (s *CacheSyncer[T]) reload() error {
 	s.logger.Debug("reloading cache...")
+
+	// 先读版本再加载：发布方在提交数据之后才递增版本，因此该版本之前的变更都已包含在加载结果中
+	version, err := s.currentVersion()
+	if err != nil {
+		// 版本未知时记为 0，下一条通知会被视为断档而再次全量加载
+		s.logger.Warnf("failed to read invalidation version: %v", err)
+		version = 0
+	}
+
 	newData, err := s.loader()
 	if err != nil {
 		s.logger.Errorf("failed to reload cache: %v", err)
@@ -85,6 +173,7 @@ func (s *CacheSyncer[T]) reload() error {
 	s.mu.Lock()
 	s.cache = newData
 	s.mu.Unlock()
+	s.version = version
 
 	s.logger.Info("cache reloaded successfully")
 	// After successfully reloading and updating the cache, trigger the hook.
@@ -95,6 +184,63 @@ func (s *CacheSyncer[T]) reload() error {
 	return nil
 }
 
+// reloadEntry reloads a single entry with the entry loader and updates the cache.
+func (s *CacheSyncer[T]) reloadEntry(key string, version int64) error {
+	s.logger.WithField("key", key).Debug("reloading cache entry...")
+
+	s.mu.RLock()
+	current := s.cache
+	s.mu.RUnlock()
+
+	newData, err := s.entryLoader(current, key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cache = newData
+	s.mu.Unlock()
+	s.version = version
+
+	s.logger.WithField("key", key).Debug("cache entry reloaded successfully")
+	if s.afterEntryReload != nil {
+		s.afterEntryReload(newData, key)
+	}
+	return nil
+}
+
+// handleNotification applies a keyed invalidation incrementally when its version directly
+// follows the cached one and falls back to a full reload otherwise.
+func (s *CacheSyncer[T]) handleNotification(payload []byte) {
+	var msg invalidation
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Version == 0 {
+		// 旧版本节点发布的 "reload" 等无版本通知
+		if err := s.reload(); err != nil {
+			s.logger.Errorf("failed to reload cache after notification: %v", err)
+		}
+		return
+	}
+
+	if msg.Key != "" && s.entryLoader != nil {
+		if msg.Version == s.version+1 {
+			err := s.reloadEntry(msg.Key, msg.Version)
+			if err == nil {
+				return
+			}
+			s.logger.WithField("key", msg.Key).Errorf("failed to reload cache entry, falling back to full reload: %v", err)
+		} else {
+			s.logger.WithFields(logrus.Fields{
+				"cached_version":   s.version,
+				"received_version": msg.Version,
+			}).Info("invalidation version gap, falling back to full reload")
+		}
+	}
+
+	if err := s.reload(); err != nil {
+		s.logger.Errorf("failed to reload cache after notification: %v", err)
+	}
+}
+
 // listenForUpdates runs in the background, listening for invalidation messages.
 func (s *CacheSyncer[T]) listenForUpdates() {
 	defer s.wg.Done()
@@ -134,9 +280,7 @@ func (s *CacheSyncer[T]) listenForUpdates() {
 					break subscriberLoop
 				}
 				s.logger.Debugf("received invalidation notification, payload: %s", string(msg.Payload))
-				if err := s.reload(); err != nil {
-					s.logger.Errorf("failed to reload cache after notification: %v", err)
-				}
+				s.handleNotification(msg.Payload)
 			case <-s.stopChan:
 				if err := subscription.Close(); err != nil {
 					s.logger.Errorf("failed to close subscription: %v", err)
@@ -0,0 +1,158 @@
+package syncer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"key-flow/internal/store"
+
+	"github.com/sirupsen/logrus"
+)
+
+// countingSyncer 返回一个 CacheSyncer，以及全量和单项加载的调用次数
+func countingSyncer(t *testing.T, s store.Store, entryErr error) (*CacheSyncer[map[string]int], *int, *int) {
+	t.Helper()
+	var fullLoads, entryLoads int
+	loader := func() (map[string]int, error) {
+		fullLoads++
+		return map[string]int{}, nil
+	}
+	entryLoader := func(current map[string]int, key string) (map[string]int, error) {
+		entryLoads++
+		if entryErr != nil {
+			return nil, entryErr
+		}
+		next := make(map[string]int, len(current)+1)
+		for k, v := range current {
+			next[k] = v
+		}
+		next[key]++
+		return next, nil
+	}
+
+	syncer, err := NewKeyedCacheSyncer(loader, entryLoader, s, "test:updated", logrus.WithField("syncer", "test"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(syncer.Stop)
+	fullLoads = 0
+	return syncer, &fullLoads, &entryLoads
+}
+
+func notification(t *testing.T, key string, version int64) []byte {
+	t.Helper()
+	payload, err := json.Marshal(invalidation{Key: key, Version: version})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestHandleNotification(t *testing.T) {
+	tests := []struct {
+		name           string
+		payload        func(t *testing.T) []byte
+		storeVersion   int64 // 全量加载时读到的版本计数
+		entryErr       error
+		wantFullLoads  int
+		wantEntryLoads int
+		wantVersion    int64
+	}{
+		{
+			name:           "next version reloads the entry",
+			payload:        func(t *testing.T) []byte { return notification(t, "a", 2) },
+			storeVersion:   2,
+			wantEntryLoads: 1,
+			wantVersion:    2,
+		},
+		{
+			name:          "skipped version forces a full reload",
+			payload:       func(t *testing.T) []byte { return notification(t, "a", 3) },
+			storeVersion:  3,
+			wantFullLoads: 1,
+			wantVersion:   3,
+		},
+		{
+			name:          "replayed version forces a full reload",
+			payload:       func(t *testing.T) []byte { return notification(t, "a", 1) },
+			storeVersion:  1,
+			wantFullLoads: 1,
+			wantVersion:   1,
+		},
+		{
+			name:           "entry loader error falls back to a full reload",
+			payload:        func(t *testing.T) []byte { return notification(t, "a", 2) },
+			storeVersion:   2,
+			entryErr:       errors.New("db down"),
+			wantFullLoads:  1,
+			wantEntryLoads: 1,
+			wantVersion:    2,
+		},
+		{
+			name:          "full invalidation",
+			payload:       func(t *testing.T) []byte { return notification(t, "", 2) },
+			storeVersion:  2,
+			wantFullLoads: 1,
+			wantVersion:   2,
+		},
+		{
+			name:          "unversioned notification",
+			payload:       func(t *testing.T) []byte { return []byte("reload") },
+			storeVersion:  1,
+			wantFullLoads: 1,
+			wantVersion:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := store.NewMemoryStore()
+			// 初始加载时缓存已包含版本 1
+			if _, err := s.HIncrBy("test:updated:version", "version", 1); err != nil {
+				t.Fatal(err)
+			}
+			syncer, fullLoads, entryLoads := countingSyncer(t, s, tt.entryErr)
+			if syncer.version != 1 {
+				t.Fatalf("initial version = %d, want 1", syncer.version)
+			}
+			if _, err := s.HIncrBy("test:updated:version", "version", tt.storeVersion-1); err != nil {
+				t.Fatal(err)
+			}
+
+			syncer.handleNotification(tt.payload(t))
+			if *fullLoads != tt.wantFullLoads || *entryLoads != tt.wantEntryLoads {
+				t.Fatalf("full loads = %d, entry loads = %d, want %d, %d", *fullLoads, *entryLoads, tt.wantFullLoads, tt.wantEntryLoads)
+			}
+			if syncer.version != tt.wantVersion {
+				t.Fatalf("version = %d, want %d", syncer.version, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestHandleNotificationSequence(t *testing.T) {
+	s := store.NewMemoryStore()
+	syncer, fullLoads, entryLoads := countingSyncer(t, s, nil)
+
+	// 连续的版本逐项更新，版本 3 丢失后全量重新加载，之后又恢复逐项更新
+	for _, version := range []int64{1, 2} {
+		syncer.handleNotification(notification(t, "a", version))
+	}
+	if *fullLoads != 0 || *entryLoads != 2 || syncer.Get()["a"] != 2 {
+		t.Fatalf("after in-order versions full loads = %d, entry loads = %d, cache = %v", *fullLoads, *entryLoads, syncer.Get())
+	}
+
+	if _, err := s.HIncrBy("test:updated:version", "version", 4); err != nil {
+		t.Fatal(err)
+	}
+	syncer.handleNotification(notification(t, "b", 4))
+	if *fullLoads != 1 || *entryLoads != 2 || syncer.version != 4 {
+		t.Fatalf("after gap full loads = %d, entry loads = %d, version = %d", *fullLoads, *entryLoads, syncer.version)
+	}
+
+	syncer.handleNotification(notification(t, "b", 5))
+	if *fullLoads != 1 || *entryLoads != 3 || syncer.Get()["b"] != 1 {
+		t.Fatalf("after resuming full loads = %d, entry loads = %d, cache = %v", *fullLoads, *entryLoads, syncer.Get())
+	}
+}
@@ -11,6 +11,8 @@ type ConfigManager interface {
 	GetEncryptionKey() string
 	GetEffectiveServerConfig() ServerConfig
 	GetRedisDSN() string
+	GetStoreType() string
+	GetSnapshotConfig() SnapshotConfig
 	Validate() error
 	DisplayServerConfig()
 	ReloadConfig() error
@@ -48,6 +50,22 @@ type SystemSettings struct {
 	EnableCacheHitEnhancement         bool   `json:"enable_cache_hit_enhancement" default:"false" name:"config.enable_cache_hit_enhancement" category:"config.category.key" desc:"config.enable_cache_hit_enhancement_desc"`
 	EnableInstantDisable              bool   `json:"enable_instant_disable" default:"false" name:"config.enable_instant_disable" category:"config.category.key" desc:"config.enable_instant_disable_desc"`
 	InstantDisableRules               string `json:"instant_disable_rules" name:"config.instant_disable_rules" category:"config.category.key" desc:"config.instant_disable_rules_desc"`
+	KeyRPMLimit                       int    `json:"key_rpm_limit" default:"0" name:"config.key_rpm_limit" category:"config.category.key" desc:"config.key_rpm_limit_desc" validate:"min=0"`
+	KeyTPMLimit                       int    `json:"key_tpm_limit" default:"0" name:"config.key_tpm_limit" category:"config.category.key" desc:"config.key_tpm_limit_desc" validate:"min=0"`
+	KeyMaxConcurrency                 int    `json:"key_max_concurrency" default:"0" name:"config.key_max_concurrency" category:"config.category.key" desc:"config.key_max_concurrency_desc" validate:"min=0"`
+	KeyCooldownSeconds                int    `json:"key_cooldown_seconds" default:"0" name:"config.key_cooldown_seconds" category:"config.category.key" desc:"config.key_cooldown_seconds_desc" validate:"min=0"`
+	KeyCooldownMaxSeconds             int    `json:"key_cooldown_max_seconds" default:"3600" name:"config.key_cooldown_max_seconds" category:"config.category.key" desc:"config.key_cooldown_max_seconds_desc" validate:"min=0"`
+	KeyCooldownExponential            bool   `json:"key_cooldown_exponential" default:"false" name:"config.key_cooldown_exponential" category:"config.category.key" desc:"config.key_cooldown_exponential_desc"`
+	KeyValidationBackoffMaxMinutes    int    `json:"key_validation_backoff_max_minutes" default:"1440" name:"config.key_validation_backoff_max_minutes" category:"config.category.key" desc:"config.key_validation_backoff_max_minutes_desc" validate:"min=0"`
+	KeyArchiveAfterDays               int    `json:"key_archive_after_days" default:"0" name:"config.key_archive_after_days" category:"config.category.key" desc:"config.key_archive_after_days_desc" validate:"min=0"`
+	ActiveKeySamplePercent            int    `json:"active_key_sample_percent" default:"0" name:"config.active_key_sample_percent" category:"config.category.key" desc:"config.active_key_sample_percent_desc" validate:"min=0,max=100"`
+	ActiveKeySampleSkipMinutes        int    `json:"active_key_sample_skip_minutes" default:"30" name:"config.active_key_sample_skip_minutes" category:"config.category.key" desc:"config.active_key_sample_skip_minutes_desc" validate:"min=0"`
+	QuotaExhaustedRules               string `json:"quota_exhausted_rules" name:"config.quota_exhausted_rules" category:"config.category.key" desc:"config.quota_exhausted_rules_desc"`
+	QuotaResetTime                    string `json:"quota_reset_time" default:"00:00" name:"config.quota_reset_time" category:"config.category.key" desc:"config.quota_reset_time_desc" validate:"required,clock"`
+	QuotaResetTimezone                string `json:"quota_reset_timezone" default:"America/Los_Angeles" name:"config.quota_reset_timezone" category:"config.category.key" desc:"config.quota_reset_timezone_desc" validate:"required,timezone"`
+	KeyTagRules                       string `json:"key_tag_rules" name:"config.key_tag_rules" category:"config.category.key" desc:"config.key_tag_rules_desc"`
+	ModelDenialTTLMinutes             int    `json:"model_denial_ttl_minutes" default:"60" name:"config.model_denial_ttl_minutes" category:"config.category.key" desc:"config.model_denial_ttl_minutes_desc" validate:"min=0"`
+	KeySelectionStrategy              string `json:"key_selection_strategy" default:"round_robin" name:"config.key_selection_strategy" category:"config.category.key" desc:"config.key_selection_strategy_desc" validate:"required,oneof=round_robin least_in_flight least_recently_used power_of_two"`
 
 	// For cache
 	ProxyKeysMap map[string]struct{} `json:"-"`
@@ -58,6 +76,8 @@ type ServerConfig struct {
 	Port                    int    `json:"port"`
 	Host                    string `json:"host"`
 	IsMaster                bool   `json:"is_master"`
+	LeaderElection          bool   `json:"leader_election"`
+	RolePinned              bool   `json:"role_pinned"`
 	ReadTimeout             int    `json:"read_timeout"`
 	WriteTimeout            int    `json:"write_timeout"`
 	IdleTimeout             int    `json:"idle_timeout"`
@@ -96,6 +116,12 @@ type DatabaseConfig struct {
 	DSN string `json:"dsn"`
 }
 
+// SnapshotConfig 内存存储快照配置，Interval 为 0 时不启用
+type SnapshotConfig struct {
+	Interval int    `json:"interval"`
+	Path     string `json:"path"`
+}
+
 type RetryError struct {
 	StatusCode         int    `json:"status_code"`
 	ErrorMessage       string `json:"error_message"`
@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"encoding/json"
 	"key-flow/internal/models"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -10,6 +12,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// keyFieldPattern matches ${KEY_FIELD:name} variables that resolve to fields of structured credentials
+var keyFieldPattern = regexp.MustCompile(`\$\{KEY_FIELD:([A-Za-z0-9_.-]+)\}`)
+
 // HeaderVariableContext holds context data for variable resolution
 type HeaderVariableContext struct {
 	ClientIP string
@@ -19,6 +24,26 @@ type HeaderVariableContext struct {
 
 // ResolveHeaderVariables resolves dynamic variables in header values
 func ResolveHeaderVariables(value string, ctx *HeaderVariableContext) string {
+	return resolveVariables(value, ctx, func(s string) string { return s })
+}
+
+// ResolveJSONVariables resolves dynamic variables inside a JSON template. The values are JSON-escaped,
+// so that a key containing quotes or backslashes cannot break out of the string it is placed in.
+func ResolveJSONVariables(value string, ctx *HeaderVariableContext) string {
+	return resolveVariables(value, ctx, EscapeJSONString)
+}
+
+// EscapeJSONString escapes s for use inside a JSON string literal, without the surrounding quotes.
+func EscapeJSONString(s string) string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return s
+	}
+	return string(data[1 : len(data)-1])
+}
+
+// resolveVariables replaces the supported variables, passing each substituted value through escape
+func resolveVariables(value string, ctx *HeaderVariableContext, escape func(string) string) string {
 	if ctx == nil {
 		return value
 	}
@@ -38,12 +63,17 @@ func ResolveHeaderVariables(value string, ctx *HeaderVariableContext) string {
 	}
 
 	if ctx.APIKey != nil {
-		variables["${API_KEY}"] = ctx.APIKey.KeyValue
+		variables["${API_KEY}"] = ctx.APIKey.Secret()
+
+		fields := ctx.APIKey.CredentialFields()
+		result = keyFieldPattern.ReplaceAllStringFunc(result, func(match string) string {
+			return escape(fields[keyFieldPattern.FindStringSubmatch(match)[1]])
+		})
 	}
 
 	// Replace variables in the value
 	for variable, replacement := range variables {
-		result = strings.ReplaceAll(result, variable, replacement)
+		result = strings.ReplaceAll(result, variable, escape(replacement))
 	}
 
 	return result
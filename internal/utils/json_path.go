@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// splitJSONPath splits a path such as "$.choices[0].message.content" or "data.0.id" into segments.
+func splitJSONPath(path string) []string {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return nil
+	}
+
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+
+	var segments []string
+	for _, seg := range strings.Split(path, ".") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// GetJSONPathValue returns the value at the given path in decoded JSON data.
+// Array elements are addressed by numeric segments, e.g. "data[0].id" or "data.0.id".
+func GetJSONPathValue(data any, path string) (any, bool) {
+	current := data
+	for _, seg := range splitJSONPath(path) {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[seg]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []any:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			current = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// SetJSONPathValue sets the value at the given path, creating intermediate objects when missing.
+// It returns false if the path traverses a non-object value.
+func SetJSONPathValue(data map[string]any, path string, value any) bool {
+	segments := splitJSONPath(path)
+	if len(segments) == 0 {
+		return false
+	}
+
+	var current any = data
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch node := current.(type) {
+		case map[string]any:
+			if last {
+				node[seg] = value
+				return true
+			}
+			next, ok := node[seg]
+			if !ok {
+				next = make(map[string]any)
+				node[seg] = next
+			}
+			current = next
+		case []any:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return false
+			}
+			if last {
+				node[idx] = value
+				return true
+			}
+			current = node[idx]
+		default:
+			return false
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import type {
   Group,
   GroupConfigOption,
   GroupStatsResponse,
+  KeyImportOptions,
   KeyStatus,
   ParentAggregateGroup,
   TaskInfo,
@@ -83,7 +84,9 @@ export const keysApi = {
     page_size: number;
     key_value?: string;
     status?: KeyStatus;
-    sort_by?: "weight" | "request_count" | "failure_count" | "last_used_at";
+    expires_before?: string;
+    tag?: string;
+    sort_by?: "weight" | "request_count" | "failure_count" | "last_used_at" | "expires_at";
     sort_order?: "asc" | "desc";
   }): Promise<{
     items: APIKey[];
@@ -113,8 +116,13 @@ export const keysApi = {
   },
 
   // 异步批量添加密钥
-  async addKeysAsync(group_id: number, keys_text?: string, file?: File): Promise<TaskInfo> {
-    let requestData: FormData | { group_id: number; keys_text: string };
+  async addKeysAsync(
+    group_id: number,
+    keys_text?: string,
+    file?: File,
+    options: KeyImportOptions = {}
+  ): Promise<TaskInfo> {
+    let requestData: FormData | ({ group_id: number; keys_text: string } & KeyImportOptions);
     const config: { hideMessage: boolean; headers?: { "Content-Type": string } } = {
       hideMessage: true,
     };
@@ -124,11 +132,23 @@ export const keysApi = {
       const formData = new FormData();
       formData.append("group_id", group_id.toString());
       formData.append("file", file);
+      if (options.activates_at) {
+        formData.append("activates_at", options.activates_at);
+      }
+      if (options.expires_at) {
+        formData.append("expires_at", options.expires_at);
+      }
+      if (options.tags?.length) {
+        formData.append("tags", options.tags.join(","));
+      }
+      if (options.upstream) {
+        formData.append("upstream", options.upstream);
+      }
       requestData = formData;
       config.headers = { "Content-Type": "multipart/form-data" };
     } else {
       // Text input mode
-      requestData = { group_id, keys_text: keys_text || "" };
+      requestData = { group_id, keys_text: keys_text || "", ...options };
     }
 
     const res = await http.post("/keys/add-async", requestData, config);
@@ -145,6 +165,30 @@ export const keysApi = {
     await http.put(`/keys/${keyId}/weight`, { weight }, { hideMessage: true });
   },
 
+  // 更新单个密钥的 RPM/TPM/并发限制，0 表示使用分组默认值
+  async updateKeyRateLimits(
+    keyId: number,
+    rpmLimit: number,
+    tpmLimit: number,
+    maxConcurrency = 0
+  ): Promise<void> {
+    await http.put(
+      `/keys/${keyId}/rate-limits`,
+      { rpm_limit: rpmLimit, tpm_limit: tpmLimit, max_concurrency: maxConcurrency },
+      { hideMessage: true }
+    );
+  },
+
+  // 替换单个密钥的标签
+  async updateKeyTags(keyId: number, tags: string[]): Promise<void> {
+    await http.put(`/keys/${keyId}/tags`, { tags }, { hideMessage: true });
+  },
+
+  // 绑定密钥到分组中的上游，空字符串表示解除绑定
+  async updateKeyUpstream(keyId: number, upstream: string): Promise<void> {
+    await http.put(`/keys/${keyId}/upstream`, { upstream }, { hideMessage: true });
+  },
+
   // 批量更新密钥权重
   async updateKeysWeight(
     groupId: number,
@@ -248,7 +292,12 @@ export const keysApi = {
   },
 
   // 构建导出密钥 URL
-  _buildExportUrl(groupId: number, status: "all" | "active" | "invalid"): string | null {
+  _buildExportUrl(
+    groupId: number,
+    status: "all" | "active" | "invalid",
+    format: "txt" | "csv" = "txt",
+    tag = ""
+  ): string | null {
     const authKey = localStorage.getItem("authKey");
     if (!authKey) {
       window.$message.error(i18n.global.t("auth.noAuthKeyFound"));
@@ -263,18 +312,29 @@ export const keysApi = {
     if (status !== "all") {
       params.append("status", status);
     }
+    if (format !== "txt") {
+      params.append("format", format);
+    }
+    if (tag) {
+      params.append("tag", tag);
+    }
 
     return `${http.defaults.baseURL}/keys/export?${params.toString()}`;
   },
 
   // 导出密钥到文件
-  exportKeys(groupId: number, status: "all" | "active" | "invalid" = "all"): void {
-    const url = this._buildExportUrl(groupId, status);
+  exportKeys(
+    groupId: number,
+    status: "all" | "active" | "invalid" = "all",
+    format: "txt" | "csv" = "txt",
+    tag = ""
+  ): void {
+    const url = this._buildExportUrl(groupId, status, format, tag);
     if (!url) return;
 
     const link = document.createElement("a");
     link.href = url;
-    link.setAttribute("download", `keys-group_${groupId}-${status}-${Date.now()}.txt`);
+    link.setAttribute("download", `keys-group_${groupId}-${status}-${Date.now()}.${format}`);
     document.body.appendChild(link);
     link.click();
     document.body.removeChild(link);
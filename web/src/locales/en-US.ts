@@ -167,6 +167,25 @@ export default {
     blacklistCount: "Blacklist Count",
     valid: "Valid",
     invalid: "Invalid",
+    cooldown: "Cooling down",
+    cooldownUntil: "Cooling down until {time}",
+    archived: "Archived",
+    archivedTip: "Archived after failing validation for too long. Not revalidated automatically; restore or validate manually.",
+    nextValidationAt: "Next check at {time} ({count} failed checks)",
+    activatesAt: "Activates At",
+    activatesAtPlaceholder: "Immediately",
+    expiresAt: "Expires At",
+    expiresAtPlaceholder: "Never",
+    scheduled: "Scheduled",
+    expired: "Expired",
+    scheduledShort: "Pending",
+    expiredShort: "Expired",
+    activatesAtTip: "Activates at {time}",
+    expiresAtTip: "Expires at {time}",
+    quotaExhausted: "Quota exhausted",
+    quotaExhaustedShort: "Quota",
+    quotaResetAtTip: "Quota resets at {time}",
+    exportCsv: "Export CSV",
     checking: "Checking",
     unchecked: "Unchecked",
     addToBlacklist: "Add to Blacklist",
@@ -349,6 +368,7 @@ export default {
     clientIpVar: "Client IP address",
     groupNameVar: "Group name",
     apiKeyVar: "Current API key",
+    keyFieldVar: "Named field of the current structured credential",
     timestampMsVar: "Milliseconds timestamp",
     timestampSVar: "Seconds timestamp",
     header: "Header",
@@ -363,6 +383,9 @@ export default {
     addHeader: "Add Header",
     paramOverridesTooltip:
       "Define the API request parameters to be overridden using JSON format. These parameters will be merged with the original parameters when sending the request.",
+    channelConfig: "Channel Config",
+    channelConfigTooltip: "Channel-specific settings in JSON format, e.g. API version and model to deployment mapping for Azure",
+    channelConfigInvalidJson: "Invalid JSON format for channel config",
     modelRedirectPolicy: "Unconfigured Model Policy",
     modelRedirectPolicyTooltip:
       "Choose how to handle requests for models not configured in redirect rules",
@@ -412,6 +435,8 @@ export default {
     restoreShort: "↻",
     validShort: "OK",
     invalidShort: "NG",
+    cooldownShort: "Cooldown",
+    archivedShort: "Archived",
     testKey: "Test Key",
     totalRecords: "Total {total} records",
     recordsPerPage: "{count} per page",
@@ -433,6 +458,16 @@ export default {
     weightUpdated: "Weight updated",
     editKeyWeight: "Edit key weight",
     keyWeightDescription: "Higher weight means higher probability of being selected. Range: 1-1000",
+    editKeyTags: "Edit key tags",
+    tagsUpdated: "Tags updated",
+    keyTagsDescription: "Requests restricted to tags (X-Key-Tags header or key tag rules) only use keys carrying all of them.",
+    tags: "Tags",
+    tagFilter: "Tag",
+    boundUpstream: "Bound Upstream",
+    boundUpstreamPlaceholder: "Any upstream (weighted)",
+    editKeyUpstream: "Bind key to upstream",
+    upstreamUpdated: "Upstream binding updated",
+    keyUpstreamDescription: "A bound key is always sent to this upstream, including during validation. Leave empty to use weighted upstream selection.",
     weightTip: "Higher weight = higher selection probability",
     resetWeightShort: "Reset",
     resetKeyWeight: "Reset key weight",
@@ -457,7 +492,7 @@ export default {
     confirmDisableKey: "Are you sure you want to disable key {key}?",
     keyDisabled: "Key disabled",
     clickOrDragFile: "Click or drag file here",
-    onlyTxtFileSupported: "Only .txt files are supported",
+    onlyTxtFileSupported: "Only .txt and .csv files are supported",
     testResultTitle: "Test Results",
     testResultSuccess: "Success",
     testResultStatusCode: "Status {code}",
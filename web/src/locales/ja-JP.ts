@@ -166,6 +166,25 @@ export default {
     blacklistCount: "ブラックリスト回数",
     valid: "有効",
     invalid: "無効",
+    cooldown: "クールダウン中",
+    cooldownUntil: "{time} までクールダウン",
+    archived: "アーカイブ済み",
+    archivedTip: "長期間検証に失敗したためアーカイブされました。自動検証されません。手動で復元または検証してください。",
+    nextValidationAt: "次回検証 {time}（連続失敗 {count} 回）",
+    activatesAt: "有効化日時",
+    activatesAtPlaceholder: "即時",
+    expiresAt: "有効期限",
+    expiresAtPlaceholder: "無期限",
+    scheduled: "有効化待ち",
+    expired: "期限切れ",
+    scheduledShort: "待機",
+    expiredShort: "期限切れ",
+    activatesAtTip: "{time} に有効化",
+    expiresAtTip: "{time} に期限切れ",
+    quotaExhausted: "クォータ枯渇",
+    quotaExhaustedShort: "枯渇",
+    quotaResetAtTip: "{time} にクォータがリセット",
+    exportCsv: "CSVをエクスポート",
     checking: "チェック中",
     unchecked: "未チェック",
     addToBlacklist: "ブラックリストに追加",
@@ -348,6 +367,7 @@ export default {
     clientIpVar: "クライアントIPアドレス",
     groupNameVar: "グループ名",
     apiKeyVar: "現在のAPIキー",
+    keyFieldVar: "現在の構造化認証情報の指定フィールド",
     timestampMsVar: "ミリ秒タイムスタンプ",
     timestampSVar: "秒タイムスタンプ",
     header: "ヘッダー",
@@ -362,6 +382,9 @@ export default {
     addHeader: "ヘッダー追加",
     paramOverridesTooltip:
       "JSON形式を使用して、上書きするAPIリクエストパラメータを定義します。これらのパラメータは、リクエスト送信時に元のパラメータにマージされます。",
+    channelConfig: "チャンネル設定",
+    channelConfigTooltip: "JSON形式のチャンネル固有設定。例：Azure の API バージョンやモデルとデプロイメントの対応",
+    channelConfigInvalidJson: "チャンネル設定のJSON形式が無効です",
     modelRedirectPolicy: "未設定モデルポリシー",
     modelRedirectPolicyTooltip:
       "リダイレクトルールで設定されていないモデルのリクエストをどう処理するか選択",
@@ -412,6 +435,8 @@ export default {
     restoreShort: "復元",
     validShort: "有効",
     invalidShort: "無効",
+    cooldownShort: "待機",
+    archivedShort: "保管",
     testKey: "キーをテスト",
     totalRecords: "合計 {total} 件",
     recordsPerPage: "{count}件/ページ",
@@ -433,6 +458,16 @@ export default {
     weightUpdated: "重みが更新されました",
     editKeyWeight: "キー重みを編集",
     keyWeightDescription: "重みが高いほど選択される確率が高くなります。範囲: 1-1000",
+    editKeyTags: "キータグを編集",
+    tagsUpdated: "タグを更新しました",
+    keyTagsDescription: "タグが指定されたリクエスト（X-Key-Tags ヘッダーまたはキータグルール）は、すべてのタグを持つキーのみ使用します。",
+    tags: "タグ",
+    tagFilter: "タグ",
+    boundUpstream: "バインドされたアップストリーム",
+    boundUpstreamPlaceholder: "任意のアップストリーム（重み付き）",
+    editKeyUpstream: "キーをアップストリームにバインド",
+    upstreamUpdated: "アップストリームのバインドを更新しました",
+    keyUpstreamDescription: "バインドされたキーは検証を含め常にこのアップストリームに送信されます。空の場合は重みに基づいて選択します。",
     weightTip: "重みが高いほど選択確率が上がります",
     resetWeightShort: "リセット",
     resetKeyWeight: "キー重みをリセット",
@@ -457,7 +492,7 @@ export default {
     confirmDisableKey: "キー {key} を無効化してもよろしいですか？",
     keyDisabled: "キーが無効化されました",
     clickOrDragFile: "クリックまたはファイルをドラッグ",
-    onlyTxtFileSupported: ".txtファイルのみサポート",
+    onlyTxtFileSupported: ".txt と .csv ファイルのみサポート",
     testResultTitle: "テスト結果",
     testResultSuccess: "成功",
     testResultStatusCode: "ステータス {code}",
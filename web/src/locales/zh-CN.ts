@@ -165,6 +165,25 @@ export default {
     blacklistCount: "黑名单次数",
     valid: "有效",
     invalid: "无效",
+    cooldown: "冷却中",
+    cooldownUntil: "冷却至 {time}",
+    archived: "已归档",
+    archivedTip: "长期验证失败已归档，不再自动验证，可手动恢复或验证。",
+    nextValidationAt: "下次验证时间 {time}（已连续失败 {count} 次）",
+    activatesAt: "生效时间",
+    activatesAtPlaceholder: "立即生效",
+    expiresAt: "过期时间",
+    expiresAtPlaceholder: "永不过期",
+    scheduled: "待生效",
+    expired: "已过期",
+    scheduledShort: "待生效",
+    expiredShort: "过期",
+    activatesAtTip: "将于 {time} 生效",
+    expiresAtTip: "将于 {time} 过期",
+    quotaExhausted: "配额耗尽",
+    quotaExhaustedShort: "配额",
+    quotaResetAtTip: "配额将于 {time} 重置",
+    exportCsv: "导出 CSV",
     checking: "检查中",
     unchecked: "未检查",
     addToBlacklist: "加入黑名单",
@@ -338,6 +357,7 @@ export default {
     clientIpVar: "客户端IP地址",
     groupNameVar: "分组名称",
     apiKeyVar: "当前轮询的API密钥",
+    keyFieldVar: "当前结构化凭证中的指定字段",
     timestampMsVar: "毫秒时间戳",
     timestampSVar: "秒时间戳",
     header: "请求头",
@@ -350,6 +370,9 @@ export default {
     addHeader: "添加请求头",
     paramOverridesTooltip:
       "使用JSON格式定义要覆盖的API请求参数。这些参数会在发送请求时合并到原始参数中",
+    channelConfig: "渠道配置",
+    channelConfigTooltip: "使用JSON格式定义渠道专属配置，例如 Azure 的 API 版本和模型到部署的映射",
+    channelConfigInvalidJson: "渠道配置 JSON 格式错误",
     modelRedirectPolicy: "未配置模型策略",
     modelRedirectPolicyTooltip: "选择如何处理未在重定向规则中配置的模型请求",
     modelRedirectStrictMode: "严格模式：拒绝未配置的模型请求（返回404）",
@@ -396,6 +419,8 @@ export default {
     restoreShort: "恢复",
     validShort: "有效",
     invalidShort: "无效",
+    cooldownShort: "冷却",
+    archivedShort: "归档",
     testKey: "测试密钥",
     totalRecords: "共 {total} 条记录",
     recordsPerPage: "{count}条/页",
@@ -417,6 +442,16 @@ export default {
     weightUpdated: "权重已更新",
     editKeyWeight: "编辑密钥权重",
     keyWeightDescription: "权重越高，密钥被选中的概率越大。范围: 1-1000",
+    editKeyTags: "编辑密钥标签",
+    tagsUpdated: "标签已更新",
+    keyTagsDescription: "限定了标签的请求（X-Key-Tags 请求头或密钥标签规则）只会使用包含全部标签的密钥。",
+    tags: "标签",
+    tagFilter: "标签",
+    boundUpstream: "绑定上游",
+    boundUpstreamPlaceholder: "任意上游（按权重）",
+    editKeyUpstream: "绑定密钥到上游",
+    upstreamUpdated: "上游绑定已更新",
+    keyUpstreamDescription: "绑定后该密钥的请求和验证始终发往此上游。留空则按权重选择上游。",
     weightTip: "权重越高被选中概率越大",
     resetWeightShort: "重置",
     resetKeyWeight: "重置密钥权重",
@@ -441,7 +476,7 @@ export default {
     confirmDisableKey: "确定要禁用密钥 {key} 吗？",
     keyDisabled: "密钥已禁用",
     clickOrDragFile: "点击或拖拽文件到此区域",
-    onlyTxtFileSupported: "仅支持 .txt 文件",
+    onlyTxtFileSupported: "仅支持 .txt 和 .csv 文件",
     testResultTitle: "测试结果",
     testResultSuccess: "成功",
     testResultStatusCode: "状态码 {code}",
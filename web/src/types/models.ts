@@ -6,13 +6,33 @@ export interface ApiResponse<T> {
 }
 
 // 密钥状态
-export type KeyStatus = "active" | "invalid" | undefined;
+export type KeyStatus =
+  | "active"
+  | "invalid"
+  | "cooldown"
+  | "archived"
+  | "scheduled"
+  | "expired"
+  | "quota_exhausted"
+  | undefined;
+
+// 密钥有效期（RFC 3339）
+export interface KeySchedule {
+  activates_at?: string;
+  expires_at?: string;
+}
+
+// 导入密钥时统一应用的选项
+export interface KeyImportOptions extends KeySchedule {
+  tags?: string[];
+  upstream?: string;
+}
 
 // 分组类型
 export type GroupType = "standard" | "aggregate";
 
 // 渠道类型
-export type ChannelType = "openai" | "openai-response" | "gemini" | "anthropic";
+export type ChannelType = "openai" | "openai-response" | "gemini" | "anthropic" | "azure" | "bedrock" | "vertex" | "custom";
 
 // 数据模型定义
 export interface APIKey {
@@ -22,6 +42,18 @@ export interface APIKey {
   notes?: string;
   status: KeyStatus;
   weight: number;
+  tags?: string[];
+  upstream?: string;
+  rpm_limit?: number;
+  tpm_limit?: number;
+  max_concurrency?: number;
+  cooldown_until?: string;
+  cooldown_count?: number;
+  validation_failures?: number;
+  next_validation_at?: string;
+  activates_at?: string;
+  expires_at?: string;
+  quota_reset_at?: string;
   request_count: number;
   failure_count: number;
   last_used_at?: string;
@@ -79,6 +111,7 @@ export interface Group {
   param_overrides: Record<string, unknown>;
   model_redirect_rules: Record<string, string>;
   model_redirect_strict: boolean;
+  channel_config?: Record<string, unknown>;
   header_rules?: HeaderRule[];
   proxy_keys: string;
   group_type?: GroupType;
@@ -124,6 +157,7 @@ export interface KeyTestResultItem {
   is_valid: boolean;
   error: string;
   status_code: number;
+  failed_assertion?: string;
 }
 
 export interface KeyValidationResult {
@@ -242,6 +276,7 @@ export interface DashboardStatsResponse {
   rpm: StatCard;
   request_count: StatCard;
   error_rate: StatCard;
+  key_state_queue: StatCard;
   security_warnings: SecurityWarning[];
 }
 